@@ -0,0 +1,250 @@
+// Package api serves the bridge operations over HTTP/JSON: quoting and
+// submitting deposits and withdrawals, tracking them, and reading balances.
+//
+// Amounts are decimal strings in wei. Every error is answered with the
+// status code that fits it and a body of the form
+//
+//	{"error": {"code": "invalid_request", "message": "amount must be positive"}}
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"try_rde/bridge"
+	"try_rde/config"
+	"try_rde/txutils"
+)
+
+// Error codes of the error body.
+const (
+	CodeInvalidRequest    = "invalid_request"
+	CodeNotFound          = "not_found"
+	CodeMethodNotAllowed  = "method_not_allowed"
+	CodeSignerUnavailable = "signer_unavailable"
+	CodeReverted          = "reverted"
+	CodeInternal          = "internal"
+)
+
+// Error is the error body.
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func invalidf(format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func notFoundf(format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// asError classifies err for the error body.
+func asError(err error) *Error {
+	var apiErr *Error
+	var reverted *txutils.RevertedError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &reverted):
+		return &Error{Status: http.StatusUnprocessableEntity, Code: CodeReverted, Message: err.Error()}
+	default:
+		return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: err.Error()}
+	}
+}
+
+// TransferRequest is the body of the quote and submit endpoints of deposits
+// and withdrawals.
+type TransferRequest struct {
+	Asset  string `json:"asset"`  // eth, mnt or erc20
+	Amount string `json:"amount"` // wei
+	// To receives the funds on the other chain, empty means the sender.
+	To string `json:"to,omitempty"`
+	// Token names an ERC20 pair of the profile. Alternatively give both
+	// L1Token and L2Token.
+	Token       string        `json:"token,omitempty"`
+	L1Token     string        `json:"l1Token,omitempty"`
+	L2Token     string        `json:"l2Token,omitempty"`
+	MinGasLimit uint32        `json:"minGasLimit,omitempty"`
+	ExtraData   hexutil.Bytes `json:"extraData,omitempty"`
+	// From is the sender to quote for, empty means the signer of the server.
+	// Submitting always sends from the signer.
+	From string `json:"from,omitempty"`
+}
+
+// transfer is a validated TransferRequest.
+type transfer struct {
+	asset bridge.Asset
+	token config.Token
+	args  bridge.TransferArgs
+	from  *common.Address
+}
+
+func (r *TransferRequest) validate(profile *config.Profile) (*transfer, error) {
+	asset, err := bridge.ParseAsset(r.Asset)
+	if err != nil {
+		return nil, invalidf("%v", err)
+	}
+	t := &transfer{asset: asset}
+	if t.args.Amount, err = parseWei("amount", r.Amount); err != nil {
+		return nil, err
+	}
+	if t.args.Amount.Sign() <= 0 {
+		return nil, invalidf("amount must be positive")
+	}
+	if r.To != "" {
+		if t.args.To, err = parseAddress("to", r.To); err != nil {
+			return nil, err
+		}
+	}
+	if r.From != "" {
+		from, err := parseAddress("from", r.From)
+		if err != nil {
+			return nil, err
+		}
+		t.from = &from
+	}
+	t.args.MinGasLimit = r.MinGasLimit
+	t.args.ExtraData = r.ExtraData
+
+	switch {
+	case asset != bridge.AssetERC20:
+		if r.Token != "" || r.L1Token != "" || r.L2Token != "" {
+			return nil, invalidf("token, l1Token and l2Token are only used with erc20")
+		}
+	case r.Token != "":
+		if r.L1Token != "" || r.L2Token != "" {
+			return nil, invalidf("give either token or l1Token and l2Token")
+		}
+		if t.token, err = profile.Token(r.Token); err != nil {
+			return nil, invalidf("%v", err)
+		}
+	default:
+		if t.token.L1, err = parseAddress("l1Token", r.L1Token); err != nil {
+			return nil, err
+		}
+		if t.token.L2, err = parseAddress("l2Token", r.L2Token); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func parseWei(field, s string) (*big.Int, error) {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, invalidf("%s must be a decimal amount of wei, got %q", field, s)
+	}
+	return v, nil
+}
+
+func parseAddress(field, s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, invalidf("%s is not an address: %q", field, s)
+	}
+	return common.HexToAddress(s), nil
+}
+
+func parseHash(field, s string) (common.Hash, error) {
+	b, err := hexutil.Decode(s)
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, invalidf("%s is not a 32 byte hex hash: %q", field, s)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// Fees are the gas limit and pricing of one tx. Either GasPrice or the
+// EIP-1559 caps are set.
+type Fees struct {
+	GasLimit             uint64 `json:"gasLimit"`
+	GasPrice             string `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas,omitempty"`
+	// MaxCost is the most the tx can cost in wei, gas limit times price.
+	MaxCost string `json:"maxCost"`
+}
+
+func newFees(f *txutils.Fees) *Fees {
+	if f == nil {
+		return nil
+	}
+	out := &Fees{GasLimit: f.GasLimit}
+	price := f.GasPrice
+	if price != nil {
+		out.GasPrice = price.String()
+	} else {
+		price = f.GasFeeCap
+		out.MaxFeePerGas = f.GasFeeCap.String()
+		out.MaxPriorityFeePerGas = f.GasTipCap.String()
+	}
+	out.MaxCost = new(big.Int).Mul(price, new(big.Int).SetUint64(f.GasLimit)).String()
+	return out
+}
+
+// QuoteResponse answers the quote endpoints. Approve is set when the bridge
+// must be approved first, the transfer can only be quoted after that.
+type QuoteResponse struct {
+	Chain    string `json:"chain"` // l1 or l2
+	Approve  *Fees  `json:"approve,omitempty"`
+	Transfer *Fees  `json:"transfer,omitempty"`
+}
+
+// SubmitResponse answers the submit endpoints.
+type SubmitResponse struct {
+	Chain  string      `json:"chain"`
+	TxHash common.Hash `json:"txHash"`
+}
+
+// DepositResponse answers GET /v1/deposits/{l1TxHash}.
+type DepositResponse struct {
+	L1TxHash common.Hash      `json:"l1TxHash"`
+	Deposits []*DepositStatus `json:"deposits"`
+}
+
+// DepositStatus is one L2 deposit tx derived from the L1 tx.
+type DepositStatus struct {
+	L2TxHash common.Hash `json:"l2TxHash"`
+	// Status is one of the history.StepDeposit* names.
+	Status        string `json:"status"`
+	L2BlockNumber uint64 `json:"l2BlockNumber,omitempty"`
+}
+
+// WithdrawalResponse answers the withdrawal status endpoints. The fields
+// about the L2 tx are only known when it was looked up by tx hash.
+type WithdrawalResponse struct {
+	TxHash            *common.Hash `json:"txHash,omitempty"`
+	LogIndex          *uint        `json:"logIndex,omitempty"` // of its MessagePassed in the L2 receipt
+	WithdrawalHash    common.Hash  `json:"withdrawalHash"`
+	State             string       `json:"state,omitempty"` // see withdrawal.State
+	L2BlockNumber     uint64       `json:"l2BlockNumber,omitempty"`
+	LatestOutputBlock uint64       `json:"latestOutputBlock,omitempty"`
+	Sent              bool         `json:"sent"`
+	Proven            bool         `json:"proven"`
+	ProvenAt          *time.Time   `json:"provenAt,omitempty"`
+	FinalizableAt     *time.Time   `json:"finalizableAt,omitempty"`
+	Finalized         bool         `json:"finalized"`
+}
+
+// BalanceResponse answers GET /v1/balances/{address}.
+type BalanceResponse struct {
+	Address common.Address `json:"address"`
+	L1      ChainBalances  `json:"l1"`
+	L2      ChainBalances  `json:"l2"`
+}
+
+// ChainBalances are the balances on one chain, in wei.
+type ChainBalances struct {
+	ETH string `json:"eth"`
+	MNT string `json:"mnt"`
+	// Tokens holds the ERC20 pairs of the profile by name.
+	Tokens map[string]string `json:"tokens,omitempty"`
+}
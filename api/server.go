@@ -0,0 +1,404 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/abistr/abijson"
+	"try_rde/bridge"
+	"try_rde/config"
+	"try_rde/history"
+	"try_rde/signer"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+// maxBodySize limits request bodies, transfer requests are tiny.
+const maxBodySize = 1 << 20
+
+// Server answers the HTTP API on top of a bridge client.
+type Server struct {
+	Client *bridge.Client
+	// Signer sends the submitted deposits and withdrawals and is the default
+	// sender of quotes. Without it the submit endpoints answer 503.
+	Signer signer.Signer
+	// Log reports failed requests, defaults to NopLogger.
+	Log txutils.Logger
+}
+
+// NewServer creates a server, s may be nil for a read-only one.
+func NewServer(c *bridge.Client, s signer.Signer) *Server {
+	return &Server{Client: c, Signer: s, Log: txutils.NopLogger}
+}
+
+// Handler routes:
+//
+//	POST /v1/deposits/quote                      TransferRequest -> QuoteResponse
+//	POST /v1/deposits                            TransferRequest -> SubmitResponse
+//	GET  /v1/deposits/{l1TxHash}                 DepositResponse
+//	POST /v1/withdrawals/quote                   TransferRequest -> QuoteResponse
+//	POST /v1/withdrawals                         TransferRequest -> SubmitResponse
+//	GET  /v1/withdrawals/{l2TxHash}[?logIndex=n] WithdrawalResponse
+//	GET  /v1/withdrawals/by-hash/{withdrawalHash} WithdrawalResponse
+//	GET  /v1/balances/{address}                  BalanceResponse
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/deposits/quote", s.endpoint(http.MethodPost, s.quoteDeposit))
+	mux.Handle("/v1/deposits", s.endpoint(http.MethodPost, s.submitDeposit))
+	mux.Handle("/v1/deposits/", s.endpoint(http.MethodGet, s.depositStatus))
+	mux.Handle("/v1/withdrawals/quote", s.endpoint(http.MethodPost, s.quoteWithdrawal))
+	mux.Handle("/v1/withdrawals", s.endpoint(http.MethodPost, s.submitWithdrawal))
+	mux.Handle("/v1/withdrawals/by-hash/", s.endpoint(http.MethodGet, s.withdrawalByHash))
+	mux.Handle("/v1/withdrawals/", s.endpoint(http.MethodGet, s.withdrawalByTx))
+	mux.Handle("/v1/balances/", s.endpoint(http.MethodGet, s.balances))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		s.writeError(w, r, notFoundf("no endpoint %s", r.URL.Path))
+	})
+	return mux
+}
+
+// handlerFunc returns the status and body of a successful request.
+type handlerFunc func(r *http.Request) (int, interface{}, error)
+
+func (s *Server) endpoint(method string, h handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			s.writeError(w, r, &Error{
+				Status:  http.StatusMethodNotAllowed,
+				Code:    CodeMethodNotAllowed,
+				Message: fmt.Sprintf("%s %s is not allowed, use %s", r.Method, r.URL.Path, method),
+			})
+			return
+		}
+		status, body, err := h(r)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		writeJSON(w, status, body)
+	})
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := asError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		s.logger().Logf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	writeJSON(w, apiErr.Status, map[string]*Error{"error": apiErr})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (s *Server) logger() txutils.Logger {
+	if s.Log == nil {
+		return txutils.NopLogger
+	}
+	return s.Log
+}
+
+// pathParam returns the last path segment after prefix.
+func pathParam(r *http.Request, prefix string) (string, error) {
+	param := strings.TrimPrefix(r.URL.Path, prefix)
+	if param == "" || strings.Contains(param, "/") {
+		return "", notFoundf("no endpoint %s", r.URL.Path)
+	}
+	return param, nil
+}
+
+func (s *Server) readTransfer(r *http.Request) (*transfer, error) {
+	var req TransferRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return nil, invalidf("invalid request body: %v", err)
+	}
+	return req.validate(s.Client.Profile)
+}
+
+func (s *Server) signer() (signer.Signer, error) {
+	if s.Signer == nil {
+		return nil, &Error{
+			Status:  http.StatusServiceUnavailable,
+			Code:    CodeSignerUnavailable,
+			Message: "the server has no signer, it can not send transactions",
+		}
+	}
+	return s.Signer, nil
+}
+
+// sender is the from address of a quote.
+func (s *Server) sender(t *transfer) (common.Address, error) {
+	if t.from != nil {
+		return *t.from, nil
+	}
+	sig, err := s.signer()
+	if err != nil {
+		return common.Address{}, invalidf("from is required, the server has no signer")
+	}
+	return sig.Address(), nil
+}
+
+func (s *Server) quoteDeposit(r *http.Request) (int, interface{}, error) {
+	t, err := s.readTransfer(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	from, err := s.sender(t)
+	if err != nil {
+		return 0, nil, err
+	}
+	q, err := s.Client.QuoteDeposit(r.Context(), from, t.asset, t.token, t.args)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, &QuoteResponse{Chain: "l1", Approve: newFees(q.Approve), Transfer: newFees(q.Transfer)}, nil
+}
+
+func (s *Server) quoteWithdrawal(r *http.Request) (int, interface{}, error) {
+	t, err := s.readTransfer(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	from, err := s.sender(t)
+	if err != nil {
+		return 0, nil, err
+	}
+	q, err := s.Client.QuoteWithdrawal(r.Context(), from, t.asset, t.token, t.args)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, &QuoteResponse{Chain: "l2", Approve: newFees(q.Approve), Transfer: newFees(q.Transfer)}, nil
+}
+
+func (s *Server) submitDeposit(r *http.Request) (int, interface{}, error) {
+	return s.submit(r, "l1", s.Client.Deposit)
+}
+
+func (s *Server) submitWithdrawal(r *http.Request) (int, interface{}, error) {
+	return s.submit(r, "l2", s.Client.Withdraw)
+}
+
+type sendFunc func(ctx context.Context, s signer.Signer, asset bridge.Asset, token config.Token, args bridge.TransferArgs) (*types.Transaction, error)
+
+func (s *Server) submit(r *http.Request, chain string, send sendFunc) (int, interface{}, error) {
+	t, err := s.readTransfer(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	sig, err := s.signer()
+	if err != nil {
+		return 0, nil, err
+	}
+	if t.from != nil && *t.from != sig.Address() {
+		return 0, nil, invalidf("from must be empty or the signer %s", sig.Address())
+	}
+	tx, err := send(r.Context(), sig, t.asset, t.token, t.args)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusAccepted, &SubmitResponse{Chain: chain, TxHash: tx.Hash()}, nil
+}
+
+func (s *Server) depositStatus(r *http.Request) (int, interface{}, error) {
+	param, err := pathParam(r, "/v1/deposits/")
+	if err != nil {
+		return 0, nil, err
+	}
+	txHash, err := parseHash("l1TxHash", param)
+	if err != nil {
+		return 0, nil, err
+	}
+	deposits, err := s.Client.Deposits(r.Context(), txHash)
+	switch {
+	case errors.Is(err, ethereum.NotFound):
+		return 0, nil, notFoundf("l1 tx %s is not mined", txHash)
+	case errors.Is(err, bridge.ErrNoDeposit):
+		return 0, nil, notFoundf("l1 tx %s made no deposit", txHash)
+	case err != nil:
+		return 0, nil, err
+	}
+	resp := &DepositResponse{L1TxHash: txHash}
+	for _, d := range deposits {
+		st := &DepositStatus{L2TxHash: d.L2TxHash, Status: history.StepDepositPending}
+		receipt, err := s.Client.L2.TransactionReceipt(r.Context(), d.L2TxHash)
+		switch {
+		case errors.Is(err, ethereum.NotFound):
+		case err != nil:
+			return 0, nil, fmt.Errorf("failed to get l2 receipt: %w", err)
+		default:
+			st.Status = history.StepDepositRelayed
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				st.Status = history.StepDepositFailed
+			}
+			st.L2BlockNumber = receipt.BlockNumber.Uint64()
+		}
+		resp.Deposits = append(resp.Deposits, st)
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) withdrawalByTx(r *http.Request) (int, interface{}, error) {
+	param, err := pathParam(r, "/v1/withdrawals/")
+	if err != nil {
+		return 0, nil, err
+	}
+	txHash, err := parseHash("l2TxHash", param)
+	if err != nil {
+		return 0, nil, err
+	}
+	// a tx that started several withdrawals needs the log index of one
+	var logIndex *uint
+	if q := r.URL.Query().Get("logIndex"); q != "" {
+		index, err := strconv.ParseUint(q, 10, 32)
+		if err != nil {
+			return 0, nil, invalidf("logIndex is not a number: %q", q)
+		}
+		logIndex = new(uint)
+		*logIndex = uint(index)
+	}
+	facts, ev, err := withdrawal.Derive(r.Context(), s.Client, txHash, logIndex)
+	switch {
+	case errors.Is(err, ethereum.NotFound):
+		return 0, nil, notFoundf("l2 tx %s is not mined", txHash)
+	case errors.Is(err, bridge.ErrSeveralWithdrawals):
+		return 0, nil, invalidf("%v", err)
+	case err != nil:
+		return 0, nil, err
+	}
+	resp := &WithdrawalResponse{
+		TxHash:            &txHash,
+		State:             facts.State().String(),
+		L2BlockNumber:     facts.L2BlockNumber,
+		LatestOutputBlock: facts.LatestOutputBlock,
+		Sent:              facts.Sent,
+		Proven:            facts.ProvenTimestamp != 0,
+		Finalized:         facts.Finalized,
+	}
+	if ev != nil {
+		index := ev.Raw.Index
+		resp.LogIndex, resp.WithdrawalHash = &index, ev.WithdrawalHash
+	}
+	if resp.Proven {
+		provenAt := time.Unix(int64(facts.ProvenTimestamp), 0)
+		finalizableAt := time.Unix(int64(facts.FinalizableAt()), 0)
+		resp.ProvenAt, resp.FinalizableAt = &provenAt, &finalizableAt
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) withdrawalByHash(r *http.Request) (int, interface{}, error) {
+	param, err := pathParam(r, "/v1/withdrawals/by-hash/")
+	if err != nil {
+		return 0, nil, err
+	}
+	hash, err := parseHash("withdrawalHash", param)
+	if err != nil {
+		return 0, nil, err
+	}
+	c := s.Client
+	opts := &bind.CallOpts{Context: r.Context()}
+	resp := &WithdrawalResponse{WithdrawalHash: hash}
+	if resp.Sent, err = c.MessagePasser.SentMessages(opts, hash); err != nil {
+		return 0, nil, fmt.Errorf("failed to get sent message: %w", err)
+	}
+	proven, err := c.Portal.ProvenWithdrawals(opts, hash)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get proven withdrawal: %w", err)
+	}
+	if resp.Finalized, err = c.Portal.FinalizedWithdrawals(opts, hash); err != nil {
+		return 0, nil, fmt.Errorf("failed to get finalized withdrawal: %w", err)
+	}
+	if !resp.Sent && proven.Timestamp.Sign() == 0 && !resp.Finalized {
+		return 0, nil, notFoundf("withdrawal %s was never initiated on l2", hash)
+	}
+	if proven.Timestamp.Sign() != 0 {
+		period, err := c.Oracle.FINALIZATIONPERIODSECONDS(opts)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to get finalization period: %w", err)
+		}
+		facts := &withdrawal.Facts{ProvenTimestamp: proven.Timestamp.Uint64(), FinalizationPeriod: period.Uint64()}
+		provenAt := time.Unix(int64(facts.ProvenTimestamp), 0)
+		finalizableAt := time.Unix(int64(facts.FinalizableAt()), 0)
+		resp.Proven, resp.ProvenAt, resp.FinalizableAt = true, &provenAt, &finalizableAt
+	}
+	return http.StatusOK, resp, nil
+}
+
+func (s *Server) balances(r *http.Request) (int, interface{}, error) {
+	param, err := pathParam(r, "/v1/balances/")
+	if err != nil {
+		return 0, nil, err
+	}
+	account, err := parseAddress("address", param)
+	if err != nil {
+		return 0, nil, err
+	}
+	ctx := r.Context()
+	c := s.Client
+	addrs := c.Profile.Addresses
+	resp := &BalanceResponse{Address: account}
+
+	l1ETH, err := c.L1.BalanceAt(ctx, account, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get l1 balance: %w", err)
+	}
+	l1MNT, err := tokenBalance(ctx, c.L1, addrs.L1MantleToken, account)
+	if err != nil {
+		return 0, nil, err
+	}
+	l2MNT, err := c.L2.BalanceAt(ctx, account, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get l2 balance: %w", err)
+	}
+	l2ETH, err := txutils.GetETHBalanceFromL2(ctx, c.L2, addrs.L2ETH, account)
+	if err != nil {
+		return 0, nil, err
+	}
+	resp.L1 = ChainBalances{ETH: l1ETH.String(), MNT: l1MNT.String()}
+	resp.L2 = ChainBalances{ETH: l2ETH.String(), MNT: l2MNT.String()}
+
+	for name, tk := range c.Profile.Tokens {
+		l1, err := tokenBalance(ctx, c.L1, tk.L1, account)
+		if err != nil {
+			return 0, nil, err
+		}
+		l2, err := tokenBalance(ctx, c.L2, tk.L2, account)
+		if err != nil {
+			return 0, nil, err
+		}
+		if resp.L1.Tokens == nil {
+			resp.L1.Tokens, resp.L2.Tokens = make(map[string]string), make(map[string]string)
+		}
+		resp.L1.Tokens[name], resp.L2.Tokens[name] = l1.String(), l2.String()
+	}
+	return http.StatusOK, resp, nil
+}
+
+func tokenBalance(ctx context.Context, backend bind.ContractCaller, token, account common.Address) (*big.Int, error) {
+	erc20, err := abijson.NewL2TestTokenCaller(token, backend) // any ERC20 binding will do
+	if err != nil {
+		return nil, err
+	}
+	bal, err := erc20.BalanceOf(&bind.CallOpts{Context: ctx}, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance of token %s: %w", token, err)
+	}
+	return bal, nil
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge/bridgetest"
+	"try_rde/history"
+	"try_rde/signer"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+type testAPI struct {
+	t   *testing.T
+	url string
+}
+
+// do sends the request and decodes the answer into out, returning the status.
+func (a *testAPI) do(method, path string, body interface{}, out interface{}) int {
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		enc, err := json.Marshal(b)
+		require.NoError(a.t, err)
+		reader = bytes.NewReader(enc)
+	}
+	req, err := http.NewRequest(method, a.url+path, reader)
+	require.NoError(a.t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(a.t, err)
+	defer resp.Body.Close()
+	require.Equal(a.t, "application/json", resp.Header.Get("Content-Type"))
+	require.NoError(a.t, json.NewDecoder(resp.Body).Decode(out))
+	return resp.StatusCode
+}
+
+type errorBody struct {
+	Error *Error `json:"error"`
+}
+
+func newTestAPI(t *testing.T, withSigner bool) (*testAPI, *bridgetest.Network, signer.Signer) {
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	var sig signer.Signer
+	if withSigner {
+		sig = s
+	}
+	srv := httptest.NewServer(NewServer(c, sig).Handler())
+	t.Cleanup(srv.Close)
+	return &testAPI{t: t, url: srv.URL}, n, s
+}
+
+func Test_DepositAndWithdraw(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	a, n, s := newTestAPI(t, true)
+	n.L1.MintToken(n.Profile.Addresses.L1MantleToken, s.Address(), big.NewInt(1000))
+
+	var bal BalanceResponse
+	ast.Equal(http.StatusOK, a.do(http.MethodGet, "/v1/balances/"+s.Address().Hex(), nil, &bal))
+	ast.Equal(bridgetest.AccountBalance.String(), bal.L1.ETH)
+	ast.Equal("1000", bal.L1.MNT)
+	ast.Equal("0", bal.L2.ETH)
+	ast.Equal("0", bal.L1.Tokens[bridgetest.TestToken])
+
+	var quote QuoteResponse
+	ast.Equal(http.StatusOK, a.do(http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "eth", Amount: "100"}, &quote))
+	ast.Equal("l1", quote.Chain)
+	ast.Nil(quote.Approve)
+	require.NotNil(t, quote.Transfer)
+	ast.NotZero(quote.Transfer.GasLimit)
+	ast.NotEmpty(quote.Transfer.MaxCost)
+	quote = QuoteResponse{}
+	ast.Equal(http.StatusOK, a.do(http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "mnt", Amount: "100"}, &quote))
+	ast.NotNil(quote.Approve, "the bridge is not approved for MNT yet")
+	ast.Nil(quote.Transfer)
+
+	var sent SubmitResponse
+	ast.Equal(http.StatusAccepted, a.do(http.MethodPost, "/v1/deposits", &TransferRequest{Asset: "eth", Amount: "100"}, &sent))
+	ast.Equal("l1", sent.Chain)
+	_, err := txutils.WaitForReceipt(ctx, n.L1, sent.TxHash, txutils.WaitOpts{})
+	require.NoError(t, err)
+	var dep DepositResponse
+	ast.Equal(http.StatusOK, a.do(http.MethodGet, "/v1/deposits/"+sent.TxHash.Hex(), nil, &dep))
+	require.Len(t, dep.Deposits, 1)
+	ast.Equal(history.StepDepositRelayed, dep.Deposits[0].Status)
+
+	ast.Equal(http.StatusAccepted, a.do(http.MethodPost, "/v1/withdrawals", &TransferRequest{Asset: "eth", Amount: "40"}, &sent))
+	ast.Equal("l2", sent.Chain)
+	_, err = txutils.WaitForReceipt(ctx, n.L2, sent.TxHash, txutils.WaitOpts{})
+	require.NoError(t, err)
+	var wd WithdrawalResponse
+	ast.Equal(http.StatusOK, a.do(http.MethodGet, "/v1/withdrawals/"+sent.TxHash.Hex(), nil, &wd))
+	ast.Equal(withdrawal.StateWaitingForOutput.String(), wd.State)
+	ast.True(wd.Sent)
+	ast.False(wd.Proven)
+	ast.NotEqual(common.Hash{}, wd.WithdrawalHash)
+
+	var byHash WithdrawalResponse
+	ast.Equal(http.StatusOK, a.do(http.MethodGet, "/v1/withdrawals/by-hash/"+wd.WithdrawalHash.Hex(), nil, &byHash))
+	ast.Equal(wd.WithdrawalHash, byHash.WithdrawalHash)
+	ast.True(byHash.Sent)
+	ast.Nil(byHash.TxHash)
+
+	ast.Equal(http.StatusOK, a.do(http.MethodGet, "/v1/balances/"+s.Address().Hex(), nil, &bal))
+	ast.Equal("60", bal.L2.ETH)
+}
+
+func Test_Errors(t *testing.T) {
+	ast := assert.New(t)
+	a, _, s := newTestAPI(t, true)
+	missing := common.HexToHash("0x01").Hex()
+
+	cases := []struct {
+		method, path string
+		body         interface{}
+		status       int
+		code         string
+	}{
+		{http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "btc", Amount: "1"}, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "eth", Amount: "1.5"}, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "eth", Amount: "0"}, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "eth", Amount: "1", To: "0x12"}, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "eth", Amount: "1", Token: "TT"}, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "erc20", Amount: "1"}, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "erc20", Amount: "1", Token: "XX"}, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/v1/withdrawals", `{"asset": "eth", "amount": "1", "memo": "x"}`, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/v1/withdrawals", `not json`, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/v1/withdrawals", &TransferRequest{Asset: "eth", Amount: "1", From: common.HexToAddress("0x01").Hex()}, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodGet, "/v1/deposits/0x1234", nil, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodGet, "/v1/deposits/" + missing, nil, http.StatusNotFound, CodeNotFound},
+		{http.MethodGet, "/v1/withdrawals/" + missing, nil, http.StatusNotFound, CodeNotFound},
+		{http.MethodGet, "/v1/withdrawals/by-hash/" + missing, nil, http.StatusNotFound, CodeNotFound},
+		{http.MethodGet, "/v1/balances/" + s.Address().Hex() + "/x", nil, http.StatusNotFound, CodeNotFound},
+		{http.MethodGet, "/v2/anything", nil, http.StatusNotFound, CodeNotFound},
+		{http.MethodGet, "/v1/deposits", nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{http.MethodDelete, "/v1/balances/" + s.Address().Hex(), nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		var body errorBody
+		ast.Equal(tc.status, a.do(tc.method, tc.path, tc.body, &body), tc.path)
+		if ast.NotNil(body.Error, tc.path) {
+			ast.Equal(tc.code, body.Error.Code, tc.path)
+			ast.NotEmpty(body.Error.Message, tc.path)
+		}
+	}
+
+	readOnly, _, funded := newTestAPI(t, false)
+	var body errorBody
+	ast.Equal(http.StatusServiceUnavailable, readOnly.do(http.MethodPost, "/v1/deposits", &TransferRequest{Asset: "eth", Amount: "1"}, &body))
+	ast.Equal(CodeSignerUnavailable, body.Error.Code)
+	ast.Equal(http.StatusBadRequest, readOnly.do(http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "eth", Amount: "1"}, &body))
+	var quote QuoteResponse
+	ast.Equal(http.StatusOK, readOnly.do(http.MethodPost, "/v1/deposits/quote", &TransferRequest{Asset: "eth", Amount: "1", From: funded.Address().Hex()}, &quote))
+	ast.NotNil(quote.Transfer)
+}
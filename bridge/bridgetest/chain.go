@@ -0,0 +1,680 @@
+package bridgetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	genesisTime = 1700000000
+	// callGas is what every contract call costs, plain transfers cost 21000.
+	callGas = 100000
+)
+
+var (
+	baseFee = big.NewInt(params.GWei)
+	tipCap  = big.NewInt(params.GWei)
+)
+
+// handler runs one contract method. It returns the method outputs in ABI
+// order, an error reverts the call.
+type handler func(env *callEnv, args []interface{}) ([]interface{}, error)
+
+type contract struct {
+	abi      *abi.ABI
+	handlers map[string]handler
+}
+
+// callEnv is what a handler sees of the chain while it runs.
+type callEnv struct {
+	chain  *Chain
+	st     *state
+	from   common.Address
+	to     common.Address
+	value  *big.Int
+	number uint64
+	time   uint64
+	logs   []*types.Log
+}
+
+// emit appends an event of the contract being called.
+func (env *callEnv) emit(name string, args ...interface{}) {
+	env.emitFrom(env.to, name, args...)
+}
+
+func (env *callEnv) emitFrom(address common.Address, name string, args ...interface{}) {
+	event := env.chain.contracts[address].abi.Events[name]
+	var indexed [][]interface{}
+	var data []interface{}
+	for i, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, []interface{}{args[i]})
+		} else {
+			data = append(data, args[i])
+		}
+	}
+	topics, err := abi.MakeTopics(indexed...)
+	if err != nil {
+		panic(fmt.Sprintf("event %s: %v", name, err))
+	}
+	enc, err := event.Inputs.NonIndexed().Pack(data...)
+	if err != nil {
+		panic(fmt.Sprintf("event %s: %v", name, err))
+	}
+	log := &types.Log{Address: address, Topics: []common.Hash{event.ID}, Data: enc}
+	for _, t := range topics {
+		log.Topics = append(log.Topics, t[0])
+	}
+	env.logs = append(env.logs, log)
+}
+
+// call runs a method of another contract from the contract being called.
+func (env *callEnv) call(to common.Address, value *big.Int, method string, args ...interface{}) error {
+	c, ok := env.chain.contracts[to]
+	if !ok {
+		return fmt.Errorf("no contract at %s", to)
+	}
+	data, err := c.abi.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	return env.callData(to, value, data)
+}
+
+// callData runs raw calldata against any account, a plain transfer if it is
+// not a contract.
+func (env *callEnv) callData(to common.Address, value *big.Int, data []byte) error {
+	sub := *env
+	sub.from, sub.to, sub.value, sub.logs = env.to, to, value, nil
+	if _, err := env.chain.exec(&sub, data); err != nil {
+		return err
+	}
+	env.logs = append(env.logs, sub.logs...)
+	return nil
+}
+
+type block struct {
+	header   *types.Header
+	txs      []*types.Transaction
+	receipts []*types.Receipt
+	// state after the block
+	state *state
+}
+
+// Chain is an in-memory chain with automining: every transaction is mined
+// into its own block as soon as it is sent. Contracts are emulated in Go and
+// gas is estimated and priced but never charged. Chain implements
+// bridge.Backend.
+type Chain struct {
+	mu sync.Mutex
+
+	chainID   *big.Int
+	signer    types.Signer
+	blockTime uint64
+	// timeOffset is added to the time of the next block, see AdvanceTime.
+	timeOffset uint64
+
+	contracts map[common.Address]*contract
+	blocks    []*block
+	state     *state
+	txs       map[common.Hash]*types.Transaction
+	receipts  map[common.Hash]*types.Receipt
+
+	// proofAccount is the only account of the state trie, the one GetProof
+	// serves. The zero address leaves the state root empty.
+	proofAccount common.Address
+	// onBlock runs after a block is mined, without the chain lock held.
+	onBlock func(b *block)
+
+	subMu sync.Mutex
+	subs  map[*logSub]struct{}
+}
+
+// logSub is a log subscription. Its queue decouples the miner from a slow
+// reader, logs that overflow it are dropped.
+type logSub struct {
+	q     ethereum.FilterQuery
+	queue chan types.Log
+}
+
+func newChain(chainID int64, blockTime uint64) *Chain {
+	c := &Chain{
+		chainID:   big.NewInt(chainID),
+		signer:    types.LatestSignerForChainID(big.NewInt(chainID)),
+		blockTime: blockTime,
+		contracts: make(map[common.Address]*contract),
+		state:     newState(),
+		txs:       make(map[common.Hash]*types.Transaction),
+		receipts:  make(map[common.Hash]*types.Receipt),
+	}
+	c.blocks = []*block{{
+		header: &types.Header{
+			Number:  big.NewInt(0),
+			Time:    genesisTime,
+			BaseFee: baseFee,
+			Root:    types.EmptyRootHash,
+		},
+		state: c.state,
+	}}
+	return c
+}
+
+func (c *Chain) register(address common.Address, contractABI *abi.ABI, handlers map[string]handler) {
+	c.contracts[address] = &contract{abi: contractABI, handlers: handlers}
+}
+
+func (c *Chain) head() *block {
+	return c.blocks[len(c.blocks)-1]
+}
+
+// mine appends a block with the given txs and receipts on top of the current
+// state. The caller holds the lock and must call c.onBlock afterwards.
+func (c *Chain) mine(txs []*types.Transaction, receipts []*types.Receipt) *block {
+	parent := c.head().header
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		Time:       parent.Time + c.blockTime + c.timeOffset,
+		BaseFee:    baseFee,
+		GasLimit:   30000000,
+		Root:       c.stateRoot(c.state),
+	}
+	c.timeOffset = 0
+	var logIndex uint
+	for i, r := range receipts {
+		header.GasUsed += r.GasUsed
+		r.CumulativeGasUsed = header.GasUsed
+		r.TransactionIndex = uint(i)
+		for _, log := range r.Logs {
+			log.TxIndex = uint(i)
+			log.TxHash = r.TxHash
+			log.Index = logIndex
+			logIndex++
+		}
+	}
+	header.Bloom = types.CreateBloom(receipts)
+	// the header is final now, hand its hash to the receipts
+	hash := header.Hash()
+	for _, r := range receipts {
+		r.BlockHash = hash
+		r.BlockNumber = header.Number
+		for _, log := range r.Logs {
+			log.BlockHash = hash
+			log.BlockNumber = header.Number.Uint64()
+		}
+		c.receipts[r.TxHash] = r
+	}
+	for _, tx := range txs {
+		c.txs[tx.Hash()] = tx
+	}
+	b := &block{header: header, txs: txs, receipts: receipts, state: c.state}
+	c.blocks = append(c.blocks, b)
+	return b
+}
+
+// run executes a top level call, the errors of every nested call are
+// reported as a revert.
+func (c *Chain) run(env *callEnv, data []byte) ([]byte, error) {
+	out, err := c.exec(env, data)
+	if err != nil {
+		return nil, fmt.Errorf("execution reverted: %w", err)
+	}
+	return out, nil
+}
+
+// exec runs the call in env against env.st, moving env.value first.
+func (c *Chain) exec(env *callEnv, data []byte) ([]byte, error) {
+	if env.value != nil && env.value.Sign() > 0 {
+		if err := env.st.transfer(env.from, env.to, env.value); err != nil {
+			return nil, err
+		}
+	}
+	ct, ok := c.contracts[env.to]
+	if !ok {
+		if len(data) > 0 {
+			return nil, fmt.Errorf("no contract at %s", env.to)
+		}
+		return nil, nil
+	}
+	if len(data) < 4 {
+		return nil, errors.New("no method")
+	}
+	method, err := ct.abi.MethodById(data[:4])
+	if err != nil {
+		return nil, err
+	}
+	h, ok := ct.handlers[method.RawName]
+	if !ok {
+		return nil, fmt.Errorf("%s is not emulated", method.Sig)
+	}
+	if !method.IsPayable() && env.value != nil && env.value.Sign() > 0 {
+		return nil, fmt.Errorf("%s is not payable", method.Sig)
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	out, err := h(env, args)
+	if err != nil {
+		return nil, err
+	}
+	return method.Outputs.Pack(out...)
+}
+
+// dryRun executes msg on a copy of the state at block b.
+func (c *Chain) dryRun(msg ethereum.CallMsg, b *block) ([]byte, error) {
+	to := common.Address{}
+	if msg.To != nil {
+		to = *msg.To
+	}
+	env := &callEnv{
+		chain:  c,
+		st:     b.state.copy(),
+		from:   msg.From,
+		to:     to,
+		value:  msg.Value,
+		number: b.header.Number.Uint64() + 1,
+		time:   b.header.Time + c.blockTime,
+	}
+	return c.run(env, msg.Data)
+}
+
+func (c *Chain) blockAt(number *big.Int) (*block, error) {
+	if number == nil {
+		return c.head(), nil
+	}
+	if !number.IsUint64() || number.Uint64() >= uint64(len(c.blocks)) {
+		return nil, ethereum.NotFound
+	}
+	return c.blocks[number.Uint64()], nil
+}
+
+// AdvanceTime moves the clock forward and mines an empty block, so the head
+// reflects the new time.
+func (c *Chain) AdvanceTime(seconds uint64) {
+	c.mu.Lock()
+	c.timeOffset += seconds
+	b := c.mine(nil, nil)
+	c.mu.Unlock()
+	c.notify(b)
+}
+
+// Mine mines an empty block.
+func (c *Chain) Mine() {
+	c.AdvanceTime(0)
+}
+
+// Fund credits the native coin to account.
+func (c *Chain) Fund(account common.Address, amount *big.Int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = c.state.copy()
+	c.state.credit(account, amount)
+	c.head().state = c.state
+}
+
+func (c *Chain) notify(b *block) {
+	c.subMu.Lock()
+	for sub := range c.subs {
+		for _, r := range b.receipts {
+			for _, log := range r.Logs {
+				if !matchLog(log, sub.q) {
+					continue
+				}
+				select {
+				case sub.queue <- *log:
+				default:
+				}
+			}
+		}
+	}
+	c.subMu.Unlock()
+	if c.onBlock != nil {
+		c.onBlock(b)
+	}
+}
+
+// system runs a call as if a tx from `from` made it and mines it in its own
+// block, without a signature or nonce. The tx hash is derived from the block
+// number so it is unique.
+func (c *Chain) system(from, to common.Address, value *big.Int, contractABI *abi.ABI, method string, args ...interface{}) (*types.Receipt, error) {
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	head := c.head().header
+	env := &callEnv{
+		chain:  c,
+		st:     c.state.copy(),
+		from:   from,
+		to:     to,
+		value:  value,
+		number: head.Number.Uint64() + 1,
+		time:   head.Time + c.blockTime + c.timeOffset,
+	}
+	if _, err := c.run(env, data); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.state = env.st
+	txHash := crypto.Keccak256Hash([]byte("system"), head.Number.Bytes(), data)
+	receipt := &types.Receipt{
+		Status:  types.ReceiptStatusSuccessful,
+		TxHash:  txHash,
+		GasUsed: callGas,
+		Logs:    env.logs,
+	}
+	b := c.mine(nil, []*types.Receipt{receipt})
+	c.mu.Unlock()
+	c.notify(b)
+	return receipt, nil
+}
+
+// ChainID implements bridge.Backend.
+func (c *Chain) ChainID(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(c.chainID), nil
+}
+
+// BlockNumber returns the number of the head block.
+func (c *Chain) BlockNumber(ctx context.Context) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.head().header.Number.Uint64(), nil
+}
+
+// HeaderByNumber implements bind.ContractTransactor.
+func (c *Chain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.blockAt(number)
+	if err != nil {
+		return nil, err
+	}
+	return types.CopyHeader(b.header), nil
+}
+
+// HeaderByHash returns the header of a canonical block.
+func (c *Chain) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, b := range c.blocks {
+		if b.header.Hash() == hash {
+			return types.CopyHeader(b.header), nil
+		}
+	}
+	return nil, ethereum.NotFound
+}
+
+// BalanceAt returns the native balance of account.
+func (c *Chain) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.blockAt(number)
+	if err != nil {
+		return nil, err
+	}
+	return b.state.balance(account), nil
+}
+
+// CodeAt implements bind.ContractCaller. Emulated contracts have a one byte code.
+func (c *Chain) CodeAt(ctx context.Context, account common.Address, number *big.Int) ([]byte, error) {
+	if _, ok := c.contracts[account]; ok {
+		return []byte{0x01}, nil
+	}
+	return nil, nil
+}
+
+// CallContract implements bind.ContractCaller.
+func (c *Chain) CallContract(ctx context.Context, msg ethereum.CallMsg, number *big.Int) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.blockAt(number)
+	if err != nil {
+		return nil, err
+	}
+	return c.dryRun(msg, b)
+}
+
+// PendingCodeAt implements bind.PendingContractCaller.
+func (c *Chain) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return c.CodeAt(ctx, account, nil)
+}
+
+// PendingCallContract implements bind.PendingContractCaller.
+func (c *Chain) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
+	return c.CallContract(ctx, msg, nil)
+}
+
+// PendingNonceAt implements bind.ContractTransactor.
+func (c *Chain) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.nonce(account), nil
+}
+
+// SuggestGasPrice implements bind.ContractTransactor.
+func (c *Chain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Add(baseFee, tipCap), nil
+}
+
+// SuggestGasTipCap implements bind.ContractTransactor.
+func (c *Chain) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(tipCap), nil
+}
+
+// FeeHistory returns a flat history, every block paid the same tip.
+func (c *Chain) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := &ethereum.FeeHistory{OldestBlock: new(big.Int)}
+	for i := uint64(0); i < blockCount; i++ {
+		reward := make([]*big.Int, len(rewardPercentiles))
+		for j := range reward {
+			reward[j] = tipCap
+		}
+		h.Reward = append(h.Reward, reward)
+		h.BaseFee = append(h.BaseFee, baseFee)
+		h.GasUsedRatio = append(h.GasUsedRatio, 0.5)
+	}
+	h.BaseFee = append(h.BaseFee, baseFee)
+	return h, nil
+}
+
+// EstimateGas implements bind.ContractTransactor. Calls that would revert
+// fail the estimate like on a real node.
+func (c *Chain) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.dryRun(msg, c.head()); err != nil {
+		return 0, err
+	}
+	if msg.To != nil && c.contracts[*msg.To] == nil && len(msg.Data) == 0 {
+		return params.TxGas, nil
+	}
+	return callGas, nil
+}
+
+// SendTransaction implements bind.ContractTransactor. The tx is mined right
+// away. A reverting tx is mined with a failed receipt.
+func (c *Chain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	c.mu.Lock()
+	from, err := types.Sender(c.signer, tx)
+	if err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	if _, ok := c.txs[tx.Hash()]; ok {
+		c.mu.Unlock()
+		return errors.New("already known")
+	}
+	switch nonce := c.state.nonce(from); {
+	case tx.Nonce() < nonce:
+		c.mu.Unlock()
+		return fmt.Errorf("nonce too low: address %s, tx: %d state: %d", from, tx.Nonce(), nonce)
+	case tx.Nonce() > nonce:
+		c.mu.Unlock()
+		return fmt.Errorf("nonce too high: address %s, tx: %d state: %d", from, tx.Nonce(), nonce)
+	}
+	if tx.To() == nil {
+		c.mu.Unlock()
+		return errors.New("contract creation is not emulated")
+	}
+
+	head := c.head().header
+	env := &callEnv{
+		chain:  c,
+		st:     c.state.copy(),
+		from:   from,
+		to:     *tx.To(),
+		value:  tx.Value(),
+		number: head.Number.Uint64() + 1,
+		time:   head.Time + c.blockTime + c.timeOffset,
+	}
+	receipt := &types.Receipt{
+		Type:    tx.Type(),
+		Status:  types.ReceiptStatusSuccessful,
+		TxHash:  tx.Hash(),
+		GasUsed: callGas,
+	}
+	if _, err := c.run(env, tx.Data()); err != nil {
+		receipt.Status = types.ReceiptStatusFailed
+		// only the nonce survives a revert
+		env.st = c.state.copy()
+		env.logs = nil
+	}
+	env.st.setNonce(from, tx.Nonce()+1)
+	receipt.Logs = env.logs
+	c.state = env.st
+	b := c.mine([]*types.Transaction{tx}, []*types.Receipt{receipt})
+	c.mu.Unlock()
+	c.notify(b)
+	return nil
+}
+
+// TransactionReceipt implements bind.DeployBackend.
+func (c *Chain) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.receipts[txHash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return r, nil
+}
+
+// TransactionByHash implements bridge.Backend. Only signed txs are known,
+// not deposits or system calls.
+func (c *Chain) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, ok := c.txs[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	return tx, false, nil
+}
+
+// FilterLogs implements bind.ContractFilterer.
+func (c *Chain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	from, to := uint64(0), c.head().header.Number.Uint64()
+	if q.FromBlock != nil {
+		from = q.FromBlock.Uint64()
+	}
+	if q.ToBlock != nil && q.ToBlock.Sign() >= 0 && q.ToBlock.Uint64() < to {
+		to = q.ToBlock.Uint64()
+	}
+	var logs []types.Log
+	for _, b := range c.blocks {
+		if q.BlockHash != nil && b.header.Hash() != *q.BlockHash {
+			continue
+		}
+		if n := b.header.Number.Uint64(); q.BlockHash == nil && (n < from || n > to) {
+			continue
+		}
+		for _, r := range b.receipts {
+			for _, log := range r.Logs {
+				if matchLog(log, q) {
+					logs = append(logs, *log)
+				}
+			}
+		}
+	}
+	return logs, nil
+}
+
+func matchLog(log *types.Log, q ethereum.FilterQuery) bool {
+	if len(q.Addresses) > 0 {
+		found := false
+		for _, a := range q.Addresses {
+			found = found || a == log.Address
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.Topics) > len(log.Topics) {
+		return false
+	}
+	for i, alternatives := range q.Topics {
+		if len(alternatives) == 0 {
+			continue
+		}
+		found := false
+		for _, t := range alternatives {
+			found = found || t == log.Topics[i]
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer. The logs of every
+// block mined from now on that match q are sent to ch, the block range of q
+// is ignored.
+func (c *Chain) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	sub := &logSub{q: q, queue: make(chan types.Log, 1024)}
+	c.subMu.Lock()
+	if c.subs == nil {
+		c.subs = make(map[*logSub]struct{})
+	}
+	c.subs[sub] = struct{}{}
+	c.subMu.Unlock()
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer func() {
+			c.subMu.Lock()
+			delete(c.subs, sub)
+			c.subMu.Unlock()
+		}()
+		for {
+			select {
+			case log := <-sub.queue:
+				select {
+				case ch <- log:
+				case <-quit:
+					return nil
+				}
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// Close implements bridge.Backend.
+func (c *Chain) Close() {}
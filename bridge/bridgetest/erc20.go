@@ -0,0 +1,141 @@
+package bridgetest
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Argument accessors for unpacked method inputs.
+
+func addressArg(v interface{}) common.Address { return v.(common.Address) }
+func bigArg(v interface{}) *big.Int           { return v.(*big.Int) }
+func bytesArg(v interface{}) []byte           { return v.([]byte) }
+func hashArg(v interface{}) common.Hash       { return common.Hash(v.([32]byte)) }
+
+// transferToken moves tokens in the state and emits Transfer from the token.
+func (env *callEnv) transferToken(token, from, to common.Address, amount *big.Int) error {
+	if err := env.st.transferToken(token, from, to, amount); err != nil {
+		return err
+	}
+	env.emitFrom(token, "Transfer", from, to, amount)
+	return nil
+}
+
+// transferTokenFrom is transferFrom called by the current contract.
+func (env *callEnv) transferTokenFrom(token, from, to common.Address, amount *big.Int) error {
+	if err := env.st.spendAllowance(token, from, env.to, amount); err != nil {
+		return err
+	}
+	return env.transferToken(token, from, to, amount)
+}
+
+func (env *callEnv) mintToken(token, to common.Address, amount *big.Int) {
+	env.st.mintToken(token, to, amount)
+	env.emitFrom(token, "Transfer", common.Address{}, to, amount)
+}
+
+func (env *callEnv) burnToken(token, from common.Address, amount *big.Int) error {
+	if err := env.st.burnToken(token, from, amount); err != nil {
+		return err
+	}
+	env.emitFrom(token, "Transfer", from, common.Address{}, amount)
+	return nil
+}
+
+// erc20 emulates the plain ERC20 part of L1MantleToken and L2TestToken.
+func erc20() map[string]handler {
+	return map[string]handler{
+		"totalSupply": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{env.st.getBig(env.to, slotOf("totalSupply"))}, nil
+		},
+		"decimals": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{uint8(18)}, nil
+		},
+		"balanceOf": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{env.st.tokenBalance(env.to, addressArg(args[0]))}, nil
+		},
+		"allowance": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{env.st.allowance(env.to, addressArg(args[0]), addressArg(args[1]))}, nil
+		},
+		"approve": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			spender, amount := addressArg(args[0]), bigArg(args[1])
+			env.st.approve(env.to, env.from, spender, amount)
+			env.emit("Approval", env.from, spender, amount)
+			return []interface{}{true}, nil
+		},
+		"transfer": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			if err := env.transferToken(env.to, env.from, addressArg(args[0]), bigArg(args[1])); err != nil {
+				return nil, err
+			}
+			return []interface{}{true}, nil
+		},
+		"transferFrom": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			from, to, amount := addressArg(args[0]), addressArg(args[1]), bigArg(args[2])
+			if err := env.st.spendAllowance(env.to, from, env.from, amount); err != nil {
+				return nil, err
+			}
+			if err := env.transferToken(env.to, from, to, amount); err != nil {
+				return nil, err
+			}
+			return []interface{}{true}, nil
+		},
+	}
+}
+
+var (
+	eip712DomainTypehash = crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	permitTypehash       = crypto.Keccak256Hash([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+)
+
+// mantleToken emulates L1MantleToken: an ERC20 with the EIP-2612 permit of
+// OpenZeppelin's ERC20Permit, under the EIP-712 domain "Mantle" version 1.
+func mantleToken(chainID *big.Int, address common.Address) map[string]handler {
+	domainSeparator := crypto.Keccak256Hash(
+		eip712DomainTypehash[:],
+		crypto.Keccak256([]byte("Mantle")),
+		crypto.Keccak256([]byte("1")),
+		common.BigToHash(chainID).Bytes(),
+		common.BytesToHash(address[:]).Bytes(),
+	)
+	handlers := erc20()
+	handlers["DOMAIN_SEPARATOR"] = func(env *callEnv, args []interface{}) ([]interface{}, error) {
+		return []interface{}{[32]byte(domainSeparator)}, nil
+	}
+	handlers["nonces"] = func(env *callEnv, args []interface{}) ([]interface{}, error) {
+		return []interface{}{env.st.getBig(env.to, slotOf("nonces", addressArg(args[0]).Bytes()))}, nil
+	}
+	handlers["permit"] = func(env *callEnv, args []interface{}) ([]interface{}, error) {
+		owner, spender, value, deadline := addressArg(args[0]), addressArg(args[1]), bigArg(args[2]), bigArg(args[3])
+		v, r, s := args[4].(uint8), hashArg(args[5]), hashArg(args[6])
+		if deadline.Cmp(new(big.Int).SetUint64(env.time)) < 0 {
+			return nil, errors.New("ERC20Permit: expired deadline")
+		}
+		nonceSlot := slotOf("nonces", owner.Bytes())
+		nonce := env.st.getBig(env.to, nonceSlot)
+		structHash := crypto.Keccak256(
+			permitTypehash[:],
+			common.BytesToHash(owner[:]).Bytes(),
+			common.BytesToHash(spender[:]).Bytes(),
+			common.BigToHash(value).Bytes(),
+			common.BigToHash(nonce).Bytes(),
+			common.BigToHash(deadline).Bytes(),
+		)
+		digest := crypto.Keccak256([]byte("\x19\x01"), domainSeparator[:], structHash)
+		if v < 27 {
+			return nil, errors.New("ECDSA: invalid signature")
+		}
+		sig := append(append(r.Bytes(), s.Bytes()...), v-27)
+		pub, err := crypto.SigToPub(digest, sig)
+		if err != nil || crypto.PubkeyToAddress(*pub) != owner {
+			return nil, errors.New("ERC20Permit: invalid signature")
+		}
+		env.st.setBig(env.to, nonceSlot, new(big.Int).Add(nonce, common.Big1))
+		env.st.approve(env.to, owner, spender, value)
+		env.emit("Approval", owner, spender, value)
+		return nil, nil
+	}
+	return handlers
+}
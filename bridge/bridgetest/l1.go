@@ -0,0 +1,492 @@
+package bridgetest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/trie"
+	"try_rde/abistr/abijson"
+	"try_rde/txutils"
+)
+
+const (
+	// SubmissionInterval of the emulated oracle. Any L2 block after the
+	// latest output may be proposed, the interval only feeds nextBlockNumber.
+	SubmissionInterval = 1
+	// L2BlockTime is the time between two L2 blocks in seconds.
+	L2BlockTime = 2
+	// L1BlockTime is the time between two L1 blocks in seconds.
+	L1BlockTime = 12
+)
+
+var aliasOffset = new(big.Int).SetBytes(common.HexToAddress("0x1111000000000000000000000000000000001111").Bytes())
+
+// applyL1ToL2Alias is the sender a deposit made by an L1 contract has on L2.
+func applyL1ToL2Alias(a common.Address) common.Address {
+	return common.BigToAddress(new(big.Int).Add(new(big.Int).SetBytes(a.Bytes()), aliasOffset))
+}
+
+// Oracle storage.
+
+func outputSlot(field string, index uint64) common.Hash {
+	return slotOf(field, new(big.Int).SetUint64(index).Bytes())
+}
+
+var (
+	outputCountSlot = slotOf("outputCount")
+	periodSlot      = slotOf("finalizationPeriod")
+)
+
+func (n *Network) outputCount(st *state) uint64 {
+	return st.getBig(n.Profile.Addresses.L2OutputOracleProxy, outputCountSlot).Uint64()
+}
+
+func (n *Network) output(st *state, index uint64) (abijson.TypesOutputProposal, error) {
+	if index >= n.outputCount(st) {
+		return abijson.TypesOutputProposal{}, fmt.Errorf("no output with index %d", index)
+	}
+	oracle := n.Profile.Addresses.L2OutputOracleProxy
+	return abijson.TypesOutputProposal{
+		OutputRoot:    st.get(oracle, outputSlot("outputRoot", index)),
+		Timestamp:     st.getBig(oracle, outputSlot("outputTime", index)),
+		L2BlockNumber: st.getBig(oracle, outputSlot("outputBlock", index)),
+	}, nil
+}
+
+func (n *Network) latestBlockNumber(st *state) *big.Int {
+	count := n.outputCount(st)
+	if count == 0 {
+		return new(big.Int)
+	}
+	out, _ := n.output(st, count-1)
+	return out.L2BlockNumber
+}
+
+func (n *Network) outputIndexAfter(st *state, l2BlockNumber *big.Int) (uint64, error) {
+	count := n.outputCount(st)
+	for i := uint64(0); i < count; i++ {
+		out, _ := n.output(st, i)
+		if out.L2BlockNumber.Cmp(l2BlockNumber) >= 0 {
+			return i, nil
+		}
+	}
+	return 0, errors.New("L2OutputOracle: cannot get output for a block that has not been proposed")
+}
+
+func (n *Network) period(st *state) uint64 {
+	return st.getBig(n.Profile.Addresses.L2OutputOracleProxy, periodSlot).Uint64()
+}
+
+func (n *Network) oracleHandlers() map[string]handler {
+	constant := func(v interface{}) handler {
+		return func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{v}, nil
+		}
+	}
+	return map[string]handler{
+		"SUBMISSION_INTERVAL": constant(big.NewInt(SubmissionInterval)),
+		"L2_BLOCK_TIME":       constant(big.NewInt(L2BlockTime)),
+		"PROPOSER":            constant(n.Proposer),
+		"CHALLENGER":          constant(n.Challenger),
+		"startingBlockNumber": constant(new(big.Int)),
+		"startingTimestamp":   constant(big.NewInt(genesisTime)),
+		"FINALIZATION_PERIOD_SECONDS": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{new(big.Int).SetUint64(n.period(env.st))}, nil
+		},
+		"computeL2Timestamp": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			elapsed := new(big.Int).Mul(bigArg(args[0]), big.NewInt(L2BlockTime))
+			return []interface{}{elapsed.Add(elapsed, big.NewInt(genesisTime))}, nil
+		},
+		"latestBlockNumber": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{n.latestBlockNumber(env.st)}, nil
+		},
+		"nextBlockNumber": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			next := n.latestBlockNumber(env.st)
+			return []interface{}{next.Add(next, big.NewInt(SubmissionInterval))}, nil
+		},
+		"latestOutputIndex": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			count := n.outputCount(env.st)
+			if count == 0 {
+				return nil, errors.New("no outputs")
+			}
+			return []interface{}{new(big.Int).SetUint64(count - 1)}, nil
+		},
+		"nextOutputIndex": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{new(big.Int).SetUint64(n.outputCount(env.st))}, nil
+		},
+		"getL2Output": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			out, err := n.output(env.st, bigArg(args[0]).Uint64())
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{out}, nil
+		},
+		"getL2OutputIndexAfter": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			index, err := n.outputIndexAfter(env.st, bigArg(args[0]))
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{new(big.Int).SetUint64(index)}, nil
+		},
+		"getL2OutputAfter": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			index, err := n.outputIndexAfter(env.st, bigArg(args[0]))
+			if err != nil {
+				return nil, err
+			}
+			out, _ := n.output(env.st, index)
+			return []interface{}{out}, nil
+		},
+		"proposeL2Output": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			root, l2BlockNumber := hashArg(args[0]), bigArg(args[1])
+			if env.from != n.Proposer {
+				return nil, errors.New("L2OutputOracle: only the proposer address can propose new outputs")
+			}
+			if l2BlockNumber.Cmp(n.latestBlockNumber(env.st)) <= 0 {
+				return nil, errors.New("L2OutputOracle: block number must be after the latest output")
+			}
+			if root == (common.Hash{}) {
+				return nil, errors.New("L2OutputOracle: L2 output proposal cannot be the zero hash")
+			}
+			oracle := env.to
+			index := n.outputCount(env.st)
+			env.st.set(oracle, outputSlot("outputRoot", index), root)
+			env.st.setBig(oracle, outputSlot("outputTime", index), new(big.Int).SetUint64(env.time))
+			env.st.setBig(oracle, outputSlot("outputBlock", index), l2BlockNumber)
+			env.st.setBig(oracle, outputCountSlot, new(big.Int).SetUint64(index+1))
+			env.emit("OutputProposed", [32]byte(root), new(big.Int).SetUint64(index), l2BlockNumber, new(big.Int).SetUint64(env.time))
+			return nil, nil
+		},
+		"deleteL2Outputs": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			if env.from != n.Challenger {
+				return nil, errors.New("L2OutputOracle: only the challenger address can delete outputs")
+			}
+			index, count := bigArg(args[0]).Uint64(), n.outputCount(env.st)
+			if index >= count {
+				return nil, errors.New("L2OutputOracle: cannot delete outputs after the latest output index")
+			}
+			out, _ := n.output(env.st, index)
+			if env.time-out.Timestamp.Uint64() >= n.period(env.st) {
+				return nil, errors.New("L2OutputOracle: cannot delete outputs that have already been finalized")
+			}
+			oracle := env.to
+			for i := index; i < count; i++ {
+				for _, field := range []string{"outputRoot", "outputTime", "outputBlock"} {
+					env.st.set(oracle, outputSlot(field, i), common.Hash{})
+				}
+			}
+			env.st.setBig(oracle, outputCountSlot, new(big.Int).SetUint64(index))
+			env.emit("OutputsDeleted", new(big.Int).SetUint64(count), new(big.Int).SetUint64(index))
+			return nil, nil
+		},
+	}
+}
+
+// Portal storage.
+
+type provenWithdrawal struct {
+	outputRoot    common.Hash
+	timestamp     *big.Int
+	l2OutputIndex *big.Int
+}
+
+var l2SenderSlot = slotOf("l2Sender")
+
+func (n *Network) provenWithdrawal(st *state, hash common.Hash) provenWithdrawal {
+	portal := n.Profile.Addresses.L1OptimismPortal
+	return provenWithdrawal{
+		outputRoot:    st.get(portal, slotOf("provenRoot", hash[:])),
+		timestamp:     st.getBig(portal, slotOf("provenTime", hash[:])),
+		l2OutputIndex: st.getBig(portal, slotOf("provenIndex", hash[:])),
+	}
+}
+
+func finalizedSlot(hash common.Hash) common.Hash {
+	return slotOf("finalized", hash[:])
+}
+
+func withdrawalArg(v interface{}) abijson.TypesWithdrawalTransaction {
+	return *abi.ConvertType(v, new(abijson.TypesWithdrawalTransaction)).(*abijson.TypesWithdrawalTransaction)
+}
+
+// verifyWithdrawalInclusion checks the storage proof the way
+// SecureMerkleTrie.verifyInclusionProof does in the portal.
+func verifyWithdrawalInclusion(storageRoot, withdrawalHash common.Hash, nodes [][]byte) error {
+	db := memorydb.New()
+	for _, node := range nodes {
+		if err := db.Put(crypto.Keccak256(node), node); err != nil {
+			return err
+		}
+	}
+	slot := txutils.StorageSlotOfWithdrawalHash(withdrawalHash)
+	value, err := trie.VerifyProof(storageRoot, crypto.Keccak256(slot[:]), db)
+	if err != nil || !bytes.Equal(value, []byte{0x01}) {
+		return errors.New("OptimismPortal: invalid withdrawal inclusion proof")
+	}
+	return nil
+}
+
+func (n *Network) portalHandlers() map[string]handler {
+	addrs := n.Profile.Addresses
+	return map[string]handler{
+		"L2_ORACLE": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{addrs.L2OutputOracleProxy}, nil
+		},
+		"L1_MNT_ADDRESS": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{addrs.L1MantleToken}, nil
+		},
+		"l2Sender": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{common.BytesToAddress(env.st.get(env.to, l2SenderSlot).Bytes())}, nil
+		},
+		"depositTransaction": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			mntValue, to, mntTxValue := bigArg(args[0]), addressArg(args[1]), bigArg(args[2])
+			gasLimit, isCreation, data := args[3].(uint64), args[4].(bool), bytesArg(args[5])
+			if isCreation && to != (common.Address{}) {
+				return nil, errors.New("OptimismPortal: must send to address(0) when creating a contract")
+			}
+			if mntValue.Sign() > 0 {
+				if err := env.transferTokenFrom(addrs.L1MantleToken, env.from, env.to, mntValue); err != nil {
+					return nil, err
+				}
+			}
+			from := env.from
+			if _, ok := env.chain.contracts[from]; ok {
+				from = applyL1ToL2Alias(from)
+			}
+			ethValue := new(big.Int)
+			if env.value != nil {
+				ethValue = env.value
+			}
+			opaque := append(common.LeftPadBytes(mntValue.Bytes(), 32), common.LeftPadBytes(mntTxValue.Bytes(), 32)...)
+			opaque = append(opaque, common.LeftPadBytes(ethValue.Bytes(), 32)...)
+			opaque = binary.BigEndian.AppendUint64(opaque, gasLimit)
+			if isCreation {
+				opaque = append(opaque, 1)
+			} else {
+				opaque = append(opaque, 0)
+			}
+			opaque = append(opaque, data...)
+			env.emit("TransactionDeposited", from, to, big.NewInt(txutils.DepositVersion0), opaque)
+			return nil, nil
+		},
+		"proveWithdrawalTransaction": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			wd, index := withdrawalArg(args[0]), bigArg(args[1])
+			proof := *abi.ConvertType(args[2], new(abijson.TypesOutputRootProof)).(*abijson.TypesOutputRootProof)
+			nodes := args[3].([][]byte)
+			if wd.Target == env.to {
+				return nil, errors.New("OptimismPortal: you cannot send messages to the portal contract")
+			}
+			output, err := n.output(env.st, index.Uint64())
+			if err != nil {
+				return nil, err
+			}
+			root, err := txutils.ComputeL2OutputRoot(&proof)
+			if err != nil || common.Hash(root) != output.OutputRoot {
+				return nil, errors.New("OptimismPortal: invalid output root proof")
+			}
+			hash, err := txutils.GetWdHash(&wd)
+			if err != nil {
+				return nil, err
+			}
+			proven := n.provenWithdrawal(env.st, hash)
+			if proven.timestamp.Sign() != 0 {
+				current, err := n.output(env.st, proven.l2OutputIndex.Uint64())
+				if err == nil && current.OutputRoot == proven.outputRoot {
+					return nil, errors.New("OptimismPortal: withdrawal hash has already been proven")
+				}
+			}
+			if err := verifyWithdrawalInclusion(proof.MessagePasserStorageRoot, hash, nodes); err != nil {
+				return nil, err
+			}
+			env.st.set(env.to, slotOf("provenRoot", hash[:]), output.OutputRoot)
+			env.st.setBig(env.to, slotOf("provenTime", hash[:]), new(big.Int).SetUint64(env.time))
+			env.st.setBig(env.to, slotOf("provenIndex", hash[:]), index)
+			env.emit("WithdrawalProven", [32]byte(hash), wd.Sender, wd.Target)
+			return nil, nil
+		},
+		"finalizeWithdrawalTransaction": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			wd := withdrawalArg(args[0])
+			hash, err := txutils.GetWdHash(&wd)
+			if err != nil {
+				return nil, err
+			}
+			proven := n.provenWithdrawal(env.st, hash)
+			if proven.timestamp.Sign() == 0 {
+				return nil, errors.New("OptimismPortal: withdrawal has not been proven yet")
+			}
+			period := n.period(env.st)
+			if env.time <= proven.timestamp.Uint64()+period {
+				return nil, errors.New("OptimismPortal: proven withdrawal finalization period has not elapsed")
+			}
+			output, err := n.output(env.st, proven.l2OutputIndex.Uint64())
+			if err != nil || output.OutputRoot != proven.outputRoot {
+				return nil, errors.New("OptimismPortal: output root proven is not the same as current output root")
+			}
+			if env.time <= output.Timestamp.Uint64()+period {
+				return nil, errors.New("OptimismPortal: output proposal finalization period has not elapsed")
+			}
+			if env.st.get(env.to, finalizedSlot(hash)) != (common.Hash{}) {
+				return nil, errors.New("OptimismPortal: withdrawal has already been finalized")
+			}
+			env.st.set(env.to, finalizedSlot(hash), common.Hash{31: 1})
+
+			// a failing target call does not revert the finalization
+			snapshot, logs := env.st.copy(), len(env.logs)
+			env.st.set(env.to, l2SenderSlot, common.BytesToHash(wd.Sender[:]))
+			err = nil
+			if wd.MntValue.Sign() > 0 {
+				err = env.transferToken(addrs.L1MantleToken, env.to, wd.Target, wd.MntValue)
+			}
+			if err == nil {
+				err = env.callData(wd.Target, wd.EthValue, wd.Data)
+			}
+			env.st.set(env.to, l2SenderSlot, common.Hash{})
+			if err != nil {
+				*env.st = *snapshot
+				env.logs = env.logs[:logs]
+			}
+			env.emit("WithdrawalFinalized", [32]byte(hash), err == nil)
+			return nil, nil
+		},
+		"provenWithdrawals": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			proven := n.provenWithdrawal(env.st, hashArg(args[0]))
+			return []interface{}{[32]byte(proven.outputRoot), proven.timestamp, proven.l2OutputIndex}, nil
+		},
+		"finalizedWithdrawals": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{env.st.get(env.to, finalizedSlot(hashArg(args[0]))) != (common.Hash{})}, nil
+		},
+		"isOutputFinalized": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			output, err := n.output(env.st, bigArg(args[0]).Uint64())
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{env.time > output.Timestamp.Uint64()+n.period(env.st)}, nil
+		},
+	}
+}
+
+// onlyOtherBridge checks that the portal relays a withdrawal of the L2 bridge.
+func (n *Network) onlyOtherBridge(env *callEnv) error {
+	portal := n.Profile.Addresses.L1OptimismPortal
+	sender := common.BytesToAddress(env.st.get(portal, l2SenderSlot).Bytes())
+	if env.from != portal || sender != n.Profile.Addresses.L2StandardBridge {
+		return errors.New("StandardBridge: function can only be called from the other bridge")
+	}
+	return nil
+}
+
+// depositGas is the gas limit of the L2 call a bridge deposit makes, on top
+// of the minimum the user asked for.
+const depositGas = 200000
+
+func (n *Network) l1BridgeHandlers() map[string]handler {
+	addrs := n.Profile.Addresses
+	deposit := func(env *callEnv, mntValue, ethValue *big.Int, minGasLimit uint32, data []byte) error {
+		if mntValue.Sign() > 0 {
+			env.st.approve(addrs.L1MantleToken, env.to, addrs.L1OptimismPortal, mntValue)
+		}
+		return env.call(addrs.L1OptimismPortal, ethValue, "depositTransaction",
+			mntValue, addrs.L2StandardBridge, mntValue, uint64(minGasLimit)+depositGas, false, data)
+	}
+	return map[string]handler{
+		"depositETHTo": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			to, minGasLimit, extra := addressArg(args[0]), args[1].(uint32), bytesArg(args[2])
+			amount := new(big.Int)
+			if env.value != nil {
+				amount.Set(env.value)
+			}
+			data, err := n.l2BridgeABI.Pack("finalizeBridgeETH", env.from, to, amount, extra)
+			if err != nil {
+				return nil, err
+			}
+			if err := deposit(env, new(big.Int), amount, minGasLimit, data); err != nil {
+				return nil, err
+			}
+			env.emit("ETHDepositInitiated", env.from, to, amount, extra)
+			env.emit("ETHBridgeInitiated", env.from, to, amount, extra)
+			return nil, nil
+		},
+		"depositMNTTo": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			to, amount, minGasLimit, extra := addressArg(args[0]), bigArg(args[1]), args[2].(uint32), bytesArg(args[3])
+			if err := env.transferTokenFrom(addrs.L1MantleToken, env.from, env.to, amount); err != nil {
+				return nil, err
+			}
+			data, err := n.l2BridgeABI.Pack("finalizeBridgeMNT", env.from, to, amount, extra)
+			if err != nil {
+				return nil, err
+			}
+			if err := deposit(env, amount, nil, minGasLimit, data); err != nil {
+				return nil, err
+			}
+			env.emit("MNTDepositInitiated", env.from, to, amount, extra)
+			env.emit("MNTBridgeInitiated", env.from, to, amount, extra)
+			return nil, nil
+		},
+		"depositERC20To": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			l1Token, l2Token, to, amount := addressArg(args[0]), addressArg(args[1]), addressArg(args[2]), bigArg(args[3])
+			minGasLimit, extra := args[4].(uint32), bytesArg(args[5])
+			if n.l1Tokens[l1Token] != l2Token {
+				return nil, errors.New("StandardBridge: wrong remote token for Optimism Mintable ERC20 local token")
+			}
+			if err := env.transferTokenFrom(l1Token, env.from, env.to, amount); err != nil {
+				return nil, err
+			}
+			data, err := n.l2BridgeABI.Pack("finalizeBridgeERC20", l2Token, l1Token, env.from, to, amount, extra)
+			if err != nil {
+				return nil, err
+			}
+			if err := deposit(env, new(big.Int), nil, minGasLimit, data); err != nil {
+				return nil, err
+			}
+			env.emit("ERC20DepositInitiated", l1Token, l2Token, env.from, to, amount, extra)
+			env.emit("ERC20BridgeInitiated", l1Token, l2Token, env.from, to, amount, extra)
+			return nil, nil
+		},
+		"finalizeBridgeETH": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			from, to, amount, extra := addressArg(args[0]), addressArg(args[1]), bigArg(args[2]), bytesArg(args[3])
+			if err := n.onlyOtherBridge(env); err != nil {
+				return nil, err
+			}
+			if env.value == nil || env.value.Cmp(amount) != 0 {
+				return nil, errors.New("StandardBridge: amount sent does not match amount required")
+			}
+			if err := env.st.transfer(env.to, to, amount); err != nil {
+				return nil, err
+			}
+			env.emit("ETHWithdrawalFinalized", from, to, amount, extra)
+			env.emit("ETHBridgeFinalized", from, to, amount, extra)
+			return nil, nil
+		},
+		"finalizeBridgeMNT": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			from, to, amount, extra := addressArg(args[0]), addressArg(args[1]), bigArg(args[2]), bytesArg(args[3])
+			if err := n.onlyOtherBridge(env); err != nil {
+				return nil, err
+			}
+			if err := env.transferToken(addrs.L1MantleToken, env.to, to, amount); err != nil {
+				return nil, err
+			}
+			env.emit("MNTWithdrawalFinalized", from, to, amount, extra)
+			env.emit("MNTBridgeFinalized", from, to, amount, extra)
+			return nil, nil
+		},
+		"finalizeBridgeERC20": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			local, remote, from, to := addressArg(args[0]), addressArg(args[1]), addressArg(args[2]), addressArg(args[3])
+			amount, extra := bigArg(args[4]), bytesArg(args[5])
+			if err := n.onlyOtherBridge(env); err != nil {
+				return nil, err
+			}
+			if err := env.transferToken(local, env.to, to, amount); err != nil {
+				return nil, err
+			}
+			env.emit("ERC20WithdrawalFinalized", local, remote, from, to, amount, extra)
+			env.emit("ERC20BridgeFinalized", local, remote, from, to, amount, extra)
+			return nil, nil
+		},
+	}
+}
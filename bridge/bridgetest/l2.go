@@ -0,0 +1,165 @@
+package bridgetest
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"try_rde/abistr/abijson"
+	"try_rde/txutils"
+)
+
+// messageVersion is encoded into the top two bytes of every withdrawal nonce.
+const messageVersion = 1
+
+// The L2ToL1MessagePasser storage layout is the real one, its storage root
+// ends up in the output roots.
+var msgNonceSlot = common.Hash{31: 1}
+
+func (n *Network) passerHandlers() map[string]handler {
+	encodeNonce := func(nonce *big.Int) *big.Int {
+		version := new(big.Int).Lsh(big.NewInt(messageVersion), 240)
+		return version.Or(version, nonce)
+	}
+	return map[string]handler{
+		"MESSAGE_VERSION": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{uint16(messageVersion)}, nil
+		},
+		"messageNonce": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			return []interface{}{encodeNonce(env.st.getBig(env.to, msgNonceSlot))}, nil
+		},
+		"sentMessages": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			slot := txutils.StorageSlotOfWithdrawalHash(hashArg(args[0]))
+			return []interface{}{env.st.get(env.to, slot) != (common.Hash{})}, nil
+		},
+		"initiateWithdrawal": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			mntValue := new(big.Int)
+			if env.value != nil {
+				mntValue.Set(env.value)
+			}
+			nonce := env.st.getBig(env.to, msgNonceSlot)
+			ev := &abijson.L2ToL1MessagePasserMessagePassed{
+				Nonce:    encodeNonce(nonce),
+				Sender:   env.from,
+				Target:   addressArg(args[1]),
+				MntValue: mntValue,
+				EthValue: bigArg(args[0]),
+				GasLimit: bigArg(args[2]),
+				Data:     bytesArg(args[3]),
+			}
+			hash, err := txutils.WithdrawalHash(ev)
+			if err != nil {
+				return nil, err
+			}
+			env.st.set(env.to, txutils.StorageSlotOfWithdrawalHash(hash), common.Hash{31: 1})
+			env.st.setBig(env.to, msgNonceSlot, nonce.Add(nonce, common.Big1))
+			env.emit("MessagePassed", ev.Nonce, ev.Sender, ev.Target, ev.MntValue, ev.EthValue, ev.GasLimit, ev.Data, [32]byte(hash))
+			return nil, nil
+		},
+	}
+}
+
+// onlyL1Bridge checks that a deposit of the L1 bridge makes the call.
+func (n *Network) onlyL1Bridge(env *callEnv) error {
+	if env.from != applyL1ToL2Alias(n.Profile.Addresses.L1StandardBridge) {
+		return errors.New("StandardBridge: function can only be called from the other bridge")
+	}
+	return nil
+}
+
+// withdrawalGas is the gas limit of the L1 call a bridge withdrawal makes, on
+// top of the minimum the user asked for.
+const withdrawalGas = 200000
+
+func (n *Network) l2BridgeHandlers() map[string]handler {
+	addrs := n.Profile.Addresses
+	return map[string]handler{
+		"finalizeBridgeETH": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			from, to, amount, extra := addressArg(args[0]), addressArg(args[1]), bigArg(args[2]), bytesArg(args[3])
+			if err := n.onlyL1Bridge(env); err != nil {
+				return nil, err
+			}
+			// the deposit minted the BVM_ETH to the aliased L1 bridge
+			if err := env.transferToken(addrs.L2ETH, env.from, to, amount); err != nil {
+				return nil, err
+			}
+			env.emit("ETHBridgeFinalized", from, to, amount, extra)
+			env.emit("DepositFinalized", common.Address{}, addrs.L2ETH, from, to, amount, extra)
+			return nil, nil
+		},
+		"finalizeBridgeMNT": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			from, to, amount, extra := addressArg(args[0]), addressArg(args[1]), bigArg(args[2]), bytesArg(args[3])
+			if err := n.onlyL1Bridge(env); err != nil {
+				return nil, err
+			}
+			if env.value == nil || env.value.Cmp(amount) != 0 {
+				return nil, errors.New("StandardBridge: amount sent does not match amount required")
+			}
+			if err := env.st.transfer(env.to, to, amount); err != nil {
+				return nil, err
+			}
+			env.emit("MNTBridgeFinalized", from, to, amount, extra)
+			env.emit("DepositFinalized", addrs.L1MantleToken, addrs.LegacyERC20MNT, from, to, amount, extra)
+			return nil, nil
+		},
+		"finalizeBridgeERC20": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			local, remote, from, to := addressArg(args[0]), addressArg(args[1]), addressArg(args[2]), addressArg(args[3])
+			amount, extra := bigArg(args[4]), bytesArg(args[5])
+			if err := n.onlyL1Bridge(env); err != nil {
+				return nil, err
+			}
+			if n.l1Tokens[remote] != local {
+				return nil, errors.New("StandardBridge: wrong remote token for Optimism Mintable ERC20 local token")
+			}
+			env.mintToken(local, to, amount)
+			env.emit("ERC20BridgeFinalized", local, remote, from, to, amount, extra)
+			env.emit("DepositFinalized", remote, local, from, to, amount, extra)
+			return nil, nil
+		},
+		"withdrawTo": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			l2Token, to, amount := addressArg(args[0]), addressArg(args[1]), bigArg(args[2])
+			minGasLimit, extra := args[3].(uint32), bytesArg(args[4])
+			from := env.from
+
+			var l1Token common.Address
+			mntValue, ethValue := new(big.Int), new(big.Int)
+			var data []byte
+			var err error
+			switch l2Token {
+			case addrs.LegacyERC20MNT:
+				if env.value == nil || env.value.Cmp(amount) != 0 {
+					return nil, errors.New("StandardBridge: bridging MNT must include sufficient MNT value")
+				}
+				l1Token, mntValue = addrs.L1MantleToken, amount
+				data, err = n.l1BridgeABI.Pack("finalizeBridgeMNT", from, to, amount, extra)
+			case addrs.L2ETH:
+				if err := env.transferTokenFrom(addrs.L2ETH, from, env.to, amount); err != nil {
+					return nil, err
+				}
+				if err := env.burnToken(addrs.L2ETH, env.to, amount); err != nil {
+					return nil, err
+				}
+				ethValue = amount
+				data, err = n.l1BridgeABI.Pack("finalizeBridgeETH", from, to, amount, extra)
+			default:
+				var ok bool
+				if l1Token, ok = n.l2Tokens[l2Token]; !ok {
+					return nil, errors.New("StandardBridge: token is not bridged")
+				}
+				if err := env.burnToken(l2Token, from, amount); err != nil {
+					return nil, err
+				}
+				data, err = n.l1BridgeABI.Pack("finalizeBridgeERC20", l1Token, l2Token, from, to, amount, extra)
+			}
+			if err != nil {
+				return nil, err
+			}
+			gasLimit := big.NewInt(int64(minGasLimit) + withdrawalGas)
+			if err := env.call(addrs.L2ToL1MessagePasser, mntValue, "initiateWithdrawal", ethValue, addrs.L1StandardBridge, gasLimit, data); err != nil {
+				return nil, err
+			}
+			env.emit("WithdrawalInitiated", l1Token, l2Token, from, to, amount, extra)
+			return nil, nil
+		},
+	}
+}
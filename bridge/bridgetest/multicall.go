@@ -0,0 +1,71 @@
+package bridgetest
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/signer"
+)
+
+// Multicall is an L2 contract that makes several calls in one tx, the way a
+// batching contract starts more than one withdrawal per receipt.
+var Multicall = common.HexToAddress("0x2200000000000000000000000000000000000020")
+
+const multicallABI = `[{"type":"function","name":"multicall","stateMutability":"nonpayable",
+	"inputs":[{"name":"targets","type":"address[]"},{"name":"data","type":"bytes[]"}],"outputs":[]}]`
+
+var parsedMulticallABI = func() *abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(multicallABI))
+	if err != nil {
+		panic(err)
+	}
+	return &parsed
+}()
+
+// Call is one call made by Multicall.
+type Call struct {
+	To   common.Address
+	Data []byte
+}
+
+func multicallHandlers() map[string]handler {
+	return map[string]handler{
+		"multicall": func(env *callEnv, args []interface{}) ([]interface{}, error) {
+			targets, data := args[0].([]common.Address), args[1].([][]byte)
+			for i, to := range targets {
+				if err := env.callData(to, nil, data[i]); err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+		},
+	}
+}
+
+// Multicall sends an L2 tx making the calls from the Multicall contract in
+// order, every call reverts the tx if it fails.
+func (n *Network) Multicall(ctx context.Context, s signer.Signer, calls ...Call) (*types.Transaction, error) {
+	targets := make([]common.Address, len(calls))
+	data := make([][]byte, len(calls))
+	for i, call := range calls {
+		targets[i], data[i] = call.To, call.Data
+	}
+	bound := bind.NewBoundContract(Multicall, *parsedMulticallABI, n.L2, n.L2, n.L2)
+	return bound.Transact(signer.TransactOpts(ctx, s, big.NewInt(L2ChainID)), "multicall", targets, data)
+}
+
+// WithdrawalCall is a Call starting a withdrawal without value straight at
+// the L2ToL1MessagePasser, which calls target with data on L1.
+func (n *Network) WithdrawalCall(target common.Address, data []byte) Call {
+	passer := n.L2.contracts[n.Profile.Addresses.L2ToL1MessagePasser]
+	calldata, err := passer.abi.Pack("initiateWithdrawal", new(big.Int), target, big.NewInt(withdrawalGas), data)
+	if err != nil {
+		panic(err)
+	}
+	return Call{To: n.Profile.Addresses.L2ToL1MessagePasser, Data: calldata}
+}
@@ -0,0 +1,271 @@
+// Package bridgetest runs the bridge against an in-memory L1 and L2 so the
+// deposit, withdraw, prove and finalize flows can be tested without nodes.
+//
+// The chains mine a block for every tx sent to them. The L1StandardBridge,
+// OptimismPortal, L2OutputOracle, L2StandardBridge, L2ToL1MessagePasser, the
+// tokens and a Multicall are emulated in Go, closely enough for package bridge to drive
+// them through the real bindings: deposits are relayed to L2 as soon as their
+// L1 block is mined, outputs are proposed with ProposeOutput and withdrawal
+// proofs are real merkle proofs against the L2 state root.
+package bridgetest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/abistr/abijson"
+	"try_rde/bridge"
+	"try_rde/config"
+	"try_rde/txutils"
+)
+
+const (
+	L1ChainID = 900
+	L2ChainID = 901
+	// FinalizationPeriod is the challenge window of the emulated oracle in
+	// seconds, see Network.AdvanceTime.
+	FinalizationPeriod = 600
+	// TestToken is the symbol of the ERC20 pair in the profile.
+	TestToken = "TT"
+)
+
+var (
+	proposer   = common.HexToAddress("0x11000000000000000000000000000000000000a1")
+	challenger = common.HexToAddress("0x11000000000000000000000000000000000000a2")
+)
+
+// Profile returns the profile of the network, every address is fixed.
+func Profile() *config.Profile {
+	return &config.Profile{
+		Name: "bridgetest",
+		L1:   config.ChainConfig{RPC: "memory://l1", ChainID: L1ChainID},
+		L2:   config.ChainConfig{RPC: "memory://l2", ChainID: L2ChainID},
+		Addresses: config.Addresses{
+			L1StandardBridge:    common.HexToAddress("0x1100000000000000000000000000000000000001"),
+			L1OptimismPortal:    common.HexToAddress("0x1100000000000000000000000000000000000002"),
+			L2OutputOracleProxy: common.HexToAddress("0x1100000000000000000000000000000000000003"),
+			L1MantleToken:       common.HexToAddress("0x1100000000000000000000000000000000000004"),
+			L2StandardBridge:    predeploys.L2StandardBridgeAddr,
+			L2ToL1MessagePasser: predeploys.L2ToL1MessagePasserAddr,
+			L2ETH:               common.HexToAddress("0xdEAddEaDdeadDEadDEADDEAddEADDEAddead1111"),
+			LegacyERC20MNT:      predeploys.LegacyERC20ETHAddr,
+		},
+		Tokens: map[string]config.Token{
+			TestToken: {
+				L1: common.HexToAddress("0x1100000000000000000000000000000000000010"),
+				L2: common.HexToAddress("0x2200000000000000000000000000000000000010"),
+			},
+		},
+	}
+}
+
+// Network is an L1 and an L2 chain bridged by the emulated contracts.
+type Network struct {
+	L1      *Chain
+	L2      *Chain
+	Profile *config.Profile
+
+	Proposer   common.Address
+	Challenger common.Address
+
+	// token pairs, keyed by their L1 and their L2 address
+	l1Tokens map[common.Address]common.Address
+	l2Tokens map[common.Address]common.Address
+
+	l1BridgeABI *abi.ABI
+	l2BridgeABI *abi.ABI
+	portalABI   *abi.ABI
+	oracleABI   *abi.ABI
+	portal      *abijson.L1OptimismPortalFilterer
+}
+
+func mustABI(meta *bind.MetaData) *abi.ABI {
+	parsed, err := meta.GetAbi()
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// NewNetwork creates both chains with the contracts of Profile deployed.
+func NewNetwork() *Network {
+	n := &Network{
+		L1:          newChain(L1ChainID, L1BlockTime),
+		L2:          newChain(L2ChainID, L2BlockTime),
+		Profile:     Profile(),
+		Proposer:    proposer,
+		Challenger:  challenger,
+		l1Tokens:    make(map[common.Address]common.Address),
+		l2Tokens:    make(map[common.Address]common.Address),
+		l1BridgeABI: mustABI(abijson.L1StandardBridgeMetaData),
+		l2BridgeABI: mustABI(abijson.L2StandardBridgeMetaData),
+		portalABI:   mustABI(abijson.L1OptimismPortalMetaData),
+		oracleABI:   mustABI(abijson.L2OutputOracleProxyMetaData),
+	}
+	var err error
+	if n.portal, err = abijson.NewL1OptimismPortalFilterer(n.Profile.Addresses.L1OptimismPortal, nil); err != nil {
+		panic(err)
+	}
+	addrs := n.Profile.Addresses
+	tokenABI := mustABI(abijson.L2TestTokenMetaData)
+
+	n.L1.register(addrs.L1StandardBridge, n.l1BridgeABI, n.l1BridgeHandlers())
+	n.L1.register(addrs.L1OptimismPortal, n.portalABI, n.portalHandlers())
+	n.L1.register(addrs.L2OutputOracleProxy, n.oracleABI, n.oracleHandlers())
+	n.L1.register(addrs.L1MantleToken, mustABI(abijson.L1MantleTokenMetaData), mantleToken(n.L1.chainID, addrs.L1MantleToken))
+	n.L1.state.setBig(addrs.L2OutputOracleProxy, periodSlot, big.NewInt(FinalizationPeriod))
+
+	n.L2.register(addrs.L2StandardBridge, n.l2BridgeABI, n.l2BridgeHandlers())
+	n.L2.register(addrs.L2ToL1MessagePasser, mustABI(abijson.L2ToL1MessagePasserMetaData), n.passerHandlers())
+	n.L2.register(addrs.L2ETH, tokenABI, erc20())
+	n.L2.register(Multicall, parsedMulticallABI, multicallHandlers())
+	for _, tk := range n.Profile.Tokens {
+		n.l1Tokens[tk.L1], n.l2Tokens[tk.L2] = tk.L2, tk.L1
+		n.L1.register(tk.L1, tokenABI, erc20())
+		n.L2.register(tk.L2, tokenABI, erc20())
+	}
+	n.L2.proofAccount = addrs.L2ToL1MessagePasser
+	n.L2.blocks[0].header.Root = n.L2.stateRoot(n.L2.state)
+
+	n.L1.onBlock = n.relayDeposits
+	return n
+}
+
+// Client returns a bridge client connected to both chains.
+func (n *Network) Client(ctx context.Context) (*bridge.Client, error) {
+	return bridge.NewClient(ctx, n.Profile, n.L1, n.L2, n.L2)
+}
+
+// relayDeposits executes the deposits made in an L1 block on L2, all of them
+// in one L2 block.
+func (n *Network) relayDeposits(b *block) {
+	var deposits []*txutils.DepositTx
+	for _, r := range b.receipts {
+		for _, log := range r.Logs {
+			if log.Address != n.Profile.Addresses.L1OptimismPortal || log.Topics[0] != n.portalABI.Events["TransactionDeposited"].ID {
+				continue
+			}
+			ev, err := n.portal.ParseTransactionDeposited(*log)
+			if err != nil {
+				panic(err)
+			}
+			dep, err := txutils.UnmarshalDepositEvent(ev)
+			if err != nil {
+				panic(err)
+			}
+			deposits = append(deposits, dep)
+		}
+	}
+	if len(deposits) == 0 {
+		return
+	}
+
+	c := n.L2
+	c.mu.Lock()
+	head := c.head().header
+	var receipts []*types.Receipt
+	for _, dep := range deposits {
+		env := &callEnv{
+			chain:  c,
+			st:     c.state.copy(),
+			from:   dep.From,
+			value:  dep.Value,
+			number: head.Number.Uint64() + 1,
+			time:   head.Time + c.blockTime + c.timeOffset,
+		}
+		// the minted MNT and BVM_ETH stay even if the call fails
+		if dep.Mint != nil {
+			env.st.credit(dep.From, dep.Mint)
+		}
+		if dep.EthValue != nil {
+			env.mintToken(n.Profile.Addresses.L2ETH, dep.From, dep.EthValue)
+		}
+		minted, mintLogs := env.st.copy(), env.logs
+
+		receipt := &types.Receipt{
+			Type:    txutils.DepositTxType,
+			Status:  types.ReceiptStatusSuccessful,
+			TxHash:  dep.Hash(),
+			GasUsed: callGas,
+		}
+		var err error
+		if dep.To == nil {
+			err = fmt.Errorf("contract creation is not emulated")
+		} else {
+			env.to = *dep.To
+			_, err = c.run(env, dep.Data)
+		}
+		if err != nil {
+			receipt.Status = types.ReceiptStatusFailed
+			env.st, env.logs = minted, mintLogs
+		}
+		env.st.setNonce(dep.From, env.st.nonce(dep.From)+1)
+		receipt.Logs = env.logs
+		c.state = env.st
+		receipts = append(receipts, receipt)
+	}
+	l2Block := c.mine(nil, receipts)
+	c.mu.Unlock()
+	c.notify(l2Block)
+}
+
+// ProposeOutput proposes the output root of the L2 head to the oracle.
+func (n *Network) ProposeOutput() error {
+	n.L2.mu.Lock()
+	head := n.L2.head()
+	header := types.CopyHeader(head.header)
+	_, _, passer := n.L2.tries(head.state)
+	n.L2.mu.Unlock()
+
+	root, err := txutils.ComputeL2OutputRoot(&abijson.TypesOutputRootProof{
+		StateRoot:                header.Root,
+		MessagePasserStorageRoot: passer.Root,
+		LatestBlockhash:          header.Hash(),
+	})
+	if err != nil {
+		return err
+	}
+	l1Head, err := n.L1.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	_, err = n.L1.system(n.Proposer, n.Profile.Addresses.L2OutputOracleProxy, nil, n.oracleABI, "proposeL2Output",
+		[32]byte(root), header.Number, l1Head.Hash(), l1Head.Number)
+	return err
+}
+
+// DeleteOutputs deletes the output at index and every later one, like the
+// challenger does with faulty outputs.
+func (n *Network) DeleteOutputs(index uint64) error {
+	_, err := n.L1.system(n.Challenger, n.Profile.Addresses.L2OutputOracleProxy, nil, n.oracleABI, "deleteL2Outputs",
+		new(big.Int).SetUint64(index))
+	return err
+}
+
+// AdvanceTime moves the L1 clock forward, e.g. past FinalizationPeriod.
+func (n *Network) AdvanceTime(seconds uint64) {
+	n.L1.AdvanceTime(seconds)
+}
+
+// MintToken credits an emulated ERC20 on chain c, e.g. the L1 MNT or the L1
+// side of TestToken.
+func (c *Chain) MintToken(token, to common.Address, amount *big.Int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = c.state.copy()
+	c.state.mintToken(token, to, amount)
+	c.head().state = c.state
+}
+
+// TokenBalance returns the balance of an emulated ERC20 at the head.
+func (c *Chain) TokenBalance(token, owner common.Address) *big.Int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.tokenBalance(token, owner)
+}
@@ -0,0 +1,102 @@
+package bridgetest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// codeHash is the hash of the one byte code CodeAt reports for contracts.
+var codeHash = crypto.Keccak256([]byte{0x01})
+
+// tries builds the state trie holding only c.proofAccount and the storage
+// trie of that account from st.
+func (c *Chain) tries(st *state) (stateTrie, storageTrie *trie.Trie, account types.StateAccount) {
+	db := trie.NewDatabase(memorydb.New())
+	storageTrie = trie.NewEmpty(db)
+	for slot, value := range st.storage[c.proofAccount] {
+		enc, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(value[:]))
+		storageTrie.Update(crypto.Keccak256(slot[:]), enc)
+	}
+	account = types.StateAccount{
+		Nonce:    st.nonce(c.proofAccount),
+		Balance:  st.balance(c.proofAccount),
+		Root:     storageTrie.Hash(),
+		CodeHash: codeHash,
+	}
+	enc, _ := rlp.EncodeToBytes(&account)
+	stateTrie = trie.NewEmpty(db)
+	stateTrie.Update(crypto.Keccak256(c.proofAccount[:]), enc)
+	return stateTrie, storageTrie, account
+}
+
+func (c *Chain) stateRoot(st *state) common.Hash {
+	if c.proofAccount == (common.Address{}) {
+		return types.EmptyRootHash
+	}
+	stateTrie, _, _ := c.tries(st)
+	return stateTrie.Hash()
+}
+
+func proveKey(tr *trie.Trie, key []byte) ([]string, error) {
+	db := memorydb.New()
+	if err := tr.Prove(key, 0, db); err != nil {
+		return nil, err
+	}
+	var nodes []string
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		nodes = append(nodes, hexutil.Encode(it.Value()))
+	}
+	return nodes, nil
+}
+
+// GetProof implements txutils.ProofBackend for the account the chain proves,
+// the L2ToL1MessagePasser on L2.
+func (c *Chain) GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*gethclient.AccountResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if account != c.proofAccount || account == (common.Address{}) {
+		return nil, fmt.Errorf("no proofs for account %s", account)
+	}
+	b, err := c.blockAt(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	stateTrie, storageTrie, acc := c.tries(b.state)
+	accountProof, err := proveKey(stateTrie, crypto.Keccak256(account[:]))
+	if err != nil {
+		return nil, err
+	}
+	res := &gethclient.AccountResult{
+		Address:      account,
+		AccountProof: accountProof,
+		Balance:      acc.Balance,
+		CodeHash:     common.BytesToHash(acc.CodeHash),
+		Nonce:        acc.Nonce,
+		StorageHash:  acc.Root,
+	}
+	for _, key := range keys {
+		slot := common.HexToHash(key)
+		proof, err := proveKey(storageTrie, crypto.Keccak256(slot[:]))
+		if err != nil {
+			return nil, err
+		}
+		res.StorageProof = append(res.StorageProof, gethclient.StorageResult{
+			Key:   key,
+			Value: b.state.get(account, slot).Big(),
+			Proof: proof,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,274 @@
+package bridgetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Serve serves the chain over JSON-RPC on a local http server until the test
+// ends and returns its URL. Only the eth methods the bridge uses are served,
+// e.g. to record an rpcreplay session against the chain.
+func (c *Chain) Serve(t testing.TB) string {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", &ethAPI{c: c}); err != nil {
+		t.Fatal(err)
+	}
+	node := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		node.Close()
+		srv.Stop()
+	})
+	return node.URL
+}
+
+// ethAPI is the eth namespace of a Chain, answering like geth does: unknown
+// blocks, txs and receipts are null.
+type ethAPI struct {
+	c *Chain
+}
+
+// callArgs are the tx fields of eth_call and eth_estimateGas.
+type callArgs struct {
+	From  common.Address  `json:"from"`
+	To    *common.Address `json:"to"`
+	Gas   hexutil.Uint64  `json:"gas"`
+	Value *hexutil.Big    `json:"value"`
+	Data  hexutil.Bytes   `json:"data"`
+	Input hexutil.Bytes   `json:"input"`
+}
+
+func (args *callArgs) msg() ethereum.CallMsg {
+	data := args.Input
+	if len(data) == 0 {
+		data = args.Data
+	}
+	return ethereum.CallMsg{From: args.From, To: args.To, Gas: uint64(args.Gas), Value: (*big.Int)(args.Value), Data: data}
+}
+
+// blockNumber maps the tags to the head, the only block the chain builds on.
+func blockNumber(n rpc.BlockNumber) *big.Int {
+	if n < 0 {
+		return nil
+	}
+	return big.NewInt(n.Int64())
+}
+
+func notFound(err error) bool {
+	return errors.Is(err, ethereum.NotFound)
+}
+
+func (api *ethAPI) ChainId(ctx context.Context) (*hexutil.Big, error) {
+	id, err := api.c.ChainID(ctx)
+	return (*hexutil.Big)(id), err
+}
+
+func (api *ethAPI) BlockNumber(ctx context.Context) (hexutil.Uint64, error) {
+	n, err := api.c.BlockNumber(ctx)
+	return hexutil.Uint64(n), err
+}
+
+// header returns the header as geth encodes it, without the fields of a
+// full block.
+func header(h *types.Header, err error) (*types.Header, error) {
+	if notFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if h.Difficulty == nil {
+		// encodes like nil, the hash stays the same
+		h.Difficulty = new(big.Int)
+	}
+	return h, nil
+}
+
+func (api *ethAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (*types.Header, error) {
+	return header(api.c.HeaderByNumber(ctx, blockNumber(number)))
+}
+
+func (api *ethAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fullTx bool) (*types.Header, error) {
+	return header(api.c.HeaderByHash(ctx, hash))
+}
+
+func (api *ethAPI) GetBalance(ctx context.Context, account common.Address, number rpc.BlockNumber) (*hexutil.Big, error) {
+	balance, err := api.c.BalanceAt(ctx, account, blockNumber(number))
+	return (*hexutil.Big)(balance), err
+}
+
+func (api *ethAPI) GetCode(ctx context.Context, account common.Address, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	return api.c.CodeAt(ctx, account, blockNumber(number))
+}
+
+// GetTransactionCount answers with the pending nonce for every block, txs
+// are mined as soon as they are sent.
+func (api *ethAPI) GetTransactionCount(ctx context.Context, account common.Address, number rpc.BlockNumber) (hexutil.Uint64, error) {
+	nonce, err := api.c.PendingNonceAt(ctx, account)
+	return hexutil.Uint64(nonce), err
+}
+
+func (api *ethAPI) Call(ctx context.Context, args callArgs, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	return api.c.CallContract(ctx, args.msg(), blockNumber(number))
+}
+
+func (api *ethAPI) EstimateGas(ctx context.Context, args callArgs, number *rpc.BlockNumber) (hexutil.Uint64, error) {
+	gas, err := api.c.EstimateGas(ctx, args.msg())
+	return hexutil.Uint64(gas), err
+}
+
+func (api *ethAPI) GasPrice(ctx context.Context) (*hexutil.Big, error) {
+	price, err := api.c.SuggestGasPrice(ctx)
+	return (*hexutil.Big)(price), err
+}
+
+func (api *ethAPI) MaxPriorityFeePerGas(ctx context.Context) (*hexutil.Big, error) {
+	tip, err := api.c.SuggestGasTipCap(ctx)
+	return (*hexutil.Big)(tip), err
+}
+
+type feeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+func (api *ethAPI) FeeHistory(ctx context.Context, blockCount hexutil.Uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*feeHistory, error) {
+	h, err := api.c.FeeHistory(ctx, uint64(blockCount), blockNumber(lastBlock), rewardPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	res := &feeHistory{OldestBlock: (*hexutil.Big)(h.OldestBlock), GasUsedRatio: h.GasUsedRatio}
+	for _, rewards := range h.Reward {
+		enc := make([]*hexutil.Big, len(rewards))
+		for i, r := range rewards {
+			enc[i] = (*hexutil.Big)(r)
+		}
+		res.Reward = append(res.Reward, enc)
+	}
+	for _, fee := range h.BaseFee {
+		res.BaseFee = append(res.BaseFee, (*hexutil.Big)(fee))
+	}
+	return res, nil
+}
+
+func (api *ethAPI) SendRawTransaction(ctx context.Context, enc hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(enc); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), api.c.SendTransaction(ctx, tx)
+}
+
+func (api *ethAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	r, err := api.c.TransactionReceipt(ctx, hash)
+	if notFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Logs == nil {
+		// geth sends an empty list, never null
+		cpy := *r
+		cpy.Logs = []*types.Log{}
+		return &cpy, nil
+	}
+	return r, nil
+}
+
+// GetTransactionByHash answers with the tx and where it was mined, as every
+// known tx is.
+func (api *ethAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	tx, _, err := api.c.TransactionByHash(ctx, hash)
+	if notFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, err := api.c.TransactionReceipt(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	from, err := types.Sender(api.c.signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := tx.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		return nil, err
+	}
+	fields["from"] = from
+	fields["blockHash"] = r.BlockHash
+	fields["blockNumber"] = (*hexutil.Big)(r.BlockNumber)
+	fields["transactionIndex"] = hexutil.Uint64(r.TransactionIndex)
+	return fields, nil
+}
+
+func (api *ethAPI) GetLogs(ctx context.Context, crit filters.FilterCriteria) ([]types.Log, error) {
+	q := ethereum.FilterQuery(crit)
+	if q.FromBlock != nil && q.FromBlock.Sign() < 0 {
+		// a tag, the head for all the chain knows
+		head, err := api.c.BlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+		q.FromBlock = new(big.Int).SetUint64(head)
+	}
+	logs, err := api.c.FilterLogs(ctx, q)
+	if logs == nil && err == nil {
+		logs = []types.Log{}
+	}
+	return logs, err
+}
+
+type storageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+type accountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []storageResult `json:"storageProof"`
+}
+
+func (api *ethAPI) GetProof(ctx context.Context, account common.Address, keys []string, number rpc.BlockNumber) (*accountResult, error) {
+	p, err := api.c.GetProof(ctx, account, keys, blockNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	res := &accountResult{
+		Address:      p.Address,
+		AccountProof: p.AccountProof,
+		Balance:      (*hexutil.Big)(p.Balance),
+		CodeHash:     p.CodeHash,
+		Nonce:        hexutil.Uint64(p.Nonce),
+		StorageHash:  p.StorageHash,
+	}
+	for _, s := range p.StorageProof {
+		res.StorageProof = append(res.StorageProof, storageResult{Key: s.Key, Value: (*hexutil.Big)(s.Value), Proof: s.Proof})
+	}
+	return res, nil
+}
@@ -0,0 +1,47 @@
+package bridgetest
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/signer"
+)
+
+// AccountBalance is what Account funds on each chain: ETH on L1, the native
+// MNT on L2.
+var AccountBalance = new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))
+
+// Setup starts a network and a client connected to it.
+func Setup(t testing.TB) (*Network, *bridge.Client) {
+	n := NewNetwork()
+	c, err := n.Client(context.Background())
+	require.NoError(t, err)
+	return n, c
+}
+
+// Key returns the signer of a fresh key that holds nothing.
+func Key(t testing.TB) signer.Signer {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	return signer.NewPrivateKeySigner(key)
+}
+
+// Account returns the signer of a fresh key funded with AccountBalance on
+// both chains.
+func Account(t testing.TB, n *Network) signer.Signer {
+	s := Key(t)
+	n.L1.Fund(s.Address(), AccountBalance)
+	n.L2.Fund(s.Address(), AccountBalance)
+	return s
+}
+
+// FundPortal mints L1 MNT to the portal, as earlier deposits would have
+// locked there, so MNT withdrawals can be finalized.
+func (n *Network) FundPortal(amount *big.Int) {
+	n.L1.MintToken(n.Profile.Addresses.L1MantleToken, n.Profile.Addresses.L1OptimismPortal, amount)
+}
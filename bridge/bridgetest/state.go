@@ -0,0 +1,173 @@
+package bridgetest
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// state is the world state of a Chain: native balances, nonces and the
+// storage of the emulated contracts. Every tx works on a copy, so old blocks
+// keep the state they were mined with.
+type state struct {
+	balances map[common.Address]*big.Int
+	nonces   map[common.Address]uint64
+	storage  map[common.Address]map[common.Hash]common.Hash
+}
+
+func newState() *state {
+	return &state{
+		balances: make(map[common.Address]*big.Int),
+		nonces:   make(map[common.Address]uint64),
+		storage:  make(map[common.Address]map[common.Hash]common.Hash),
+	}
+}
+
+func (s *state) copy() *state {
+	cp := newState()
+	for a, b := range s.balances {
+		cp.balances[a] = b
+	}
+	for a, n := range s.nonces {
+		cp.nonces[a] = n
+	}
+	for a, slots := range s.storage {
+		m := make(map[common.Hash]common.Hash, len(slots))
+		for k, v := range slots {
+			m[k] = v
+		}
+		cp.storage[a] = m
+	}
+	return cp
+}
+
+func (s *state) nonce(a common.Address) uint64 {
+	return s.nonces[a]
+}
+
+func (s *state) setNonce(a common.Address, n uint64) {
+	s.nonces[a] = n
+}
+
+func (s *state) balance(a common.Address) *big.Int {
+	if b, ok := s.balances[a]; ok {
+		return new(big.Int).Set(b)
+	}
+	return new(big.Int)
+}
+
+// credit and the other balance setters never mutate a *big.Int in place, a
+// copied state shares them.
+func (s *state) credit(a common.Address, amount *big.Int) {
+	s.balances[a] = new(big.Int).Add(s.balance(a), amount)
+}
+
+func (s *state) debit(a common.Address, amount *big.Int) error {
+	b := s.balance(a)
+	if b.Cmp(amount) < 0 {
+		return fmt.Errorf("insufficient funds for transfer: address %s have %s want %s", a, b, amount)
+	}
+	s.balances[a] = b.Sub(b, amount)
+	return nil
+}
+
+func (s *state) transfer(from, to common.Address, amount *big.Int) error {
+	if err := s.debit(from, amount); err != nil {
+		return err
+	}
+	s.credit(to, amount)
+	return nil
+}
+
+func (s *state) get(a common.Address, slot common.Hash) common.Hash {
+	return s.storage[a][slot]
+}
+
+func (s *state) set(a common.Address, slot, value common.Hash) {
+	slots, ok := s.storage[a]
+	if !ok {
+		slots = make(map[common.Hash]common.Hash)
+		s.storage[a] = slots
+	}
+	if value == (common.Hash{}) {
+		delete(slots, slot)
+		return
+	}
+	slots[slot] = value
+}
+
+func (s *state) getBig(a common.Address, slot common.Hash) *big.Int {
+	return s.get(a, slot).Big()
+}
+
+func (s *state) setBig(a common.Address, slot common.Hash, v *big.Int) {
+	s.set(a, slot, common.BigToHash(v))
+}
+
+// slotOf names a storage slot of an emulated contract. Only the message
+// passer uses the real solidity layout, it is the one that gets proven.
+func slotOf(name string, keys ...[]byte) common.Hash {
+	return crypto.Keccak256Hash(append([][]byte{[]byte(name)}, keys...)...)
+}
+
+// ERC20 storage, shared by every emulated token.
+
+var errInsufficientAllowance = errors.New("ERC20: insufficient allowance")
+
+func balanceSlot(owner common.Address) common.Hash {
+	return slotOf("balance", owner[:])
+}
+
+func allowanceSlot(owner, spender common.Address) common.Hash {
+	return slotOf("allowance", owner[:], spender[:])
+}
+
+func (s *state) tokenBalance(token, owner common.Address) *big.Int {
+	return s.getBig(token, balanceSlot(owner))
+}
+
+func (s *state) mintToken(token, to common.Address, amount *big.Int) {
+	s.setBig(token, balanceSlot(to), new(big.Int).Add(s.tokenBalance(token, to), amount))
+	s.setBig(token, slotOf("totalSupply"), new(big.Int).Add(s.getBig(token, slotOf("totalSupply")), amount))
+}
+
+func (s *state) burnToken(token, from common.Address, amount *big.Int) error {
+	b := s.tokenBalance(token, from)
+	if b.Cmp(amount) < 0 {
+		return errors.New("ERC20: burn amount exceeds balance")
+	}
+	s.setBig(token, balanceSlot(from), b.Sub(b, amount))
+	s.setBig(token, slotOf("totalSupply"), new(big.Int).Sub(s.getBig(token, slotOf("totalSupply")), amount))
+	return nil
+}
+
+func (s *state) transferToken(token, from, to common.Address, amount *big.Int) error {
+	b := s.tokenBalance(token, from)
+	if b.Cmp(amount) < 0 {
+		return errors.New("ERC20: transfer amount exceeds balance")
+	}
+	s.setBig(token, balanceSlot(from), b.Sub(b, amount))
+	s.setBig(token, balanceSlot(to), new(big.Int).Add(s.tokenBalance(token, to), amount))
+	return nil
+}
+
+func (s *state) allowance(token, owner, spender common.Address) *big.Int {
+	return s.getBig(token, allowanceSlot(owner, spender))
+}
+
+func (s *state) approve(token, owner, spender common.Address, amount *big.Int) {
+	s.setBig(token, allowanceSlot(owner, spender), amount)
+}
+
+// spendAllowance lowers the allowance of spender over the tokens of owner.
+func (s *state) spendAllowance(token, owner, spender common.Address, amount *big.Int) error {
+	allowed := s.allowance(token, owner, spender)
+	if allowed.Cmp(amount) < 0 {
+		return errInsufficientAllowance
+	}
+	s.approve(token, owner, spender, allowed.Sub(allowed, amount))
+	return nil
+}
@@ -0,0 +1,274 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"try_rde/abistr/abijson"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+// ProveBundleVersion is the version of the ProveBundle JSON format, bundles
+// of other versions are rejected.
+const ProveBundleVersion = 1
+
+var (
+	// ErrStaleBundle is returned for a ProveBundle whose output was deleted
+	// or replaced on L1 since it was built, it must be built again.
+	ErrStaleBundle = errors.New("output of the prove bundle is no longer on l1")
+	// ErrAlreadyProven is returned for a ProveBundle of a withdrawal that is
+	// already proven against the same output.
+	ErrAlreadyProven = errors.New("withdrawal is already proven against the output of the bundle")
+	// ErrAlreadyFinalized is returned for a ProveBundle of a finalized
+	// withdrawal.
+	ErrAlreadyFinalized = errors.New("withdrawal is already finalized")
+)
+
+// ProveBundle holds everything proveWithdrawalTransaction needs to prove one
+// withdrawal. It is built on a machine with an L2 archive node and submitted
+// from one that only reaches L1, its JSON encoding is the exchange format:
+// quantities and bytes are hex like in the JSON-RPC API.
+type ProveBundle struct {
+	Version   int            `json:"version"`
+	L1ChainID uint64         `json:"l1ChainId"`
+	Portal    common.Address `json:"portal"`
+
+	// TxHash and LogIndex locate the MessagePassed event of the withdrawal.
+	TxHash         common.Hash      `json:"txHash"`
+	LogIndex       uint             `json:"logIndex"`
+	WithdrawalHash common.Hash      `json:"withdrawalHash"`
+	Withdrawal     BundleWithdrawal `json:"withdrawal"`
+
+	// L2BlockNumber and OutputRoot are the output at L2OutputIndex the proof
+	// was built against.
+	L2OutputIndex   uint64                `json:"l2OutputIndex"`
+	L2BlockNumber   uint64                `json:"l2BlockNumber"`
+	OutputRoot      common.Hash           `json:"outputRoot"`
+	OutputRootProof BundleOutputRootProof `json:"outputRootProof"`
+	WithdrawalProof []hexutil.Bytes       `json:"withdrawalProof"` // storage trie nodes
+}
+
+// BundleWithdrawal is the withdrawal transaction of a ProveBundle.
+type BundleWithdrawal struct {
+	Nonce    *hexutil.Big   `json:"nonce"`
+	Sender   common.Address `json:"sender"`
+	Target   common.Address `json:"target"`
+	MntValue *hexutil.Big   `json:"mntValue"`
+	EthValue *hexutil.Big   `json:"ethValue"`
+	GasLimit *hexutil.Big   `json:"gasLimit"`
+	Data     hexutil.Bytes  `json:"data"`
+}
+
+// BundleOutputRootProof is the output root preimage of a ProveBundle.
+type BundleOutputRootProof struct {
+	Version                  common.Hash `json:"version"`
+	StateRoot                common.Hash `json:"stateRoot"`
+	MessagePasserStorageRoot common.Hash `json:"messagePasserStorageRoot"`
+	LatestBlockhash          common.Hash `json:"latestBlockhash"`
+}
+
+// DecodeProveBundle parses the JSON encoding of a ProveBundle. Unknown fields
+// and versions are rejected rather than silently dropped.
+func DecodeProveBundle(raw []byte) (*ProveBundle, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	b := &ProveBundle{}
+	if err := dec.Decode(b); err != nil {
+		return nil, fmt.Errorf("failed to decode prove bundle: %w", err)
+	}
+	if b.Version != ProveBundleVersion {
+		return nil, fmt.Errorf("unsupported prove bundle version %d, want %d", b.Version, ProveBundleVersion)
+	}
+	return b, nil
+}
+
+// WithdrawalTx is the withdrawal of the bundle as the portal expects it.
+func (b *ProveBundle) WithdrawalTx() (abijson.TypesWithdrawalTransaction, error) {
+	w := b.Withdrawal
+	if w.Nonce == nil || w.MntValue == nil || w.EthValue == nil || w.GasLimit == nil {
+		return abijson.TypesWithdrawalTransaction{}, errors.New("prove bundle withdrawal is missing fields")
+	}
+	return abijson.TypesWithdrawalTransaction{
+		Nonce:    w.Nonce.ToInt(),
+		Sender:   w.Sender,
+		Target:   w.Target,
+		MntValue: w.MntValue.ToInt(),
+		EthValue: w.EthValue.ToInt(),
+		GasLimit: w.GasLimit.ToInt(),
+		Data:     w.Data,
+	}, nil
+}
+
+func (b *ProveBundle) outputRootProof() abijson.TypesOutputRootProof {
+	return abijson.TypesOutputRootProof{
+		Version:                  b.OutputRootProof.Version,
+		StateRoot:                b.OutputRootProof.StateRoot,
+		MessagePasserStorageRoot: b.OutputRootProof.MessagePasserStorageRoot,
+		LatestBlockhash:          b.OutputRootProof.LatestBlockhash,
+	}
+}
+
+func (b *ProveBundle) trieNodes() [][]byte {
+	nodes := make([][]byte, len(b.WithdrawalProof))
+	for i, node := range b.WithdrawalProof {
+		nodes[i] = node
+	}
+	return nodes
+}
+
+// Verify checks the bundle on its own, without any chain: the withdrawal
+// hashes to WithdrawalHash, the output root proof hashes to OutputRoot and
+// the trie nodes prove the withdrawal sent in the message passer storage.
+func (b *ProveBundle) Verify() error {
+	if b.Version != ProveBundleVersion {
+		return fmt.Errorf("unsupported prove bundle version %d, want %d", b.Version, ProveBundleVersion)
+	}
+	wd, err := b.WithdrawalTx()
+	if err != nil {
+		return err
+	}
+	hash, err := txutils.GetWdHash(&wd)
+	if err != nil {
+		return err
+	}
+	if hash != b.WithdrawalHash {
+		return fmt.Errorf("prove bundle withdrawal hashes to %s, not %s", hash, b.WithdrawalHash)
+	}
+	proof := b.outputRootProof()
+	if err := txutils.VerifyOutputRoot(&proof, b.OutputRoot); err != nil {
+		return err
+	}
+	nodes := make([]string, len(b.WithdrawalProof))
+	for i, node := range b.WithdrawalProof {
+		nodes[i] = node.String()
+	}
+	return txutils.VerifyStorageProof(proof.MessagePasserStorageRoot, gethclient.StorageResult{
+		Key:   txutils.StorageSlotOfWithdrawalHash(hash).Hex(),
+		Value: big.NewInt(1),
+		Proof: nodes,
+	})
+}
+
+// BuildProveBundle builds the bundle that proves the withdrawal of the L2 tx
+// against the first output that covers its block. A nil log index picks the
+// only withdrawal of the tx, like Withdrawal. It needs an L2 node that still
+// serves eth_getProof at the output block.
+func (c *Client) BuildProveBundle(ctx context.Context, txHash common.Hash, logIndex *uint) (*ProveBundle, error) {
+	receipt, ev, err := c.withdrawal(ctx, txHash, logIndex)
+	if err != nil {
+		return nil, err
+	}
+	params, header, err := c.proveParameters(ctx, receipt, ev)
+	if err != nil {
+		return nil, err
+	}
+	root, err := txutils.ComputeL2OutputRoot(&params.OutputRootProof)
+	if err != nil {
+		return nil, err
+	}
+	proof := make([]hexutil.Bytes, len(params.WithdrawalProof))
+	for i, node := range params.WithdrawalProof {
+		proof[i] = node
+	}
+	return &ProveBundle{
+		Version:        ProveBundleVersion,
+		L1ChainID:      c.L1ChainID.Uint64(),
+		Portal:         c.Profile.Addresses.L1OptimismPortal,
+		TxHash:         txHash,
+		LogIndex:       ev.Raw.Index,
+		WithdrawalHash: ev.WithdrawalHash,
+		Withdrawal: BundleWithdrawal{
+			Nonce:    (*hexutil.Big)(ev.Nonce),
+			Sender:   ev.Sender,
+			Target:   ev.Target,
+			MntValue: (*hexutil.Big)(ev.MntValue),
+			EthValue: (*hexutil.Big)(ev.EthValue),
+			GasLimit: (*hexutil.Big)(ev.GasLimit),
+			Data:     ev.Data,
+		},
+		L2OutputIndex: params.L2OutputIndex.Uint64(),
+		L2BlockNumber: header.Number.Uint64(),
+		OutputRoot:    common.Hash(root),
+		OutputRootProof: BundleOutputRootProof{
+			Version:                  params.OutputRootProof.Version,
+			StateRoot:                params.OutputRootProof.StateRoot,
+			MessagePasserStorageRoot: params.OutputRootProof.MessagePasserStorageRoot,
+			LatestBlockhash:          params.OutputRootProof.LatestBlockhash,
+		},
+		WithdrawalProof: proof,
+	}, nil
+}
+
+// CheckProveBundle verifies the bundle and checks that it still fits L1: it
+// was built for this chain and portal, its output is still proposed, and the
+// withdrawal is neither finalized nor proven against that output. It only
+// talks to L1.
+func (c *Client) CheckProveBundle(ctx context.Context, b *ProveBundle) error {
+	if err := b.Verify(); err != nil {
+		return err
+	}
+	if b.L1ChainID != c.L1ChainID.Uint64() {
+		return fmt.Errorf("prove bundle is for l1 chain %d, connected to %d", b.L1ChainID, c.L1ChainID)
+	}
+	if b.Portal != c.Profile.Addresses.L1OptimismPortal {
+		return fmt.Errorf("prove bundle is for portal %s, profile has %s", b.Portal, c.Profile.Addresses.L1OptimismPortal)
+	}
+
+	opts := callOpts(ctx)
+	next, err := c.Oracle.NextOutputIndex(opts)
+	if err != nil {
+		return fmt.Errorf("failed to get next output index: %w", err)
+	}
+	index := new(big.Int).SetUint64(b.L2OutputIndex)
+	if index.Cmp(next) >= 0 {
+		return fmt.Errorf("%w: output %d was deleted", ErrStaleBundle, b.L2OutputIndex)
+	}
+	output, err := c.Oracle.GetL2Output(opts, index)
+	if err != nil {
+		return fmt.Errorf("failed to get l2 output %d: %w", b.L2OutputIndex, err)
+	}
+	if output.OutputRoot != b.OutputRoot || output.L2BlockNumber.Uint64() != b.L2BlockNumber {
+		return fmt.Errorf("%w: output %d is now %s at l2 block %d", ErrStaleBundle, b.L2OutputIndex, common.Hash(output.OutputRoot), output.L2BlockNumber)
+	}
+
+	finalized, err := c.Portal.FinalizedWithdrawals(opts, b.WithdrawalHash)
+	if err != nil {
+		return fmt.Errorf("failed to get finalized withdrawal: %w", err)
+	}
+	if finalized {
+		return fmt.Errorf("%w: %s", ErrAlreadyFinalized, b.WithdrawalHash)
+	}
+	proven, err := c.Portal.ProvenWithdrawals(opts, b.WithdrawalHash)
+	if err != nil {
+		return fmt.Errorf("failed to get proven withdrawal: %w", err)
+	}
+	if proven.Timestamp.Sign() != 0 && proven.OutputRoot == b.OutputRoot {
+		return fmt.Errorf("%w: %s", ErrAlreadyProven, b.WithdrawalHash)
+	}
+	return nil
+}
+
+// SubmitProveBundle checks the bundle with CheckProveBundle and sends the
+// proveWithdrawalTransaction it holds. It only talks to L1, so it works on a
+// client of NewL1Client. Hooks.Proved is not run, there is no L2 receipt.
+func (c *Client) SubmitProveBundle(ctx context.Context, s signer.Signer, b *ProveBundle) (*types.Transaction, error) {
+	if err := c.CheckProveBundle(ctx, b); err != nil {
+		return nil, err
+	}
+	wd, err := b.WithdrawalTx()
+	if err != nil {
+		return nil, err
+	}
+	l1 := c.l1()
+	return l1.send(l1.transactor(ctx, s), c.Profile.Addresses.L1OptimismPortal, abijson.L1OptimismPortalMetaData, "proveWithdrawalTransaction",
+		wd, new(big.Int).SetUint64(b.L2OutputIndex), b.outputRootProof(), b.trieNodes())
+}
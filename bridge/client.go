@@ -0,0 +1,176 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"try_rde/abistr/abijson"
+	"try_rde/config"
+	"try_rde/txutils"
+)
+
+// Backend is what the client needs from a chain node. *ethclient.Client
+// satisfies it, so does the in-memory chain of package bridgetest.
+type Backend interface {
+	bind.ContractBackend
+	txutils.ReceiptBackend
+	txutils.FeeBackend
+	ChainID(ctx context.Context) (*big.Int, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	Close()
+}
+
+// Client bundles the L1/L2 connections and the contract bindings of one
+// network profile.
+type Client struct {
+	Profile *config.Profile
+
+	L1     Backend
+	L2     Backend
+	L2Geth txutils.ProofBackend // eth_getProof on L2
+
+	L1Bridge      *abijson.L1StandardBridge
+	L2Bridge      *abijson.L2StandardBridge
+	Portal        *abijson.L1OptimismPortal
+	Oracle        *abijson.L2OutputOracleProxy
+	MessagePasser *abijson.L2ToL1MessagePasser
+
+	L1ChainID *big.Int
+	L2ChainID *big.Int
+
+	// L1Nonces and L2Nonces hand out the nonces of every tx the client
+	// sends, so approve+deposit sequences and parallel sends don't collide.
+	L1Nonces *txutils.NonceManager
+	L2Nonces *txutils.NonceManager
+	// L1Fees and L2Fees estimate the gas limit and fees of every tx the
+	// client sends, tune their margin and pricing before sending.
+	L1Fees *txutils.FeeEstimator
+	L2Fees *txutils.FeeEstimator
+
+	Hooks Hooks
+}
+
+// Dial connects to both chains of the profile and binds all bridge contracts.
+func Dial(ctx context.Context, profile *config.Profile) (*Client, error) {
+	l1, err := rpc.DialContext(ctx, profile.L1.RPC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial l1: %w", err)
+	}
+	l2, err := rpc.DialContext(ctx, profile.L2.RPC)
+	if err != nil {
+		l1.Close()
+		return nil, fmt.Errorf("failed to dial l2: %w", err)
+	}
+	c, err := NewClientFromRPC(ctx, profile, l1, l2)
+	if err != nil {
+		l1.Close()
+		l2.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClientFromRPC binds all bridge contracts on already dialed rpc
+// connections, e.g. ones recorded or replayed by package rpcreplay.
+func NewClientFromRPC(ctx context.Context, profile *config.Profile, l1, l2 *rpc.Client) (*Client, error) {
+	return NewClient(ctx, profile, ethclient.NewClient(l1), ethclient.NewClient(l2), gethclient.New(l2))
+}
+
+// NewClient binds all bridge contracts of the profile on already connected
+// backends. l2Proof serves eth_getProof on L2.
+func NewClient(ctx context.Context, profile *config.Profile, l1, l2 Backend, l2Proof txutils.ProofBackend) (*Client, error) {
+	c, err := NewL1Client(ctx, profile, l1)
+	if err != nil {
+		return nil, err
+	}
+	c.L2 = l2
+	c.L2Geth = l2Proof
+	c.L2Nonces = txutils.NewNonceManager(c.L2)
+	c.L2Fees = txutils.NewFeeEstimator(c.L2)
+	if c.L2ChainID, err = checkChainID(ctx, c.L2, profile.L2.ChainID); err != nil {
+		return nil, fmt.Errorf("l2: %w", err)
+	}
+
+	addrs := profile.Addresses
+	if c.L2Bridge, err = abijson.NewL2StandardBridge(addrs.L2StandardBridge, c.L2); err != nil {
+		return nil, err
+	}
+	if c.MessagePasser, err = abijson.NewL2ToL1MessagePasser(addrs.L2ToL1MessagePasser, c.L2); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// DialL1 connects to the L1 chain of the profile only, see NewL1Client.
+func DialL1(ctx context.Context, profile *config.Profile) (*Client, error) {
+	l1, err := rpc.DialContext(ctx, profile.L1.RPC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial l1: %w", err)
+	}
+	c, err := NewL1Client(ctx, profile, ethclient.NewClient(l1))
+	if err != nil {
+		l1.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewL1Client binds the L1 contracts of the profile only, for machines
+// without L2 access. The L2 fields stay nil, so only the methods that talk
+// to L1 alone may be used, e.g. SubmitProveBundle.
+func NewL1Client(ctx context.Context, profile *config.Profile, l1 Backend) (*Client, error) {
+	c := &Client{
+		Profile: profile,
+		L1:      l1,
+	}
+	c.L1Nonces = txutils.NewNonceManager(c.L1)
+	c.L1Fees = txutils.NewFeeEstimator(c.L1)
+
+	var err error
+	if c.L1ChainID, err = checkChainID(ctx, c.L1, profile.L1.ChainID); err != nil {
+		return nil, fmt.Errorf("l1: %w", err)
+	}
+
+	addrs := profile.Addresses
+	if c.L1Bridge, err = abijson.NewL1StandardBridge(addrs.L1StandardBridge, c.L1); err != nil {
+		return nil, err
+	}
+	if c.Portal, err = abijson.NewL1OptimismPortal(addrs.L1OptimismPortal, c.L1); err != nil {
+		return nil, err
+	}
+	if c.Oracle, err = abijson.NewL2OutputOracleProxy(addrs.L2OutputOracleProxy, c.L1); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close releases both rpc connections.
+func (c *Client) Close() {
+	c.L1.Close()
+	if c.L2 != nil {
+		c.L2.Close()
+	}
+}
+
+func checkChainID(ctx context.Context, cli Backend, expected uint64) (*big.Int, error) {
+	chainID, err := cli.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain id: %w", err)
+	}
+	if expected != 0 && chainID.Uint64() != expected {
+		return nil, fmt.Errorf("chain id mismatch, profile says %d but node reports %d", expected, chainID)
+	}
+	return chainID, nil
+}
+
+func callOpts(ctx context.Context) *bind.CallOpts {
+	return &bind.CallOpts{Context: ctx}
+}
@@ -0,0 +1,55 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/config"
+	"try_rde/signer"
+)
+
+// TransferArgs are the arguments shared by every deposit and withdrawal.
+type TransferArgs struct {
+	// To receives the funds on the other chain, the zero address means the sender.
+	To          common.Address
+	Amount      *big.Int
+	MinGasLimit uint32
+	ExtraData   []byte
+}
+
+func (a *TransferArgs) check() error {
+	if a.Amount == nil || a.Amount.Sign() <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if a.ExtraData == nil {
+		a.ExtraData = []byte{}
+	}
+	return nil
+}
+
+func (a *TransferArgs) recipient(from common.Address) common.Address {
+	if a.To == (common.Address{}) {
+		return from
+	}
+	return a.To
+}
+
+// DepositETH bridges ETH from L1 to L2 through L1StandardBridge.depositETHTo.
+func (c *Client) DepositETH(ctx context.Context, s signer.Signer, args TransferArgs) (*types.Transaction, error) {
+	return c.Deposit(ctx, s, AssetETH, config.Token{}, args)
+}
+
+// DepositMNT approves the L1 MNT token for the bridge if needed and bridges
+// MNT through L1StandardBridge.depositMNTTo.
+func (c *Client) DepositMNT(ctx context.Context, s signer.Signer, args TransferArgs) (*types.Transaction, error) {
+	return c.Deposit(ctx, s, AssetMNT, config.Token{}, args)
+}
+
+// DepositERC20 approves l1Token for the bridge if needed and bridges it to
+// l2Token through L1StandardBridge.depositERC20To.
+func (c *Client) DepositERC20(ctx context.Context, s signer.Signer, l1Token, l2Token common.Address, args TransferArgs) (*types.Transaction, error) {
+	return c.Deposit(ctx, s, AssetERC20, config.Token{L1: l1Token, L2: l2Token}, args)
+}
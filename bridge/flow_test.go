@@ -0,0 +1,497 @@
+package bridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/config"
+	"try_rde/rpcreplay"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+func ether(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.Ether))
+}
+
+func mined(t *testing.T, backend txutils.ReceiptBackend, tx *types.Transaction) *types.Receipt {
+	receipt, err := txutils.WaitForReceipt(context.Background(), backend, tx.Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+	return receipt
+}
+
+func Test_DepositAndWithdrawETH(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	l2ETH := n.Profile.Addresses.L2ETH
+
+	tx, err := c.DepositETH(ctx, s, bridge.TransferArgs{Amount: ether(2)})
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	deposits, err := c.Deposits(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.NoError(t, c.WaitForDeposit(ctx, deposits[0], txutils.WaitOpts{}))
+	require.NotNil(t, deposits[0].ETHBridgeFinalized)
+	ast.Equal(s.Address(), deposits[0].ETHBridgeFinalized.To)
+	ast.Equal(ether(2), n.L2.TokenBalance(l2ETH, s.Address()))
+
+	tx, err = c.WithdrawETH(ctx, s, bridge.TransferArgs{Amount: ether(1)})
+	require.NoError(t, err)
+	withdrawal := mined(t, c.L2, tx).TxHash
+	ast.Equal(ether(1), n.L2.TokenBalance(l2ETH, s.Address()))
+
+	_, err = c.ProveWithdrawal(ctx, s, withdrawal)
+	ast.ErrorIs(err, bridge.ErrNoOutputYet)
+	require.NoError(t, n.ProposeOutput())
+
+	tx, err = c.ProveWithdrawal(ctx, s, withdrawal)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	st, err := c.WithdrawalStatus(ctx, withdrawal)
+	require.NoError(t, err)
+	ast.True(st.Proven)
+	ast.False(st.Finalized)
+
+	_, err = c.FinalizeWithdrawal(ctx, s, withdrawal)
+	ast.ErrorContains(err, "finalization period has not elapsed")
+
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	before, err := n.L1.BalanceAt(ctx, s.Address(), nil)
+	require.NoError(t, err)
+	tx, err = c.FinalizeWithdrawal(ctx, s, withdrawal)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+
+	st, err = c.WithdrawalStatus(ctx, withdrawal)
+	require.NoError(t, err)
+	ast.True(st.Finalized)
+	after, err := n.L1.BalanceAt(ctx, s.Address(), nil)
+	require.NoError(t, err)
+	ast.Equal(ether(1), new(big.Int).Sub(after, before), "the fake chain charges no gas")
+
+	_, err = c.FinalizeWithdrawal(ctx, s, withdrawal)
+	ast.ErrorContains(err, "already been finalized")
+}
+
+// Test_ReplayedWithdrawal runs an ETH withdrawal from deposit to finalization
+// over JSON-RPC. The committed fixture replays it without the network, record
+// it again with RDE_RPC_MODE=record after changing the calls the bridge makes.
+func Test_ReplayedWithdrawal(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	rpcs := rpcreplay.ForTest(t, "testdata")
+	profile := bridgetest.Profile()
+	// a fixed key, so every run signs the same txs
+	key, err := crypto.HexToECDSA("8f2a55949038a9610f50fb23b5883af3b4ecb3c3bb792cbcefbd1542c692be63")
+	require.NoError(t, err)
+	s := signer.NewPrivateKeySigner(key)
+	var n *bridgetest.Network
+	if rpcs.Mode() != rpcreplay.ModeReplay {
+		n = bridgetest.NewNetwork()
+		n.L1.Fund(s.Address(), bridgetest.AccountBalance)
+		profile.L1.RPC, profile.L2.RPC = n.L1.Serve(t), n.L2.Serve(t)
+	}
+	// onNetwork drives the network, replaying there is none
+	onNetwork := func(fn func(n *bridgetest.Network)) {
+		if n != nil {
+			fn(n)
+		}
+	}
+	l1, err := rpcs.Dial(ctx, "l1", profile.L1.RPC)
+	require.NoError(t, err)
+	l2, err := rpcs.Dial(ctx, "l2", profile.L2.RPC)
+	require.NoError(t, err)
+	c, err := bridge.NewClientFromRPC(ctx, profile, l1, l2)
+	require.NoError(t, err)
+	defer c.Close()
+
+	tx, err := c.DepositETH(ctx, s, bridge.TransferArgs{Amount: ether(2)})
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	deposits, err := c.Deposits(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.NoError(t, c.WaitForDeposit(ctx, deposits[0], txutils.WaitOpts{}))
+
+	tx, err = c.WithdrawETH(ctx, s, bridge.TransferArgs{Amount: ether(1)})
+	require.NoError(t, err)
+	withdrawal := mined(t, c.L2, tx).TxHash
+	onNetwork(func(n *bridgetest.Network) { require.NoError(t, n.ProposeOutput()) })
+	tx, err = c.ProveWithdrawal(ctx, s, withdrawal)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+
+	onNetwork(func(n *bridgetest.Network) { n.AdvanceTime(bridgetest.FinalizationPeriod) })
+	before, err := c.L1.BalanceAt(ctx, s.Address(), nil)
+	require.NoError(t, err)
+	tx, err = c.FinalizeWithdrawal(ctx, s, withdrawal)
+	require.NoError(t, err)
+	ast.Equal(types.ReceiptStatusSuccessful, mined(t, c.L1, tx).Status)
+	st, err := c.WithdrawalStatus(ctx, withdrawal)
+	require.NoError(t, err)
+	ast.True(st.Finalized)
+	after, err := c.L1.BalanceAt(ctx, s.Address(), nil)
+	require.NoError(t, err)
+	ast.Equal(ether(1), new(big.Int).Sub(after, before))
+}
+
+func Test_DepositAndWithdrawMNTAndERC20(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	mnt := n.Profile.Addresses.L1MantleToken
+	tk, err := n.Profile.Token(bridgetest.TestToken)
+	require.NoError(t, err)
+	n.L1.MintToken(mnt, s.Address(), ether(10))
+	n.L1.MintToken(tk.L1, s.Address(), ether(10))
+
+	tx, err := c.DepositMNT(ctx, s, bridge.TransferArgs{Amount: ether(3)})
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	deposits, err := c.Deposits(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.NoError(t, c.WaitForDeposit(ctx, deposits[0], txutils.WaitOpts{}))
+	ast.NotNil(deposits[0].MNTBridgeFinalized)
+	balance, err := n.L2.BalanceAt(ctx, s.Address(), nil)
+	require.NoError(t, err)
+	ast.Equal(ether(103), balance)
+
+	_, err = c.DepositERC20(ctx, s, common.Address{}, tk.L2, bridge.TransferArgs{Amount: ether(4)})
+	ast.ErrorContains(err, "token pair needs both addresses")
+	_, err = c.QuoteDeposit(ctx, s.Address(), bridge.AssetERC20, config.Token{L1: tk.L1}, bridge.TransferArgs{Amount: ether(4)})
+	ast.ErrorContains(err, "token pair needs both addresses")
+	tx, err = c.DepositERC20(ctx, s, tk.L1, tk.L2, bridge.TransferArgs{Amount: ether(4)})
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	deposits, err = c.Deposits(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.NoError(t, c.WaitForDeposit(ctx, deposits[0], txutils.WaitOpts{}))
+	require.NotNil(t, deposits[0].DepositFinalized)
+	ast.Equal(tk.L1, deposits[0].DepositFinalized.L1Token)
+	ast.Equal(ether(4), n.L2.TokenBalance(tk.L2, s.Address()))
+
+	var withdrawals []common.Hash
+	tx, err = c.WithdrawMNT(ctx, s, bridge.TransferArgs{Amount: ether(2)})
+	require.NoError(t, err)
+	withdrawals = append(withdrawals, mined(t, c.L2, tx).TxHash)
+	_, err = c.WithdrawERC20(ctx, s, common.Address{}, bridge.TransferArgs{Amount: ether(1)})
+	ast.ErrorContains(err, "l2 token address must not be zero")
+	tx, err = c.WithdrawERC20(ctx, s, tk.L2, bridge.TransferArgs{Amount: ether(1)})
+	require.NoError(t, err)
+	withdrawals = append(withdrawals, mined(t, c.L2, tx).TxHash)
+
+	require.NoError(t, n.ProposeOutput())
+	for _, w := range withdrawals {
+		tx, err = c.ProveWithdrawal(ctx, s, w)
+		require.NoError(t, err)
+		mined(t, c.L1, tx)
+	}
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	for _, w := range withdrawals {
+		tx, err = c.FinalizeWithdrawal(ctx, s, w)
+		require.NoError(t, err)
+		mined(t, c.L1, tx)
+	}
+	ast.Equal(ether(9), n.L1.TokenBalance(mnt, s.Address()))
+	ast.Equal(ether(7), n.L1.TokenBalance(tk.L1, s.Address()))
+}
+
+func Test_BatchedWithdrawals(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	targets := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+
+	tx, err := n.Multicall(ctx, s, n.WithdrawalCall(targets[0], nil), n.WithdrawalCall(targets[1], nil))
+	require.NoError(t, err)
+	batch := mined(t, c.L2, tx).TxHash
+	_, evs, err := c.Withdrawals(ctx, batch)
+	require.NoError(t, err)
+	require.Len(t, evs, 2)
+	for i, ev := range evs {
+		ast.Equal(bridgetest.Multicall, ev.Sender)
+		ast.Equal(targets[i], ev.Target)
+	}
+	ast.NotEqual(evs[0].Raw.Index, evs[1].Raw.Index)
+	_, _, err = c.Withdrawal(ctx, batch)
+	ast.ErrorIs(err, bridge.ErrSeveralWithdrawals)
+	_, _, err = c.WithdrawalAt(ctx, batch, 99)
+	ast.ErrorContains(err, "no MessagePassed event at log index 99")
+	_, ev, err := c.WithdrawalAt(ctx, batch, evs[1].Raw.Index)
+	require.NoError(t, err)
+	ast.Equal(evs[1].WithdrawalHash, ev.WithdrawalHash)
+
+	require.NoError(t, n.ProposeOutput())
+	_, err = c.ProveWithdrawal(ctx, s, batch)
+	ast.ErrorIs(err, bridge.ErrSeveralWithdrawals)
+	// the first one is proven on its own, only the second one is left
+	tx, err = c.ProveWithdrawalAt(ctx, s, batch, evs[0].Raw.Index)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	txs, err := c.ProveWithdrawals(ctx, s, batch)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	mined(t, c.L1, txs[0])
+	for _, ev := range evs {
+		st, err := c.WithdrawalStatusAt(ctx, batch, ev.Raw.Index)
+		require.NoError(t, err)
+		ast.Equal(common.Hash(ev.WithdrawalHash), st.WithdrawalHash)
+		ast.True(st.Proven)
+	}
+
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	tx, err = c.FinalizeWithdrawalAt(ctx, s, batch, evs[1].Raw.Index)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	// the first one is finalized, the second one was already
+	txs, err = c.FinalizeWithdrawals(ctx, s, batch)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	mined(t, c.L1, txs[0])
+	for _, ev := range evs {
+		st, err := c.WithdrawalStatusAt(ctx, batch, ev.Raw.Index)
+		require.NoError(t, err)
+		ast.True(st.Finalized)
+	}
+}
+
+func Test_ProveBundle(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	tx, err := n.Multicall(ctx, s, n.WithdrawalCall(common.HexToAddress("0x01"), nil))
+	require.NoError(t, err)
+	withdrawal := mined(t, c.L2, tx).TxHash
+
+	_, err = c.BuildProveBundle(ctx, withdrawal, nil)
+	ast.ErrorIs(err, bridge.ErrNoOutputYet)
+	require.NoError(t, n.ProposeOutput())
+	b, err := c.BuildProveBundle(ctx, withdrawal, nil)
+	require.NoError(t, err)
+	ast.NoError(b.Verify())
+
+	// the bundle goes through its JSON encoding to a machine without L2
+	raw, err := json.Marshal(b)
+	require.NoError(t, err)
+	imported, err := bridge.DecodeProveBundle(raw)
+	require.NoError(t, err)
+	ast.Equal(b, imported)
+	_, err = bridge.DecodeProveBundle([]byte(`{"version":2}`))
+	ast.ErrorContains(err, "unsupported prove bundle version 2")
+	l1Only, err := bridge.NewL1Client(ctx, n.Profile, n.L1)
+	require.NoError(t, err)
+
+	tampered := *imported
+	tampered.Withdrawal.Target = common.HexToAddress("0xbad")
+	ast.ErrorContains(tampered.Verify(), "prove bundle withdrawal hashes to")
+	tampered = *imported
+	tampered.OutputRoot = common.Hash{}
+	ast.ErrorIs(tampered.Verify(), txutils.ErrOutputRootMismatch)
+	tampered = *imported
+	tampered.WithdrawalProof = imported.WithdrawalProof[:len(imported.WithdrawalProof)-1]
+	ast.ErrorIs(tampered.Verify(), txutils.ErrInvalidProof)
+
+	// an output deleted after the bundle was built makes it stale
+	require.NoError(t, n.DeleteOutputs(b.L2OutputIndex))
+	_, err = l1Only.SubmitProveBundle(ctx, s, imported)
+	ast.ErrorIs(err, bridge.ErrStaleBundle)
+	n.L2.Mine()
+	require.NoError(t, n.ProposeOutput())
+	_, err = l1Only.SubmitProveBundle(ctx, s, imported)
+	ast.ErrorIs(err, bridge.ErrStaleBundle)
+
+	b, err = c.BuildProveBundle(ctx, withdrawal, nil)
+	require.NoError(t, err)
+	tx, err = l1Only.SubmitProveBundle(ctx, s, b)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	st, err := c.WithdrawalStatus(ctx, withdrawal)
+	require.NoError(t, err)
+	ast.True(st.Proven)
+	_, err = l1Only.SubmitProveBundle(ctx, s, b)
+	ast.ErrorIs(err, bridge.ErrAlreadyProven)
+
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	tx, err = c.FinalizeWithdrawal(ctx, s, withdrawal)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	_, err = l1Only.SubmitProveBundle(ctx, s, b)
+	ast.ErrorIs(err, bridge.ErrAlreadyFinalized)
+}
+
+func Test_DepositMNTWithPermit(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	sponsor := bridgetest.Account(t, n)
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	// the holder has MNT but no ETH to pay for an L1 tx
+	holder := signer.NewPrivateKeySigner(key)
+	mnt := n.Profile.Addresses.L1MantleToken
+	n.L1.MintToken(mnt, holder.Address(), ether(10))
+	deadline := time.Now().Add(time.Hour)
+	pulls, err := bridge.NewPermitPullDir(t.TempDir())
+	require.NoError(t, err)
+
+	p, err := c.SignMNTPermit(ctx, holder, sponsor.Address(), ether(5), deadline)
+	require.NoError(t, err)
+	ast.NoError(p.Verify())
+	tampered := *p
+	tampered.Value = ether(10)
+	ast.ErrorContains(tampered.Verify(), "not its owner")
+	_, err = c.DepositMNTWithPermit(ctx, bridgetest.Account(t, n), p, bridge.TransferArgs{Amount: ether(3)}, pulls)
+	ast.ErrorContains(err, "must be the spender")
+	_, err = c.DepositMNTWithPermit(ctx, sponsor, p, bridge.TransferArgs{Amount: ether(6)}, pulls)
+	ast.ErrorContains(err, "permit allows")
+
+	tx, err := c.DepositMNTWithPermit(ctx, sponsor, p, bridge.TransferArgs{Amount: ether(3)}, pulls)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	deposits, err := c.Deposits(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.NoError(t, c.WaitForDeposit(ctx, deposits[0], txutils.WaitOpts{}))
+	require.NotNil(t, deposits[0].MNTBridgeFinalized)
+	ast.Equal(holder.Address(), deposits[0].MNTBridgeFinalized.To)
+	balance, err := n.L2.BalanceAt(ctx, holder.Address(), nil)
+	require.NoError(t, err)
+	ast.Equal(ether(3), balance)
+	ast.Equal(ether(7), n.L1.TokenBalance(mnt, holder.Address()))
+	ast.Zero(n.L1.TokenBalance(mnt, sponsor.Address()).Sign())
+	nonce, err := c.L1.PendingNonceAt(ctx, holder.Address())
+	require.NoError(t, err)
+	ast.Equal(uint64(0), nonce, "the holder never sent an L1 tx")
+
+	// the rest of the allowance is spent without submitting the used permit again
+	tx, err = c.DepositMNTWithPermit(ctx, sponsor, p, bridge.TransferArgs{Amount: ether(2)}, pulls)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	ast.Equal(ether(5), n.L1.TokenBalance(mnt, holder.Address()))
+	_, err = c.SubmitPermit(ctx, sponsor, p)
+	ast.ErrorContains(err, "ERC20Permit: invalid signature", "the permit nonce was used")
+
+	// a permit for the bridge saves the holder the approval of their own deposit
+	p, err = c.SignMNTPermit(ctx, holder, n.Profile.Addresses.L1StandardBridge, ether(1), deadline)
+	require.NoError(t, err)
+	tx, err = c.SubmitPermit(ctx, sponsor, p)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	q, err := c.QuoteDeposit(ctx, holder.Address(), bridge.AssetMNT, config.Token{}, bridge.TransferArgs{Amount: ether(1)})
+	require.NoError(t, err)
+	ast.Nil(q.Approve)
+}
+
+// refusingBackend refuses every tx sent to the address.
+type refusingBackend struct {
+	bridge.Backend
+	to common.Address
+}
+
+func (b refusingBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if tx.To() != nil && *tx.To() == b.to {
+		return errors.New("node down")
+	}
+	return b.Backend.SendTransaction(ctx, tx)
+}
+
+// forgetfulPulls fails to delete pulls while forget is set, as if the
+// process died right after the deposit was mined.
+type forgetfulPulls struct {
+	bridge.PermitPulls
+	forget bool
+}
+
+func (f *forgetfulPulls) Delete(owner common.Address, nonce *big.Int) error {
+	if f.forget {
+		return errors.New("process died")
+	}
+	return f.PermitPulls.Delete(owner, nonce)
+}
+
+func Test_DepositMNTWithPermitResumes(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	sponsor := bridgetest.Account(t, n)
+	holder := bridgetest.Key(t)
+	mnt := n.Profile.Addresses.L1MantleToken
+	n.L1.MintToken(mnt, holder.Address(), ether(10))
+	// MNT of the sponsor's own, never to be deposited for the holder
+	n.L1.MintToken(mnt, sponsor.Address(), ether(4))
+	dir, err := bridge.NewPermitPullDir(t.TempDir())
+	require.NoError(t, err)
+	pulls := &forgetfulPulls{PermitPulls: dir}
+	p, err := c.SignMNTPermit(ctx, holder, sponsor.Address(), ether(5), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	l2Balance := func() *big.Int {
+		balance, err := n.L2.BalanceAt(ctx, holder.Address(), nil)
+		require.NoError(t, err)
+		return balance
+	}
+
+	// the MNT is pulled to the sponsor, then the deposit fails
+	down, err := bridge.NewL1Client(ctx, n.Profile, refusingBackend{Backend: n.L1, to: n.Profile.Addresses.L1StandardBridge})
+	require.NoError(t, err)
+	_, err = down.DepositMNTWithPermit(ctx, sponsor, p, bridge.TransferArgs{Amount: ether(3)}, pulls)
+	ast.ErrorContains(err, "node down")
+	ast.Equal(ether(7), n.L1.TokenBalance(mnt, holder.Address()))
+	ast.Equal(ether(7), n.L1.TokenBalance(mnt, sponsor.Address()))
+	pull, err := pulls.Get(holder.Address(), p.Nonce)
+	require.NoError(t, err)
+	require.NotNil(t, pull)
+	ast.Equal(ether(3), pull.Amount)
+
+	_, err = c.DepositMNTWithPermit(ctx, sponsor, p, bridge.TransferArgs{Amount: ether(4)}, pulls)
+	ast.ErrorContains(err, "resume with that amount")
+
+	// the retry deposits exactly the pulled MNT without pulling again, the
+	// process dies before it forgets the pull
+	pulls.forget = true
+	tx, err := c.DepositMNTWithPermit(ctx, sponsor, p, bridge.TransferArgs{Amount: ether(3)}, pulls)
+	ast.ErrorContains(err, "process died")
+	ast.Equal(ether(7), n.L1.TokenBalance(mnt, holder.Address()))
+	ast.Equal(ether(4), n.L1.TokenBalance(mnt, sponsor.Address()), "the sponsor's own MNT stays")
+
+	// the next retry finds the deposit mined
+	pulls.forget = false
+	again, err := c.DepositMNTWithPermit(ctx, sponsor, p, bridge.TransferArgs{Amount: ether(3)}, pulls)
+	require.NoError(t, err)
+	require.NotNil(t, again)
+	deposits, err := c.Deposits(ctx, again.Hash())
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.NoError(t, c.WaitForDeposit(ctx, deposits[0], txutils.WaitOpts{}))
+	ast.Equal(ether(3), l2Balance())
+	ast.Equal(ether(7), n.L1.TokenBalance(mnt, holder.Address()))
+	ast.Equal(ether(4), n.L1.TokenBalance(mnt, sponsor.Address()))
+	pull, err = pulls.Get(holder.Address(), p.Nonce)
+	require.NoError(t, err)
+	ast.Nil(pull)
+	require.NotNil(t, tx)
+	ast.Equal(tx.Hash(), again.Hash(), "no second deposit")
+
+	// with the pull forgotten, the rest of the allowance is a new deposit
+	tx, err = c.DepositMNTWithPermit(ctx, sponsor, p, bridge.TransferArgs{Amount: ether(2)}, pulls)
+	require.NoError(t, err)
+	mined(t, c.L1, tx)
+	ast.Equal(ether(5), n.L1.TokenBalance(mnt, holder.Address()))
+	ast.Equal(ether(4), n.L1.TokenBalance(mnt, sponsor.Address()))
+}
@@ -0,0 +1,18 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Hooks observe the bridge txs the client sends, e.g. to count them. They
+// run after the tx was accepted by the node, nil hooks are skipped.
+type Hooks struct {
+	Deposited func(ctx context.Context, asset Asset, tx *types.Transaction)
+	Withdrew  func(ctx context.Context, asset Asset, tx *types.Transaction)
+	// Proved and Finalized get the receipt of the L2 tx that started the
+	// withdrawal.
+	Proved    func(ctx context.Context, withdrawal *types.Receipt, tx *types.Transaction)
+	Finalized func(ctx context.Context, withdrawal *types.Receipt, tx *types.Transaction)
+}
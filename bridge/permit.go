@@ -0,0 +1,367 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"try_rde/abistr/abijson"
+	"try_rde/config"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+// permitTypehash is the EIP-2612 type hash of a permit.
+var permitTypehash = crypto.Keccak256Hash([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+
+// Permit is an EIP-2612 permit of the L1 MNT token: Owner allows Spender to
+// spend Value of its MNT until Deadline, without sending a tx. It is signed
+// with the holder's key alone, so it can be built on a networked machine and
+// signed offline.
+type Permit struct {
+	Owner           common.Address `json:"owner"`
+	Spender         common.Address `json:"spender"`
+	Value           *big.Int       `json:"value"`
+	Nonce           *big.Int       `json:"nonce"`
+	Deadline        *big.Int       `json:"deadline"` // unix time
+	DomainSeparator common.Hash    `json:"domainSeparator"`
+	V               uint8          `json:"v"` // 27 or 28 once signed
+	R               common.Hash    `json:"r"`
+	S               common.Hash    `json:"s"`
+}
+
+// NewMNTPermit prepares an unsigned permit of the L1 MNT token with the next
+// nonce of owner.
+func (c *Client) NewMNTPermit(ctx context.Context, owner, spender common.Address, value *big.Int, deadline time.Time) (*Permit, error) {
+	if value == nil || value.Sign() <= 0 {
+		return nil, errors.New("permit value must be positive")
+	}
+	token, err := abijson.NewL1MantleTokenCaller(c.Profile.Addresses.L1MantleToken, c.L1)
+	if err != nil {
+		return nil, err
+	}
+	opts := callOpts(ctx)
+	nonce, err := token.Nonces(opts, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permit nonce: %w", err)
+	}
+	domain, err := token.DOMAINSEPARATOR(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain separator: %w", err)
+	}
+	return &Permit{
+		Owner:           owner,
+		Spender:         spender,
+		Value:           new(big.Int).Set(value),
+		Nonce:           nonce,
+		Deadline:        big.NewInt(deadline.Unix()),
+		DomainSeparator: domain,
+	}, nil
+}
+
+// SignMNTPermit prepares the permit with NewMNTPermit and signs it with the
+// key of holder.
+func (c *Client) SignMNTPermit(ctx context.Context, holder signer.Signer, spender common.Address, value *big.Int, deadline time.Time) (*Permit, error) {
+	p, err := c.NewMNTPermit(ctx, holder.Address(), spender, value, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Sign(holder); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Digest is the EIP-712 hash the owner signs.
+func (p *Permit) Digest() common.Hash {
+	structHash := crypto.Keccak256(
+		permitTypehash[:],
+		common.BytesToHash(p.Owner[:]).Bytes(),
+		common.BytesToHash(p.Spender[:]).Bytes(),
+		common.BigToHash(p.Value).Bytes(),
+		common.BigToHash(p.Nonce).Bytes(),
+		common.BigToHash(p.Deadline).Bytes(),
+	)
+	return crypto.Keccak256Hash([]byte("\x19\x01"), p.DomainSeparator[:], structHash)
+}
+
+// Sign signs the permit with the key of its owner, which needs no network.
+func (p *Permit) Sign(s signer.Signer) error {
+	if s.Address() != p.Owner {
+		return fmt.Errorf("permit is for %s, the signer is %s", p.Owner, s.Address())
+	}
+	sig, err := s.SignHash(p.Digest())
+	if err != nil {
+		return fmt.Errorf("failed to sign permit: %w", err)
+	}
+	p.R, p.S, p.V = common.BytesToHash(sig[:32]), common.BytesToHash(sig[32:64]), sig[64]+27
+	return nil
+}
+
+// Verify checks that the permit is signed by its owner.
+func (p *Permit) Verify() error {
+	if p.V < 27 {
+		return errors.New("permit is not signed")
+	}
+	sig := append(append(p.R.Bytes(), p.S.Bytes()...), p.V-27)
+	pub, err := crypto.SigToPub(p.Digest().Bytes(), sig)
+	if err != nil {
+		return fmt.Errorf("invalid permit signature: %w", err)
+	}
+	if signed := crypto.PubkeyToAddress(*pub); signed != p.Owner {
+		return fmt.Errorf("permit is signed by %s, not its owner %s", signed, p.Owner)
+	}
+	return nil
+}
+
+// SubmitPermit sends the permit from s, anyone may submit it. It does not
+// wait for the tx to be mined.
+func (c *Client) SubmitPermit(ctx context.Context, s signer.Signer, p *Permit) (*types.Transaction, error) {
+	if err := p.Verify(); err != nil {
+		return nil, err
+	}
+	l1 := c.l1()
+	return l1.send(l1.transactor(ctx, s), c.Profile.Addresses.L1MantleToken, abijson.L1MantleTokenMetaData, "permit",
+		p.Owner, p.Spender, p.Value, p.Deadline, p.V, [32]byte(p.R), [32]byte(p.S))
+}
+
+// DepositMNTWithPermit deposits MNT of the permit owner for them, sent
+// entirely by sponsor: the holder only signs the permit. L1StandardBridge
+// always deposits from its caller, it can not pull MNT for anyone else, so a
+// permit for the bridge would still need a tx of the holder. The permit lets
+// the sponsor spend instead, and the MNT passes through the custody of the
+// sponsor: it is pulled to the sponsor, then deposited to args.To, the owner
+// if zero. Every step is mined before the next.
+//
+// The pull is kept in pulls, by permit owner and nonce, from before it is
+// sent until its deposit is mined. Calling again after an interruption
+// deposits exactly the pulled MNT, without pulling a second time and without
+// touching MNT the sponsor holds otherwise.
+func (c *Client) DepositMNTWithPermit(ctx context.Context, sponsor signer.Signer, p *Permit, args TransferArgs, pulls PermitPulls) (*types.Transaction, error) {
+	if p.Spender != sponsor.Address() {
+		return nil, fmt.Errorf("permit lets %s spend, the sponsor %s must be the spender", p.Spender, sponsor.Address())
+	}
+	call, err := c.depositCall(p.Owner, AssetMNT, config.Token{}, args)
+	if err != nil {
+		return nil, err
+	}
+	if args.Amount.Cmp(p.Value) > 0 {
+		return nil, fmt.Errorf("permit allows %s, the deposit is %s", p.Value, args.Amount)
+	}
+	pull, err := pulls.Get(p.Owner, p.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if pull != nil {
+		deposit, pulled, err := c.resumePull(ctx, pull)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case deposit != nil:
+			// only forgetting the pull was interrupted
+			if err := pulls.Delete(p.Owner, p.Nonce); err != nil {
+				return nil, err
+			}
+			if pull.Amount.Cmp(args.Amount) == 0 {
+				return deposit, nil
+			}
+			pull = nil
+		case !pulled:
+			pull = nil
+		case pull.Amount.Cmp(args.Amount) != 0:
+			return nil, fmt.Errorf("%s MNT pulled with this permit are not deposited yet, resume with that amount", pull.Amount)
+		}
+	}
+	if pull == nil {
+		if pull, err = c.pullWithPermit(ctx, sponsor, p, args.Amount, pulls); err != nil {
+			return nil, err
+		}
+	}
+
+	// the deposit tx is stored once signed, before it is sent
+	bridgeAddr := c.Profile.Addresses.L1StandardBridge
+	tx, err := call.send(ctx, onSigned{Signer: sponsor, fn: func(tx *types.Transaction) error {
+		if tx.To() == nil || *tx.To() != bridgeAddr {
+			return nil // the approval of the bridge
+		}
+		hash := tx.Hash()
+		pull.DepositTx = &hash
+		return pulls.Put(pull)
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if c.Hooks.Deposited != nil {
+		c.Hooks.Deposited(ctx, AssetMNT, tx)
+	}
+	if _, err := txutils.WaitForReceipt(ctx, c.L1, tx.Hash(), txutils.WaitOpts{}); err != nil {
+		return nil, fmt.Errorf("deposit, the pulled MNT stays with the sponsor until resumed: %w", err)
+	}
+	return tx, pulls.Delete(p.Owner, p.Nonce)
+}
+
+// resumePull waits for the txs of a stored pull. It returns the deposit tx
+// if that was mined, and otherwise whether the MNT was pulled.
+func (c *Client) resumePull(ctx context.Context, pull *PermitPull) (*types.Transaction, bool, error) {
+	if pull.DepositTx != nil {
+		tx, mined, err := c.awaitL1Tx(ctx, *pull.DepositTx)
+		if err != nil || mined {
+			return tx, false, err
+		}
+		pull.DepositTx = nil
+	}
+	_, pulled, err := c.awaitL1Tx(ctx, pull.PullTx)
+	return nil, pulled, err
+}
+
+// awaitL1Tx waits for a tx sent earlier and reports whether it was mined
+// successfully: false if it reverted or the node does not know it.
+func (c *Client) awaitL1Tx(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	tx, _, err := c.L1.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = txutils.WaitForReceipt(ctx, c.L1, hash, txutils.WaitOpts{})
+	var reverted *txutils.RevertedError
+	if errors.As(err, &reverted) {
+		return tx, false, nil
+	}
+	return tx, err == nil, err
+}
+
+// pullWithPermit moves amount of the permit owner's MNT to the sponsor,
+// submitting the permit unless its allowance is already in place. The pull
+// is stored once its tx is signed, before it is sent.
+func (c *Client) pullWithPermit(ctx context.Context, sponsor signer.Signer, p *Permit, amount *big.Int, pulls PermitPulls) (*PermitPull, error) {
+	l1 := c.l1()
+	mnt := c.Profile.Addresses.L1MantleToken
+	allowance, err := l1.allowance(ctx, mnt, p.Owner, sponsor.Address())
+	if err != nil {
+		return nil, err
+	}
+	if allowance.Cmp(amount) < 0 {
+		tx, err := c.SubmitPermit(ctx, sponsor, p)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := txutils.WaitForReceipt(ctx, c.L1, tx.Hash(), txutils.WaitOpts{}); err != nil {
+			return nil, fmt.Errorf("permit: %w", err)
+		}
+	}
+	pull := &PermitPull{Owner: p.Owner, Nonce: p.Nonce, Amount: amount}
+	store := onSigned{Signer: sponsor, fn: func(tx *types.Transaction) error {
+		pull.PullTx = tx.Hash()
+		return pulls.Put(pull)
+	}}
+	tx, err := l1.send(l1.transactor(ctx, store), mnt, abijson.L1MantleTokenMetaData, "transferFrom", p.Owner, sponsor.Address(), amount)
+	if err != nil {
+		// the pull stays stored in case the node got the tx anyway
+		return nil, err
+	}
+	if _, err := txutils.WaitForReceipt(ctx, c.L1, tx.Hash(), txutils.WaitOpts{}); err != nil {
+		return nil, fmt.Errorf("transferFrom: %w", err)
+	}
+	return pull, nil
+}
+
+// onSigned is a signer that hands every tx it signs to fn before it can be
+// sent, an error of fn keeps the tx from being sent.
+type onSigned struct {
+	signer.Signer
+	fn func(tx *types.Transaction) error
+}
+
+func (s onSigned) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signed, err := s.Signer.SignTx(tx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	return signed, s.fn(signed)
+}
+
+// PermitPull is MNT a sponsor pulls with a permit for a deposit, kept from
+// before the pull is sent until the deposit is mined.
+type PermitPull struct {
+	Owner  common.Address `json:"owner"`
+	Nonce  *big.Int       `json:"nonce"` // of the permit
+	Amount *big.Int       `json:"amount"`
+	// PullTx is the transferFrom to the sponsor, DepositTx the deposit of
+	// what it pulled.
+	PullTx    common.Hash  `json:"pullTx"`
+	DepositTx *common.Hash `json:"depositTx,omitempty"`
+}
+
+// PermitPulls keeps the pulls of permit deposits in flight, by permit owner
+// and nonce. Get returns nil without error if there is none.
+type PermitPulls interface {
+	Get(owner common.Address, nonce *big.Int) (*PermitPull, error)
+	Put(pull *PermitPull) error
+	Delete(owner common.Address, nonce *big.Int) error
+}
+
+// PermitPullDir keeps one JSON file per pull in a directory.
+type PermitPullDir struct {
+	dir string
+}
+
+func NewPermitPullDir(dir string) (*PermitPullDir, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create permit pull dir: %w", err)
+	}
+	return &PermitPullDir{dir: dir}, nil
+}
+
+func (d *PermitPullDir) path(owner common.Address, nonce *big.Int) string {
+	return filepath.Join(d.dir, fmt.Sprintf("%s-%s.json", owner.Hex(), nonce))
+}
+
+func (d *PermitPullDir) Get(owner common.Address, nonce *big.Int) (*PermitPull, error) {
+	raw, err := os.ReadFile(d.path(owner, nonce))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pull := &PermitPull{}
+	if err := json.Unmarshal(raw, pull); err != nil {
+		return nil, fmt.Errorf("failed to decode permit pull of %s nonce %s: %w", owner, nonce, err)
+	}
+	return pull, nil
+}
+
+// Put writes the pull to a temp file first so a crash never leaves a
+// truncated one behind.
+func (d *PermitPullDir) Put(pull *PermitPull) error {
+	raw, err := json.MarshalIndent(pull, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := d.path(pull.Owner, pull.Nonce)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return fmt.Errorf("failed to write permit pull: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (d *PermitPullDir) Delete(owner common.Address, nonce *big.Int) error {
+	err := os.Remove(d.path(owner, nonce))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,283 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/abistr/abijson"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+// ErrNoOutputYet is returned when no L2 output covering the withdrawal block
+// has been proposed yet, so the withdrawal cannot be proven.
+var ErrNoOutputYet = errors.New("no output covers the withdrawal block yet")
+
+// ErrSeveralWithdrawals is returned when an L2 tx started more than one
+// withdrawal and none was chosen by its log index.
+var ErrSeveralWithdrawals = errors.New("l2 tx started several withdrawals, choose one by log index")
+
+// Withdrawal loads the receipt of the L2 tx and the MessagePassed event it
+// emitted. It fails with ErrSeveralWithdrawals if the tx emitted more than
+// one, see WithdrawalAt and Withdrawals.
+func (c *Client) Withdrawal(ctx context.Context, txHash common.Hash) (*types.Receipt, *abijson.L2ToL1MessagePasserMessagePassed, error) {
+	return c.withdrawal(ctx, txHash, nil)
+}
+
+// WithdrawalAt is Withdrawal for the MessagePassed event at the log index.
+func (c *Client) WithdrawalAt(ctx context.Context, txHash common.Hash, logIndex uint) (*types.Receipt, *abijson.L2ToL1MessagePasserMessagePassed, error) {
+	return c.withdrawal(ctx, txHash, &logIndex)
+}
+
+// Withdrawals loads the receipt of the L2 tx and every MessagePassed event it
+// emitted, in log order.
+func (c *Client) Withdrawals(ctx context.Context, txHash common.Hash) (*types.Receipt, []*abijson.L2ToL1MessagePasserMessagePassed, error) {
+	receipt, err := c.L2.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get l2 receipt: %w", err)
+	}
+	evs, err := txutils.ParseMessagesPassed(c.MessagePasser, receipt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return receipt, evs, nil
+}
+
+func (c *Client) withdrawal(ctx context.Context, txHash common.Hash, logIndex *uint) (*types.Receipt, *abijson.L2ToL1MessagePasserMessagePassed, error) {
+	receipt, err := c.L2.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get l2 receipt: %w", err)
+	}
+	ev, err := c.ParseWithdrawal(receipt, logIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	return receipt, ev, nil
+}
+
+// ParseWithdrawal returns the MessagePassed event of the receipt at the log
+// index. A nil index picks the only one, ErrSeveralWithdrawals if there are
+// more.
+func (c *Client) ParseWithdrawal(receipt *types.Receipt, logIndex *uint) (*abijson.L2ToL1MessagePasserMessagePassed, error) {
+	evs, err := txutils.ParseMessagesPassed(c.MessagePasser, receipt)
+	if err != nil {
+		return nil, err
+	}
+	if logIndex == nil {
+		if len(evs) > 1 {
+			return nil, fmt.Errorf("%w: %d in tx %s", ErrSeveralWithdrawals, len(evs), receipt.TxHash)
+		}
+		return evs[0], nil
+	}
+	for _, ev := range evs {
+		if ev.Raw.Index == *logIndex {
+			return ev, nil
+		}
+	}
+	return nil, fmt.Errorf("tx %s has no MessagePassed event at log index %d", receipt.TxHash, *logIndex)
+}
+
+// WithdrawalTx converts a MessagePassed event into the struct the portal expects.
+func WithdrawalTx(ev *abijson.L2ToL1MessagePasserMessagePassed) abijson.TypesWithdrawalTransaction {
+	return abijson.TypesWithdrawalTransaction{
+		Nonce:    ev.Nonce,
+		Sender:   ev.Sender,
+		Target:   ev.Target,
+		MntValue: ev.MntValue,
+		EthValue: ev.EthValue,
+		GasLimit: ev.GasLimit,
+		Data:     ev.Data,
+	}
+}
+
+// ProveWithdrawal proves the withdrawal started by the L2 tx against the
+// first output that covers its block.
+func (c *Client) ProveWithdrawal(ctx context.Context, s signer.Signer, txHash common.Hash) (*types.Transaction, error) {
+	receipt, ev, err := c.Withdrawal(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	return c.prove(ctx, s, receipt, ev)
+}
+
+// ProveWithdrawalAt is ProveWithdrawal for the withdrawal of the tx whose
+// MessagePassed event is at the log index.
+func (c *Client) ProveWithdrawalAt(ctx context.Context, s signer.Signer, txHash common.Hash, logIndex uint) (*types.Transaction, error) {
+	receipt, ev, err := c.WithdrawalAt(ctx, txHash, logIndex)
+	if err != nil {
+		return nil, err
+	}
+	return c.prove(ctx, s, receipt, ev)
+}
+
+// ProveWithdrawals proves every withdrawal started by the L2 tx, one L1 tx
+// each in log order. Withdrawals already proven against an output that is
+// still on L1, or finalized, are skipped. It stops at the first that fails
+// and returns the txs sent until then.
+func (c *Client) ProveWithdrawals(ctx context.Context, s signer.Signer, txHash common.Hash) ([]*types.Transaction, error) {
+	receipt, evs, err := c.Withdrawals(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*types.Transaction, 0, len(evs))
+	for _, ev := range evs {
+		proven, finalized, err := c.progress(ctx, ev.WithdrawalHash)
+		if err != nil {
+			return txs, fmt.Errorf("withdrawal at log index %d: %w", ev.Raw.Index, err)
+		}
+		if proven || finalized {
+			continue
+		}
+		tx, err := c.prove(ctx, s, receipt, ev)
+		if err != nil {
+			return txs, fmt.Errorf("withdrawal at log index %d: %w", ev.Raw.Index, err)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+func (c *Client) prove(ctx context.Context, s signer.Signer, receipt *types.Receipt, ev *abijson.L2ToL1MessagePasserMessagePassed) (*types.Transaction, error) {
+	params, _, err := c.proveParameters(ctx, receipt, ev)
+	if err != nil {
+		return nil, err
+	}
+
+	l1 := c.l1()
+	tx, err := l1.send(l1.transactor(ctx, s), c.Profile.Addresses.L1OptimismPortal, abijson.L1OptimismPortalMetaData, "proveWithdrawalTransaction",
+		WithdrawalTx(ev), params.L2OutputIndex, params.OutputRootProof, params.WithdrawalProof)
+	if err == nil && c.Hooks.Proved != nil {
+		c.Hooks.Proved(ctx, receipt, tx)
+	}
+	return tx, err
+}
+
+// proveParameters builds the proof of the withdrawal against the first output
+// that covers its block. It also returns the L2 header of that output.
+func (c *Client) proveParameters(ctx context.Context, receipt *types.Receipt, ev *abijson.L2ToL1MessagePasserMessagePassed) (txutils.ProvenWithdrawalParameters, *types.Header, error) {
+	opts := callOpts(ctx)
+	latest, err := c.Oracle.LatestBlockNumber(opts)
+	if err != nil {
+		return txutils.ProvenWithdrawalParameters{}, nil, fmt.Errorf("failed to get latest output block: %w", err)
+	}
+	if latest.Cmp(receipt.BlockNumber) < 0 {
+		return txutils.ProvenWithdrawalParameters{}, nil, fmt.Errorf("%w: withdrawal block %d, latest output block %d", ErrNoOutputYet, receipt.BlockNumber, latest)
+	}
+	index, err := c.Oracle.GetL2OutputIndexAfter(opts, receipt.BlockNumber)
+	if err != nil {
+		return txutils.ProvenWithdrawalParameters{}, nil, fmt.Errorf("failed to get l2OutputIndex: %w", err)
+	}
+	output, err := c.Oracle.GetL2Output(opts, index)
+	if err != nil {
+		return txutils.ProvenWithdrawalParameters{}, nil, fmt.Errorf("failed to get l2 output %d: %w", index, err)
+	}
+	header, err := c.L2.HeaderByNumber(ctx, output.L2BlockNumber)
+	if err != nil {
+		return txutils.ProvenWithdrawalParameters{}, nil, fmt.Errorf("failed to get l2 header %d: %w", output.L2BlockNumber, err)
+	}
+
+	params, err := txutils.ProveMessagePassedParameters(ctx, c.L2Geth, c.Profile.Addresses.L2ToL1MessagePasser, ev, header, &c.Oracle.L2OutputOracleProxyCaller)
+	if err != nil {
+		return txutils.ProvenWithdrawalParameters{}, nil, err
+	}
+	return params, header, nil
+}
+
+// FinalizeWithdrawal finalizes a proven withdrawal once its challenge window
+// has passed.
+func (c *Client) FinalizeWithdrawal(ctx context.Context, s signer.Signer, txHash common.Hash) (*types.Transaction, error) {
+	receipt, ev, err := c.Withdrawal(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	return c.finalize(ctx, s, receipt, ev)
+}
+
+// FinalizeWithdrawalAt is FinalizeWithdrawal for the withdrawal of the tx
+// whose MessagePassed event is at the log index.
+func (c *Client) FinalizeWithdrawalAt(ctx context.Context, s signer.Signer, txHash common.Hash, logIndex uint) (*types.Transaction, error) {
+	receipt, ev, err := c.WithdrawalAt(ctx, txHash, logIndex)
+	if err != nil {
+		return nil, err
+	}
+	return c.finalize(ctx, s, receipt, ev)
+}
+
+// FinalizeWithdrawals finalizes every withdrawal started by the L2 tx, like
+// ProveWithdrawals. Withdrawals already finalized are skipped.
+func (c *Client) FinalizeWithdrawals(ctx context.Context, s signer.Signer, txHash common.Hash) ([]*types.Transaction, error) {
+	receipt, evs, err := c.Withdrawals(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*types.Transaction, 0, len(evs))
+	for _, ev := range evs {
+		_, finalized, err := c.progress(ctx, ev.WithdrawalHash)
+		if err != nil {
+			return txs, fmt.Errorf("withdrawal at log index %d: %w", ev.Raw.Index, err)
+		}
+		if finalized {
+			continue
+		}
+		tx, err := c.finalize(ctx, s, receipt, ev)
+		if err != nil {
+			return txs, fmt.Errorf("withdrawal at log index %d: %w", ev.Raw.Index, err)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+func (c *Client) finalize(ctx context.Context, s signer.Signer, receipt *types.Receipt, ev *abijson.L2ToL1MessagePasserMessagePassed) (*types.Transaction, error) {
+	l1 := c.l1()
+	tx, err := l1.send(l1.transactor(ctx, s), c.Profile.Addresses.L1OptimismPortal, abijson.L1OptimismPortalMetaData, "finalizeWithdrawalTransaction",
+		WithdrawalTx(ev))
+	if err == nil && c.Hooks.Finalized != nil {
+		c.Hooks.Finalized(ctx, receipt, tx)
+	}
+	return tx, err
+}
+
+// progress reports whether the withdrawal is proven against an output that
+// is still on L1, and whether it is finalized.
+func (c *Client) progress(ctx context.Context, withdrawalHash common.Hash) (proven, finalized bool, err error) {
+	opts := callOpts(ctx)
+	if finalized, err = c.Portal.FinalizedWithdrawals(opts, withdrawalHash); err != nil {
+		return false, false, fmt.Errorf("failed to get finalized withdrawal: %w", err)
+	}
+	p, err := c.Portal.ProvenWithdrawals(opts, withdrawalHash)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get proven withdrawal: %w", err)
+	}
+	if p.Timestamp.Sign() == 0 {
+		return false, finalized, nil
+	}
+	gone, err := c.OutputGone(opts, p.L2OutputIndex, p.OutputRoot)
+	if err != nil {
+		return false, false, err
+	}
+	return !gone, finalized, nil
+}
+
+// OutputGone reports whether the output at index no longer has the root a
+// withdrawal was proven against: it was deleted, and maybe another one was
+// proposed in its place since.
+func (c *Client) OutputGone(opts *bind.CallOpts, index *big.Int, root [32]byte) (bool, error) {
+	next, err := c.Oracle.NextOutputIndex(opts)
+	if err != nil {
+		return false, fmt.Errorf("failed to get next output index: %w", err)
+	}
+	if index.Cmp(next) >= 0 {
+		return true, nil
+	}
+	output, err := c.Oracle.GetL2Output(opts, index)
+	if err != nil {
+		return false, fmt.Errorf("failed to get l2 output %d: %w", index, err)
+	}
+	return output.OutputRoot != root, nil
+}
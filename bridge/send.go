@@ -0,0 +1,104 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/abistr/abijson"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+// side is one chain of the bridge together with the nonce manager and fee
+// estimator every tx sent to it goes through.
+type side struct {
+	backend Backend
+	chainID *big.Int
+	nonces  *txutils.NonceManager
+	fees    *txutils.FeeEstimator
+}
+
+func (c *Client) l1() side {
+	return side{backend: c.L1, chainID: c.L1ChainID, nonces: c.L1Nonces, fees: c.L1Fees}
+}
+
+func (c *Client) l2() side {
+	return side{backend: c.L2, chainID: c.L2ChainID, nonces: c.L2Nonces, fees: c.L2Fees}
+}
+
+func (s side) transactor(ctx context.Context, sig signer.Signer) *bind.TransactOpts {
+	return signer.TransactOpts(ctx, sig, s.chainID)
+}
+
+// send calls method on contract with an estimated gas limit and fees and a
+// managed nonce.
+func (s side) send(opts *bind.TransactOpts, contract common.Address, meta *bind.MetaData, method string, args ...interface{}) (*types.Transaction, error) {
+	contractABI, err := meta.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	fees, err := s.fees.Estimate(opts.Context, opts.From, &contract, opts.Value, contractABI, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	priced := *opts
+	fees.Apply(&priced)
+
+	bound := bind.NewBoundContract(contract, *contractABI, s.backend, s.backend, s.backend)
+	tx, err := s.nonces.Transact(&priced, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return bound.Transact(opts, method, args...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", method, err)
+	}
+	return tx, nil
+}
+
+// ErrApproveFirst is returned by a deposit or withdrawal that needs a token
+// approval when its txs are only built, not sent: the approval was built, the
+// transfer can only be built once the approval is mined.
+var ErrApproveFirst = errors.New("the token approval must be mined before the transfer can be built")
+
+// ensureAllowance approves spender for amount of token if the current
+// allowance is too small and waits until the approval is mined. With opts
+// that do not send it fails with ErrApproveFirst after building the approval.
+func (s side) ensureAllowance(opts *bind.TransactOpts, token, spender common.Address, amount *big.Int) error {
+	allowance, err := s.allowance(opts.Context, token, opts.From, spender)
+	if err != nil {
+		return err
+	}
+	if allowance.Cmp(amount) >= 0 {
+		return nil
+	}
+
+	approveOpts := *opts
+	approveOpts.Value = nil
+	tx, err := s.send(&approveOpts, token, abijson.L2TestTokenMetaData, "approve", spender, amount)
+	if err != nil {
+		return err
+	}
+	if opts.NoSend {
+		return ErrApproveFirst
+	}
+	if _, err := txutils.WaitForReceipt(opts.Context, s.backend, tx.Hash(), txutils.WaitOpts{}); err != nil {
+		return fmt.Errorf("approve: %w", err)
+	}
+	return nil
+}
+
+func (s side) allowance(ctx context.Context, token, owner, spender common.Address) (*big.Int, error) {
+	erc20, err := abijson.NewL2TestTokenCaller(token, s.backend) // any ERC20 binding will do
+	if err != nil {
+		return nil, err
+	}
+	allowance, err := erc20.Allowance(callOpts(ctx), owner, spender)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get allowance: %w", err)
+	}
+	return allowance, nil
+}
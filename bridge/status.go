@@ -0,0 +1,67 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// WithdrawalStatus is a snapshot of where a withdrawal is on L1.
+type WithdrawalStatus struct {
+	TxHash            common.Hash
+	WithdrawalHash    common.Hash
+	L2BlockNumber     *big.Int
+	LatestOutputBlock *big.Int
+	Proven            bool
+	ProvenAt          time.Time
+	FinalizableAt     time.Time
+	Finalized         bool
+}
+
+// WithdrawalStatus reports the proven/finalized state of the withdrawal
+// started by the L2 tx.
+func (c *Client) WithdrawalStatus(ctx context.Context, txHash common.Hash) (*WithdrawalStatus, error) {
+	return c.withdrawalStatus(ctx, txHash, nil)
+}
+
+// WithdrawalStatusAt is WithdrawalStatus for the withdrawal of the tx whose
+// MessagePassed event is at the log index.
+func (c *Client) WithdrawalStatusAt(ctx context.Context, txHash common.Hash, logIndex uint) (*WithdrawalStatus, error) {
+	return c.withdrawalStatus(ctx, txHash, &logIndex)
+}
+
+func (c *Client) withdrawalStatus(ctx context.Context, txHash common.Hash, logIndex *uint) (*WithdrawalStatus, error) {
+	receipt, ev, err := c.withdrawal(ctx, txHash, logIndex)
+	if err != nil {
+		return nil, err
+	}
+	opts := callOpts(ctx)
+	st := &WithdrawalStatus{
+		TxHash:         txHash,
+		WithdrawalHash: ev.WithdrawalHash,
+		L2BlockNumber:  receipt.BlockNumber,
+	}
+	if st.LatestOutputBlock, err = c.Oracle.LatestBlockNumber(opts); err != nil {
+		return nil, fmt.Errorf("failed to get latest output block: %w", err)
+	}
+	proven, err := c.Portal.ProvenWithdrawals(opts, ev.WithdrawalHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proven withdrawal: %w", err)
+	}
+	if proven.Timestamp.Sign() != 0 {
+		period, err := c.Oracle.FINALIZATIONPERIODSECONDS(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get finalization period: %w", err)
+		}
+		st.Proven = true
+		st.ProvenAt = time.Unix(proven.Timestamp.Int64(), 0)
+		st.FinalizableAt = st.ProvenAt.Add(time.Duration(period.Int64()) * time.Second)
+	}
+	if st.Finalized, err = c.Portal.FinalizedWithdrawals(opts, ev.WithdrawalHash); err != nil {
+		return nil, fmt.Errorf("failed to get finalized withdrawal: %w", err)
+	}
+	return st, nil
+}
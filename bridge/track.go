@@ -0,0 +1,100 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/abistr/abijson"
+	"try_rde/txutils"
+)
+
+// ErrNoDeposit is returned for L1 txs that emitted no TransactionDeposited event.
+var ErrNoDeposit = errors.New("tx made no deposit")
+
+// Deposit follows one TransactionDeposited event of an L1 tx to the L2 tx
+// derived from it.
+type Deposit struct {
+	L1TxHash common.Hash
+	Tx       *txutils.DepositTx
+	L2TxHash common.Hash
+
+	// Set by WaitForDeposit.
+	L2Receipt *types.Receipt
+	// The bridge events emitted on L2. Bridged ETH and MNT emit their
+	// BridgeFinalized event next to the legacy DepositFinalized, direct
+	// portal deposits emit none of them.
+	ETHBridgeFinalized *abijson.L2StandardBridgeETHBridgeFinalized
+	MNTBridgeFinalized *abijson.L2StandardBridgeMNTBridgeFinalized
+	DepositFinalized   *abijson.L2StandardBridgeDepositFinalized
+}
+
+// Deposits derives the L2 deposit txs from the TransactionDeposited events
+// of an L1 tx.
+func (c *Client) Deposits(ctx context.Context, l1TxHash common.Hash) ([]*Deposit, error) {
+	receipt, err := c.L1.TransactionReceipt(ctx, l1TxHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get l1 receipt: %w", err)
+	}
+	portalABI, err := abijson.L1OptimismPortalMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	topic := portalABI.Events["TransactionDeposited"].ID
+
+	var deposits []*Deposit
+	for _, log := range receipt.Logs {
+		if log.Address != c.Profile.Addresses.L1OptimismPortal || len(log.Topics) == 0 || log.Topics[0] != topic {
+			continue
+		}
+		ev, err := c.Portal.ParseTransactionDeposited(*log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TransactionDeposited: %w", err)
+		}
+		dep, err := txutils.UnmarshalDepositEvent(ev)
+		if err != nil {
+			return nil, err
+		}
+		deposits = append(deposits, &Deposit{L1TxHash: l1TxHash, Tx: dep, L2TxHash: dep.Hash()})
+	}
+	if len(deposits) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoDeposit, l1TxHash)
+	}
+	return deposits, nil
+}
+
+// WaitForDeposit waits for the L2 receipt of the deposit and collects the
+// bridge events it emitted. A deposit that reverted on L2 is reported with
+// its receipt and a *txutils.RevertedError.
+func (c *Client) WaitForDeposit(ctx context.Context, d *Deposit, opts txutils.WaitOpts) error {
+	receipt, err := txutils.WaitForReceipt(ctx, c.L2, d.L2TxHash, opts)
+	d.L2Receipt = receipt
+	if err != nil {
+		return err
+	}
+
+	bridgeABI, err := abijson.L2StandardBridgeMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+	filterer := &c.L2Bridge.L2StandardBridgeFilterer
+	for _, log := range receipt.Logs {
+		if log.Address != c.Profile.Addresses.L2StandardBridge || len(log.Topics) == 0 {
+			continue
+		}
+		switch log.Topics[0] {
+		case bridgeABI.Events["ETHBridgeFinalized"].ID:
+			d.ETHBridgeFinalized, err = filterer.ParseETHBridgeFinalized(*log)
+		case bridgeABI.Events["MNTBridgeFinalized"].ID:
+			d.MNTBridgeFinalized, err = filterer.ParseMNTBridgeFinalized(*log)
+		case bridgeABI.Events["DepositFinalized"].ID:
+			d.DepositFinalized, err = filterer.ParseDepositFinalized(*log)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse bridge event: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/abistr/abijson"
+	"try_rde/config"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+// Asset is what a deposit or withdrawal moves.
+type Asset string
+
+const (
+	AssetETH   Asset = "eth"
+	AssetMNT   Asset = "mnt"
+	AssetERC20 Asset = "erc20"
+)
+
+// ParseAsset parses eth, mnt or erc20.
+func ParseAsset(s string) (Asset, error) {
+	switch a := Asset(strings.ToLower(s)); a {
+	case AssetETH, AssetMNT, AssetERC20:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown asset %q, want eth, mnt or erc20", s)
+}
+
+// Quote is the estimated cost of a deposit or withdrawal.
+type Quote struct {
+	// Approve is set when the bridge must be allowed to spend the token
+	// first. The transfer itself can only be estimated after that approval,
+	// so Transfer is nil then.
+	Approve  *txutils.Fees
+	Transfer *txutils.Fees
+}
+
+// bridgeCall is the bridge tx of a deposit or withdrawal.
+type bridgeCall struct {
+	side     side
+	contract common.Address
+	meta     *bind.MetaData
+	method   string
+	value    *big.Int
+	args     []interface{}
+	// approve is the token the bridge must be allowed to spend amount of,
+	// zero if none.
+	approve common.Address
+	amount  *big.Int
+}
+
+// checkToken rejects a pair missing one of its addresses, the bridge would be
+// called with the zero address and nothing approved.
+func checkToken(token config.Token) error {
+	if token.L1 == (common.Address{}) || token.L2 == (common.Address{}) {
+		return fmt.Errorf("token pair needs both addresses, got l1 %s and l2 %s", token.L1, token.L2)
+	}
+	return nil
+}
+
+func (c *Client) depositCall(from common.Address, asset Asset, token config.Token, args TransferArgs) (*bridgeCall, error) {
+	if err := args.check(); err != nil {
+		return nil, err
+	}
+	call := &bridgeCall{
+		side:     c.l1(),
+		contract: c.Profile.Addresses.L1StandardBridge,
+		meta:     abijson.L1StandardBridgeMetaData,
+		amount:   args.Amount,
+	}
+	to := args.recipient(from)
+	switch asset {
+	case AssetETH:
+		call.method, call.value = "depositETHTo", args.Amount
+		call.args = []interface{}{to, args.MinGasLimit, args.ExtraData}
+	case AssetMNT:
+		call.method, call.approve = "depositMNTTo", c.Profile.Addresses.L1MantleToken
+		call.args = []interface{}{to, args.Amount, args.MinGasLimit, args.ExtraData}
+	case AssetERC20:
+		if err := checkToken(token); err != nil {
+			return nil, err
+		}
+		call.method, call.approve = "depositERC20To", token.L1
+		call.args = []interface{}{token.L1, token.L2, to, args.Amount, args.MinGasLimit, args.ExtraData}
+	default:
+		return nil, fmt.Errorf("unknown asset %q", asset)
+	}
+	return call, nil
+}
+
+func (c *Client) withdrawalCall(from common.Address, asset Asset, token config.Token, args TransferArgs) (*bridgeCall, error) {
+	if err := args.check(); err != nil {
+		return nil, err
+	}
+	call := &bridgeCall{
+		side:     c.l2(),
+		contract: c.Profile.Addresses.L2StandardBridge,
+		meta:     abijson.L2StandardBridgeMetaData,
+		method:   "withdrawTo",
+		amount:   args.Amount,
+	}
+	to := args.recipient(from)
+	var l2Token common.Address
+	switch asset {
+	case AssetETH:
+		// BVM_ETH is pulled by the bridge, it needs an allowance
+		l2Token = c.Profile.Addresses.L2ETH
+		call.approve = l2Token
+	case AssetMNT:
+		// the native MNT is sent as the tx value
+		l2Token = c.Profile.Addresses.LegacyERC20MNT
+		call.value = args.Amount
+	case AssetERC20:
+		if token.L2 == (common.Address{}) {
+			return nil, errors.New("l2 token address must not be zero")
+		}
+		// the bridge burns the L2 token so no approval is needed
+		l2Token = token.L2
+	default:
+		return nil, fmt.Errorf("unknown asset %q", asset)
+	}
+	call.args = []interface{}{l2Token, to, args.Amount, args.MinGasLimit, args.ExtraData}
+	return call, nil
+}
+
+func (b *bridgeCall) send(ctx context.Context, s signer.Signer) (*types.Transaction, error) {
+	opts := b.side.transactor(ctx, s)
+	if b.approve != (common.Address{}) {
+		if err := b.side.ensureAllowance(opts, b.approve, b.contract, b.amount); err != nil {
+			return nil, err
+		}
+	}
+	opts.Value = b.value
+	return b.side.send(opts, b.contract, b.meta, b.method, b.args...)
+}
+
+func (b *bridgeCall) quote(ctx context.Context, from common.Address) (*Quote, error) {
+	if b.approve != (common.Address{}) {
+		allowance, err := b.side.allowance(ctx, b.approve, from, b.contract)
+		if err != nil {
+			return nil, err
+		}
+		if allowance.Cmp(b.amount) < 0 {
+			erc20ABI, err := abijson.L2TestTokenMetaData.GetAbi()
+			if err != nil {
+				return nil, err
+			}
+			fees, err := b.side.fees.Estimate(ctx, from, &b.approve, nil, erc20ABI, "approve", b.contract, b.amount)
+			if err != nil {
+				return nil, err
+			}
+			return &Quote{Approve: fees}, nil
+		}
+	}
+	contractABI, err := b.meta.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	fees, err := b.side.fees.Estimate(ctx, from, &b.contract, b.value, contractABI, b.method, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Quote{Transfer: fees}, nil
+}
+
+// Deposit bridges the asset from L1 to L2, approving the bridge first if the
+// asset is a token. token is only used for AssetERC20.
+func (c *Client) Deposit(ctx context.Context, s signer.Signer, asset Asset, token config.Token, args TransferArgs) (*types.Transaction, error) {
+	call, err := c.depositCall(s.Address(), asset, token, args)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := call.send(ctx, s)
+	if err == nil && c.Hooks.Deposited != nil {
+		c.Hooks.Deposited(ctx, asset, tx)
+	}
+	return tx, err
+}
+
+// Withdraw starts a withdrawal of the asset from L2 to L1, approving the
+// bridge first if needed. token is only used for AssetERC20.
+func (c *Client) Withdraw(ctx context.Context, s signer.Signer, asset Asset, token config.Token, args TransferArgs) (*types.Transaction, error) {
+	call, err := c.withdrawalCall(s.Address(), asset, token, args)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := call.send(ctx, s)
+	if err == nil && c.Hooks.Withdrew != nil {
+		c.Hooks.Withdrew(ctx, asset, tx)
+	}
+	return tx, err
+}
+
+// QuoteDeposit estimates the L1 gas and fees of a deposit from the sender.
+func (c *Client) QuoteDeposit(ctx context.Context, from common.Address, asset Asset, token config.Token, args TransferArgs) (*Quote, error) {
+	call, err := c.depositCall(from, asset, token, args)
+	if err != nil {
+		return nil, err
+	}
+	return call.quote(ctx, from)
+}
+
+// QuoteWithdrawal estimates the L2 gas and fees of starting a withdrawal from
+// the sender. Proving and finalizing on L1 are not included.
+func (c *Client) QuoteWithdrawal(ctx context.Context, from common.Address, asset Asset, token config.Token, args TransferArgs) (*Quote, error) {
+	call, err := c.withdrawalCall(from, asset, token, args)
+	if err != nil {
+		return nil, err
+	}
+	return call.quote(ctx, from)
+}
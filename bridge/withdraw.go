@@ -0,0 +1,28 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/config"
+	"try_rde/signer"
+)
+
+// WithdrawETH approves BVM_ETH for the L2 bridge if needed and starts an ETH
+// withdrawal through L2StandardBridge.withdrawTo.
+func (c *Client) WithdrawETH(ctx context.Context, s signer.Signer, args TransferArgs) (*types.Transaction, error) {
+	return c.Withdraw(ctx, s, AssetETH, config.Token{}, args)
+}
+
+// WithdrawMNT starts a withdrawal of the native L2 MNT. The amount is sent as
+// the tx value against the legacy MNT token address.
+func (c *Client) WithdrawMNT(ctx context.Context, s signer.Signer, args TransferArgs) (*types.Transaction, error) {
+	return c.Withdraw(ctx, s, AssetMNT, config.Token{}, args)
+}
+
+// WithdrawERC20 starts a withdrawal of a bridged L2 token. The bridge burns
+// the L2 token so no approval is needed.
+func (c *Client) WithdrawERC20(ctx context.Context, s signer.Signer, l2Token common.Address, args TransferArgs) (*types.Transaction, error) {
+	return c.Withdraw(ctx, s, AssetERC20, config.Token{L2: l2Token}, args)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"try_rde/bridge"
+)
+
+// runBundle moves the proof of a withdrawal between machines: export builds
+// it where an L2 archive node is reachable, verify and submit only need L1.
+func runBundle(ctx context.Context, env *cliEnv, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("missing action, use export, verify or submit")
+	}
+	switch args[0] {
+	case "export":
+		return runBundleExport(ctx, env, args[1:])
+	case "verify":
+		return runBundleVerify(ctx, env, args[1:])
+	case "submit":
+		return runBundleSubmit(ctx, env, args[1:])
+	}
+	return usageErrorf("unknown action %q, use export, verify or submit", args[0])
+}
+
+func runBundleExport(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("bundle export", flag.ContinueOnError)
+	var wf withdrawalFlags
+	wf.register(fs, false)
+	out := fs.String("out", "", "file to write the bundle to (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	txHash, logIndex, err := wf.parse()
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	b, err := c.BuildProveBundle(ctx, txHash, logIndex)
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	raw = append(raw, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(raw)
+		return err
+	}
+	if err := os.WriteFile(*out, raw, 0o644); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	fmt.Printf("bundle of withdrawal %s written to %s\n", b.WithdrawalHash.Hex(), *out)
+	return nil
+}
+
+func runBundleVerify(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("bundle verify", flag.ContinueOnError)
+	in := fs.String("in", "", "bundle file written by bundle export")
+	offline := fs.Bool("offline", false, "only check the proofs of the bundle, not that it fits L1")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	b, err := readBundle(*in)
+	if err != nil {
+		return err
+	}
+	if *offline {
+		err = b.Verify()
+	} else {
+		c, derr := env.dialL1(ctx)
+		if derr != nil {
+			return derr
+		}
+		err = c.CheckProveBundle(ctx, b)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("withdrawal hash:     %s\n", b.WithdrawalHash.Hex())
+	fmt.Printf("l2 tx:               %s\n", b.TxHash.Hex())
+	fmt.Printf("log index:           %d\n", b.LogIndex)
+	fmt.Printf("output index:        %d\n", b.L2OutputIndex)
+	fmt.Printf("output block:        %d\n", b.L2BlockNumber)
+	fmt.Printf("output root:         %s\n", b.OutputRoot.Hex())
+	if *offline {
+		fmt.Printf("bundle is valid, not checked against L1\n")
+	} else {
+		fmt.Printf("bundle is valid and can be submitted\n")
+	}
+	return nil
+}
+
+func runBundleSubmit(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("bundle submit", flag.ContinueOnError)
+	in := fs.String("in", "", "bundle file written by bundle export")
+	wait := fs.Bool("wait", true, "wait for the receipt")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	b, err := readBundle(*in)
+	if err != nil {
+		return err
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	c, err := env.dialL1(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := c.SubmitProveBundle(ctx, s, b)
+	if err != nil {
+		return err
+	}
+	return env.report(ctx, c.L1, tx, *wait)
+}
+
+func readBundle(path string) (*bridge.ProveBundle, error) {
+	if path == "" {
+		return nil, usageErrorf("--in is required")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	return bridge.DecodeProveBundle(raw)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"try_rde/bridge"
+	"try_rde/config"
+	"try_rde/metrics"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+const (
+	envKeystorePassword = "RDE_KEYSTORE_PASSWORD"
+	envMnemonic         = "RDE_MNEMONIC"
+)
+
+// usageError makes run exit with exitUsage instead of exitFailure.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string { return e.msg }
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// cliEnv lazily resolves the profile, signer and connections a command needs.
+type cliEnv struct {
+	profileName string
+	profileFile string
+
+	keystore     string
+	passwordFile string
+	mnemonicFile string
+	hdPath       string
+	keyEnv       string
+
+	confirmations  uint64
+	receiptTimeout time.Duration
+	gasMargin      float64
+	legacyFees     bool
+
+	// metrics, when set, instruments the client dial returns.
+	metrics *metrics.Metrics
+	// recorder, when set, is the signer of every command, which then builds
+	// its txs for offline signing instead of sending them.
+	recorder *signer.Recorder
+
+	profile *config.Profile
+	client  *bridge.Client
+}
+
+func (e *cliEnv) loadProfile() (*config.Profile, error) {
+	if e.profile == nil {
+		p, err := config.Load(e.profileName, e.profileFile)
+		if err != nil {
+			return nil, err
+		}
+		e.profile = p
+	}
+	return e.profile, nil
+}
+
+func (e *cliEnv) dial(ctx context.Context) (*bridge.Client, error) {
+	if e.client != nil {
+		return e.client, nil
+	}
+	p, err := e.loadProfile()
+	if err != nil {
+		return nil, err
+	}
+	if e.metrics != nil {
+		e.client, err = e.metrics.Dial(ctx, p)
+	} else {
+		e.client, err = bridge.Dial(ctx, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, fees := range []*txutils.FeeEstimator{e.client.L1Fees, e.client.L2Fees} {
+		fees.GasMargin = e.gasMargin
+		fees.Legacy = e.legacyFees
+	}
+	return e.client, nil
+}
+
+// dialL1 connects to L1 only, for commands that must work without L2 access.
+// A command uses either dial or dialL1.
+func (e *cliEnv) dialL1(ctx context.Context) (*bridge.Client, error) {
+	if e.client != nil {
+		return e.client, nil
+	}
+	p, err := e.loadProfile()
+	if err != nil {
+		return nil, err
+	}
+	if e.client, err = bridge.DialL1(ctx, p); err != nil {
+		return nil, err
+	}
+	e.client.L1Fees.GasMargin = e.gasMargin
+	e.client.L1Fees.Legacy = e.legacyFees
+	return e.client, nil
+}
+
+// signer picks the sender: the recorder of offline build, an encrypted
+// keystore, then a mnemonic, then a raw hex key from the environment.
+func (e *cliEnv) signer() (signer.Signer, error) {
+	switch {
+	case e.recorder != nil:
+		return e.recorder, nil
+	case e.keystore != "":
+		password := os.Getenv(envKeystorePassword)
+		if e.passwordFile != "" {
+			var err error
+			if password, err = signer.ReadPassphrase(e.passwordFile); err != nil {
+				return nil, err
+			}
+		}
+		return signer.FromKeystore(e.keystore, password)
+	case e.mnemonicFile != "":
+		raw, err := os.ReadFile(e.mnemonicFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mnemonic: %w", err)
+		}
+		return signer.FromMnemonic(strings.TrimSpace(string(raw)), "", e.hdPath)
+	case os.Getenv(envMnemonic) != "":
+		return signer.FromMnemonic(os.Getenv(envMnemonic), "", e.hdPath)
+	case os.Getenv(e.keyEnv) != "":
+		return signer.FromEnv(e.keyEnv)
+	}
+	return nil, usageErrorf("no signer, use --keystore, --mnemonic-file, $%s or $%s", envMnemonic, e.keyEnv)
+}
+
+func (e *cliEnv) close() {
+	if e.client != nil {
+		e.client.Close()
+	}
+}
+
+// transferFlags registers the flags shared by deposits and withdrawals.
+type transferFlags struct {
+	amount      string
+	to          string
+	minGasLimit uint
+	extraData   string
+	wait        bool
+}
+
+func (f *transferFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.amount, "amount", "", "amount in wei, or with an ether/gwei suffix (e.g. 1.5ether)")
+	fs.StringVar(&f.to, "to", "", "recipient on the other chain (default: sender)")
+	fs.UintVar(&f.minGasLimit, "min-gas-limit", 200000, "gas limit for the message on the other chain")
+	fs.StringVar(&f.extraData, "extra-data", "", "hex extra data passed along with the message")
+	fs.BoolVar(&f.wait, "wait", true, "wait for the receipt")
+}
+
+func (f *transferFlags) args() (bridge.TransferArgs, error) {
+	amount, err := parseAmount(f.amount)
+	if err != nil {
+		return bridge.TransferArgs{}, usageErrorf("--amount: %s", err)
+	}
+	var to common.Address
+	if f.to != "" {
+		if !common.IsHexAddress(f.to) {
+			return bridge.TransferArgs{}, usageErrorf("--to: invalid address %q", f.to)
+		}
+		to = common.HexToAddress(f.to)
+	}
+	if f.minGasLimit > uint(^uint32(0)) {
+		return bridge.TransferArgs{}, usageErrorf("--min-gas-limit: %d does not fit uint32", f.minGasLimit)
+	}
+	return bridge.TransferArgs{
+		To:          to,
+		Amount:      amount,
+		MinGasLimit: uint32(f.minGasLimit),
+		ExtraData:   common.FromHex(f.extraData),
+	}, nil
+}
+
+// withdrawalFlags pick the withdrawals of an L2 tx.
+type withdrawalFlags struct {
+	tx       string
+	logIndex int
+	all      bool
+}
+
+// register adds --tx and --log-index, and --all if the command can act on
+// every withdrawal of the tx.
+func (f *withdrawalFlags) register(fs *flag.FlagSet, all bool) {
+	fs.StringVar(&f.tx, "tx", "", "L2 withdrawal tx hash")
+	fs.IntVar(&f.logIndex, "log-index", -1, "log index of the MessagePassed event, for a tx that started several withdrawals")
+	if all {
+		fs.BoolVar(&f.all, "all", false, "every withdrawal the tx started")
+	}
+}
+
+// parse returns the tx hash and the log index, nil if not given.
+func (f *withdrawalFlags) parse() (common.Hash, *uint, error) {
+	txHash, err := parseTxHash(f.tx)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	if f.logIndex < 0 {
+		return txHash, nil, nil
+	}
+	if f.all {
+		return common.Hash{}, nil, usageErrorf("give either --log-index or --all")
+	}
+	logIndex := uint(f.logIndex)
+	return txHash, &logIndex, nil
+}
+
+var units = []struct {
+	suffix string
+	wei    int64
+}{
+	{"ether", params.Ether},
+	{"eth", params.Ether},
+	{"gwei", params.GWei},
+	{"wei", params.Wei},
+}
+
+// parseAmount parses "1000", "1000wei", "2gwei" or "1.5ether" into wei.
+func parseAmount(s string) (*big.Int, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" {
+		return nil, errors.New("amount is required")
+	}
+	unit := big.NewInt(params.Wei)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			unit = big.NewInt(u.wei)
+			break
+		}
+	}
+	amount, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	amount.Mul(amount, new(big.Rat).SetInt(unit))
+	if !amount.IsInt() {
+		return nil, fmt.Errorf("amount %q is not a whole number of wei", s)
+	}
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("amount %q must be positive", s)
+	}
+	return amount.Num(), nil
+}
+
+func parseTxHash(s string) (common.Hash, error) {
+	b := common.FromHex(s)
+	if len(b) != common.HashLength {
+		return common.Hash{}, usageErrorf("invalid tx hash %q", s)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// report prints the tx hash and, if asked to, waits for a successful receipt
+// with the confirmations and timeout given on the command line.
+func (e *cliEnv) report(ctx context.Context, backend txutils.ReceiptBackend, tx *types.Transaction, wait bool) error {
+	if e.recorder != nil {
+		fmt.Printf("built unsigned tx with nonce %d\n", tx.Nonce())
+		return nil
+	}
+	fmt.Printf("tx hash is %s\n", tx.Hash().Hex())
+	if !wait {
+		return nil
+	}
+	receipt, err := txutils.WaitForReceipt(ctx, backend, tx.Hash(), txutils.WaitOpts{
+		Confirmations: e.confirmations,
+		Timeout:       e.receiptTimeout,
+		Log:           txutils.NewStdLogger(nil),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("tx mined in block %d, gas used %d\n", receipt.BlockNumber, receipt.GasUsed)
+	return nil
+}
+
+// reportAll reports every tx sent for the withdrawals of an L2 tx, then the
+// error that stopped sending the rest if there was one.
+func (e *cliEnv) reportAll(ctx context.Context, backend txutils.ReceiptBackend, txs []*types.Transaction, sendErr error, wait bool) error {
+	for _, tx := range txs {
+		if err := e.report(ctx, backend, tx, wait); err != nil {
+			return err
+		}
+	}
+	return sendErr
+}
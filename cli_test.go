@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/config"
+)
+
+func Test_parseAmount(t *testing.T) {
+	ast := assert.New(t)
+	cases := map[string]string{
+		"1000":       "1000",
+		"1000wei":    "1000",
+		"2gwei":      "2000000000",
+		"1.5ether":   "1500000000000000000",
+		"0.001 eth":  "1000000000000000",
+		"1.5E+1gwei": "15000000000",
+	}
+	for in, want := range cases {
+		got, err := parseAmount(in)
+		if ast.NoError(err, in) {
+			expected, _ := new(big.Int).SetString(want, 10)
+			ast.Equal(expected, got, in)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "0", "-1", "1.5", "0.1gwei1"} {
+		_, err := parseAmount(in)
+		ast.Error(err, in)
+	}
+}
+
+func Test_setChainID(t *testing.T) {
+	ast := assert.New(t)
+	p, ok := config.Builtin(config.DefaultProfile)
+	require.True(t, ok)
+	ast.Zero(p.L1.ChainID, "the local profile sets none")
+
+	ast.NoError(setChainID(p, "l1", 0, &p.L1.ChainID))
+	ast.Zero(p.L1.ChainID)
+	ast.NoError(setChainID(p, "l1", 900, &p.L1.ChainID))
+	ast.Equal(uint64(900), p.L1.ChainID)
+	ast.NoError(setChainID(p, "l1", 900, &p.L1.ChainID))
+	ast.ErrorContains(setChainID(p, "l1", 1, &p.L1.ChainID), "profile \"local\" sets 900")
+}
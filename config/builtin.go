@@ -0,0 +1,58 @@
+package config
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultProfile is used when no profile is selected.
+const DefaultProfile = "local"
+
+var builtins = map[string]*Profile{
+	// local devnet started from the mantle v2 repo (make devnet-up)
+	"local": {
+		Name: "local",
+		L1:   ChainConfig{RPC: "http://localhost:8545"},
+		L2:   ChainConfig{RPC: "http://localhost:9545"},
+		Addresses: Addresses{
+			L1StandardBridge:       common.HexToAddress("0xDc64a140Aa3E981100a9becA4E685f962f0cF6C9"),
+			L1OptimismPortal:       common.HexToAddress("0xa513E6E4b8f2a923D98304ec87F64353C4D5C853"),
+			L2OutputOracleProxy:    common.HexToAddress("0x5FC8d32690cc91D4c39d9d3abcBD16989F875707"),
+			L1CrossDomainMessenger: common.HexToAddress("0x0165878A594ca255338adfa4d48449f69242Eb8F"),
+			L1MantleToken:          common.HexToAddress("0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512"),
+
+			L2StandardBridge:             common.HexToAddress("0x4200000000000000000000000000000000000010"),
+			L2CrossDomainMessenger:       common.HexToAddress("0x4200000000000000000000000000000000000007"),
+			L2ToL1MessagePasser:          common.HexToAddress("0x4200000000000000000000000000000000000016"),
+			L2ETH:                        common.HexToAddress("0xdEAddEaDdeadDEadDEADDEAddEADDEAddead1111"), //"0x4200000000000000000000000000000000000006"
+			LegacyERC20MNT:               common.HexToAddress("0xDeadDeAddeAddEAddeadDEaDDEAdDeaDDeAD0000"),
+			OptimismMintableERC20Factory: common.HexToAddress("0x4200000000000000000000000000000000000012"),
+		},
+		Tokens: map[string]Token{
+			"WWQT": {
+				L1: common.HexToAddress("0xfaeEBf311d135C7918430542e612b77033c4CA14"),
+				L2: common.HexToAddress("0x7c6b91D9Be155A6Db01f749217d76fF02A7227F2"),
+			},
+		},
+	},
+}
+
+// Builtin returns a copy of the named built-in profile.
+func Builtin(name string) (*Profile, bool) {
+	p, ok := builtins[name]
+	if !ok {
+		return nil, false
+	}
+	return p.Clone(), true
+}
+
+// BuiltinNames lists the built-in profiles in alphabetical order.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	EnvProfile     = "RDE_PROFILE"
+	EnvProfileFile = "RDE_PROFILE_FILE"
+
+	EnvL1RPC     = "RDE_L1_RPC"
+	EnvL2RPC     = "RDE_L2_RPC"
+	EnvL1ChainID = "RDE_L1_CHAIN_ID"
+	EnvL2ChainID = "RDE_L2_CHAIN_ID"
+)
+
+// addressEnv maps every overridable address to its environment variable.
+func addressEnv(a *Addresses) map[string]*common.Address {
+	return map[string]*common.Address{
+		"RDE_L1_STANDARD_BRIDGE":        &a.L1StandardBridge,
+		"RDE_L1_OPTIMISM_PORTAL":        &a.L1OptimismPortal,
+		"RDE_L2_OUTPUT_ORACLE":          &a.L2OutputOracleProxy,
+		"RDE_L1_CROSS_DOMAIN_MESSENGER": &a.L1CrossDomainMessenger,
+		"RDE_L1_MANTLE_TOKEN":           &a.L1MantleToken,
+		"RDE_L2_STANDARD_BRIDGE":        &a.L2StandardBridge,
+		"RDE_L2_CROSS_DOMAIN_MESSENGER": &a.L2CrossDomainMessenger,
+		"RDE_L2_TO_L1_MESSAGE_PASSER":   &a.L2ToL1MessagePasser,
+		"RDE_L2_ETH":                    &a.L2ETH,
+		"RDE_LEGACY_ERC20_MNT":          &a.LegacyERC20MNT,
+		"RDE_ERC20_FACTORY":             &a.OptimismMintableERC20Factory,
+	}
+}
+
+// ApplyEnv overrides profile fields with the RDE_* environment variables.
+func ApplyEnv(p *Profile) error {
+	if v := os.Getenv(EnvL1RPC); v != "" {
+		p.L1.RPC = v
+	}
+	if v := os.Getenv(EnvL2RPC); v != "" {
+		p.L2.RPC = v
+	}
+	if err := chainIDFromEnv(EnvL1ChainID, &p.L1.ChainID); err != nil {
+		return err
+	}
+	if err := chainIDFromEnv(EnvL2ChainID, &p.L2.ChainID); err != nil {
+		return err
+	}
+	for name, addr := range addressEnv(&p.Addresses) {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		if !common.IsHexAddress(v) {
+			return fmt.Errorf("%s is not a valid address: %q", name, v)
+		}
+		*addr = common.HexToAddress(v)
+	}
+	return nil
+}
+
+func chainIDFromEnv(name string, dst *uint64) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	id, err := strconv.ParseUint(v, 0, 64)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid chain id: %w", name, err)
+	}
+	*dst = id
+	return nil
+}
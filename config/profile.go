@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChainConfig describes how to reach one side of the bridge.
+type ChainConfig struct {
+	RPC string `json:"rpc"`
+	// ChainID is the expected chain id, 0 means "ask the node".
+	ChainID uint64 `json:"chainId"`
+}
+
+// Addresses holds every contract address the tooling talks to.
+type Addresses struct {
+	// L1
+	L1StandardBridge       common.Address `json:"l1StandardBridge"` // proxy addr
+	L1OptimismPortal       common.Address `json:"l1OptimismPortal"`
+	L2OutputOracleProxy    common.Address `json:"l2OutputOracleProxy"`
+	L1CrossDomainMessenger common.Address `json:"l1CrossDomainMessenger"`
+	L1MantleToken          common.Address `json:"l1MantleToken"` // proxy addr
+
+	// L2
+	L2StandardBridge             common.Address `json:"l2StandardBridge"`
+	L2CrossDomainMessenger       common.Address `json:"l2CrossDomainMessenger"`
+	L2ToL1MessagePasser          common.Address `json:"l2ToL1MessagePasser"`
+	L2ETH                        common.Address `json:"l2Eth"` // BVM_ETH (WETH9) on L2
+	LegacyERC20MNT               common.Address `json:"legacyErc20Mnt"`
+	OptimismMintableERC20Factory common.Address `json:"optimismMintableErc20Factory"`
+}
+
+// Token is an ERC20 pair bridged through the standard bridges.
+type Token struct {
+	L1 common.Address `json:"l1"`
+	L2 common.Address `json:"l2"`
+}
+
+// Profile is a complete description of one L1/L2 network pair.
+type Profile struct {
+	Name      string           `json:"name"`
+	L1        ChainConfig      `json:"l1"`
+	L2        ChainConfig      `json:"l2"`
+	Addresses Addresses        `json:"addresses"`
+	Tokens    map[string]Token `json:"tokens,omitempty"`
+}
+
+// Token looks up a bridged ERC20 by its symbol, case-insensitively.
+func (p *Profile) Token(symbol string) (Token, error) {
+	for name, tk := range p.Tokens {
+		if strings.EqualFold(name, symbol) {
+			return tk, nil
+		}
+	}
+	return Token{}, fmt.Errorf("token %q is not configured in profile %q", symbol, p.Name)
+}
+
+// Validate checks that the profile can be used to reach both chains.
+func (p *Profile) Validate() error {
+	if p.L1.RPC == "" {
+		return errors.New("l1 rpc url is empty")
+	}
+	if p.L2.RPC == "" {
+		return errors.New("l2 rpc url is empty")
+	}
+	required := map[string]common.Address{
+		"l1StandardBridge":    p.Addresses.L1StandardBridge,
+		"l1OptimismPortal":    p.Addresses.L1OptimismPortal,
+		"l2OutputOracleProxy": p.Addresses.L2OutputOracleProxy,
+		"l2StandardBridge":    p.Addresses.L2StandardBridge,
+		"l2ToL1MessagePasser": p.Addresses.L2ToL1MessagePasser,
+	}
+	for name, addr := range required {
+		if addr == (common.Address{}) {
+			return fmt.Errorf("address %s is not set", name)
+		}
+	}
+	return nil
+}
+
+// Clone returns a deep copy so callers can override fields without
+// touching the built-in profiles.
+func (p *Profile) Clone() *Profile {
+	cp := *p
+	cp.Tokens = make(map[string]Token, len(p.Tokens))
+	for k, v := range p.Tokens {
+		cp.Tokens[k] = v
+	}
+	return &cp
+}
+
+// Load resolves a profile. If path is not empty the profile is read from that
+// JSON file, otherwise the built-in profile called name is used. Environment
+// overrides are applied on top in both cases.
+func Load(name, path string) (*Profile, error) {
+	var p *Profile
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read profile file: %w", err)
+		}
+		p = &Profile{}
+		if err := json.Unmarshal(raw, p); err != nil {
+			return nil, fmt.Errorf("failed to parse profile file %s: %w", path, err)
+		}
+		if p.Name == "" {
+			p.Name = path
+		}
+	} else {
+		if name == "" {
+			name = DefaultProfile
+		}
+		builtin, ok := builtins[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+		p = builtin.Clone()
+	}
+
+	if err := ApplyEnv(p); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid profile %q: %w", p.Name, err)
+	}
+	return p, nil
+}
+
+// FromEnv loads the profile selected by RDE_PROFILE / RDE_PROFILE_FILE.
+func FromEnv() (*Profile, error) {
+	return Load(os.Getenv(EnvProfile), os.Getenv(EnvProfileFile))
+}
+
+// MustFromEnv is like FromEnv but panics on error. It is meant for tests.
+func MustFromEnv() *Profile {
+	p, err := FromEnv()
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
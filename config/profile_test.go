@@ -0,0 +1,65 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadBuiltin(t *testing.T) {
+	ast := assert.New(t)
+	p, err := Load("", "")
+	require.NoError(t, err)
+	ast.Equal(DefaultProfile, p.Name)
+	ast.Equal("http://localhost:8545", p.L1.RPC)
+	ast.Equal(common.HexToAddress("0x4200000000000000000000000000000000000016"), p.Addresses.L2ToL1MessagePasser)
+
+	_, err = Load("no-such-profile", "")
+	ast.Error(err)
+}
+
+func Test_LoadEnvOverrides(t *testing.T) {
+	ast := assert.New(t)
+	t.Setenv(EnvL2RPC, "http://staging:9545")
+	t.Setenv(EnvL1ChainID, "0x5")
+	t.Setenv("RDE_L1_OPTIMISM_PORTAL", "0x0000000000000000000000000000000000000abc")
+
+	p, err := Load("local", "")
+	require.NoError(t, err)
+	ast.Equal("http://staging:9545", p.L2.RPC)
+	ast.Equal(uint64(5), p.L1.ChainID)
+	ast.Equal(common.HexToAddress("0xabc"), p.Addresses.L1OptimismPortal)
+
+	// the built-in profile must not be modified by overrides
+	b, _ := Builtin("local")
+	ast.Equal("http://localhost:9545", b.L2.RPC)
+
+	t.Setenv("RDE_L1_OPTIMISM_PORTAL", "not-an-address")
+	_, err = Load("local", "")
+	ast.Error(err)
+}
+
+func Test_LoadFile(t *testing.T) {
+	ast := assert.New(t)
+	b, _ := Builtin("local")
+	b.Name = "staging"
+	b.L1.RPC = "http://staging:8545"
+	raw, err := json.Marshal(b)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "staging.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o644))
+
+	p, err := Load("", path)
+	require.NoError(t, err)
+	ast.Equal("staging", p.Name)
+	ast.Equal("http://staging:8545", p.L1.RPC)
+	tk, err := p.Token("wwqt")
+	ast.NoError(err)
+	ast.Equal(b.Tokens["WWQT"], tk)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/bridge"
+	"try_rde/config"
+)
+
+// tokenFlags selects an ERC20 pair either by profile symbol or by address.
+type tokenFlags struct {
+	symbol  string
+	l1Token string
+	l2Token string
+}
+
+func (f *tokenFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.symbol, "token", "", "token symbol from the profile")
+	fs.StringVar(&f.l1Token, "l1-token", "", "L1 token address (instead of --token)")
+	fs.StringVar(&f.l2Token, "l2-token", "", "L2 token address (instead of --token)")
+}
+
+func (f *tokenFlags) resolve(p *config.Profile) (config.Token, error) {
+	if f.symbol != "" {
+		return p.Token(f.symbol)
+	}
+	if !common.IsHexAddress(f.l1Token) || !common.IsHexAddress(f.l2Token) {
+		return config.Token{}, usageErrorf("use --token or both --l1-token and --l2-token")
+	}
+	return config.Token{L1: common.HexToAddress(f.l1Token), L2: common.HexToAddress(f.l2Token)}, nil
+}
+
+func checkAsset(asset string) error {
+	switch asset {
+	case "eth", "mnt", "erc20":
+		return nil
+	}
+	return usageErrorf("unknown asset %q, use eth, mnt or erc20", asset)
+}
+
+func runDeposit(ctx context.Context, env *cliEnv, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("missing asset, use eth, mnt or erc20")
+	}
+	asset := args[0]
+	if err := checkAsset(asset); err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("deposit "+asset, flag.ContinueOnError)
+	var tf transferFlags
+	var tk tokenFlags
+	tf.register(fs)
+	follow := fs.Bool("follow", true, "with --wait, also wait for the deposit to run on L2")
+	var permitFile, pullDir string
+	switch asset {
+	case "mnt":
+		fs.StringVar(&permitFile, "permit", "", "permit file of the holder, written by the permit command: the sponsor pulls their MNT and deposits it to them, it is in the sponsor's account until the deposit is mined; run again to resume an interrupted one")
+		fs.StringVar(&pullDir, "state-dir", ".rde/permits", "with --permit, directory where the pulled MNT is kept track of until it is deposited")
+	case "erc20":
+		tk.register(fs)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	transfer, err := tf.args()
+	if err != nil {
+		return err
+	}
+	if permitFile != "" && env.recorder != nil {
+		return usageErrorf("--permit deposits can not be built offline, each step must be mined before the next")
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tx *types.Transaction
+	switch asset {
+	case "eth":
+		tx, err = c.DepositETH(ctx, s, transfer)
+	case "mnt":
+		if permitFile == "" {
+			tx, err = c.DepositMNT(ctx, s, transfer)
+			break
+		}
+		p, rerr := readPermit(permitFile)
+		if rerr != nil {
+			return rerr
+		}
+		pulls, rerr := bridge.NewPermitPullDir(pullDir)
+		if rerr != nil {
+			return rerr
+		}
+		tx, err = c.DepositMNTWithPermit(ctx, s, p, transfer, pulls)
+	case "erc20":
+		token, rerr := tk.resolve(c.Profile)
+		if rerr != nil {
+			return rerr
+		}
+		tx, err = c.DepositERC20(ctx, s, token.L1, token.L2, transfer)
+	}
+	if err != nil {
+		return err
+	}
+	if err := env.report(ctx, c.L1, tx, tf.wait); err != nil || !tf.wait || !*follow || env.recorder != nil {
+		return err
+	}
+	return env.followDeposits(ctx, c, tx.Hash())
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"try_rde/history"
+)
+
+func runHistory(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	account := fs.String("account", "", "address to list (default the signer)")
+	dbPath := fs.String("db", ".rde/index", "directory of the event index")
+	sync := fs.Bool("sync", true, "bring the index up to the head first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var addr common.Address
+	switch {
+	case *account == "":
+		s, err := env.signer()
+		if err != nil {
+			return err
+		}
+		addr = s.Address()
+	case common.IsHexAddress(*account):
+		addr = common.HexToAddress(*account)
+	default:
+		return usageErrorf("invalid --account %q", *account)
+	}
+
+	ix, err := env.openIndex(ctx, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer ix.DB.Close()
+	if *sync {
+		if err := ix.Sync(ctx); err != nil {
+			return err
+		}
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	transfers, err := history.ForAccount(ctx, c, ix.DB, addr)
+	if err != nil {
+		return err
+	}
+	for _, t := range transfers {
+		fmt.Printf("%s %-10s %-5s %s %s\n", t.InitiatedAt.Format(time.RFC3339), t.Direction, t.Asset, t.Amount, t.Step)
+		fmt.Printf("  l1 tx: %s\n", txOrDash(t.L1TxHash))
+		fmt.Printf("  l2 tx: %s\n", txOrDash(t.L2TxHash))
+		if t.Direction == history.Withdrawal {
+			fmt.Printf("  prove tx: %s\n", txOrDash(t.ProveTxHash))
+		}
+		if !t.CompletedAt.IsZero() {
+			fmt.Printf("  completed: %s\n", t.CompletedAt.Format(time.RFC3339))
+		}
+	}
+	if len(transfers) == 0 {
+		fmt.Printf("no transfers of %s\n", addr.Hex())
+	}
+	return nil
+}
+
+func txOrDash(h common.Hash) string {
+	if h == (common.Hash{}) {
+		return "-"
+	}
+	return h.Hex()
+}
@@ -0,0 +1,368 @@
+// Package history lists the deposits and withdrawals an account started on
+// either chain, built from the bridge and portal events of the indexer.
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"try_rde/bridge"
+	"try_rde/indexer"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+// Direction tells deposits and withdrawals apart.
+type Direction string
+
+const (
+	Deposit    Direction = "deposit"
+	Withdrawal Direction = "withdrawal"
+)
+
+// Steps of a deposit. Withdrawals use the names of withdrawal.State.
+const (
+	StepDepositPending = "pending-on-l2"
+	StepDepositRelayed = "relayed"
+	StepDepositFailed  = "failed-on-l2"
+)
+
+// Transfer is one deposit or withdrawal started by the account.
+type Transfer struct {
+	Direction Direction
+	Asset     bridge.Asset
+	L1Token   common.Address // zero for ETH
+	L2Token   common.Address
+	From      common.Address
+	To        common.Address
+	Amount    *big.Int
+
+	// Deposits: the L1 bridge tx and the L2 deposit tx derived from it.
+	// Withdrawals: the L2 bridge tx and the L1 finalize tx, zero until
+	// finalized.
+	L1TxHash common.Hash
+	L2TxHash common.Hash
+	// ProveTxHash is the L1 prove tx of a withdrawal, zero until proven.
+	ProveTxHash    common.Hash
+	WithdrawalHash common.Hash
+
+	// Step is the current lifecycle step, see StepDeposit* and
+	// withdrawal.State.
+	Step string
+
+	InitiatedAt time.Time
+	ProvenAt    time.Time // withdrawals only
+	// CompletedAt is when the deposit ran on L2 or the withdrawal was
+	// finalized on L1, zero before that.
+	CompletedAt time.Time
+}
+
+// ForAccount lists the transfers started by account that are in the index,
+// oldest first. Sync the indexer first to include the latest ones.
+func ForAccount(ctx context.Context, c *bridge.Client, db ethdb.KeyValueStore, account common.Address) ([]*Transfer, error) {
+	h := &builder{
+		ctx:     ctx,
+		c:       c,
+		db:      db,
+		l1Times: make(map[uint64]time.Time),
+		l2Times: make(map[uint64]time.Time),
+	}
+	deposits, err := h.deposits(account)
+	if err != nil {
+		return nil, err
+	}
+	withdrawals, err := h.withdrawals(account)
+	if err != nil {
+		return nil, err
+	}
+	transfers := append(deposits, withdrawals...)
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return transfers[i].InitiatedAt.Before(transfers[j].InitiatedAt)
+	})
+	return transfers, nil
+}
+
+type builder struct {
+	ctx context.Context
+	c   *bridge.Client
+	db  ethdb.KeyValueStore
+
+	// block timestamps by number
+	l1Times map[uint64]time.Time
+	l2Times map[uint64]time.Time
+}
+
+func (h *builder) blockTime(backend bridge.Backend, cache map[uint64]time.Time, number uint64) (time.Time, error) {
+	if t, ok := cache[number]; ok {
+		return t, nil
+	}
+	header, err := backend.HeaderByNumber(h.ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get header %d: %w", number, err)
+	}
+	cache[number] = time.Unix(int64(header.Time), 0)
+	return cache[number], nil
+}
+
+// sameTx returns the events of the source and name emitted by the tx, in
+// log order.
+func (h *builder) sameTx(txHash common.Hash, source, name string) ([]*indexer.Event, error) {
+	events, err := indexer.EventsByTx(h.db, txHash)
+	if err != nil {
+		return nil, err
+	}
+	var matching []*indexer.Event
+	for _, ev := range events {
+		if ev.Source == source && ev.Name == name {
+			matching = append(matching, ev)
+		}
+	}
+	return matching, nil
+}
+
+// pair returns the position of ev among the events of the same kind in its
+// tx, so the n-th bridge event of a tx can be matched with the n-th portal or
+// passer event of it.
+func (h *builder) pair(ev *indexer.Event) (int, error) {
+	siblings, err := h.sameTx(ev.Log.TxHash, ev.Source, ev.Name)
+	if err != nil {
+		return 0, err
+	}
+	for i, s := range siblings {
+		if s.Log.Index == ev.Log.Index {
+			return i, nil
+		}
+	}
+	return 0, errors.New("event is missing from the tx index")
+}
+
+func (h *builder) deposits(account common.Address) ([]*Transfer, error) {
+	events, err := indexer.Events(h.db, indexer.SourceL1Bridge, 0, ^uint64(0))
+	if err != nil {
+		return nil, err
+	}
+	filterer := &h.c.L1Bridge.L1StandardBridgeFilterer
+	addrs := h.c.Profile.Addresses
+
+	var transfers []*Transfer
+	for _, ev := range events {
+		t := &Transfer{Direction: Deposit, L1TxHash: ev.Log.TxHash}
+		switch ev.Name {
+		case "ETHDepositInitiated":
+			e, err := filterer.ParseETHDepositInitiated(ev.Log)
+			if err != nil {
+				return nil, err
+			}
+			t.Asset, t.L2Token, t.From, t.To, t.Amount = bridge.AssetETH, addrs.L2ETH, e.From, e.To, e.Amount
+		case "MNTDepositInitiated":
+			e, err := filterer.ParseMNTDepositInitiated(ev.Log)
+			if err != nil {
+				return nil, err
+			}
+			t.Asset, t.L1Token, t.L2Token, t.From, t.To, t.Amount = bridge.AssetMNT, addrs.L1MantleToken, addrs.LegacyERC20MNT, e.From, e.To, e.Amount
+		case "ERC20DepositInitiated":
+			e, err := filterer.ParseERC20DepositInitiated(ev.Log)
+			if err != nil {
+				return nil, err
+			}
+			t.Asset, t.L1Token, t.L2Token, t.From, t.To, t.Amount = bridge.AssetERC20, e.L1Token, e.L2Token, e.From, e.To, e.Amount
+		default:
+			continue
+		}
+		if t.From != account {
+			continue
+		}
+		if t.InitiatedAt, err = h.blockTime(h.c.L1, h.l1Times, ev.Log.BlockNumber); err != nil {
+			return nil, err
+		}
+		if err := h.followDeposit(t, ev); err != nil {
+			return nil, fmt.Errorf("deposit %s: %w", t.L1TxHash, err)
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, nil
+}
+
+// followDeposit finds the L2 deposit tx of the bridge event and whether it
+// ran yet.
+func (h *builder) followDeposit(t *Transfer, ev *indexer.Event) error {
+	t.Step = StepDepositPending
+	n, err := h.pair(ev)
+	if err != nil {
+		return err
+	}
+	deposited, err := h.sameTx(ev.Log.TxHash, indexer.SourcePortal, "TransactionDeposited")
+	if err != nil {
+		return err
+	}
+	if n >= len(deposited) {
+		// the portal is indexed separately and may lag behind the bridge
+		return nil
+	}
+	parsed, err := h.c.Portal.ParseTransactionDeposited(deposited[n].Log)
+	if err != nil {
+		return err
+	}
+	dep, err := txutils.UnmarshalDepositEvent(parsed)
+	if err != nil {
+		return err
+	}
+	t.L2TxHash = dep.Hash()
+
+	receipt, err := h.c.L2.TransactionReceipt(h.ctx, t.L2TxHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get l2 receipt: %w", err)
+	}
+	t.Step = StepDepositRelayed
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Step = StepDepositFailed
+	}
+	t.CompletedAt, err = h.blockTime(h.c.L2, h.l2Times, receipt.BlockNumber.Uint64())
+	return err
+}
+
+func (h *builder) withdrawals(account common.Address) ([]*Transfer, error) {
+	events, err := indexer.Events(h.db, indexer.SourceL2Bridge, 0, ^uint64(0))
+	if err != nil {
+		return nil, err
+	}
+	var initiated []*indexer.Event
+	var transfers []*Transfer
+	addrs := h.c.Profile.Addresses
+	for _, ev := range events {
+		if ev.Name != "WithdrawalInitiated" {
+			continue
+		}
+		e, err := h.c.L2Bridge.ParseWithdrawalInitiated(ev.Log)
+		if err != nil {
+			return nil, err
+		}
+		if e.From != account {
+			continue
+		}
+		t := &Transfer{
+			Direction: Withdrawal,
+			Asset:     bridge.AssetERC20,
+			L1Token:   e.L1Token,
+			L2Token:   e.L2Token,
+			From:      e.From,
+			To:        e.To,
+			Amount:    e.Amount,
+			L2TxHash:  ev.Log.TxHash,
+		}
+		switch e.L2Token {
+		case addrs.L2ETH:
+			t.Asset = bridge.AssetETH
+		case addrs.LegacyERC20MNT:
+			t.Asset = bridge.AssetMNT
+		}
+		if t.InitiatedAt, err = h.blockTime(h.c.L2, h.l2Times, ev.Log.BlockNumber); err != nil {
+			return nil, err
+		}
+		initiated = append(initiated, ev)
+		transfers = append(transfers, t)
+	}
+	if len(transfers) == 0 {
+		return nil, nil
+	}
+
+	// the L1 side is looked up by withdrawal hash
+	proven, finalized, err := h.portalEvents()
+	if err != nil {
+		return nil, err
+	}
+	opts := &bind.CallOpts{Context: h.ctx}
+	latest, err := h.c.Oracle.LatestBlockNumber(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest output block: %w", err)
+	}
+	period, err := h.c.Oracle.FINALIZATIONPERIODSECONDS(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get finalization period: %w", err)
+	}
+	head, err := h.c.L1.HeaderByNumber(h.ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get l1 head: %w", err)
+	}
+
+	for i, t := range transfers {
+		ev := initiated[i]
+		facts := &withdrawal.Facts{
+			L2BlockNumber:      ev.Log.BlockNumber,
+			LatestOutputBlock:  latest.Uint64(),
+			FinalizationPeriod: period.Uint64(),
+			L1Time:             head.Time,
+		}
+		n, err := h.pair(ev)
+		if err != nil {
+			return nil, err
+		}
+		passed, err := h.sameTx(ev.Log.TxHash, indexer.SourceMessagePasser, "MessagePassed")
+		if err != nil {
+			return nil, err
+		}
+		if n >= len(passed) {
+			// the passer is indexed separately and may lag behind the bridge
+			t.Step = withdrawal.StateInitiated.String()
+			continue
+		}
+		msg, err := h.c.MessagePasser.ParseMessagePassed(passed[n].Log)
+		if err != nil {
+			return nil, err
+		}
+		t.WithdrawalHash = msg.WithdrawalHash
+		facts.Sent = true
+		if p, ok := proven[t.WithdrawalHash]; ok {
+			t.ProveTxHash = p.TxHash
+			if t.ProvenAt, err = h.blockTime(h.c.L1, h.l1Times, p.BlockNumber); err != nil {
+				return nil, err
+			}
+			facts.ProvenTimestamp = uint64(t.ProvenAt.Unix())
+		}
+		if f, ok := finalized[t.WithdrawalHash]; ok {
+			t.L1TxHash = f.TxHash
+			if t.CompletedAt, err = h.blockTime(h.c.L1, h.l1Times, f.BlockNumber); err != nil {
+				return nil, err
+			}
+			facts.Finalized = true
+		}
+		t.Step = facts.State().String()
+	}
+	return transfers, nil
+}
+
+// portalEvents maps withdrawal hashes to their latest WithdrawalProven and
+// WithdrawalFinalized logs.
+func (h *builder) portalEvents() (map[common.Hash]types.Log, map[common.Hash]types.Log, error) {
+	events, err := indexer.Events(h.db, indexer.SourcePortal, 0, ^uint64(0))
+	if err != nil {
+		return nil, nil, err
+	}
+	proven := make(map[common.Hash]types.Log)
+	finalized := make(map[common.Hash]types.Log)
+	for _, ev := range events {
+		if len(ev.Log.Topics) < 2 {
+			continue
+		}
+		// the withdrawal hash is the first indexed argument of both
+		switch ev.Name {
+		case "WithdrawalProven":
+			proven[ev.Log.Topics[1]] = ev.Log
+		case "WithdrawalFinalized":
+			finalized[ev.Log.Topics[1]] = ev.Log
+		}
+	}
+	return proven, finalized, nil
+}
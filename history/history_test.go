@@ -0,0 +1,113 @@
+package history
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/indexer"
+	"try_rde/signer"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+func Test_ForAccount(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	ether := big.NewInt(params.Ether)
+	newAccount := func() signer.Signer {
+		s := bridgetest.Account(t, n)
+		n.L1.MintToken(n.Profile.Addresses.L1MantleToken, s.Address(), new(big.Int).Mul(ether, big.NewInt(10)))
+		return s
+	}
+	mined := func(backend txutils.ReceiptBackend, tx *types.Transaction, err error) common.Hash {
+		require.NoError(t, err)
+		_, err = txutils.WaitForReceipt(ctx, backend, tx.Hash(), txutils.WaitOpts{})
+		require.NoError(t, err)
+		return tx.Hash()
+	}
+	s, other := newAccount(), newAccount()
+	n.FundPortal(new(big.Int).Mul(ether, big.NewInt(10)))
+
+	tx, err := c.DepositETH(ctx, s, bridge.TransferArgs{Amount: big.NewInt(3)})
+	ethDeposit := mined(c.L1, tx, err)
+	tx, err = c.DepositMNT(ctx, s, bridge.TransferArgs{Amount: big.NewInt(4)})
+	mntDeposit := mined(c.L1, tx, err)
+	tx, err = c.DepositETH(ctx, other, bridge.TransferArgs{Amount: big.NewInt(5)})
+	mined(c.L1, tx, err)
+
+	tx, err = c.WithdrawETH(ctx, s, bridge.TransferArgs{Amount: big.NewInt(1)})
+	ethWithdrawal := mined(c.L2, tx, err)
+	require.NoError(t, n.ProposeOutput())
+	tx, err = c.ProveWithdrawal(ctx, s, ethWithdrawal)
+	prove := mined(c.L1, tx, err)
+	tx, err = c.WithdrawMNT(ctx, s, bridge.TransferArgs{Amount: big.NewInt(2)})
+	mntWithdrawal := mined(c.L2, tx, err)
+
+	db := memorydb.New()
+	sources, err := indexer.Sources(c)
+	require.NoError(t, err)
+	ix := indexer.New(db, sources)
+	sync := func() []*Transfer {
+		require.NoError(t, ix.Sync(ctx))
+		transfers, err := ForAccount(ctx, c, db, s.Address())
+		require.NoError(t, err)
+		return transfers
+	}
+
+	transfers := sync()
+	require.Len(t, transfers, 4)
+	byTx := make(map[common.Hash]*Transfer)
+	for _, tr := range transfers {
+		ast.Equal(s.Address(), tr.From)
+		ast.False(tr.InitiatedAt.IsZero())
+		if tr.Direction == Deposit {
+			byTx[tr.L1TxHash] = tr
+		} else {
+			byTx[tr.L2TxHash] = tr
+		}
+	}
+
+	dep := byTx[ethDeposit]
+	require.NotNil(t, dep)
+	ast.Equal(bridge.AssetETH, dep.Asset)
+	ast.Equal(big.NewInt(3), dep.Amount)
+	ast.Equal(StepDepositRelayed, dep.Step)
+	deposits, err := c.Deposits(ctx, ethDeposit)
+	require.NoError(t, err)
+	ast.Equal(deposits[0].L2TxHash, dep.L2TxHash)
+	ast.False(dep.CompletedAt.IsZero())
+	ast.Equal(bridge.AssetMNT, byTx[mntDeposit].Asset)
+
+	wd := byTx[ethWithdrawal]
+	require.NotNil(t, wd)
+	ast.Equal(bridge.AssetETH, wd.Asset)
+	ast.Equal(big.NewInt(1), wd.Amount)
+	ast.Equal(withdrawal.StateInChallengeWindow.String(), wd.Step)
+	ast.Equal(prove, wd.ProveTxHash)
+	ast.NotEqual(common.Hash{}, wd.WithdrawalHash)
+	ast.Equal(common.Hash{}, wd.L1TxHash)
+
+	ast.Equal(bridge.AssetMNT, byTx[mntWithdrawal].Asset)
+	ast.Equal(withdrawal.StateWaitingForOutput.String(), byTx[mntWithdrawal].Step)
+
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	tx, err = c.FinalizeWithdrawal(ctx, s, ethWithdrawal)
+	finalize := mined(c.L1, tx, err)
+	for _, tr := range sync() {
+		if tr.L2TxHash == ethWithdrawal {
+			ast.Equal(withdrawal.StateFinalized.String(), tr.Step)
+			ast.Equal(finalize, tr.L1TxHash)
+			ast.False(tr.CompletedAt.Before(tr.ProvenAt))
+		}
+	}
+}
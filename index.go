@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"try_rde/indexer"
+	"try_rde/txutils"
+)
+
+func runIndex(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("index", flag.ContinueOnError)
+	dbPath := fs.String("db", ".rde/index", "directory of the event index")
+	pageSize := fs.Uint64("page-size", indexer.DefaultPageSize, "blocks fetched per eth_getLogs call")
+	poll := fs.Duration("poll", indexer.DefaultPollInterval, "how often to check for new blocks")
+	once := fs.Bool("once", false, "sync up to the head and exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *pageSize == 0 {
+		return usageErrorf("--page-size must be positive")
+	}
+	ix, err := env.openIndex(ctx, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer ix.DB.Close()
+	ix.PageSize = *pageSize
+	ix.PollInterval = *poll
+	ix.Log = txutils.NewStdLogger(nil)
+	if *once {
+		if err := ix.Sync(ctx); err != nil {
+			return err
+		}
+		for _, src := range ix.Sources {
+			block, _, err := indexer.Checkpoint(ix.DB, src.Name)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%-24s %d\n", src.Name, block)
+		}
+		return nil
+	}
+	fmt.Printf("%s indexing into %s\n", time.Now().Format(time.RFC3339), *dbPath)
+	if err := ix.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// openIndex opens the event index at path with the bridge contracts of the
+// profile as sources. The caller closes ix.DB.
+func (e *cliEnv) openIndex(ctx context.Context, path string) (*indexer.Indexer, error) {
+	c, err := e.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := indexer.Sources(c)
+	if err != nil {
+		return nil, err
+	}
+	db, err := indexer.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	ix := indexer.New(db, sources)
+	ix.Confirmations = e.confirmations
+	return ix, nil
+}
@@ -0,0 +1,315 @@
+// Package indexer ingests the bridge events of both chains into an embedded
+// key-value store, page by page, and keeps a checkpoint per contract so a
+// restarted indexer resumes where it stopped instead of scanning from genesis.
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"try_rde/abistr/abijson"
+	"try_rde/bridge"
+	"try_rde/txutils"
+)
+
+const (
+	// DefaultPageSize is the number of blocks fetched per eth_getLogs call.
+	DefaultPageSize = 1000
+	// DefaultPollInterval is how long Run waits for new blocks once it caught
+	// up with the head.
+	DefaultPollInterval = 12 * time.Second
+)
+
+// Names of the sources returned by Sources.
+const (
+	SourceL1Bridge      = "l1.L1StandardBridge"
+	SourcePortal        = "l1.OptimismPortal"
+	SourceOracle        = "l1.L2OutputOracle"
+	SourceL2Bridge      = "l2.L2StandardBridge"
+	SourceMessagePasser = "l2.L2ToL1MessagePasser"
+)
+
+// Backend is what the indexer needs from a chain node.
+type Backend interface {
+	ethereum.LogFilterer
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Source is one contract whose events are indexed under its own checkpoint.
+type Source struct {
+	// Name keys the checkpoint and the events of the source in the store, it
+	// must not change once the source was indexed.
+	Name    string
+	Backend Backend
+	Address common.Address
+	ABI     *abi.ABI
+	// Events are the names of the ABI events to index.
+	Events []string
+	// StartBlock is where the first sync starts, e.g. the deployment block of
+	// the contract.
+	StartBlock uint64
+}
+
+// NewSource looks up the events in the ABI of the binding metadata.
+func NewSource(name string, backend Backend, address common.Address, meta interface {
+	GetAbi() (*abi.ABI, error)
+}, events ...string) (*Source, error) {
+	parsed, err := meta.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	for _, ev := range events {
+		if _, ok := parsed.Events[ev]; !ok {
+			return nil, fmt.Errorf("abi has no event %s", ev)
+		}
+	}
+	return &Source{Name: name, Backend: backend, Address: address, ABI: parsed, Events: events}, nil
+}
+
+// Sources returns the bridge contracts of the client with all events the
+// abijson filterers expose for deposits, withdrawals and outputs.
+func Sources(c *bridge.Client) ([]*Source, error) {
+	addrs := c.Profile.Addresses
+	specs := []struct {
+		name    string
+		backend Backend
+		address common.Address
+		meta    interface{ GetAbi() (*abi.ABI, error) }
+		events  []string
+	}{
+		{SourceL1Bridge, c.L1, addrs.L1StandardBridge, abijson.L1StandardBridgeMetaData,
+			[]string{"ETHDepositInitiated", "ERC20DepositInitiated", "MNTDepositInitiated"}},
+		{SourcePortal, c.L1, addrs.L1OptimismPortal, abijson.L1OptimismPortalMetaData,
+			[]string{"TransactionDeposited", "WithdrawalProven", "WithdrawalFinalized"}},
+		{SourceOracle, c.L1, addrs.L2OutputOracleProxy, abijson.L2OutputOracleProxyMetaData,
+			[]string{"OutputProposed", "OutputsDeleted"}},
+		{SourceL2Bridge, c.L2, addrs.L2StandardBridge, abijson.L2StandardBridgeMetaData,
+			[]string{"WithdrawalInitiated"}},
+		{SourceMessagePasser, c.L2, addrs.L2ToL1MessagePasser, abijson.L2ToL1MessagePasserMetaData,
+			[]string{"MessagePassed"}},
+	}
+	sources := make([]*Source, 0, len(specs))
+	for _, spec := range specs {
+		src, err := NewSource(spec.name, spec.backend, spec.address, spec.meta, spec.events...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", spec.name, err)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// Indexer copies the events of its sources into DB.
+type Indexer struct {
+	DB      ethdb.KeyValueStore
+	Sources []*Source
+	// PageSize is the largest block range of one eth_getLogs call. A page the
+	// node refuses is split in halves until it is accepted.
+	PageSize uint64
+	// Confirmations keeps the indexer this many blocks behind the head, so
+	// reorgs near the tip are rarely indexed at all. The ones that are get
+	// rolled back: every sync first checks the hashes of the last indexed
+	// blocks and deletes the events above the fork.
+	Confirmations uint64
+	PollInterval  time.Duration
+	Log           txutils.Logger
+}
+
+// New creates an indexer with the default page size and poll interval.
+func New(db ethdb.KeyValueStore, sources []*Source) *Indexer {
+	return &Indexer{
+		DB:           db,
+		Sources:      sources,
+		PageSize:     DefaultPageSize,
+		PollInterval: DefaultPollInterval,
+		Log:          txutils.NopLogger,
+	}
+}
+
+// Run syncs until ctx is done, polling for new blocks in between. Failed
+// syncs are logged and retried on the next poll.
+func (ix *Indexer) Run(ctx context.Context) error {
+	for {
+		if err := ix.Sync(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			ix.logger().Logf("index sync failed, retrying: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(ix.pollInterval()):
+		}
+	}
+}
+
+// Sync indexes every source from its checkpoint up to the confirmed head.
+func (ix *Indexer) Sync(ctx context.Context) error {
+	for _, src := range ix.Sources {
+		if err := ix.syncSource(ctx, src); err != nil {
+			return fmt.Errorf("%s: %w", src.Name, err)
+		}
+	}
+	return nil
+}
+
+func (ix *Indexer) syncSource(ctx context.Context, src *Source) error {
+	header, err := src.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get head: %w", err)
+	}
+	if header.Number.Uint64() < ix.Confirmations {
+		return nil
+	}
+	head := header.Number.Uint64() - ix.Confirmations
+
+	from := src.StartBlock
+	last, ok, err := Checkpoint(ix.DB, src.Name)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if last, ok, err = ix.rewind(ctx, src, last); err != nil {
+			return err
+		}
+	}
+	if ok {
+		from = last + 1
+	}
+
+	names := make(map[common.Hash]string, len(src.Events))
+	topics := make([]common.Hash, 0, len(src.Events))
+	for _, name := range src.Events {
+		id := src.ABI.Events[name].ID
+		names[id] = name
+		topics = append(topics, id)
+	}
+
+	pageSize := ix.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	for from <= head {
+		to := from + pageSize - 1
+		if to > head {
+			to = head
+		}
+		logs, err := src.Backend.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{src.Address},
+			Topics:    [][]common.Hash{topics},
+		})
+		if err != nil {
+			if to > from && ctx.Err() == nil {
+				// too many results or too wide a range for the node, retry
+				// with half the page
+				pageSize = (to - from + 1) / 2
+				continue
+			}
+			return fmt.Errorf("failed to get logs of blocks %d-%d: %w", from, to, err)
+		}
+
+		batch := ix.DB.NewBatch()
+		for _, l := range logs {
+			if l.Removed || len(l.Topics) == 0 {
+				continue
+			}
+			name, ok := names[l.Topics[0]]
+			if !ok {
+				continue
+			}
+			if err := putEvent(batch, &Event{Source: src.Name, Name: name, Log: l}); err != nil {
+				return err
+			}
+		}
+		end, err := src.Backend.HeaderByNumber(ctx, new(big.Int).SetUint64(to))
+		if err != nil {
+			return fmt.Errorf("failed to get header %d: %w", to, err)
+		}
+		if err := putBlockHash(batch, src.Name, to, end.Hash()); err != nil {
+			return err
+		}
+		if err := putCheckpoint(batch, src.Name, to); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return fmt.Errorf("failed to write blocks %d-%d: %w", from, to, err)
+		}
+		if err := pruneBlockHashes(ix.DB, src.Name); err != nil {
+			return err
+		}
+		ix.logger().Logf("indexed %s blocks %d-%d, %d events", src.Name, from, to, len(logs))
+		from = to + 1
+	}
+	return nil
+}
+
+// rewind checks the kept hashes of the source against the chain, newest
+// first. If the chain reorged, it deletes the events above the newest block
+// still on the chain and returns that block as the checkpoint, or false if
+// the reorg is deeper than the kept hashes and the source is indexed again.
+// Stores written before hashes were kept are trusted as they are.
+func (ix *Indexer) rewind(ctx context.Context, src *Source, last uint64) (uint64, bool, error) {
+	hashes, err := blockHashes(ix.DB, src.Name)
+	if err != nil || len(hashes) == 0 {
+		return last, err == nil, err
+	}
+	keep, ok := uint64(0), false
+	for i := len(hashes) - 1; i >= 0 && !ok; i-- {
+		header, err := src.Backend.HeaderByNumber(ctx, new(big.Int).SetUint64(hashes[i].number))
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to get header %d: %w", hashes[i].number, err)
+		}
+		if header.Hash() == hashes[i].hash {
+			keep, ok = hashes[i].number, true
+		}
+	}
+	if ok && keep == last {
+		return last, true, nil
+	}
+
+	from := src.StartBlock
+	if ok {
+		from = keep + 1
+	}
+	batch := ix.DB.NewBatch()
+	if err := deleteFrom(ix.DB, batch, src.Name, from); err != nil {
+		return 0, false, err
+	}
+	if ok {
+		err = putCheckpoint(batch, src.Name, keep)
+	} else {
+		err = batch.Delete(checkpointKey(src.Name))
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if err := batch.Write(); err != nil {
+		return 0, false, fmt.Errorf("failed to roll back reorged blocks: %w", err)
+	}
+	ix.logger().Logf("reorg: rolled %s back to block %d", src.Name, from)
+	return keep, ok, nil
+}
+
+func (ix *Indexer) logger() txutils.Logger {
+	if ix.Log == nil {
+		return txutils.NopLogger
+	}
+	return ix.Log
+}
+
+func (ix *Indexer) pollInterval() time.Duration {
+	if ix.PollInterval <= 0 {
+		return DefaultPollInterval
+	}
+	return ix.PollInterval
+}
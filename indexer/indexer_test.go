@@ -0,0 +1,261 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/txutils"
+)
+
+// recordingBackend remembers the ranges the indexer asked for and refuses
+// ranges wider than maxRange blocks, if set.
+type recordingBackend struct {
+	Backend
+	maxRange uint64
+	queries  []ethereum.FilterQuery
+}
+
+func (b *recordingBackend) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	b.queries = append(b.queries, q)
+	if b.maxRange > 0 && q.ToBlock.Uint64()-q.FromBlock.Uint64()+1 > b.maxRange {
+		return nil, errors.New("block range too large")
+	}
+	return b.Backend.FilterLogs(ctx, q)
+}
+
+func countByName(t *testing.T, db ethdb.KeyValueStore, source string) map[string]int {
+	events, err := Events(db, source, 0, ^uint64(0))
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	for _, ev := range events {
+		counts[ev.Name]++
+	}
+	return counts
+}
+
+func Test_SyncAndResume(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	amount := big.NewInt(params.Ether)
+	mined := func(backend txutils.ReceiptBackend, tx *types.Transaction, err error) *types.Receipt {
+		require.NoError(t, err)
+		receipt, err := txutils.WaitForReceipt(ctx, backend, tx.Hash(), txutils.WaitOpts{})
+		require.NoError(t, err)
+		return receipt
+	}
+
+	tx, err := c.DepositETH(ctx, s, bridge.TransferArgs{Amount: amount})
+	mined(c.L1, tx, err)
+	tx, err = c.WithdrawETH(ctx, s, bridge.TransferArgs{Amount: amount})
+	withdrawal := mined(c.L2, tx, err).TxHash
+	require.NoError(t, n.ProposeOutput())
+	tx, err = c.ProveWithdrawal(ctx, s, withdrawal)
+	mined(c.L1, tx, err)
+
+	db := memorydb.New()
+	sources, err := Sources(c)
+	require.NoError(t, err)
+	ix := New(db, sources)
+	ix.PageSize = 2
+	require.NoError(t, ix.Sync(ctx))
+
+	ast.Equal(map[string]int{"ETHDepositInitiated": 1}, countByName(t, db, SourceL1Bridge))
+	ast.Equal(map[string]int{"TransactionDeposited": 1, "WithdrawalProven": 1}, countByName(t, db, SourcePortal))
+	ast.Equal(map[string]int{"OutputProposed": 1}, countByName(t, db, SourceOracle))
+	ast.Equal(map[string]int{"WithdrawalInitiated": 1}, countByName(t, db, SourceL2Bridge))
+	ast.Equal(map[string]int{"MessagePassed": 1}, countByName(t, db, SourceMessagePasser))
+
+	events, err := EventsByTx(db, withdrawal)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	var names []string
+	for _, ev := range events {
+		names = append(names, ev.Name)
+		if ev.Name != "MessagePassed" {
+			continue
+		}
+		passed, err := c.MessagePasser.ParseMessagePassed(ev.Log)
+		require.NoError(t, err)
+		ast.Equal(n.Profile.Addresses.L2StandardBridge, passed.Sender)
+		ast.Equal(amount, passed.EthValue)
+	}
+	ast.ElementsMatch([]string{"MessagePassed", "WithdrawalInitiated"}, names)
+
+	l1Head, err := n.L1.BlockNumber(ctx)
+	require.NoError(t, err)
+	cp, ok, err := Checkpoint(db, SourcePortal)
+	require.NoError(t, err)
+	ast.True(ok)
+	ast.Equal(l1Head, cp)
+
+	// a new indexer on the same store starts after the checkpoint
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	tx, err = c.FinalizeWithdrawal(ctx, s, withdrawal)
+	mined(c.L1, tx, err)
+	sources, err = Sources(c)
+	require.NoError(t, err)
+	rec := &recordingBackend{Backend: c.L1}
+	for _, src := range sources {
+		if src.Name == SourcePortal {
+			src.Backend = rec
+		}
+	}
+	require.NoError(t, New(db, sources).Sync(ctx))
+	require.NotEmpty(t, rec.queries)
+	ast.Equal(cp+1, rec.queries[0].FromBlock.Uint64())
+	ast.Equal(map[string]int{"TransactionDeposited": 1, "WithdrawalProven": 1, "WithdrawalFinalized": 1}, countByName(t, db, SourcePortal))
+	ast.Equal(map[string]int{"MessagePassed": 1}, countByName(t, db, SourceMessagePasser), "nothing is indexed twice")
+}
+
+func Test_ConfirmationsAndRanges(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	for i := 0; i < 3; i++ {
+		n.L2.Mine()
+		require.NoError(t, n.ProposeOutput())
+	}
+	head, err := n.L1.BlockNumber(ctx)
+	require.NoError(t, err)
+
+	db := memorydb.New()
+	sources, err := Sources(c)
+	require.NoError(t, err)
+	ix := New(db, sources)
+	ix.Confirmations = 1
+	require.NoError(t, ix.Sync(ctx))
+	cp, _, err := Checkpoint(db, SourceOracle)
+	require.NoError(t, err)
+	ast.Equal(head-1, cp)
+	ast.Len(countByName(t, db, SourceOracle), 1)
+
+	events, err := Events(db, SourceOracle, 0, head)
+	require.NoError(t, err)
+	ast.Len(events, 2, "the newest output is not confirmed yet")
+	first := events[0].Log.BlockNumber
+	events, err = Events(db, SourceOracle, first+1, head)
+	require.NoError(t, err)
+	ast.Len(events, 1)
+}
+
+func Test_SplitRefusedPages(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	for i := 0; i < 3; i++ {
+		n.L2.Mine()
+		require.NoError(t, n.ProposeOutput())
+	}
+
+	sources, err := Sources(c)
+	require.NoError(t, err)
+	rec := &recordingBackend{Backend: c.L1, maxRange: 2}
+	var oracle []*Source
+	for _, src := range sources {
+		if src.Name == SourceOracle {
+			src.Backend = rec
+			oracle = append(oracle, src)
+		}
+	}
+	db := memorydb.New()
+	require.NoError(t, New(db, oracle).Sync(ctx))
+	ast.Equal(map[string]int{"OutputProposed": 3}, countByName(t, db, SourceOracle))
+	ast.Greater(len(rec.queries), 1)
+	for _, q := range rec.queries[1:] {
+		ast.LessOrEqual(q.ToBlock.Uint64()-q.FromBlock.Uint64()+1, uint64(2))
+	}
+
+	rec.maxRange = 0
+	rec.Backend = failingBackend{Backend: c.L1}
+	require.NoError(t, New(db, oracle).Sync(ctx), "nothing new, no logs are requested")
+	n.L1.Mine()
+	ast.Error(New(db, oracle).Sync(ctx))
+}
+
+// reorgedBackend reports other hashes for the blocks from fork on, as if
+// they were replaced by a reorg.
+type reorgedBackend struct {
+	Backend
+	fork uint64
+}
+
+func (b reorgedBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	header, err := b.Backend.HeaderByNumber(ctx, number)
+	if err != nil || header.Number.Uint64() < b.fork {
+		return header, err
+	}
+	header = types.CopyHeader(header)
+	header.Extra = []byte("reorged")
+	return header, nil
+}
+
+func Test_RollBackReorgedBlocks(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	sources, err := Sources(c)
+	require.NoError(t, err)
+	var oracle []*Source
+	for _, src := range sources {
+		if src.Name == SourceOracle {
+			oracle = append(oracle, src)
+		}
+	}
+	db := memorydb.New()
+	ix := New(db, oracle)
+	ix.PageSize = 1
+	for i := 0; i < 3; i++ {
+		n.L2.Mine()
+		require.NoError(t, n.ProposeOutput())
+		require.NoError(t, ix.Sync(ctx))
+	}
+	head, err := n.L1.BlockNumber(ctx)
+	require.NoError(t, err)
+	events, err := Events(db, SourceOracle, 0, head)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	// the block of the last output is reorged, a stale event of it stays
+	// in the store until the indexer notices
+	fork := events[2].Log.BlockNumber
+	stale := &Event{Source: SourceOracle, Name: "OutputProposed", Log: events[2].Log}
+	stale.Log.Index, stale.Log.TxHash = 7, common.Hash{7}
+	batch := db.NewBatch()
+	require.NoError(t, putEvent(batch, stale))
+	require.NoError(t, batch.Write())
+	oracle[0].Backend = reorgedBackend{Backend: c.L1, fork: fork}
+	require.NoError(t, ix.Sync(ctx))
+
+	ast.Equal(map[string]int{"OutputProposed": 3}, countByName(t, db, SourceOracle))
+	byTx, err := EventsByTx(db, stale.Log.TxHash)
+	require.NoError(t, err)
+	ast.Empty(byTx)
+	cp, _, err := Checkpoint(db, SourceOracle)
+	require.NoError(t, err)
+	ast.Equal(head, cp)
+
+	// deeper than every kept hash, the source is indexed again
+	oracle[0].Backend = reorgedBackend{Backend: c.L1, fork: 0}
+	require.NoError(t, ix.Sync(ctx))
+	ast.Equal(map[string]int{"OutputProposed": 3}, countByName(t, db, SourceOracle))
+}
+
+type failingBackend struct{ Backend }
+
+func (failingBackend) FilterLogs(context.Context, ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, errors.New("node down")
+}
@@ -0,0 +1,242 @@
+package indexer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/leveldb"
+)
+
+// Key layout of the store:
+//
+//	cp/<source>                          -> last indexed block, 8 bytes big endian
+//	bh/<source>/<block 8>                -> hash of a recently indexed page end
+//	ev/<source>/<block 8><log index 4>   -> json Event
+//	tx/<tx hash 32><log index 4>         -> key of the event
+var (
+	checkpointPrefix = []byte("cp/")
+	blockHashPrefix  = []byte("bh/")
+	eventPrefix      = []byte("ev/")
+	txPrefix         = []byte("tx/")
+)
+
+// keptBlockHashes is how many page end hashes are kept per source to find
+// where a reorg forked off.
+const keptBlockHashes = 128
+
+// Event is an indexed log together with the source and event name it was
+// ingested as. Decode it with the Parse method of the abijson binding, e.g.
+// L2ToL1MessagePasserFilterer.ParseMessagePassed(ev.Log).
+type Event struct {
+	Source string    `json:"source"`
+	Name   string    `json:"name"`
+	Log    types.Log `json:"log"`
+}
+
+// Open opens the leveldb store at path, creating it if needed.
+func Open(path string) (ethdb.KeyValueStore, error) {
+	db, err := leveldb.New(path, 16, 16, "rde/indexer", false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index %s: %w", path, err)
+	}
+	return db, nil
+}
+
+func checkpointKey(source string) []byte {
+	return append(append([]byte{}, checkpointPrefix...), source...)
+}
+
+func blockHashSourcePrefix(source string) []byte {
+	key := append(append([]byte{}, blockHashPrefix...), source...)
+	return append(key, '/')
+}
+
+func eventSourcePrefix(source string) []byte {
+	key := append(append([]byte{}, eventPrefix...), source...)
+	return append(key, '/')
+}
+
+func eventKey(source string, blockNumber uint64, logIndex uint) []byte {
+	key := eventSourcePrefix(source)
+	key = binary.BigEndian.AppendUint64(key, blockNumber)
+	return binary.BigEndian.AppendUint32(key, uint32(logIndex))
+}
+
+func txKey(txHash common.Hash, logIndex uint) []byte {
+	key := append(append([]byte{}, txPrefix...), txHash[:]...)
+	return binary.BigEndian.AppendUint32(key, uint32(logIndex))
+}
+
+// putEvent adds the event and its tx index entry to the batch.
+func putEvent(b ethdb.Batch, ev *Event) error {
+	enc, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	key := eventKey(ev.Source, ev.Log.BlockNumber, ev.Log.Index)
+	if err := b.Put(key, enc); err != nil {
+		return err
+	}
+	return b.Put(txKey(ev.Log.TxHash, ev.Log.Index), key)
+}
+
+func putCheckpoint(b ethdb.KeyValueWriter, source string, blockNumber uint64) error {
+	return b.Put(checkpointKey(source), binary.BigEndian.AppendUint64(nil, blockNumber))
+}
+
+// blockHash is the hash an indexed block had when it was indexed.
+type blockHash struct {
+	number uint64
+	hash   common.Hash
+}
+
+// putBlockHash adds the hash of a page end to the batch.
+func putBlockHash(b ethdb.KeyValueWriter, source string, number uint64, hash common.Hash) error {
+	key := binary.BigEndian.AppendUint64(blockHashSourcePrefix(source), number)
+	return b.Put(key, hash[:])
+}
+
+// blockHashes returns the kept hashes of the source, oldest first.
+func blockHashes(db ethdb.Iteratee, source string) ([]blockHash, error) {
+	prefix := blockHashSourcePrefix(source)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+	var hashes []blockHash
+	for it.Next() {
+		if len(it.Key()) != len(prefix)+8 || len(it.Value()) != common.HashLength {
+			return nil, errors.New("corrupt block hash")
+		}
+		hashes = append(hashes, blockHash{
+			number: binary.BigEndian.Uint64(it.Key()[len(prefix):]),
+			hash:   common.BytesToHash(it.Value()),
+		})
+	}
+	return hashes, it.Error()
+}
+
+// pruneBlockHashes deletes all but the newest keptBlockHashes hashes of the
+// source.
+func pruneBlockHashes(db ethdb.KeyValueStore, source string) error {
+	hashes, err := blockHashes(db, source)
+	if err != nil || len(hashes) <= keptBlockHashes {
+		return err
+	}
+	b := db.NewBatch()
+	for _, h := range hashes[:len(hashes)-keptBlockHashes] {
+		if err := b.Delete(binary.BigEndian.AppendUint64(blockHashSourcePrefix(source), h.number)); err != nil {
+			return err
+		}
+	}
+	return b.Write()
+}
+
+// deleteFrom adds the deletion of the events and block hashes of the source
+// in blocks from and above to the batch.
+func deleteFrom(db ethdb.Iteratee, b ethdb.Batch, source string, from uint64) error {
+	start := binary.BigEndian.AppendUint64(nil, from)
+	it := db.NewIterator(eventSourcePrefix(source), start)
+	defer it.Release()
+	for it.Next() {
+		ev, err := decodeEvent(it.Value())
+		if err != nil {
+			return err
+		}
+		if err := b.Delete(append([]byte{}, it.Key()...)); err != nil {
+			return err
+		}
+		if err := b.Delete(txKey(ev.Log.TxHash, ev.Log.Index)); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	hashes := db.NewIterator(blockHashSourcePrefix(source), start)
+	defer hashes.Release()
+	for hashes.Next() {
+		if err := b.Delete(append([]byte{}, hashes.Key()...)); err != nil {
+			return err
+		}
+	}
+	return hashes.Error()
+}
+
+// Checkpoint returns the last block indexed for the source, false if the
+// source was never indexed.
+func Checkpoint(db ethdb.KeyValueReader, source string) (uint64, bool, error) {
+	enc, err := db.Get(checkpointKey(source))
+	if err != nil {
+		if ok, _ := db.Has(checkpointKey(source)); !ok {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	if len(enc) != 8 {
+		return 0, false, errors.New("corrupt checkpoint")
+	}
+	return binary.BigEndian.Uint64(enc), true, nil
+}
+
+func decodeEvent(enc []byte) (*Event, error) {
+	var ev Event
+	if err := json.Unmarshal(enc, &ev); err != nil {
+		return nil, fmt.Errorf("corrupt event: %w", err)
+	}
+	return &ev, nil
+}
+
+// Events returns the events of the source in blocks [from, to], in chain
+// order.
+func Events(db ethdb.Iteratee, source string, from, to uint64) ([]*Event, error) {
+	var events []*Event
+	err := ForEachEvent(db, source, from, to, func(ev *Event) (bool, error) {
+		events = append(events, ev)
+		return true, nil
+	})
+	return events, err
+}
+
+// ForEachEvent calls fn with the events of the source in blocks [from, to],
+// in chain order, until fn returns false or an error.
+func ForEachEvent(db ethdb.Iteratee, source string, from, to uint64, fn func(ev *Event) (bool, error)) error {
+	it := db.NewIterator(eventSourcePrefix(source), binary.BigEndian.AppendUint64(nil, from))
+	defer it.Release()
+	for it.Next() {
+		ev, err := decodeEvent(it.Value())
+		if err != nil {
+			return err
+		}
+		if ev.Log.BlockNumber > to {
+			break
+		}
+		more, err := fn(ev)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// EventsByTx returns the indexed events emitted by a tx, of any source.
+func EventsByTx(db ethdb.KeyValueStore, txHash common.Hash) ([]*Event, error) {
+	it := db.NewIterator(append(append([]byte{}, txPrefix...), txHash[:]...), nil)
+	defer it.Release()
+	var events []*Event
+	for it.Next() {
+		enc, err := db.Get(it.Value())
+		if err != nil {
+			return nil, err
+		}
+		ev, err := decodeEvent(enc)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, it.Error()
+}
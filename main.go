@@ -2,96 +2,121 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"math/big"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"try_rde/abistr"
+	"try_rde/config"
+	"try_rde/signer"
+	"try_rde/txutils"
 )
 
+// exit codes
 const (
-	L1URL              = "http://localhost:8545"
-	L2URL              = "http://localhost:9545"
-	l1ContractAddr     = "0xDc64a140Aa3E981100a9becA4E685f962f0cF6C9" //proxy addr
-	l2ContractAddr     = "0x4200000000000000000000000000000000000010"
-	legacyERC20MNTAddr = "0xDeadDeAddeAddEAddeadDEaDDEAdDeaDDeAD0000"
-	WETH9Addr          = "0xdEAddEaDdeadDEadDEADDEAddEADDEAddead1111" //"0x4200000000000000000000000000000000000006"
-	//l2StandardBridgeAddr = "0xC0d3c0d3c0D3c0d3C0D3c0D3C0d3C0D3C0D30010"
-	L2_CROSS_DOMAIN_MESSENGER_AddrHex         = "0x4200000000000000000000000000000000000007"
-	Proxy__BVM_L1CrossDomainMessenger_AddrHex = "0x0165878A594ca255338adfa4d48449f69242Eb8F"
-	L2ToL1MessagePasser                       = "0x4200000000000000000000000000000000000016"
-	L1OptimismPortal                          = "0xa513E6E4b8f2a923D98304ec87F64353C4D5C853"
-	L2OutputOracleProxy                       = "0x5FC8d32690cc91D4c39d9d3abcBD16989F875707"
-
-	account1  = "0x784e50947Df23dBa8f91029089ef7B046257E544"
-	account4  = "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
-	account20 = "0x00000500E87eE83A1BFa233512af25a4003836C8" // Account20
-
-	account1SK  = "0d0c6dd2f25fc746bcec70aa27b31ec7fcd949ff5ec69dc58276d2d233f344c9"
-	account4SK  = "59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d"
-	account20SK = "dd888cfabd6d3c3eeb683063657706fb660416ec4972bb5761204e0dbf59e33c"
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
 )
 
-func main() {
-	l1Client, err := ethclient.Dial(L1URL)
-	if err != nil {
-		fmt.Printf("[err 00] %s\n", err.Error())
-	}
-	l1AccountAddress := common.HexToAddress(account4)
-	l1ContractAddress := common.HexToAddress(l1ContractAddr)
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, env *cliEnv, args []string) error
+}
 
-	// abi
-	l1ContractABI, err := abi.JSON(strings.NewReader(abistr.L1StandardBridgeABI))
-	if err != nil {
-		fmt.Printf("[err 0] %s\n", err.Error())
-	}
+var commands = []*command{
+	{name: "deposit", usage: "deposit eth|mnt|erc20 [flags]   bridge funds from L1 to L2", run: runDeposit},
+	{name: "permit", usage: "permit --spender <addr> [flags]  sign a permit of L1 MNT for a sponsored deposit", run: runPermit},
+	{name: "track", usage: "track --tx <l1 tx hash>         follow a deposit to its L2 execution", run: runTrack},
+	{name: "withdraw", usage: "withdraw eth|mnt|erc20 [flags]  start a withdrawal from L2 to L1", run: runWithdraw},
+	{name: "prove", usage: "prove --tx <l2 tx hash>         prove a withdrawal on L1", run: runProve},
+	{name: "finalize", usage: "finalize --tx <l2 tx hash>      finalize a proven withdrawal on L1", run: runFinalize},
+	{name: "bundle", usage: "bundle export|verify|submit     prove a withdrawal from a machine without L2 access", run: runBundle},
+	{name: "status", usage: "status --tx <l2 tx hash>        show the state of a withdrawal", run: runStatus},
+	{name: "resolve", usage: "resolve --hash <withdrawal>      find the L2 tx and state of a withdrawal", run: runResolve},
+	{name: "eta", usage: "eta --tx <l2 tx hash>           estimate when a withdrawal can be proven and finalized", run: runETA},
+	{name: "offline", usage: "offline build|sign|broadcast     sign the txs of a command on an air-gapped machine", run: runOffline},
+	{name: "complete", usage: "complete --tx <l2 tx hash>      prove and finalize a withdrawal, resumable", run: runComplete},
+	{name: "watch", usage: "watch [--state-dir dir]          re-prove withdrawals after outputs were deleted", run: runWatch},
+	{name: "relay", usage: "relay [--targets addrs]          prove and finalize everyone's withdrawals", run: runRelay},
+	{name: "index", usage: "index [--db dir] [--once]        index bridge events into a local store", run: runIndex},
+	{name: "history", usage: "history [--account <addr>]      list the deposits and withdrawals of an account", run: runHistory},
+	{name: "serve", usage: "serve [--listen addr]            serve the HTTP/JSON API and /metrics", run: runServe},
+}
 
-	//// approve
-	//amountToApprove := big.NewInt(10000000) //
-	//approveData, err := l1ContractABI.Pack("approve", l1ContractAddress, amountToApprove)
-	//if err != nil {
-	//	fmt.Printf("[err 0.5] %s\n", err.Error())
-	//}
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	// depositMNT args
-	amount := big.NewInt(10000000)
-	gasPrice, err := l1Client.SuggestGasPrice(context.Background())
-	if err != nil {
-		fmt.Printf("[err 1] %s\n", err.Error())
+func run(args []string) int {
+	fs := flag.NewFlagSet("rde", flag.ContinueOnError)
+	env := &cliEnv{}
+	fs.StringVar(&env.profileName, "profile", os.Getenv(config.EnvProfile), "built-in network profile")
+	fs.StringVar(&env.profileFile, "profile-file", os.Getenv(config.EnvProfileFile), "network profile JSON file")
+	fs.StringVar(&env.keystore, "keystore", "", "encrypted keystore file of the sender")
+	fs.StringVar(&env.passwordFile, "password-file", "", "file holding the keystore password (default $"+envKeystorePassword+")")
+	fs.StringVar(&env.mnemonicFile, "mnemonic-file", "", "file holding a BIP-39 mnemonic (default $"+envMnemonic+")")
+	fs.StringVar(&env.hdPath, "hd-path", signer.DefaultHDPath, "BIP-44 derivation path used with a mnemonic")
+	fs.StringVar(&env.keyEnv, "key-env", signer.EnvPrivateKey, "environment variable holding a raw hex key (devnets only)")
+	fs.Uint64Var(&env.confirmations, "confirmations", 1, "blocks a tx needs on top of it before it counts as mined")
+	fs.DurationVar(&env.receiptTimeout, "receipt-timeout", 10*time.Minute, "how long to wait for a receipt, 0 waits forever")
+	fs.Float64Var(&env.gasMargin, "gas-margin", txutils.DefaultGasMargin, "fraction added on top of every gas estimate, 0 adds none")
+	fs.BoolVar(&env.legacyFees, "legacy-fees", false, "price txs with eth_gasPrice instead of EIP-1559 fees")
+	fs.Usage = func() { printUsage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
 	}
-	gasLimit := uint32(2000000)
-	callData, err := l1ContractABI.Pack("depositMNT", big.NewInt(100), gasLimit, []byte{}) // approveData)
-	if err != nil {
-		fmt.Printf("[err 2] %s\n", err.Error())
+	if env.gasMargin < 0 {
+		fmt.Fprintf(os.Stderr, "--gas-margin must not be negative, got %v\n", env.gasMargin)
+		return exitUsage
 	}
-
-	pendingNonce, err := l1Client.PendingNonceAt(context.Background(), l1AccountAddress)
-	if err != nil {
-		fmt.Printf("[err 3] %s\n", err.Error())
+	if fs.NArg() == 0 {
+		printUsage(fs)
+		return exitUsage
 	}
-	tx := types.NewTransaction(pendingNonce, l1ContractAddress, amount, uint64(gasLimit), gasPrice, callData)
 
-	chainID, err := l1Client.ChainID(context.Background())
-	if err != nil {
-		fmt.Printf("[err 4] %s\n", err.Error())
+	var cmd *command
+	for _, c := range commands {
+		if c.name == fs.Arg(0) {
+			cmd = c
+		}
 	}
-	privKey, err := crypto.HexToECDSA(account4SK)
-	if err != nil {
-		fmt.Printf("[err 5] %s\n", err.Error())
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", fs.Arg(0))
+		printUsage(fs)
+		return exitUsage
 	}
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privKey)
-	if err != nil {
-		fmt.Printf("[err 6] %s\n", err.Error())
-	}
-	err = l1Client.SendTransaction(context.Background(), signedTx)
-	if err != nil {
-		fmt.Printf("[err 7] %s\n", err.Error())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer env.close()
+
+	err := cmd.run(ctx, env, fs.Args()[1:])
+	var usageErr *usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitUsage
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(os.Stderr, "rde %s: %s\n", cmd.name, err)
+		return exitUsage
+	default:
+		fmt.Fprintf(os.Stderr, "rde %s: %s\n", cmd.name, err)
+		return exitFailure
 	}
+}
 
-	fmt.Println("finish")
+func printUsage(fs *flag.FlagSet) {
+	fmt.Fprintln(os.Stderr, "usage: rde [global flags] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", c.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nglobal flags:")
+	fs.PrintDefaults()
 }
@@ -9,32 +9,49 @@ import (
 	"testing"
 	"time"
 
-	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
 	"github.com/ethereum/go-ethereum/params"
-	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"try_rde/abistr"
 	"try_rde/abistr/abijson"
+	"try_rde/bridge"
+	"try_rde/config"
+	"try_rde/history"
+	"try_rde/indexer"
+	"try_rde/rpcreplay"
+	"try_rde/signer"
 	"try_rde/txutils"
+	"try_rde/txutils/txtest"
 )
 
+var profile = config.MustFromEnv()
+
+const account20 = "0x00000500E87eE83A1BFa233512af25a4003836C8" // Account20
+
+// testSigner loads the sender from $RDE_PRIVATE_KEY, which is expected to
+// hold the key of account20 on the devnet.
+func testSigner(t *testing.T) signer.Signer {
+	s, err := signer.FromEnv(signer.EnvPrivateKey)
+	require.NoError(t, err)
+	return s
+}
+
 func Test_L1DepositEth_failed_pending(t *testing.T) {
 	ast := assert.New(t)
 
-	l1Client, err := ethclient.Dial(L1URL)
+	l1Client, err := ethclient.Dial(profile.L1.RPC)
 	if err != nil {
 		t.Fatalf("[err 00] %s\n", err.Error())
 	}
 	l1AccountAddress := common.HexToAddress(account20)
-	l1ContractAddress := common.HexToAddress(l1ContractAddr)
+	l1ContractAddress := profile.Addresses.L1StandardBridge
 
 	// abi
 	l1ContractABI, err := abi.JSON(strings.NewReader(abistr.L1StandardBridgeABI))
@@ -46,8 +63,9 @@ func Test_L1DepositEth_failed_pending(t *testing.T) {
 	//gasLimit := uint32(3000000) //uint32(2000000000)
 
 	//gas
-	//gasTipCap, gasFeeCap, estGasLimit, err := txutils.GetGas(l1Client, l1ContractABI, gasLimit, l1AccountAddress, l1ContractAddress, amount)
+	fees, err := txutils.GetGas(context.Background(), l1Client, &l1ContractABI, "depositETH", l1AccountAddress, l1ContractAddress, amount, uint32(100000), []byte{})
 	ast.NoError(err)
+	t.Logf("estimated gas is %d\n", fees.GasLimit)
 
 	callData, err := l1ContractABI.Pack("depositETH", uint32(100000), []byte{}) // approveData)
 	ast.NoError(err)
@@ -63,22 +81,15 @@ func Test_L1DepositEth_failed_pending(t *testing.T) {
 	tx := types.NewTx(&types.DynamicFeeTx{
 		ChainID:   chainID,
 		Nonce:     pendingNonce,
-		GasTipCap: big.NewInt(99),
-		GasFeeCap: big.NewInt(99),
-		Gas:       uint64(2500000),
+		GasTipCap: fees.GasTipCap,
+		GasFeeCap: fees.GasFeeCap,
+		Gas:       fees.GasLimit,
 		To:        &l1ContractAddress,
 		Value:     amount,
 		Data:      callData,
 	})
 
-	privKey, err := crypto.HexToECDSA(account20SK)
-	ast.NoError(err)
-
-	//signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privKey)
-	signer := types.LatestSignerForChainID(chainID)
-	signature, err := crypto.Sign(signer.Hash(tx).Bytes(), privKey)
-	ast.NoError(err)
-	signedTx, err := tx.WithSignature(signer, signature)
+	signedTx, err := testSigner(t).SignTx(tx, chainID)
 	ast.NoError(err)
 	t.Logf("tx hash is %s\n", signedTx.Hash().Hex())
 
@@ -90,34 +101,21 @@ func Test_L1DepositEth_failed_pending(t *testing.T) {
 
 func Test_L1DepositETHWithAbiInst_nogasPrice(t *testing.T) {
 	ast := assert.New(t)
-	cli, err := ethclient.Dial(L1URL)
+	cli, err := ethclient.Dial(profile.L1.RPC)
 	ast.NoError(err)
 
 	l1AccountAddress := common.HexToAddress(account20)
-	l1ContractAddress := common.HexToAddress(l1ContractAddr)
+	l1ContractAddress := profile.Addresses.L1StandardBridge
 	chainID, err := cli.ChainID(context.Background())
 	ast.NoError(err)
 
 	contract, err := abijson.NewL1StandardBridge(l1ContractAddress, cli)
 	ast.NoError(err)
 
-	header, err := cli.HeaderByNumber(context.Background(), nil)
-	ast.NoError(err)
+	s := testSigner(t)
 
-	privKey, err := crypto.HexToECDSA(account20SK)
-	ast.NoError(err)
-
-	gasTipCap, err := cli.SuggestGasTipCap(context.Background())
-	ast.NoError(err)
-
-	gasFeeCap := new(big.Int).Add(gasTipCap, new(big.Int).Mul(header.BaseFee, big.NewInt(2)))
+	opt := signer.TransactOpts(context.Background(), s, chainID)
 
-	opt, err := bind.NewKeyedTransactorWithChainID(privKey, chainID)
-	ast.NoError(err)
-
-	opt.GasFeeCap = gasFeeCap
-	opt.GasTipCap = gasTipCap
-	opt.GasLimit = uint64(2000000)
 	//opt.GasPrice = big.NewInt(100)
 	opt.Value = new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether)) //big.NewInt(200000)
 
@@ -127,6 +125,11 @@ func Test_L1DepositETHWithAbiInst_nogasPrice(t *testing.T) {
 	opt.Nonce = big.NewInt(int64(pendingNonce))
 
 	minGasLimit := uint32(200000)
+	l1ContractABI, err := abijson.L1StandardBridgeMetaData.GetAbi()
+	ast.NoError(err)
+	fees, err := txutils.GetGas(context.Background(), cli, l1ContractABI, "depositETH", l1AccountAddress, l1ContractAddress, opt.Value, minGasLimit, []byte{})
+	ast.NoError(err)
+	fees.Apply(opt)
 	tx, err := contract.DepositETH(opt, minGasLimit, []byte{})
 	ast.NoError(err)
 
@@ -142,11 +145,11 @@ func Test_L1DepositETHWithAbiInst_nogasPrice(t *testing.T) {
 
 func Test_L1DepositETHWithAbiInst_gasPrice(t *testing.T) {
 	ast := assert.New(t)
-	cli, err := ethclient.Dial(L1URL)
+	cli, err := ethclient.Dial(profile.L1.RPC)
 	ast.NoError(err)
 
 	l1AccountAddress := common.HexToAddress(account20)
-	l1ContractAddress := common.HexToAddress(l1ContractAddr)
+	l1ContractAddress := profile.Addresses.L1StandardBridge
 	chainID, err := cli.ChainID(context.Background())
 	ast.NoError(err)
 
@@ -156,21 +159,15 @@ func Test_L1DepositETHWithAbiInst_gasPrice(t *testing.T) {
 	//header, err := cli.HeaderByNumber(context.Background(), nil)
 	//ast.NoError(err)
 
-	privKey, err := crypto.HexToECDSA(account20SK)
-	ast.NoError(err)
+	s := testSigner(t)
 
 	//gasTipCap, err := cli.SuggestGasTipCap(context.Background())
 	//ast.NoError(err)
 
 	//gasFeeCap := new(big.Int).Add(gasTipCap, new(big.Int).Mul(header.BaseFee, big.NewInt(2)))
 
-	opt, err := bind.NewKeyedTransactorWithChainID(privKey, chainID)
-	ast.NoError(err)
+	opt := signer.TransactOpts(context.Background(), s, chainID)
 
-	//opt.GasFeeCap = gasFeeCap
-	//opt.GasTipCap = gasTipCap
-	opt.GasLimit = uint64(2500000)
-	opt.GasPrice = big.NewInt(99)
 	opt.Value = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether)) //big.NewInt(1000000000000000000)
 
 	pendingNonce, err := cli.PendingNonceAt(context.Background(), l1AccountAddress)
@@ -179,6 +176,11 @@ func Test_L1DepositETHWithAbiInst_gasPrice(t *testing.T) {
 	opt.Nonce = big.NewInt(int64(pendingNonce))
 
 	minGasLimit := uint32(100000)
+	l1ContractABI, err := abijson.L1StandardBridgeMetaData.GetAbi()
+	ast.NoError(err)
+	fees, err := txutils.GetGas(context.Background(), cli, l1ContractABI, "depositETH", l1AccountAddress, l1ContractAddress, opt.Value, minGasLimit, []byte{})
+	ast.NoError(err)
+	fees.Apply(opt)
 	tx, err := contract.DepositETH(opt, minGasLimit, []byte{})
 	ast.NoError(err)
 
@@ -198,13 +200,13 @@ func Test_L1DepositETHWithAbiInst_gasPrice(t *testing.T) {
 func Test_L1DepositMNT(t *testing.T) {
 	ast := assert.New(t)
 
-	l1Client, err := ethclient.Dial(L1URL)
+	l1Client, err := ethclient.Dial(profile.L1.RPC)
 	require.NoError(t, err)
 	if err != nil {
 		t.Logf("[err 00] %s\n", err.Error())
 	}
 	l1AccountAddress := common.HexToAddress(account20)
-	l1ContractAddress := common.HexToAddress(l1ContractAddr)
+	l1ContractAddress := profile.Addresses.L1StandardBridge
 
 	// abi
 	l1ContractABI, err := abi.JSON(strings.NewReader(abistr.L1StandardBridgeABI))
@@ -237,25 +239,21 @@ func Test_L1DepositMNT(t *testing.T) {
 		t.Logf("[err 4] %s\n", err.Error())
 	}
 
+	fees, err := txutils.GetGas(context.Background(), l1Client, &l1ContractABI, "depositMNT", l1AccountAddress, l1ContractAddress, nil, big.NewInt(100), gasLimit, []byte{})
+	ast.NoError(err)
+
 	tx := types.NewTx(&types.DynamicFeeTx{
 		ChainID:   chainID,
 		Nonce:     pendingNonce,
-		GasTipCap: big.NewInt(99),
-		GasFeeCap: big.NewInt(99),
-		Gas:       uint64(2500000),
+		GasTipCap: fees.GasTipCap,
+		GasFeeCap: fees.GasFeeCap,
+		Gas:       fees.GasLimit,
 		To:        &l1ContractAddress,
 		Value:     nil,
 		Data:      callData,
 	})
 
-	privKey, err := crypto.HexToECDSA(account20SK)
-	ast.NoError(err)
-
-	//signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privKey)
-	signer := types.LatestSignerForChainID(chainID)
-	signature, err := crypto.Sign(signer.Hash(tx).Bytes(), privKey)
-	ast.NoError(err)
-	signedTx, err := tx.WithSignature(signer, signature)
+	signedTx, err := testSigner(t).SignTx(tx, chainID)
 	ast.NoError(err)
 	t.Logf("tx hash is %s\n", signedTx.Hash().Hex())
 
@@ -266,63 +264,61 @@ func Test_L1DepositMNT(t *testing.T) {
 }
 func Test_L1DepositMNTWithAbiInst_succ(t *testing.T) {
 	ast := assert.New(t)
-	cli, err := ethclient.Dial(L1URL)
+	cli, err := ethclient.Dial(profile.L1.RPC)
 	ast.NoError(err)
 
 	l1AccountAddress := common.HexToAddress(account20)
-	l1ContractAddress := common.HexToAddress(l1ContractAddr)
+	l1ContractAddress := profile.Addresses.L1StandardBridge
 	chainID, err := cli.ChainID(context.Background())
 	ast.NoError(err)
-	privKey, err := crypto.HexToECDSA(account20SK)
-	ast.NoError(err)
+	s := testSigner(t)
+	ast.Equal(l1AccountAddress, s.Address())
 
 	contract, err := abijson.NewL1StandardBridge(l1ContractAddress, cli)
 	ast.NoError(err)
 
 	// approve
-	l1MNTokenAddr := common.HexToAddress("0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512") //proxy_l1MantleToken
-	l1MNTContract, err := abijson.NewL1MantleToken(l1MNTokenAddr, cli)                 // l1ContractAddress
-	ast.NoError(err)
-
-	opt, err := bind.NewKeyedTransactorWithChainID(privKey, chainID)
-	ast.NoError(err)
-	tx, err := l1MNTContract.Approve(opt, l1AccountAddress, big.NewInt(100000))
+	l1MNTokenAddr := profile.Addresses.L1MantleToken                   //proxy_l1MantleToken
+	l1MNTContract, err := abijson.NewL1MantleToken(l1MNTokenAddr, cli) // l1ContractAddress
 	ast.NoError(err)
 
-	signedTx, err := opt.Signer(l1AccountAddress, tx)
+	// approve and deposit share the nonce manager so neither asks the node
+	// for the pending nonce
+	nonces := txutils.NewNonceManager(cli)
+	opt := signer.TransactOpts(context.Background(), s, chainID)
+	tx, err := nonces.Transact(opt, func(opt *bind.TransactOpts) (*types.Transaction, error) {
+		return l1MNTContract.Approve(opt, l1ContractAddress, big.NewInt(100000))
+	})
 	ast.NoError(err)
-	err = cli.SendTransaction(context.Background(), signedTx)
+	t.Logf("approve tx.hash is %s\n", tx.Hash())
+	// the deposit can only be estimated once the allowance is in place
+	_, err = txutils.WaitForReceipt(context.Background(), cli, tx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
 	ast.NoError(err)
 
 	// deposit MNT
-	opt, err = bind.NewKeyedTransactorWithChainID(privKey, chainID)
-	ast.NoError(err)
-
+	opt = signer.TransactOpts(context.Background(), s, chainID)
 	opt.Value = big.NewInt(1000)
 
 	minGasLimit := uint32(200000)
-	tx, err = contract.DepositMNT(opt, big.NewInt(10000), minGasLimit, []byte{})
+	l1ContractABI, err := abijson.L1StandardBridgeMetaData.GetAbi()
 	ast.NoError(err)
-
-	t.Logf("tx is nil? %v\n", tx == nil)
-	signedTx, err = opt.Signer(l1AccountAddress, tx)
+	fees, err := txutils.GetGas(context.Background(), cli, l1ContractABI, "depositMNT", l1AccountAddress, l1ContractAddress, opt.Value, big.NewInt(10000), minGasLimit, []byte{})
 	ast.NoError(err)
-
-	err = cli.SendTransaction(context.Background(), signedTx)
+	fees.Apply(opt)
+	tx, err = nonces.Transact(opt, func(opt *bind.TransactOpts) (*types.Transaction, error) {
+		return contract.DepositMNT(opt, big.NewInt(10000), minGasLimit, []byte{})
+	})
 	ast.NoError(err)
 	t.Logf("tx.hash is %s\n", tx.Hash())
-	t.Logf("最后的err %s\n", err.Error())
 }
 
 func Test_L2Withdraw_0(t *testing.T) {
-	l2Client, err := ethclient.Dial(L2URL)
+	l2Client, err := ethclient.Dial(profile.L2.RPC)
 	if err != nil {
 		t.Logf("[err 00] %s\n", err.Error())
 	}
 	l2Account := account20
 	l2AccountAddress := common.HexToAddress(l2Account)
-	l2AccountSK := account20SK
-	l2AccountSKEcdsa, err := crypto.HexToECDSA(l2AccountSK)
 	l2ContractABI, err := abi.JSON(strings.NewReader(abistr.L1StandardBridgeABI))
 	chainID, err := l2Client.ChainID(context.Background())
 
@@ -331,8 +327,8 @@ func Test_L2Withdraw_0(t *testing.T) {
 	gasLimit := uint64(10000)
 	callData, err := l2ContractABI.Pack("withdraw", l2AccountAddress, uint32(gasLimit), []byte{})
 	gasPrice, err := l2Client.SuggestGasPrice(context.Background())
-	tx := types.NewTransaction(pendingNonce, common.HexToAddress(l2ContractAddr), amount, gasLimit, gasPrice, callData)
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), l2AccountSKEcdsa)
+	tx := types.NewTransaction(pendingNonce, profile.Addresses.L2StandardBridge, amount, gasLimit, gasPrice, callData)
+	signedTx, err := testSigner(t).SignTx(tx, chainID)
 
 	sendTxErr := l2Client.SendTransaction(context.Background(), signedTx)
 	require.NoError(t, sendTxErr, "sendTransaction should has no err")
@@ -340,11 +336,11 @@ func Test_L2Withdraw_0(t *testing.T) {
 
 func Test_L2WithdrawETH(t *testing.T) {
 	ast := assert.New(t)
-	l1cli, err := ethclient.Dial(L1URL)
-	l2cli, err := ethclient.Dial(L2URL)
+	l1cli, err := ethclient.Dial(profile.L1.RPC)
+	l2cli, err := ethclient.Dial(profile.L2.RPC)
 	ast.NoError(err)
-	//l1StandardBridge := common.HexToAddress(l1ContractAddr)
-	l2StandardBridge := common.HexToAddress(l2ContractAddr)
+	//l1StandardBridge := profile.Addresses.L1StandardBridge
+	l2StandardBridge := profile.Addresses.L2StandardBridge
 
 	//l1ChainID, err := l1cli.ChainID(context.Background())
 	ast.NoError(err)
@@ -354,8 +350,7 @@ func Test_L2WithdrawETH(t *testing.T) {
 	//l2TokenAddr := common.HexToAddress("")
 	//BVM_ETH_Addr := common.HexToAddress("0xdEAddEaDdeadDEadDEADDEAddEADDEAddead1111")
 	account20Addr := common.HexToAddress("0x00000500E87eE83A1BFa233512af25a4003836C8")
-	privKey, err := crypto.HexToECDSA(account20SK)
-	ast.NoError(err)
+	s := testSigner(t)
 
 	contract, err := abijson.NewL2StandardBridge(l2StandardBridge, l2cli)
 	ast.NoError(err)
@@ -371,17 +366,16 @@ func Test_L2WithdrawETH(t *testing.T) {
 		t.Logf("l1bal is %d\n", l1Bal) // 9998899998809314943436246
 	}
 
-	l2Bal := txutils.GetETHBalanceFromL2(t, account20)
+	l2Bal := txtest.GetETHBalanceFromL2(t, profile, account20)
 	ast.NotNil(l2Bal)
 	t.Logf("l2bal is %d\n", l2Bal) // 10000000000000200000
 
 	// approve
 	t.Log("================= approve =================")
-	l2ETHTokenAddr := common.HexToAddress(WETH9Addr)
+	l2ETHTokenAddr := profile.Addresses.L2ETH
 	L2WETHContract, err := abijson.NewL2TestToken(l2ETHTokenAddr, l2cli)
 	ast.NoError(err)
-	opt, err := bind.NewKeyedTransactorWithChainID(privKey, l2ChainID)
-	ast.NoError(err)
+	opt := signer.TransactOpts(context.Background(), s, l2ChainID)
 	//tx, err := L2WETHContract.Approve(opt, account20Addr, approveAmount)
 	tx, err := L2WETHContract.Approve(opt, l2StandardBridge, approveAmount)
 	ast.NoError(err)
@@ -391,7 +385,8 @@ func Test_L2WithdrawETH(t *testing.T) {
 	ast.NoError(err)
 	t.Logf("approve tx hash is %s\n", tx.Hash().Hex())
 
-	time.Sleep(5 * time.Second)
+	_, err = txutils.WaitForReceipt(context.Background(), l2cli, tx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
+	ast.NoError(err)
 
 	// allowance
 	t.Log("================= allowance =================")
@@ -402,68 +397,60 @@ func Test_L2WithdrawETH(t *testing.T) {
 
 	// withdraw
 	t.Log("================= withdraw =================")
-	opts, err := bind.NewKeyedTransactorWithChainID(privKey, l2ChainID)
-	ast.NoError(err)
+	opts := signer.TransactOpts(context.Background(), s, l2ChainID)
 
-	wdTx, err := contract.Withdraw(opts, common.HexToAddress(WETH9Addr), amount, 200000, []byte{})
+	wdTx, err := contract.Withdraw(opts, profile.Addresses.L2ETH, amount, 200000, []byte{})
 	ast.NoError(err)
 	if err == nil {
 		t.Logf("wdtx.hash is %s\n", wdTx.Hash()) // 0x37665cddfa9afb8ff481abfc757623b271317b40b0ddfbf0a4b766145ceff8b4
 	}
 
-	withdrawNonce := wdTx.Nonce()
-	t.Logf("withdraw nonce is %d\n", withdrawNonce)
-	// filter event
-	l2ToL1MessagePasserAddr := common.HexToAddress("0x4200000000000000000000000000000000000016")
-	l2ToL1MessagePasser, err := abijson.NewL2ToL1MessagePasser(l2ToL1MessagePasserAddr, l2cli)
-
-	L2_CROSS_DOMAIN_MESSENGER_Addr := common.HexToAddress(L2_CROSS_DOMAIN_MESSENGER_AddrHex)
-	Proxy__BVM_L1CrossDomainMessenger := common.HexToAddress("0x0165878A594ca255338adfa4d48449f69242Eb8F")
-	wdNonce, bigbool := new(big.Int).SetString("01000000000000000000000000000000000000000000000000000000000006", 16) // 27
-	t.Logf("big.setString bigbool %v, wdNonce %d\n", bigbool, wdNonce)
-	iter, err := l2ToL1MessagePasser.FilterMessagePassed(&bind.FilterOpts{}, []*big.Int{wdNonce}, []common.Address{L2_CROSS_DOMAIN_MESSENGER_Addr}, []common.Address{Proxy__BVM_L1CrossDomainMessenger})
-	ast.NoError(err)
-	//event MessagePassed(
-	//	uint256 indexed nonce,
-	//	address indexed sender,
-	//	address indexed target,
-	//	uint256 mntValue,
-	//	uint256 ethValue,
-	//	uint256 gasLimit,
-	//	bytes data,
-	//	bytes32 withdrawalHash
-	//);
-	for iter.Next() {
-		t.Logf("nonce: %d, sender: %s, target: %s, mntValue: %d, ethValue: %d, gasLimit: %d, data: %s, wdHash: %s",
-			iter.Event.Nonce, iter.Event.Sender.Hex(), iter.Event.Target.Hex(), iter.Event.MntValue, iter.Event.EthValue, iter.Event.GasLimit, iter.Event.Data, iter.Event.WithdrawalHash)
-	}
-
-	time.Sleep(3 * time.Second)
-	receipt, err := l2cli.TransactionReceipt(context.Background(), wdTx.Hash())
+	receipt, err := txutils.WaitForReceipt(context.Background(), l2cli, wdTx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
 	ast.NoError(err)
 	ast.NotNil(receipt)
 
+	// history
+	t.Log("================= history =================")
+	c, err := bridge.Dial(context.Background(), profile)
+	require.NoError(t, err)
+	defer c.Close()
+	db := memorydb.New()
+	sources, err := indexer.Sources(c)
+	require.NoError(t, err)
+	require.NoError(t, indexer.New(db, sources).Sync(context.Background()))
+	transfers, err := history.ForAccount(context.Background(), c, db, account20Addr)
+	require.NoError(t, err)
+	var found bool
+	for _, tr := range transfers {
+		t.Logf("%s %s %d %s, l1 tx: %s, l2 tx: %s, wdHash: %s",
+			tr.Direction, tr.Asset, tr.Amount, tr.Step, tr.L1TxHash.Hex(), tr.L2TxHash.Hex(), tr.WithdrawalHash.Hex())
+		found = found || tr.L2TxHash == wdTx.Hash()
+	}
+	ast.True(found)
 }
 
 func Test_ProvenAndFinalize(t *testing.T) {
 	ast := assert.New(t)
 
-	// env
-	l1cli, err := ethclient.Dial(L1URL)
-	l2cli, err := ethclient.Dial(L2URL)
-	ast.NoError(err)
-	//l1StandardBridge := common.HexToAddress(l1ContractAddr)
-	//l2StandardBridge := common.HexToAddress(l2ContractAddr)
+	// env, set RDE_RPC_MODE=record to capture a fixture for replaying without nodes
+	rpcs := rpcreplay.ForTest(t, "testdata")
+	l1RpcClient, err := rpcs.Dial(context.Background(), "l1", profile.L1.RPC)
+	require.NoError(t, err)
+	l2RpcClient, err := rpcs.Dial(context.Background(), "l2", profile.L2.RPC)
+	require.NoError(t, err)
+	l1cli := ethclient.NewClient(l1RpcClient)
+	l2cli := ethclient.NewClient(l2RpcClient)
+	//l1StandardBridge := profile.Addresses.L1StandardBridge
+	//l2StandardBridge := profile.Addresses.L2StandardBridge
 
 	l1ChainID, err := l1cli.ChainID(context.Background())
 	ast.NoError(err)
 	//l2ChainID, err := l2cli.ChainID(context.Background())
 	//ast.NoError(err)
 
-	privKey, err := crypto.HexToECDSA(account20SK)
-	ast.NoError(err)
+	s := testSigner(t)
 
-	l2ToL1MessagePasser, err := abijson.NewL2ToL1MessagePasser(common.HexToAddress(L2ToL1MessagePasser), l2cli)
+	l2ToL1MessagePasser, err := abijson.NewL2ToL1MessagePasser(profile.Addresses.L2ToL1MessagePasser, l2cli)
 	ast.NoError(err)
 
 	//l2Contract, err := abijson.NewL2StandardBridge(l2StandardBridge, l2cli)
@@ -477,11 +464,9 @@ func Test_ProvenAndFinalize(t *testing.T) {
 
 	// prepare wd args
 	t.Log("================= prepare withdraw tx =================")
-	L2OutputOracleProxyAddr := common.HexToAddress(L2OutputOracleProxy)
+	L2OutputOracleProxyAddr := profile.Addresses.L2OutputOracleProxy
 	L2OutputOracleProxyContract, err := abijson.NewL2OutputOracleProxy(L2OutputOracleProxyAddr, l1cli)
 
-	l2RpcClient, err := rpc.DialContext(context.Background(), L2URL)
-	ast.NoError(err)
 	l2GethCli := gethclient.New(l2RpcClient)
 	receiptCli := ethclient.NewClient(l2RpcClient)
 
@@ -498,14 +483,14 @@ func Test_ProvenAndFinalize(t *testing.T) {
 		t.Logf("finalizationPeriod is %d\n", finalizationPeriod)
 	}
 	//blkNum := txutils.GetBlockNum(t, submissionInterval, startingBlockNumber, receipt.BlockNumber)
-	blkNum, err := txutils.WaitForFinalizationPeriod(context.Background(), t, l1cli, common.HexToAddress(L1OptimismPortal), receipt.BlockNumber, finalizationPeriod)
+	blkNum, err := txtest.WaitForFinalizationPeriod(context.Background(), t, l1cli, profile.Addresses.L1OptimismPortal, receipt.BlockNumber, finalizationPeriod)
 	ast.NoError(err)
 	header, err := receiptCli.HeaderByNumber(context.Background(), big.NewInt(int64(blkNum)))
 	ast.NoError(err)
 	ast.NotNil(header)
 	t.Logf("blkNum is %d, header.Num is %d\n", blkNum, header.Number)
 
-	params, err := txutils.ProveWithdrawalParameters(context.Background(), t, l2GethCli, receiptCli, common.HexToHash(wdTxHex), header, &L2OutputOracleProxyContract.L2OutputOracleProxyCaller, l2ToL1MessagePasser)
+	params, err := txtest.ProveWithdrawalParameters(context.Background(), t, l2GethCli, receiptCli, profile.Addresses.L2ToL1MessagePasser, common.HexToHash(wdTxHex), header, &L2OutputOracleProxyContract.L2OutputOracleProxyCaller, l2ToL1MessagePasser)
 	ast.NoError(err)
 
 	// // prepare withdraw transaction
@@ -521,7 +506,7 @@ func Test_ProvenAndFinalize(t *testing.T) {
 
 	// prepare proven
 	t.Log("================= prepare proven =================")
-	L1OptimismPortalAddr := common.HexToAddress("0xa513E6E4b8f2a923D98304ec87F64353C4D5C853")
+	L1OptimismPortalAddr := profile.Addresses.L1OptimismPortal
 	opPortal, err := abijson.NewL1OptimismPortal(L1OptimismPortalAddr, l1cli)
 	ast.NoError(err)
 
@@ -530,39 +515,22 @@ func Test_ProvenAndFinalize(t *testing.T) {
 	slot, err := mytypeWd.StorageSlot()
 	ast.NoError(err)
 
-	proof, err := l2GethCli.GetProof(context.Background(), predeploys.L2ToL1MessagePasserAddr, []string{slot.String()}, big.NewInt(int64(blkNum)))
+	proof, err := l2GethCli.GetProof(context.Background(), profile.Addresses.L2ToL1MessagePasser, []string{slot.String()}, big.NewInt(int64(blkNum)))
 	ast.NoError(err)
 	ast.NotNil(proof)
 	t.Logf("proof.storage.len is %d", len(proof.StorageProof))
 
-	//outputRootProof := abijson.TypesOutputRootProof{ //bindings.TypesOutputRootProof{
-	//	Version:                  [32]byte{},
-	//	StateRoot:                header.Root,
-	//	MessagePasserStorageRoot: proof.StorageHash,
-	//	LatestBlockhash:          header.Hash(),
-	//}
-
-	//trieNodes := make([][]byte, len(proof.StorageProof[0].Proof))
-	//for i, s := range proof.StorageProof[0].Proof {
-	//	trieNodes[i] = common.FromHex(s)
-	//}
+	// Compute the output root locally and ensure that it matches the proposed one
+	l2Output, err := L2OutputOracleProxyContract.GetL2Output(opt, params.L2OutputIndex)
+	ast.NoError(err)
+	ast.NoError(txutils.VerifyOutputRoot(&params.OutputRootProof, l2Output.OutputRoot))
 
-	//// Compute the output root locally
-	//l2OutputRoot, err := txutils.ComputeL2OutputRoot(&outputRootProof)
-	//localOutputRootHash := common.Hash(l2OutputRoot)
-	//ast.NoError(err)
-	//// ensure that the locally computed hash matches
-	////ast.Equal(l2Output.OutputRoot, localOutputRootHash, fmt.Sprintf("mismatch in output root hashes, got 0x%x expected 0x%x", localOutputRootHash, l2Output.OutputRoot))
-	//if params.OutputRootProof.OutputRoot == localOutputRootHash {
-	//	t.Log("l2Output.OutputRoot == localOutputRootHash")
-	//}
-	//
 	// check storage value
 	// abiTrue represents the storage representation of the boolean
 	// value true.
 	var abiTrue = common.Hash{31: 0x01}
 
-	storageValue, err := l2cli.StorageAt(context.Background(), predeploys.L2ToL1MessagePasserAddr, slot, big.NewInt(int64(blkNum)))
+	storageValue, err := l2cli.StorageAt(context.Background(), profile.Addresses.L2ToL1MessagePasser, slot, big.NewInt(int64(blkNum)))
 	ast.NoError(err)
 	if err == nil {
 		t.Logf("L2ToL1MessagePasser status value %s\n", common.Bytes2Hex(storageValue))
@@ -590,8 +558,7 @@ func Test_ProvenAndFinalize(t *testing.T) {
 	if !alreadyProve {
 		// proven
 		t.Log("================= proven =================")
-		proveOpt, err := bind.NewKeyedTransactorWithChainID(privKey, l1ChainID)
-		ast.NoError(err)
+		proveOpt := signer.TransactOpts(context.Background(), s, l1ChainID)
 		ast.NotNil(proveOpt)
 		//proveTx, err := opPortal.ProveWithdrawalTransaction(opt, *wd, params.L2OutputIndex, outputRootProof, trieNodes)
 		proveTx, err := opPortal.ProveWithdrawalTransaction(
@@ -632,8 +599,7 @@ func Test_ProvenAndFinalize(t *testing.T) {
 
 	// opPortal
 	t.Log("================= finalize optimismPortal =================")
-	finOpt, err := bind.NewKeyedTransactorWithChainID(privKey, l1ChainID)
-	ast.NoError(err)
+	finOpt := signer.TransactOpts(context.Background(), s, l1ChainID)
 	fTx, err := opPortal.FinalizeWithdrawalTransaction(finOpt, *wd)
 	ast.NoError(err)
 	ast.NotNil(fTx)
@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"try_rde/abistr/abijson"
+	"try_rde/bridge"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+// DefaultCollectInterval is how often Collector.Run refreshes the gauges.
+const DefaultCollectInterval = 30 * time.Second
+
+// Collector refreshes the gauges that are read from the chains and the
+// withdrawal store rather than counted as things happen.
+type Collector struct {
+	Metrics *Metrics
+	Client  *bridge.Client
+	// Signer is the account whose balances are exported, none if zero.
+	Signer common.Address
+	// Store holds the withdrawals in flight, e.g. the one of a Driver. The
+	// pending gauges stay zero without it.
+	Store    withdrawal.Store
+	Interval time.Duration
+	Log      txutils.Logger
+}
+
+// Run collects until ctx is done. Failed collections are logged and retried
+// on the next tick.
+func (c *Collector) Run(ctx context.Context) error {
+	for {
+		if err := c.Collect(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.logger().Logf("metrics collection failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.interval()):
+		}
+	}
+}
+
+// Collect refreshes every gauge once.
+func (c *Collector) Collect(ctx context.Context) error {
+	if err := c.collectOracle(ctx); err != nil {
+		return err
+	}
+	if c.Signer != (common.Address{}) {
+		if err := c.collectBalances(ctx); err != nil {
+			return err
+		}
+	}
+	if c.Store != nil {
+		records, err := c.Store.List()
+		if err != nil {
+			return fmt.Errorf("failed to list withdrawals: %w", err)
+		}
+		c.Metrics.SetPending(records)
+	}
+	return nil
+}
+
+// collectOracle exports the latest output index, how many L2 blocks the L2
+// head is ahead of it and how old it is in L1 time. The index is -1 while
+// no output was proposed.
+func (c *Collector) collectOracle(ctx context.Context) error {
+	m, opts := c.Metrics, &bind.CallOpts{Context: ctx}
+	next, err := c.Client.Oracle.NextOutputIndex(opts)
+	if err != nil {
+		return fmt.Errorf("failed to get next output index: %w", err)
+	}
+	if next.Sign() == 0 {
+		m.outputIndex.Update(-1)
+		return nil
+	}
+	index := new(big.Int).Sub(next, common.Big1)
+	output, err := c.Client.Oracle.GetL2Output(opts, index)
+	if err != nil {
+		return fmt.Errorf("failed to get l2 output %d: %w", index, err)
+	}
+	l2Head, err := c.Client.L2.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get l2 head: %w", err)
+	}
+	l1Head, err := c.Client.L1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get l1 head: %w", err)
+	}
+	m.outputIndex.Update(index.Int64())
+	m.outputLag.Update(new(big.Int).Sub(l2Head.Number, output.L2BlockNumber).Int64())
+	m.outputAge.Update(int64(l1Head.Time) - output.Timestamp.Int64())
+	return nil
+}
+
+func (c *Collector) collectBalances(ctx context.Context) error {
+	m, cl, addrs := c.Metrics, c.Client, c.Client.Profile.Addresses
+	l1ETH, err := cl.L1.BalanceAt(ctx, c.Signer, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get l1 balance: %w", err)
+	}
+	mnt, err := abijson.NewL2TestTokenCaller(addrs.L1MantleToken, cl.L1) // any ERC20 binding will do
+	if err != nil {
+		return err
+	}
+	l1MNT, err := mnt.BalanceOf(&bind.CallOpts{Context: ctx}, c.Signer)
+	if err != nil {
+		return fmt.Errorf("failed to get l1 mnt balance: %w", err)
+	}
+	l2MNT, err := cl.L2.BalanceAt(ctx, c.Signer, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get l2 balance: %w", err)
+	}
+	l2ETH, err := txutils.GetETHBalanceFromL2(ctx, cl.L2, addrs.L2ETH, c.Signer)
+	if err != nil {
+		return err
+	}
+	m.l1ETH.Update(ether(l1ETH))
+	m.l1MNT.Update(ether(l1MNT))
+	m.l2MNT.Update(ether(l2MNT))
+	m.l2ETH.Update(ether(l2ETH))
+	return nil
+}
+
+// ether converts wei to ether, a gauge of wei would overflow at 9.2 ether.
+func ether(wei *big.Int) float64 {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether)).Float64()
+	return f
+}
+
+func (c *Collector) logger() txutils.Logger {
+	if c.Log == nil {
+		return txutils.NopLogger
+	}
+	return c.Log
+}
+
+func (c *Collector) interval() time.Duration {
+	if c.Interval <= 0 {
+		return DefaultCollectInterval
+	}
+	return c.Interval
+}
@@ -0,0 +1,158 @@
+// Package metrics exports the bridge activity in the Prometheus text format:
+// the deposits and withdrawals sent, how long withdrawals take to be proven
+// and finalized, the withdrawals still in flight, the latency and errors of
+// every JSON-RPC method, the progress of the L2OutputOracle and the balances
+// of the signer.
+//
+// The metrics are kept in go-ethereum's metrics registry, a name like
+// rde/rpc/l1/eth_call/errors is exported as rde_rpc_l1_eth_call_errors.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	gethmetrics "github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/prometheus"
+	"try_rde/bridge"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+// Metrics holds the bridge metrics in a registry of their own.
+type Metrics struct {
+	Registry gethmetrics.Registry
+	Log      txutils.Logger
+
+	deposits    map[bridge.Asset]gethmetrics.Counter
+	withdrawals map[bridge.Asset]gethmetrics.Counter
+	// seconds from the L2 block of the withdrawal to the L1 head when the
+	// prove and finalize txs were sent
+	proveDelay    gethmetrics.Histogram
+	finalizeDelay gethmetrics.Histogram
+	pending       map[withdrawal.State]gethmetrics.Gauge
+
+	outputIndex gethmetrics.Gauge
+	outputLag   gethmetrics.Gauge
+	outputAge   gethmetrics.Gauge
+
+	l1ETH gethmetrics.GaugeFloat64
+	l1MNT gethmetrics.GaugeFloat64
+	l2ETH gethmetrics.GaugeFloat64
+	l2MNT gethmetrics.GaugeFloat64
+}
+
+// New registers all metrics in a new registry. It turns on go-ethereum's
+// metrics.Enabled, its constructors hand out no-op metrics otherwise.
+func New() *Metrics {
+	gethmetrics.Enabled = true
+	r := gethmetrics.NewRegistry()
+	m := &Metrics{
+		Registry:      r,
+		Log:           txutils.NopLogger,
+		deposits:      make(map[bridge.Asset]gethmetrics.Counter),
+		withdrawals:   make(map[bridge.Asset]gethmetrics.Counter),
+		proveDelay:    newHistogram("rde/withdrawals/prove_delay_seconds", r),
+		finalizeDelay: newHistogram("rde/withdrawals/finalize_delay_seconds", r),
+		pending:       make(map[withdrawal.State]gethmetrics.Gauge),
+		outputIndex:   gethmetrics.NewRegisteredGauge("rde/oracle/latest_output_index", r),
+		outputLag:     gethmetrics.NewRegisteredGauge("rde/oracle/output_lag_blocks", r),
+		outputAge:     gethmetrics.NewRegisteredGauge("rde/oracle/output_age_seconds", r),
+		l1ETH:         gethmetrics.NewRegisteredGaugeFloat64("rde/signer/l1/eth", r),
+		l1MNT:         gethmetrics.NewRegisteredGaugeFloat64("rde/signer/l1/mnt", r),
+		l2ETH:         gethmetrics.NewRegisteredGaugeFloat64("rde/signer/l2/eth", r),
+		l2MNT:         gethmetrics.NewRegisteredGaugeFloat64("rde/signer/l2/mnt", r),
+	}
+	for _, asset := range []bridge.Asset{bridge.AssetETH, bridge.AssetMNT, bridge.AssetERC20} {
+		m.deposits[asset] = gethmetrics.NewRegisteredCounter("rde/deposits/"+string(asset), r)
+		m.withdrawals[asset] = gethmetrics.NewRegisteredCounter("rde/withdrawals/"+string(asset), r)
+	}
+	for s := withdrawal.StateInitiated; s <= withdrawal.StateFailed; s++ {
+		if !s.Terminal() {
+			m.pending[s] = gethmetrics.NewRegisteredGauge("rde/withdrawals/pending/"+metricName(s.String()), r)
+		}
+	}
+	return m
+}
+
+func newHistogram(name string, r gethmetrics.Registry) gethmetrics.Histogram {
+	return gethmetrics.NewRegisteredHistogram(name, r, gethmetrics.NewExpDecaySample(1028, 0.015))
+}
+
+// metricName makes s usable in a Prometheus metric name.
+func metricName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return prometheus.Handler(m.Registry)
+}
+
+// Instrument sets the hooks of the client to count the transfers it sends
+// and to time the withdrawals it proves and finalizes.
+func (m *Metrics) Instrument(c *bridge.Client) {
+	c.Hooks.Deposited = func(_ context.Context, asset bridge.Asset, _ *types.Transaction) {
+		if counter, ok := m.deposits[asset]; ok {
+			counter.Inc(1)
+		}
+	}
+	c.Hooks.Withdrew = func(_ context.Context, asset bridge.Asset, _ *types.Transaction) {
+		if counter, ok := m.withdrawals[asset]; ok {
+			counter.Inc(1)
+		}
+	}
+	c.Hooks.Proved = func(ctx context.Context, receipt *types.Receipt, _ *types.Transaction) {
+		m.observeDelay(ctx, c, m.proveDelay, receipt)
+	}
+	c.Hooks.Finalized = func(ctx context.Context, receipt *types.Receipt, _ *types.Transaction) {
+		m.observeDelay(ctx, c, m.finalizeDelay, receipt)
+	}
+}
+
+// observeDelay adds the seconds between the L2 block of the withdrawal and
+// the L1 head. Both are chain time, so a devnet that skips time is measured
+// the way its contracts see it.
+func (m *Metrics) observeDelay(ctx context.Context, c *bridge.Client, h gethmetrics.Histogram, receipt *types.Receipt) {
+	initiated, err := c.L2.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		m.Log.Logf("metrics: failed to get l2 header %d: %v", receipt.BlockNumber, err)
+		return
+	}
+	head, err := c.L1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		m.Log.Logf("metrics: failed to get l1 head: %v", err)
+		return
+	}
+	if head.Time >= initiated.Time {
+		h.Update(int64(head.Time - initiated.Time))
+	}
+}
+
+// SetPending sets the number of withdrawals per state that is not terminal.
+func (m *Metrics) SetPending(records []*withdrawal.Record) {
+	counts := make(map[withdrawal.State]int64)
+	for _, rec := range records {
+		counts[rec.State]++
+	}
+	for s, g := range m.pending {
+		g.Update(counts[s])
+	}
+}
+
+// rpcTimer and rpcErrors are created on first use, the methods a node is
+// called with are not known up front.
+func (m *Metrics) rpcTimer(endpoint, method string) gethmetrics.Timer {
+	return gethmetrics.GetOrRegisterTimer("rde/rpc/"+endpoint+"/"+metricName(method)+"/latency", m.Registry)
+}
+
+func (m *Metrics) rpcErrors(endpoint, method string) gethmetrics.Counter {
+	return gethmetrics.GetOrRegisterCounter("rde/rpc/"+endpoint+"/"+metricName(method)+"/errors", m.Registry)
+}
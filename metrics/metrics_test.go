@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+// scrape returns the exported lines by metric name, without the TYPE lines.
+func scrape(t *testing.T, m *Metrics) map[string]string {
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	out := make(map[string]string)
+	for _, line := range strings.Split(string(body), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.LastIndex(line, " "); i > 0 {
+			out[line[:i]] = line[i+1:]
+		}
+	}
+	return out
+}
+
+func Test_BridgeMetrics(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	ether := big.NewInt(params.Ether)
+
+	m := New()
+	m.Instrument(c)
+	store := withdrawal.NewMemStore()
+	col := &Collector{Metrics: m, Client: c, Signer: s.Address(), Store: store}
+	require.NoError(t, col.Collect(ctx))
+	ast.Equal("-1", scrape(t, m)["rde_oracle_latest_output_index"])
+
+	mined := func(backend txutils.ReceiptBackend) func(*types.Transaction, error) common.Hash {
+		return func(tx *types.Transaction, err error) common.Hash {
+			require.NoError(t, err)
+			_, err = txutils.WaitForReceipt(ctx, backend, tx.Hash(), txutils.WaitOpts{})
+			require.NoError(t, err)
+			return tx.Hash()
+		}
+	}
+	onL1, onL2 := mined(c.L1), mined(c.L2)
+	onL1(c.DepositETH(ctx, s, bridge.TransferArgs{Amount: ether}))
+	onL1(c.DepositETH(ctx, s, bridge.TransferArgs{Amount: ether}))
+	wd := onL2(c.WithdrawETH(ctx, s, bridge.TransferArgs{Amount: big.NewInt(1)}))
+	ast.NoError(store.Put(&withdrawal.Record{TxHash: wd, State: withdrawal.StateWaitingForOutput}))
+	ast.NoError(store.Put(&withdrawal.Record{TxHash: common.HexToHash("0x01"), State: withdrawal.StateFinalized}))
+
+	n.L2.Mine()
+	require.NoError(t, n.ProposeOutput())
+	onL1(c.ProveWithdrawal(ctx, s, wd))
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	onL1(c.FinalizeWithdrawal(ctx, s, wd))
+	n.L2.Mine()
+	require.NoError(t, col.Collect(ctx))
+
+	got := scrape(t, m)
+	ast.Equal("2", got["rde_deposits_eth"])
+	ast.Equal("0", got["rde_deposits_mnt"])
+	ast.Equal("1", got["rde_withdrawals_eth"])
+	ast.Equal("1", got["rde_withdrawals_prove_delay_seconds_count"])
+	ast.Equal("1", got["rde_withdrawals_finalize_delay_seconds_count"])
+	ast.Equal("1", got["rde_withdrawals_pending_waiting_for_output"])
+	ast.Equal("0", got["rde_withdrawals_pending_proven"])
+	ast.Equal("0", got["rde_oracle_latest_output_index"])
+	head, err := c.L2.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	output, err := c.Oracle.GetL2Output(nil, common.Big0)
+	require.NoError(t, err)
+	ast.Equal(new(big.Int).Sub(head.Number, output.L2BlockNumber).String(), got["rde_oracle_output_lag_blocks"])
+	ast.Equal("2", got["rde_signer_l2_eth"], "2 ether deposited less 1 wei")
+	ast.True(strings.HasPrefix(got["rde_signer_l1_eth"], "98"), "100 ether less the deposits, got %s", got["rde_signer_l1_eth"])
+	_, ok := got["rde_withdrawals_pending_finalized"]
+	ast.False(ok, "terminal states are not pending")
+}
+
+func Test_Transport(t *testing.T) {
+	ast := assert.New(t)
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == "eth_chainId" {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x1"}`, req.ID)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"method not found"}}`, req.ID)
+	}))
+	defer node.Close()
+
+	m := New()
+	rc, err := m.DialRPC(context.Background(), "l1", node.URL)
+	require.NoError(t, err)
+	defer rc.Close()
+	var id string
+	ast.NoError(rc.Call(&id, "eth_chainId"))
+	ast.NoError(rc.Call(&id, "eth_chainId"))
+	ast.Error(rc.Call(&id, "eth_nope"))
+
+	got := scrape(t, m)
+	ast.Equal("2", got["rde_rpc_l1_eth_chainId_latency_count"])
+	ast.Equal("1", got["rde_rpc_l1_eth_nope_latency_count"])
+	ast.Equal("1", got["rde_rpc_l1_eth_nope_errors"])
+	_, ok := got["rde_rpc_l1_eth_chainId_errors"]
+	ast.False(ok, "the error counter is created on the first error")
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"try_rde/bridge"
+	"try_rde/config"
+)
+
+// rpcMessage is the part of a JSON-RPC request or response the transport
+// looks at.
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+func parseRPCMessages(body []byte) ([]*rpcMessage, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []*rpcMessage
+		err := json.Unmarshal(body, &batch)
+		return batch, err
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	return []*rpcMessage{&msg}, nil
+}
+
+// transport times every JSON-RPC call sent through it and counts the ones
+// that failed, either on the wire or with an error answer. The calls of a
+// batch all get the latency of the whole batch.
+type transport struct {
+	metrics  *Metrics
+	endpoint string
+	next     http.RoundTripper
+}
+
+// Transport instruments the JSON-RPC calls sent over next under the endpoint
+// name, e.g. "l1".
+func (m *Metrics) Transport(endpoint string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{metrics: m, endpoint: endpoint, next: next}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	calls, err := parseRPCMessages(reqBody)
+	if err != nil {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+	failed := make(map[string]bool, len(calls))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		for _, call := range calls {
+			failed[string(call.ID)] = true
+		}
+	} else if respBody, readErr := io.ReadAll(resp.Body); readErr != nil {
+		resp.Body.Close()
+		return nil, readErr
+	} else {
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+		answers, _ := parseRPCMessages(respBody)
+		for _, a := range answers {
+			if len(a.Error) > 0 && string(a.Error) != "null" {
+				failed[string(a.ID)] = true
+			}
+		}
+	}
+	for _, call := range calls {
+		if call.Method == "" {
+			continue
+		}
+		t.metrics.rpcTimer(t.endpoint, call.Method).Update(elapsed)
+		if failed[string(call.ID)] {
+			t.metrics.rpcErrors(t.endpoint, call.Method).Inc(1)
+		}
+	}
+	return resp, err
+}
+
+// DialRPC connects to endpoint, instrumenting the calls under name when it
+// is an http(s) endpoint. Other transports are dialed as they are.
+func (m *Metrics) DialRPC(ctx context.Context, name, endpoint string) (*rpc.Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return rpc.DialContext(ctx, endpoint)
+	}
+	return rpc.DialHTTPWithClient(endpoint, &http.Client{Transport: m.Transport(name, nil)})
+}
+
+// Dial connects to both chains of the profile like bridge.Dial, with the
+// RPC calls and the client instrumented.
+func (m *Metrics) Dial(ctx context.Context, profile *config.Profile) (*bridge.Client, error) {
+	l1, err := m.DialRPC(ctx, "l1", profile.L1.RPC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial l1: %w", err)
+	}
+	l2, err := m.DialRPC(ctx, "l2", profile.L2.RPC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial l2: %w", err)
+	}
+	c, err := bridge.NewClientFromRPC(ctx, profile, l1, l2)
+	if err != nil {
+		return nil, err
+	}
+	m.Instrument(c)
+	return c, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"try_rde/bridge"
+	"try_rde/config"
+	"try_rde/offline"
+	"try_rde/signer"
+)
+
+// offlineCommands are the commands offline build can run, the ones that send
+// a fixed set of txs.
+var offlineCommands = map[string]func(ctx context.Context, env *cliEnv, args []string) error{
+	"deposit":  runDeposit,
+	"withdraw": runWithdraw,
+	"prove":    runProve,
+	"finalize": runFinalize,
+	"bundle":   runBundle,
+}
+
+// runOffline signs the txs of a bridge operation on a machine without
+// network: build writes them unsigned, sign signs them, broadcast sends them.
+func runOffline(ctx context.Context, env *cliEnv, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("missing action, use build, show, sign or broadcast")
+	}
+	switch args[0] {
+	case "build":
+		return runOfflineBuild(ctx, env, args[1:])
+	case "show":
+		return runOfflineShow(args[1:])
+	case "sign":
+		return runOfflineSign(env, args[1:])
+	case "broadcast":
+		return runOfflineBroadcast(ctx, env, args[1:])
+	}
+	return usageErrorf("unknown action %q, use build, show, sign or broadcast", args[0])
+}
+
+func runOfflineBuild(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("offline build", flag.ContinueOnError)
+	from := fs.String("from", "", "account whose key signs offline")
+	out := fs.String("out", "", "file to write the unsigned txs to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !common.IsHexAddress(*from) {
+		return usageErrorf("--from: invalid address %q", *from)
+	}
+	if *out == "" {
+		return usageErrorf("--out is required")
+	}
+	if fs.NArg() == 0 {
+		return usageErrorf("missing command, e.g. offline build --from <addr> --out tx.json deposit eth --amount 1ether")
+	}
+	run, ok := offlineCommands[fs.Arg(0)]
+	if !ok {
+		return usageErrorf("%s can not be built offline, use deposit, withdraw, prove, finalize or bundle", fs.Arg(0))
+	}
+
+	env.recorder = signer.NewRecorder(common.HexToAddress(*from))
+	err := run(ctx, env, fs.Args()[1:])
+	approveFirst := errors.Is(err, bridge.ErrApproveFirst)
+	if err != nil && !approveFirst {
+		return err
+	}
+	f, err := offline.Build(env.client, env.recorder)
+	if err != nil {
+		return err
+	}
+	if err := f.WriteFile(*out); err != nil {
+		return err
+	}
+	printTxFile(f)
+	fmt.Printf("unsigned txs written to %s\n", *out)
+	if approveFirst {
+		fmt.Printf("the transfer needs this approval mined first, broadcast it then build the transfer again\n")
+	}
+	return nil
+}
+
+func runOfflineShow(args []string) error {
+	fs := flag.NewFlagSet("offline show", flag.ContinueOnError)
+	in := fs.String("in", "", "tx file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := readTxFile(*in)
+	if err != nil {
+		return err
+	}
+	printTxFile(f)
+	return nil
+}
+
+func runOfflineSign(env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("offline sign", flag.ContinueOnError)
+	in := fs.String("in", "", "tx file written by offline build")
+	out := fs.String("out", "", "file to write the signed txs to (default: --in)")
+	l1ChainID := fs.Uint64("l1-chain-id", 0, "chain id the l1 txs must be for, required unless the profile sets it")
+	l2ChainID := fs.Uint64("l2-chain-id", 0, "chain id the l2 txs must be for, required unless the profile sets it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := readTxFile(*in)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = *in
+	}
+	p, err := env.loadProfile()
+	if err != nil {
+		return err
+	}
+	p = p.Clone()
+	if err := setChainID(p, "l1", *l1ChainID, &p.L1.ChainID); err != nil {
+		return err
+	}
+	if err := setChainID(p, "l2", *l2ChainID, &p.L2.ChainID); err != nil {
+		return err
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	printTxFile(f)
+	if err := offline.Sign(f, s, p); err != nil {
+		return err
+	}
+	if err := f.WriteFile(*out); err != nil {
+		return err
+	}
+	fmt.Printf("signed txs written to %s\n", *out)
+	return nil
+}
+
+// setChainID sets a chain id of the profile from its --<chain>-chain-id flag,
+// which must agree with the profile if that sets one too.
+func setChainID(p *config.Profile, chain string, flag uint64, conf *uint64) error {
+	switch {
+	case flag == 0:
+	case *conf != 0 && *conf != flag:
+		return usageErrorf("--%s-chain-id %d, profile %q sets %d", chain, flag, p.Name, *conf)
+	default:
+		*conf = flag
+	}
+	return nil
+}
+
+func runOfflineBroadcast(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("offline broadcast", flag.ContinueOnError)
+	in := fs.String("in", "", "tx file signed by offline sign")
+	wait := fs.Bool("wait", true, "wait for the receipts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := readTxFile(*in)
+	if err != nil {
+		return err
+	}
+	dial := env.dialL1
+	for _, tx := range f.Txs {
+		if tx.Chain == offline.ChainL2 {
+			dial = env.dial
+		}
+	}
+	c, err := dial(ctx)
+	if err != nil {
+		return err
+	}
+	sent, sendErr := offline.Broadcast(ctx, c, f)
+	for i, tx := range sent {
+		backend := c.L1
+		if f.Txs[i].Chain == offline.ChainL2 {
+			backend = c.L2
+		}
+		if err := env.report(ctx, backend, tx, *wait); err != nil {
+			return err
+		}
+	}
+	return sendErr
+}
+
+func readTxFile(path string) (*offline.File, error) {
+	if path == "" {
+		return nil, usageErrorf("--in is required")
+	}
+	return offline.ReadFile(path)
+}
+
+// printTxFile shows the txs of a file for review.
+func printTxFile(f *offline.File) {
+	for _, tx := range f.Txs {
+		fmt.Printf("%s tx %d from %s, chain id %d\n", tx.Chain, tx.Nonce, tx.From.Hex(), tx.ChainID)
+		fmt.Printf("  call:                %s.%s at %s\n", tx.Intent.Contract, tx.Intent.Method, tx.To.Hex())
+		for _, arg := range tx.Intent.Args {
+			fmt.Printf("    %s %s = %s\n", arg.Type, arg.Name, arg.Value)
+		}
+		fmt.Printf("  value:               %s wei\n", tx.Value)
+		price := tx.GasPrice
+		if price == "" {
+			price = tx.MaxFeePerGas
+		}
+		if p, ok := new(big.Int).SetString(price, 10); ok {
+			fmt.Printf("  max fee:             %s wei (gas %d)\n", p.Mul(p, new(big.Int).SetUint64(tx.Gas)), tx.Gas)
+		}
+		if tx.Hash != nil {
+			fmt.Printf("  signed, tx hash:     %s\n", tx.Hash.Hex())
+		}
+	}
+}
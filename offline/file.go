@@ -0,0 +1,206 @@
+// Package offline moves the txs of a bridge operation through a key that
+// never touches a networked machine: Build writes the unsigned txs, with
+// nonce, fees and decoded intent, to a File on a networked machine, Sign
+// signs it on the air-gapped one and Broadcast sends the signed txs.
+//
+// A File is JSON meant to be read by the people approving it. Amounts are
+// decimal strings in wei and every tx says what it calls in Intent, which
+// Sign decodes again from the calldata so a file whose intent was edited is
+// refused.
+package offline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// FileVersion is the version of the File format, files of other versions are
+// rejected.
+const FileVersion = 1
+
+// Chains of a Tx.
+const (
+	ChainL1 = "l1"
+	ChainL2 = "l2"
+)
+
+// File is the txs of one bridge operation in the order they must be sent.
+type File struct {
+	Version int    `json:"version"`
+	Profile string `json:"profile"`
+	Txs     []*Tx  `json:"txs"`
+}
+
+// Tx is one tx of a File. Either GasPrice or the EIP-1559 caps are set.
+// Signed and Hash are empty until the file is signed.
+type Tx struct {
+	Chain                string         `json:"chain"` // l1 or l2
+	ChainID              uint64         `json:"chainId"`
+	From                 common.Address `json:"from"`
+	To                   common.Address `json:"to"`
+	Nonce                uint64         `json:"nonce"`
+	Value                string         `json:"value"` // wei
+	Gas                  uint64         `json:"gas"`
+	GasPrice             string         `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string         `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string         `json:"maxPriorityFeePerGas,omitempty"`
+	Data                 hexutil.Bytes  `json:"data"`
+	Intent               *Intent        `json:"intent"`
+	Signed               hexutil.Bytes  `json:"signed,omitempty"` // RLP of the signed tx
+	Hash                 *common.Hash   `json:"hash,omitempty"`
+}
+
+// Intent is the call of a tx decoded with the ABI of its contract.
+type Intent struct {
+	Contract string `json:"contract"` // name of the contract in the profile
+	Method   string `json:"method"`
+	Args     []Arg  `json:"args"`
+}
+
+// Arg is one decoded argument of an Intent.
+type Arg struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// ReadFile reads and decodes a File. Unknown fields and versions are
+// rejected rather than silently dropped.
+func ReadFile(path string) (*File, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tx file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	f := &File{}
+	if err := dec.Decode(f); err != nil {
+		return nil, fmt.Errorf("failed to decode tx file %s: %w", path, err)
+	}
+	if f.Version != FileVersion {
+		return nil, fmt.Errorf("unsupported tx file version %d, want %d", f.Version, FileVersion)
+	}
+	return f, nil
+}
+
+// WriteFile writes the file as indented JSON.
+func (f *File) WriteFile(path string) error {
+	raw, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(raw, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write tx file: %w", err)
+	}
+	return nil
+}
+
+// newTx describes an unsigned tx built for chain.
+func newTx(chain string, chainID *big.Int, from common.Address, tx *types.Transaction) *Tx {
+	t := &Tx{
+		Chain:   chain,
+		ChainID: chainID.Uint64(),
+		From:    from,
+		Nonce:   tx.Nonce(),
+		Value:   tx.Value().String(),
+		Gas:     tx.Gas(),
+		Data:    tx.Data(),
+	}
+	if tx.To() != nil {
+		t.To = *tx.To()
+	}
+	if tx.Type() == types.LegacyTxType {
+		t.GasPrice = tx.GasPrice().String()
+	} else {
+		t.MaxFeePerGas = tx.GasFeeCap().String()
+		t.MaxPriorityFeePerGas = tx.GasTipCap().String()
+	}
+	return t
+}
+
+// Unsigned rebuilds the tx the file describes.
+func (t *Tx) Unsigned() (*types.Transaction, error) {
+	value, err := parseWei("value", t.Value)
+	if err != nil {
+		return nil, err
+	}
+	to := t.To
+	if t.GasPrice != "" {
+		if t.MaxFeePerGas != "" || t.MaxPriorityFeePerGas != "" {
+			return nil, fmt.Errorf("tx %d: give either gasPrice or the EIP-1559 fee caps", t.Nonce)
+		}
+		price, err := parseWei("gasPrice", t.GasPrice)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewTx(&types.LegacyTx{Nonce: t.Nonce, GasPrice: price, Gas: t.Gas, To: &to, Value: value, Data: t.Data}), nil
+	}
+	feeCap, err := parseWei("maxFeePerGas", t.MaxFeePerGas)
+	if err != nil {
+		return nil, err
+	}
+	tipCap, err := parseWei("maxPriorityFeePerGas", t.MaxPriorityFeePerGas)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   new(big.Int).SetUint64(t.ChainID),
+		Nonce:     t.Nonce,
+		GasTipCap: tipCap,
+		GasFeeCap: feeCap,
+		Gas:       t.Gas,
+		To:        &to,
+		Value:     value,
+		Data:      t.Data,
+	}), nil
+}
+
+// signedTx decodes Signed and checks that it is the tx the file describes,
+// signed by From for ChainID.
+func (t *Tx) signedTx() (*types.Transaction, error) {
+	if len(t.Signed) == 0 {
+		return nil, fmt.Errorf("tx %d of %s is not signed", t.Nonce, t.From)
+	}
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(t.Signed); err != nil {
+		return nil, fmt.Errorf("failed to decode signed tx %d: %w", t.Nonce, err)
+	}
+	unsigned, err := t.Unsigned()
+	if err != nil {
+		return nil, err
+	}
+	chainID := new(big.Int).SetUint64(t.ChainID)
+	if signed.ChainId().Cmp(chainID) != 0 {
+		return nil, fmt.Errorf("tx %d is signed for chain %d, the file says %d", t.Nonce, signed.ChainId(), t.ChainID)
+	}
+	txSigner := types.LatestSignerForChainID(chainID)
+	if txSigner.Hash(signed) != txSigner.Hash(unsigned) {
+		return nil, fmt.Errorf("signed tx %d differs from the tx the file describes", t.Nonce)
+	}
+	from, err := types.Sender(txSigner, signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover signer of tx %d: %w", t.Nonce, err)
+	}
+	if from != t.From {
+		return nil, fmt.Errorf("tx %d is signed by %s, not %s", t.Nonce, from, t.From)
+	}
+	if t.Hash != nil && *t.Hash != signed.Hash() {
+		return nil, fmt.Errorf("tx %d hashes to %s, the file says %s", t.Nonce, signed.Hash(), t.Hash)
+	}
+	return signed, nil
+}
+
+func parseWei(field, s string) (*big.Int, error) {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok || v.Sign() < 0 {
+		return nil, fmt.Errorf("%s must be a decimal amount of wei, got %q", field, s)
+	}
+	return v, nil
+}
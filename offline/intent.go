@@ -0,0 +1,115 @@
+package offline
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"try_rde/abistr/abijson"
+	"try_rde/config"
+)
+
+// contract is a contract of the profile a tx may call.
+type contract struct {
+	name string
+	meta *bind.MetaData
+}
+
+// contracts lists the contracts of the profile on chain by address. Tokens
+// are only ever approved, any ERC20 binding decodes that.
+func contracts(p *config.Profile, chain string) map[common.Address]contract {
+	addrs := p.Addresses
+	out := make(map[common.Address]contract)
+	add := func(addr common.Address, name string, meta *bind.MetaData) {
+		if addr != (common.Address{}) {
+			out[addr] = contract{name: name, meta: meta}
+		}
+	}
+	symbols := make([]string, 0, len(p.Tokens))
+	for symbol := range p.Tokens {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	switch chain {
+	case ChainL1:
+		for _, symbol := range symbols {
+			add(p.Tokens[symbol].L1, "token "+symbol, abijson.L2TestTokenMetaData)
+		}
+		add(addrs.L1MantleToken, "l1MantleToken", abijson.L1MantleTokenMetaData)
+		add(addrs.L1StandardBridge, "l1StandardBridge", abijson.L1StandardBridgeMetaData)
+		add(addrs.L1OptimismPortal, "l1OptimismPortal", abijson.L1OptimismPortalMetaData)
+	case ChainL2:
+		for _, symbol := range symbols {
+			add(p.Tokens[symbol].L2, "token "+symbol, abijson.L2TestTokenMetaData)
+		}
+		add(addrs.L2ETH, "l2Eth", abijson.L2TestTokenMetaData)
+		add(addrs.L2StandardBridge, "l2StandardBridge", abijson.L2StandardBridgeMetaData)
+		add(addrs.L2ToL1MessagePasser, "l2ToL1MessagePasser", abijson.L2ToL1MessagePasserMetaData)
+	}
+	return out
+}
+
+// decodeIntent decodes the call of a tx to a contract of the profile. Calls
+// to anything else are refused, a reviewer could not tell what they do.
+func decodeIntent(p *config.Profile, chain string, to common.Address, data []byte) (*Intent, error) {
+	c, ok := contracts(p, chain)[to]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a contract of profile %q on %s", to, p.Name, chain)
+	}
+	contractABI, err := c.meta.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("calldata to %s is too short for a method call", c.name)
+	}
+	method, err := contractABI.MethodById(data[:4])
+	if err != nil {
+		return nil, fmt.Errorf("unknown method of %s: %w", c.name, err)
+	}
+	values, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s.%s: %w", c.name, method.Name, err)
+	}
+	intent := &Intent{Contract: c.name, Method: method.Name, Args: make([]Arg, len(values))}
+	for i, v := range values {
+		intent.Args[i] = Arg{Name: method.Inputs[i].Name, Type: method.Inputs[i].Type.String(), Value: formatValue(v)}
+	}
+	return intent, nil
+}
+
+// formatValue prints a decoded ABI value for people: addresses checksummed,
+// integers in decimal, bytes in hex and tuples field by field.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case common.Address:
+		return v.Hex()
+	case *big.Int:
+		return v.String()
+	case []byte:
+		return hexutil.Encode(v)
+	case [32]byte:
+		return common.Hash(v).Hex()
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = formatValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Struct:
+		fields := make([]string, rv.NumField())
+		for i := range fields {
+			fields[i] = rv.Type().Field(i).Name + ": " + formatValue(rv.Field(i).Interface())
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,149 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/bridge"
+	"try_rde/config"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+// ErrNothingRecorded is returned by Build when the operation built no tx.
+var ErrNothingRecorded = errors.New("the operation built no tx")
+
+// Build turns the txs rec recorded while an operation ran on c into a File.
+// c may be an L1 only client if every tx is on L1.
+func Build(c *bridge.Client, rec *signer.Recorder) (*File, error) {
+	recorded := rec.Txs()
+	if len(recorded) == 0 {
+		return nil, ErrNothingRecorded
+	}
+	f := &File{Version: FileVersion, Profile: c.Profile.Name}
+	for _, r := range recorded {
+		var name string
+		switch {
+		case r.ChainID.Cmp(c.L1ChainID) == 0:
+			name = ChainL1
+		case c.L2ChainID != nil && r.ChainID.Cmp(c.L2ChainID) == 0:
+			name = ChainL2
+		default:
+			return nil, fmt.Errorf("tx %d was built for unknown chain %d", r.Tx.Nonce(), r.ChainID)
+		}
+		t := newTx(name, r.ChainID, rec.Address(), r.Tx)
+		intent, err := decodeIntent(c.Profile, name, t.To, t.Data)
+		if err != nil {
+			return nil, err
+		}
+		t.Intent = intent
+		f.Txs = append(f.Txs, t)
+	}
+	return f, nil
+}
+
+// Sign signs every tx of the file with s, without any network. It refuses a
+// tx from another account, one for a chain other than the one the profile
+// expects, and one whose intent does not match its calldata. The profile must
+// set the chain id of every chain the file has txs for, the file comes from a
+// networked machine and can not vouch for it.
+func Sign(f *File, s signer.Signer, p *config.Profile) error {
+	for _, t := range f.Txs {
+		if len(t.Signed) != 0 {
+			return fmt.Errorf("tx %d of %s is already signed", t.Nonce, t.From)
+		}
+		if t.From != s.Address() {
+			return fmt.Errorf("tx %d is from %s, the signer is %s", t.Nonce, t.From, s.Address())
+		}
+		var expected uint64
+		switch t.Chain {
+		case ChainL1:
+			expected = p.L1.ChainID
+		case ChainL2:
+			expected = p.L2.ChainID
+		default:
+			return fmt.Errorf("tx %d is for unknown chain %q", t.Nonce, t.Chain)
+		}
+		if expected == 0 {
+			return fmt.Errorf("profile %q sets no %s chain id to check tx %d against", p.Name, t.Chain, t.Nonce)
+		}
+		if t.ChainID != expected {
+			return fmt.Errorf("tx %d is for %s chain id %d, profile %q expects %d", t.Nonce, t.Chain, t.ChainID, p.Name, expected)
+		}
+		intent, err := decodeIntent(p, t.Chain, t.To, t.Data)
+		if err != nil {
+			return fmt.Errorf("tx %d: %w", t.Nonce, err)
+		}
+		if !reflect.DeepEqual(intent, t.Intent) {
+			return fmt.Errorf("tx %d: intent does not match its calldata, which calls %s.%s", t.Nonce, intent.Contract, intent.Method)
+		}
+
+		unsigned, err := t.Unsigned()
+		if err != nil {
+			return err
+		}
+		signed, err := s.SignTx(unsigned, new(big.Int).SetUint64(t.ChainID))
+		if err != nil {
+			return fmt.Errorf("failed to sign tx %d: %w", t.Nonce, err)
+		}
+		if t.Signed, err = signed.MarshalBinary(); err != nil {
+			return err
+		}
+		hash := signed.Hash()
+		t.Hash = &hash
+		// catch a signer that signed something else
+		if _, err := t.signedTx(); err != nil {
+			t.Signed, t.Hash = nil, nil
+			return err
+		}
+	}
+	return nil
+}
+
+// Broadcast sends the signed txs of the file in order and returns them. It
+// checks every tx against the file and the chain id of the node first. A tx
+// the node already knows counts as sent, so a broadcast can be retried.
+// c may be an L1 only client if every tx is on L1.
+func Broadcast(ctx context.Context, c *bridge.Client, f *File) ([]*types.Transaction, error) {
+	signed := make([]*types.Transaction, len(f.Txs))
+	backends := make([]bridge.Backend, len(f.Txs))
+	for i, t := range f.Txs {
+		backend, chainID, err := chain(c, t.Chain)
+		if err != nil {
+			return nil, err
+		}
+		if chainID.Uint64() != t.ChainID {
+			return nil, fmt.Errorf("tx %d is for %s chain id %d, the node is on %d", t.Nonce, t.Chain, t.ChainID, chainID)
+		}
+		if signed[i], err = t.signedTx(); err != nil {
+			return nil, err
+		}
+		backends[i] = backend
+	}
+
+	sent := make([]*types.Transaction, 0, len(signed))
+	for i, tx := range signed {
+		if err := backends[i].SendTransaction(ctx, tx); err != nil && !txutils.IsAlreadyKnown(err) {
+			return sent, fmt.Errorf("failed to send tx %s: %w", tx.Hash(), err)
+		}
+		sent = append(sent, tx)
+	}
+	return sent, nil
+}
+
+// chain returns the backend and chain id of the named chain of c.
+func chain(c *bridge.Client, name string) (bridge.Backend, *big.Int, error) {
+	switch {
+	case name == ChainL1:
+		return c.L1, c.L1ChainID, nil
+	case name == ChainL2 && c.L2 != nil:
+		return c.L2, c.L2ChainID, nil
+	case name == ChainL2:
+		return nil, nil, errors.New("the file has l2 txs but there is no l2 connection")
+	}
+	return nil, nil, fmt.Errorf("unknown chain %q", name)
+}
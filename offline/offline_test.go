@@ -0,0 +1,96 @@
+package offline
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+func Test_OfflineSigning(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	treasury := bridgetest.Account(t, n)
+	n.L1.MintToken(n.Profile.Addresses.L1MantleToken, treasury.Address(), big.NewInt(params.Ether))
+
+	// build on the networked machine, nothing is sent
+	rec := signer.NewRecorder(treasury.Address())
+	_, err := c.DepositETH(ctx, rec, bridge.TransferArgs{Amount: big.NewInt(params.Ether)})
+	require.NoError(t, err)
+	f, err := Build(c, rec)
+	require.NoError(t, err)
+	require.Len(t, f.Txs, 1)
+	tx := f.Txs[0]
+	ast.Equal(ChainL1, tx.Chain)
+	ast.Equal(uint64(bridgetest.L1ChainID), tx.ChainID)
+	ast.Equal("1000000000000000000", tx.Value)
+	ast.Equal("l1StandardBridge", tx.Intent.Contract)
+	ast.Equal("depositETHTo", tx.Intent.Method)
+	ast.Equal(Arg{Name: "_to", Type: "address", Value: treasury.Address().Hex()}, tx.Intent.Args[0])
+	nonce, err := c.L1.PendingNonceAt(ctx, treasury.Address())
+	require.NoError(t, err)
+	ast.Equal(uint64(0), nonce)
+	_, err = rec.SignHash(common.Hash{})
+	ast.ErrorIs(err, signer.ErrNoKey)
+
+	path := filepath.Join(t.TempDir(), "deposit.json")
+	require.NoError(t, f.WriteFile(path))
+
+	// sign on the air-gapped machine
+	f, err = ReadFile(path)
+	require.NoError(t, err)
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	ast.ErrorContains(Sign(f, signer.NewPrivateKeySigner(other), n.Profile), "the signer is")
+	wrongChain := n.Profile.Clone()
+	wrongChain.L1.ChainID = 1
+	ast.ErrorContains(Sign(f, treasury, wrongChain), "profile \"bridgetest\" expects 1")
+	wrongChain.L1.ChainID = 0
+	ast.ErrorContains(Sign(f, treasury, wrongChain), "sets no l1 chain id")
+	f.Txs[0].Intent.Args[0].Value = common.HexToAddress("0x01").Hex()
+	ast.ErrorContains(Sign(f, treasury, n.Profile), "intent does not match its calldata")
+	f, err = ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, Sign(f, treasury, n.Profile))
+	ast.ErrorContains(Sign(f, treasury, n.Profile), "already signed")
+	require.NoError(t, f.WriteFile(path))
+
+	// broadcast on the networked machine
+	f, err = ReadFile(path)
+	require.NoError(t, err)
+	tampered := *f.Txs[0]
+	tampered.Value = "2000000000000000000"
+	_, err = Broadcast(ctx, c, &File{Version: FileVersion, Txs: []*Tx{&tampered}})
+	ast.ErrorContains(err, "differs from the tx the file describes")
+	l1Only, err := bridge.NewL1Client(ctx, n.Profile, n.L1)
+	require.NoError(t, err)
+	sent, err := Broadcast(ctx, l1Only, f)
+	require.NoError(t, err)
+	require.Len(t, sent, 1)
+	ast.Equal(*f.Txs[0].Hash, sent[0].Hash())
+	receipt, err := txutils.WaitForReceipt(ctx, c.L1, sent[0].Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+	ast.Equal(uint64(1), receipt.Status)
+
+	// a deposit that needs an approval builds the approval alone
+	rec = signer.NewRecorder(treasury.Address())
+	_, err = c.DepositMNT(ctx, rec, bridge.TransferArgs{Amount: big.NewInt(params.Ether)})
+	ast.ErrorIs(err, bridge.ErrApproveFirst)
+	f, err = Build(c, rec)
+	require.NoError(t, err)
+	require.Len(t, f.Txs, 1)
+	ast.Equal("l1MantleToken", f.Txs[0].Intent.Contract)
+	ast.Equal("approve", f.Txs[0].Intent.Method)
+	ast.Equal(uint64(1), f.Txs[0].Nonce)
+}
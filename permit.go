@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"try_rde/bridge"
+)
+
+// runPermit signs an EIP-2612 permit of the holder's L1 MNT. The holder only
+// signs, a sponsor spends it with deposit mnt --permit. L1StandardBridge
+// only deposits from its caller and can't be the spender for a third party,
+// so the sponsor pulls the MNT to its account and deposits it from there.
+func runPermit(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("permit", flag.ContinueOnError)
+	spender := fs.String("spender", "", "account allowed to spend, the sponsor of deposit mnt --permit; not L1StandardBridge, which only deposits from its caller, the MNT is in the sponsor's account until it is deposited")
+	amount := fs.String("amount", "", "amount in wei, or with an ether/gwei suffix (e.g. 1.5ether)")
+	validFor := fs.Duration("valid-for", time.Hour, "how long the permit can be submitted")
+	out := fs.String("out", "", "file to write the signed permit to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !common.IsHexAddress(*spender) {
+		return usageErrorf("--spender: invalid address %q", *spender)
+	}
+	value, err := parseAmount(*amount)
+	if err != nil {
+		return usageErrorf("--amount: %s", err)
+	}
+	if *out == "" {
+		return usageErrorf("--out is required")
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	c, err := env.dialL1(ctx)
+	if err != nil {
+		return err
+	}
+	p, err := c.SignMNTPermit(ctx, s, common.HexToAddress(*spender), value, time.Now().Add(*validFor))
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, append(raw, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write permit: %w", err)
+	}
+	fmt.Printf("owner:               %s\n", p.Owner.Hex())
+	fmt.Printf("spender:             %s\n", p.Spender.Hex())
+	fmt.Printf("value:               %s wei\n", p.Value)
+	fmt.Printf("deadline:            %s\n", time.Unix(p.Deadline.Int64(), 0).Format(time.RFC3339))
+	fmt.Printf("permit written to %s\n", *out)
+	return nil
+}
+
+func readPermit(path string) (*bridge.Permit, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read permit: %w", err)
+	}
+	p := &bridge.Permit{}
+	if err := json.Unmarshal(raw, p); err != nil {
+		return nil, fmt.Errorf("failed to decode permit %s: %w", path, err)
+	}
+	if p.Value == nil || p.Nonce == nil || p.Deadline == nil {
+		return nil, fmt.Errorf("permit %s is missing fields", path)
+	}
+	return p, nil
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"try_rde/bridge"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+func runProve(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("prove", flag.ContinueOnError)
+	var wf withdrawalFlags
+	wf.register(fs, true)
+	wait := fs.Bool("wait", true, "wait for the receipt")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	txHash, logIndex, err := wf.parse()
+	if err != nil {
+		return err
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case wf.all:
+		txs, err := c.ProveWithdrawals(ctx, s, txHash)
+		return env.reportAll(ctx, c.L1, txs, err, *wait)
+	case logIndex != nil:
+		tx, err := c.ProveWithdrawalAt(ctx, s, txHash, *logIndex)
+		if err != nil {
+			return err
+		}
+		return env.report(ctx, c.L1, tx, *wait)
+	}
+	tx, err := c.ProveWithdrawal(ctx, s, txHash)
+	if err != nil {
+		return err
+	}
+	return env.report(ctx, c.L1, tx, *wait)
+}
+
+func runFinalize(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("finalize", flag.ContinueOnError)
+	var wf withdrawalFlags
+	wf.register(fs, true)
+	wait := fs.Bool("wait", true, "wait for the receipt")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	txHash, logIndex, err := wf.parse()
+	if err != nil {
+		return err
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case wf.all:
+		txs, err := c.FinalizeWithdrawals(ctx, s, txHash)
+		return env.reportAll(ctx, c.L1, txs, err, *wait)
+	case logIndex != nil:
+		tx, err := c.FinalizeWithdrawalAt(ctx, s, txHash, *logIndex)
+		if err != nil {
+			return err
+		}
+		return env.report(ctx, c.L1, tx, *wait)
+	}
+	tx, err := c.FinalizeWithdrawal(ctx, s, txHash)
+	if err != nil {
+		return err
+	}
+	return env.report(ctx, c.L1, tx, *wait)
+}
+
+func runStatus(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	var wf withdrawalFlags
+	wf.register(fs, false)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	txHash, logIndex, err := wf.parse()
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	var st *bridge.WithdrawalStatus
+	if logIndex != nil {
+		st, err = c.WithdrawalStatusAt(ctx, txHash, *logIndex)
+	} else {
+		st, err = c.WithdrawalStatus(ctx, txHash)
+	}
+	if err != nil {
+		return err
+	}
+	facts, _, err := withdrawal.Derive(ctx, c, txHash, logIndex)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("state:               %s\n", facts.State())
+	fmt.Printf("withdrawal hash:     %s\n", st.WithdrawalHash.Hex())
+	fmt.Printf("l2 block:            %d\n", st.L2BlockNumber)
+	fmt.Printf("latest output block: %d\n", st.LatestOutputBlock)
+	fmt.Printf("proven:              %v\n", st.Proven)
+	if st.Proven {
+		fmt.Printf("proven at:           %s\n", st.ProvenAt.Format(time.RFC3339))
+		fmt.Printf("finalizable at:      %s\n", st.FinalizableAt.Format(time.RFC3339))
+	}
+	fmt.Printf("finalized:           %v\n", st.Finalized)
+	return nil
+}
+
+func runComplete(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("complete", flag.ContinueOnError)
+	var wf withdrawalFlags
+	wf.register(fs, true)
+	stateDir := fs.String("state-dir", ".rde/withdrawals", "directory where progress is kept between runs")
+	poll := fs.Duration("poll", 12*time.Second, "how often to check the chain while waiting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	txHash, logIndex, err := wf.parse()
+	if err != nil {
+		return err
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	store, err := withdrawal.NewFileStore(*stateDir)
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	d := &withdrawal.Driver{
+		Client:       c,
+		Signer:       s,
+		Store:        store,
+		PollInterval: *poll,
+		OnTransition: func(rec *withdrawal.Record) {
+			if rec.LogIndex != nil {
+				fmt.Printf("%s log %d %s\n", time.Now().Format(time.RFC3339), *rec.LogIndex, rec.State)
+				return
+			}
+			fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), rec.State)
+		},
+	}
+	var records []*withdrawal.Record
+	if wf.all {
+		records, err = d.RunAll(ctx, txHash)
+	} else {
+		var rec *withdrawal.Record
+		rec, err = d.Run(ctx, txHash, logIndex)
+		records = []*withdrawal.Record{rec}
+	}
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if rec.State != withdrawal.StateFailed {
+			continue
+		}
+		if rec.LogIndex != nil {
+			return fmt.Errorf("withdrawal at log index %d failed: %s", *rec.LogIndex, rec.Error)
+		}
+		return fmt.Errorf("withdrawal failed: %s", rec.Error)
+	}
+	return nil
+}
+
+func runWatch(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	stateDir := fs.String("state-dir", ".rde/withdrawals", "withdrawal progress dir of complete")
+	fromBlock := fs.Uint64("from-block", 0, "first L1 block to scan on the first run (default: head)")
+	poll := fs.Duration("poll", 12*time.Second, "how often to check for new L1 blocks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	store, err := withdrawal.NewFileStore(*stateDir)
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	w := &withdrawal.Watcher{
+		Driver: &withdrawal.Driver{
+			Client: c,
+			Signer: s,
+			Store:  store,
+			OnTransition: func(rec *withdrawal.Record) {
+				fmt.Printf("%s %s %s\n", time.Now().Format(time.RFC3339), rec.TxHash.Hex(), rec.State)
+			},
+		},
+		FromBlock:    *fromBlock,
+		PollInterval: *poll,
+		Log:          txutils.NewStdLogger(nil),
+	}
+	fmt.Printf("%s watching the output oracle\n", time.Now().Format(time.RFC3339))
+	if err := w.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+func runETA(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("eta", flag.ContinueOnError)
+	var wf withdrawalFlags
+	wf.register(fs, false)
+	block := fs.Uint64("block", 0, "L2 block of the withdrawal, instead of --tx")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (wf.tx == "") == (*block == 0) {
+		return usageErrorf("give either --tx or --block")
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	var eta *withdrawal.ETA
+	if wf.tx != "" {
+		txHash, logIndex, err := wf.parse()
+		if err != nil {
+			return err
+		}
+		eta, err = withdrawal.EstimateWithdrawalETA(ctx, c, txHash, logIndex)
+		if err != nil {
+			return err
+		}
+	} else if eta, err = withdrawal.EstimateETA(ctx, c, *block); err != nil {
+		return err
+	}
+
+	// estimates are marked with a ~
+	mark := func(known bool) string {
+		if known {
+			return ""
+		}
+		return "~"
+	}
+	fmt.Printf("l2 block:            %d\n", eta.L2BlockNumber)
+	fmt.Printf("output block:        %d\n", eta.OutputBlock)
+	if eta.Proposed() {
+		fmt.Printf("output index:        %d\n", eta.OutputIndex)
+	}
+	fmt.Printf("proposed at:         %s%s\n", mark(eta.Proposed()), eta.ProposedAt.Format(time.RFC3339))
+	fmt.Printf("provable at:         %s%s\n", mark(eta.Proposed()), eta.ProvableAt.Format(time.RFC3339))
+	if eta.Proven {
+		fmt.Printf("proven at:           %s\n", eta.ProvenAt.Format(time.RFC3339))
+	}
+	fmt.Printf("finalizable at:      %s%s\n", mark(eta.Proven), eta.FinalizableAt.Format(time.RFC3339))
+	if wait := eta.FinalizableAt.Sub(eta.L1Time); wait > 0 && !eta.Finalized {
+		fmt.Printf("time left:           %s%s\n", mark(eta.Proven), wait)
+	}
+	fmt.Printf("finalized:           %v\n", eta.Finalized)
+	if eta.Cadence > 0 {
+		fmt.Printf("proposer cadence:    %s, %s behind L2\n", eta.Cadence, eta.Lag)
+	}
+	return nil
+}
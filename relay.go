@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+func runRelay(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("relay", flag.ContinueOnError)
+	stateDir := fs.String("state-dir", ".rde/relayer", "directory where the relayer keeps its progress")
+	fromBlock := fs.Uint64("from-block", 0, "first L2 block to scan on the first run (default: head)")
+	senders := fs.String("senders", "", "comma separated L2 senders to relay for, or --targets (default: any)")
+	targets := fs.String("targets", "", "comma separated L1 targets to relay for, or --senders (default: any)")
+	poll := fs.Duration("poll", 12*time.Second, "how often to check for new blocks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	senderList, err := parseAddressList("--senders", *senders)
+	if err != nil {
+		return err
+	}
+	targetList, err := parseAddressList("--targets", *targets)
+	if err != nil {
+		return err
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	store, err := withdrawal.NewFileStore(*stateDir)
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	r := &withdrawal.Relayer{
+		Driver: &withdrawal.Driver{
+			Client: c,
+			Signer: s,
+			Store:  store,
+			OnTransition: func(rec *withdrawal.Record) {
+				fmt.Printf("%s %s %s\n", time.Now().Format(time.RFC3339), rec.TxHash.Hex(), rec.State)
+			},
+		},
+		Senders:      senderList,
+		Targets:      targetList,
+		FromBlock:    *fromBlock,
+		PollInterval: *poll,
+		Log:          txutils.NewStdLogger(nil),
+	}
+	fmt.Printf("%s relaying withdrawals as %s\n", time.Now().Format(time.RFC3339), s.Address().Hex())
+	if err := r.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// parseAddressList parses a comma separated list of addresses, nil if s is
+// empty.
+func parseAddressList(flagName, s string) ([]common.Address, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var out []common.Address
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if !common.IsHexAddress(part) {
+			return nil, usageErrorf("%s: invalid address %q", flagName, part)
+		}
+		out = append(out, common.HexToAddress(part))
+	}
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"try_rde/withdrawal"
+)
+
+func runResolve(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	hashHex := fs.String("hash", "", "withdrawal hash")
+	dbPath := fs.String("db", "", "event index to search first (default: scan the L2 node only)")
+	fromBlock := fs.Uint64("from-block", 0, "first L2 block to scan (default: --scan-blocks before the head)")
+	scanBlocks := fs.Uint64("scan-blocks", withdrawal.DefaultScanBlocks, "how many of the latest L2 blocks to scan")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	b := common.FromHex(*hashHex)
+	if len(b) != common.HashLength {
+		return usageErrorf("invalid withdrawal hash %q", *hashHex)
+	}
+	hash := common.BytesToHash(b)
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	scan := &withdrawal.ScanFinder{Client: c, FromBlock: *fromBlock, MaxBlocks: *scanBlocks}
+	var res *withdrawal.Resolution
+	if *dbPath != "" {
+		ix, err := env.openIndex(ctx, *dbPath)
+		if err != nil {
+			return err
+		}
+		defer ix.DB.Close()
+		res, err = withdrawal.Resolve(ctx, c, &withdrawal.IndexFinder{DB: ix.DB, Passer: c.MessagePasser}, hash)
+		if errors.Is(err, withdrawal.ErrMessageNotFound) {
+			// the index may be behind the node
+			res, err = withdrawal.Resolve(ctx, c, scan, hash)
+		}
+		if err != nil {
+			return err
+		}
+	} else if res, err = withdrawal.Resolve(ctx, c, scan, hash); err != nil {
+		return err
+	}
+
+	facts := res.Facts
+	fmt.Printf("withdrawal hash:     %s\n", res.WithdrawalHash.Hex())
+	fmt.Printf("l2 tx:               %s\n", res.TxHash.Hex())
+	fmt.Printf("log index:           %d\n", res.LogIndex)
+	fmt.Printf("l2 block:            %d\n", facts.L2BlockNumber)
+	fmt.Printf("sender:              %s\n", res.Message.Sender.Hex())
+	fmt.Printf("target:              %s\n", res.Message.Target.Hex())
+	fmt.Printf("state:               %s\n", res.State())
+	fmt.Printf("proven:              %v\n", facts.ProvenTimestamp != 0)
+	if facts.ProvenTimestamp != 0 {
+		fmt.Printf("proven at:           %s\n", time.Unix(int64(facts.ProvenTimestamp), 0).Format(time.RFC3339))
+		fmt.Printf("finalizable at:      %s\n", time.Unix(int64(facts.FinalizableAt()), 0).Format(time.RFC3339))
+	}
+	fmt.Printf("finalized:           %v\n", facts.Finalized)
+	return nil
+}
@@ -0,0 +1,68 @@
+// Package rpcreplay records the JSON-RPC traffic of a session to a fixture
+// file and serves it back later, so tests written against live nodes can run
+// without them.
+package rpcreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Interaction is one JSON-RPC call and the answer the node gave.
+type Interaction struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// key identifies the calls a replay treats as the same request.
+func (i *Interaction) key() string {
+	return i.Method + " " + compact(i.Params)
+}
+
+func compact(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// Fixture holds the recorded calls per endpoint, in the order they were made.
+// Endpoints are named by the session, e.g. "l1" and "l2".
+type Fixture struct {
+	Endpoints map[string][]*Interaction `json:"endpoints"`
+}
+
+// LoadFixture reads a fixture written by Save.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	if f.Endpoints == nil {
+		f.Endpoints = make(map[string][]*Interaction)
+	}
+	return &f, nil
+}
+
+// Save writes the fixture as indented JSON so it diffs well, creating the
+// directory if needed.
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
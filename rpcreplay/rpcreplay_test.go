@@ -0,0 +1,160 @@
+package rpcreplay
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type counterService struct{ n int }
+
+func (s *counterService) Next() int {
+	s.n++
+	return s.n
+}
+
+func (s *counterService) Echo(v string) string {
+	return v
+}
+
+func Test_RecordAndReplay(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("test", &counterService{}))
+	node := httptest.NewServer(server)
+
+	rec, err := NewSession(ModeRecord, path)
+	require.NoError(t, err)
+	cl, err := rec.Dial(ctx, "l1", node.URL)
+	require.NoError(t, err)
+	var n int
+	var s string
+	for want := 1; want <= 2; want++ {
+		require.NoError(t, cl.CallContext(ctx, &n, "test_next"))
+		ast.Equal(want, n)
+	}
+	require.NoError(t, cl.CallContext(ctx, &s, "test_echo", "a"))
+	require.NoError(t, cl.CallContext(ctx, &s, "test_echo", "b"))
+	batch := []rpc.BatchElem{{Method: "test_echo", Args: []interface{}{"c"}, Result: &s}}
+	require.NoError(t, cl.BatchCallContext(ctx, batch))
+	require.NoError(t, batch[0].Error)
+	ast.Error(cl.CallContext(ctx, &s, "test_missing"))
+	cl.Close()
+	require.NoError(t, rec.Close())
+	node.Close()
+
+	// the node is gone, everything comes from the fixture
+	replay, err := NewSession(ModeReplay, path)
+	require.NoError(t, err)
+	cl, err = replay.Dial(ctx, "l1", "http://unused")
+	require.NoError(t, err)
+	require.NoError(t, cl.CallContext(ctx, &s, "test_echo", "b"))
+	ast.Equal("b", s)
+	require.NoError(t, cl.CallContext(ctx, &s, "test_echo", "a"))
+	ast.Equal("a", s)
+	for _, want := range []int{1, 2, 2} {
+		require.NoError(t, cl.CallContext(ctx, &n, "test_next"))
+		ast.Equal(want, n, "answers are served in order, the last one repeats")
+	}
+	batch[0].Result, batch[0].Error = &s, nil
+	require.NoError(t, cl.BatchCallContext(ctx, batch))
+	require.NoError(t, batch[0].Error)
+	ast.Equal("c", s)
+	ast.Error(cl.CallContext(ctx, &s, "test_missing"), "recorded errors are replayed")
+	ast.ErrorContains(cl.CallContext(ctx, &s, "test_echo", "d"), "no recorded response")
+
+	// endpoints are kept apart
+	other, err := replay.Dial(ctx, "l2", "http://unused")
+	require.NoError(t, err)
+	ast.ErrorContains(other.CallContext(ctx, &s, "test_echo", "a"), "no recorded response")
+}
+
+func Test_ParseMode(t *testing.T) {
+	ast := assert.New(t)
+	for in, want := range map[string]Mode{"": ModeLive, "live": ModeLive, "record": ModeRecord, "REPLAY": ModeReplay} {
+		m, err := ParseMode(in)
+		ast.NoError(err)
+		ast.Equal(want, m, in)
+	}
+	_, err := ParseMode("tape")
+	ast.Error(err)
+
+	_, err = NewSession(ModeReplay, filepath.Join(t.TempDir(), "missing.json"))
+	ast.Error(err)
+}
+
+// fakeT runs ForTest without failing the real test: Fatalf stops ForTest
+// with a panic and the cleanups run on demand.
+type fakeT struct {
+	testing.TB
+	failed   bool
+	fatal    string
+	cleanups []func()
+}
+
+func (f *fakeT) Name() string                { return "Test_Fake" }
+func (f *fakeT) Failed() bool                { return f.failed }
+func (f *fakeT) Cleanup(fn func())           { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeT) Logf(string, ...interface{}) {}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.fatal = fmt.Sprintf(format, args...)
+	panic(f)
+}
+
+func (f *fakeT) forTest(dir string) *Session {
+	defer func() {
+		if r := recover(); r != nil && r != f {
+			panic(r)
+		}
+	}()
+	return ForTest(f, dir)
+}
+
+func (f *fakeT) end() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func Test_ForTest(t *testing.T) {
+	ast := assert.New(t)
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "Test_Fake.json")
+
+	t.Setenv(EnvMode, "replay")
+	f := &fakeT{TB: t}
+	ast.Nil(f.forTest(dir))
+	ast.Contains(f.fatal, "no rpc fixture", "replaying requires the fixture")
+
+	t.Setenv(EnvMode, "")
+	f = &fakeT{TB: t}
+	ast.Equal(ModeLive, f.forTest(dir).Mode(), "without a fixture the nodes are used")
+
+	// a failed recording keeps the fixture there is
+	t.Setenv(EnvMode, "record")
+	f = &fakeT{TB: t, failed: true}
+	ast.Equal(ModeRecord, f.forTest(dir).Mode())
+	f.end()
+	ast.NoFileExists(fixture)
+	f = &fakeT{TB: t}
+	f.forTest(dir)
+	f.end()
+	ast.FileExists(fixture)
+
+	t.Setenv(EnvMode, "")
+	f = &fakeT{TB: t}
+	ast.Equal(ModeReplay, f.forTest(dir).Mode(), "a committed fixture is replayed")
+	t.Setenv(EnvMode, "live")
+	f = &fakeT{TB: t}
+	ast.Equal(ModeLive, f.forTest(dir).Mode())
+}
@@ -0,0 +1,187 @@
+package rpcreplay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// EnvMode selects the Mode of ForTest: "record", "replay" or "live", see
+// ForTest for the default.
+const EnvMode = "RDE_RPC_MODE"
+
+// Mode is how a Session treats the connections it dials.
+type Mode string
+
+const (
+	// ModeLive talks to the nodes and records nothing.
+	ModeLive Mode = ""
+	// ModeRecord talks to the nodes and saves every call on Close.
+	ModeRecord Mode = "record"
+	// ModeReplay answers every call from the fixture, the nodes are never
+	// contacted. Repeated calls get the recorded answers in order, polling
+	// past the last one keeps getting the last one.
+	ModeReplay Mode = "replay"
+)
+
+// ParseMode parses the value of EnvMode.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(s)); m {
+	case ModeLive, ModeRecord, ModeReplay:
+		return m, nil
+	case "live":
+		return ModeLive, nil
+	}
+	return "", fmt.Errorf("unknown rpc mode %q, want record or replay", s)
+}
+
+// Session records or replays the connections dialed through it into a single
+// fixture file.
+type Session struct {
+	mode Mode
+	path string
+
+	mu      sync.Mutex
+	fixture *Fixture
+	// replay state: the recorded answers per endpoint and call, and how many
+	// of them were served
+	answers map[string]map[string][]*Interaction
+	served  map[string]map[string]int
+}
+
+// NewSession starts a session backed by the fixture at path. Replay requires
+// the fixture to exist, record overwrites it on Close.
+func NewSession(mode Mode, path string) (*Session, error) {
+	s := &Session{
+		mode:    mode,
+		path:    path,
+		fixture: &Fixture{Endpoints: make(map[string][]*Interaction)},
+		answers: make(map[string]map[string][]*Interaction),
+		served:  make(map[string]map[string]int),
+	}
+	if mode != ModeReplay {
+		return s, nil
+	}
+	var err error
+	if s.fixture, err = LoadFixture(path); err != nil {
+		return nil, err
+	}
+	for name, calls := range s.fixture.Endpoints {
+		byKey := make(map[string][]*Interaction)
+		for _, call := range calls {
+			byKey[call.key()] = append(byKey[call.key()], call)
+		}
+		s.answers[name] = byKey
+		s.served[name] = make(map[string]int)
+	}
+	return s, nil
+}
+
+// Mode returns the mode of the session.
+func (s *Session) Mode() Mode {
+	return s.mode
+}
+
+// Dial connects to endpoint under name. Names tell the endpoints of a session
+// apart in the fixture, so they must be the same when recording and
+// replaying. Recording needs an http(s) endpoint.
+func (s *Session) Dial(ctx context.Context, name, endpoint string) (*rpc.Client, error) {
+	switch s.mode {
+	case ModeRecord:
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("rpcreplay: can only record http endpoints, got %s", endpoint)
+		}
+		transport := &recorder{session: s, name: name, next: http.DefaultTransport}
+		return rpc.DialHTTPWithClient(endpoint, &http.Client{Transport: transport})
+	case ModeReplay:
+		transport := &replayer{session: s, name: name}
+		return rpc.DialHTTPWithClient("http://"+name+".rpcreplay", &http.Client{Transport: transport})
+	default:
+		return rpc.DialContext(ctx, endpoint)
+	}
+}
+
+// Close saves the fixture when recording.
+func (s *Session) Close() error {
+	if s.mode != ModeRecord {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fixture.Save(s.path)
+}
+
+func (s *Session) record(name string, call *Interaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixture.Endpoints[name] = append(s.fixture.Endpoints[name], call)
+}
+
+func (s *Session) replay(name string, call *message) *Interaction {
+	key := (&Interaction{Method: call.Method, Params: call.Params}).key()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	answers := s.answers[name][key]
+	if len(answers) == 0 {
+		return nil
+	}
+	i := s.served[name][key]
+	if i >= len(answers) {
+		return answers[len(answers)-1]
+	}
+	s.served[name][key] = i + 1
+	return answers[i]
+}
+
+// ForTest starts a session with the fixture dir/<test name>.json in the mode
+// of $RDE_RPC_MODE. Unset, a committed fixture is replayed and a test without
+// one talks to the nodes; "live" always talks to them. Replaying requires the
+// fixture, the test fails without one. A recorded fixture is saved when the
+// test ends, unless it failed, so a broken session never replaces a good one.
+func ForTest(t testing.TB, dir string) *Session {
+	t.Helper()
+	env := os.Getenv(EnvMode)
+	mode, err := ParseMode(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	path := filepath.Join(dir, name+".json")
+	if env == "" {
+		if _, err := os.Stat(path); err == nil {
+			mode = ModeReplay
+		}
+	}
+	s, err := NewSession(mode, path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("no rpc fixture for %s, record one with %s=record", t.Name(), EnvMode)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			if mode == ModeRecord {
+				t.Logf("test failed, rpc fixture %s not saved", path)
+			}
+			return
+		}
+		if err := s.Close(); err != nil {
+			t.Errorf("failed to save rpc fixture: %v", err)
+		}
+	})
+	return s
+}
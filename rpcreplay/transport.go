@@ -0,0 +1,149 @@
+package rpcreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// message is a JSON-RPC request or response.
+type message struct {
+	Version string          `json:"jsonrpc,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// parseMessages decodes a single message or a batch.
+func parseMessages(body []byte) ([]*message, bool, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []*message
+		err := json.Unmarshal(body, &batch)
+		return batch, true, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, false, err
+	}
+	return []*message{&msg}, false, nil
+}
+
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// recorder forwards every request to the node and adds the calls it answered
+// to the session.
+type recorder struct {
+	session *Session
+	name    string
+	next    http.RoundTripper
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	resp, err := r.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	calls, _, err := parseMessages(reqBody)
+	if err != nil {
+		return resp, nil
+	}
+	answers, _, err := parseMessages(respBody)
+	if err != nil {
+		return resp, nil
+	}
+	byID := make(map[string]*message, len(answers))
+	for _, a := range answers {
+		byID[string(a.ID)] = a
+	}
+	for _, call := range calls {
+		answer, ok := byID[string(call.ID)]
+		if !ok {
+			continue
+		}
+		r.session.record(r.name, &Interaction{
+			Method: call.Method,
+			Params: call.Params,
+			Result: answer.Result,
+			Error:  answer.Error,
+		})
+	}
+	return resp, nil
+}
+
+// replayer answers every request from the fixture of the session, the node is
+// never contacted.
+type replayer struct {
+	session *Session
+	name    string
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	calls, batch, err := parseMessages(body)
+	if err != nil {
+		return nil, fmt.Errorf("rpcreplay: invalid request: %w", err)
+	}
+	var answers []*message
+	for _, call := range calls {
+		if len(call.ID) == 0 {
+			// notifications get no answer
+			continue
+		}
+		answer := &message{Version: "2.0", ID: call.ID}
+		if rec := r.session.replay(r.name, call); rec != nil {
+			answer.Result, answer.Error = rec.Result, rec.Error
+		} else {
+			answer.Error, _ = json.Marshal(map[string]interface{}{
+				"code":    -32000,
+				"message": fmt.Sprintf("rpcreplay: no recorded response on %s for %s %s", r.name, call.Method, compact(call.Params)),
+			})
+		}
+		answers = append(answers, answer)
+	}
+
+	var out []byte
+	if batch {
+		out, err = json.Marshal(answers)
+	} else if len(answers) == 1 {
+		out, err = json.Marshal(answers[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(out)),
+		ContentLength: int64(len(out)),
+		Request:       req,
+	}, nil
+}
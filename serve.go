@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"try_rde/api"
+	"try_rde/metrics"
+	"try_rde/signer"
+	"try_rde/txutils"
+	"try_rde/withdrawal"
+)
+
+func runServe(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "address the HTTP API listens on")
+	readOnly := fs.Bool("read-only", false, "serve without a signer, submitting answers 503")
+	withMetrics := fs.Bool("metrics", true, "serve Prometheus metrics on /metrics")
+	stateDir := fs.String("state-dir", ".rde/withdrawals", "withdrawal progress dir of complete, exported as pending withdrawals")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *withMetrics {
+		env.metrics = metrics.New()
+	}
+	var s signer.Signer
+	if !*readOnly {
+		var err error
+		if s, err = env.signer(); err != nil {
+			return err
+		}
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	srv := api.NewServer(c, s)
+	srv.Log = txutils.NewStdLogger(nil)
+	handler := srv.Handler()
+	if env.metrics != nil {
+		store, err := withdrawal.NewFileStore(*stateDir)
+		if err != nil {
+			return err
+		}
+		col := &metrics.Collector{Metrics: env.metrics, Client: c, Store: store, Log: srv.Log}
+		if s != nil {
+			col.Signer = s.Address()
+		}
+		go col.Run(ctx)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", env.metrics.Handler())
+		mux.Handle("/", handler)
+		handler = mux
+	}
+	httpSrv := &http.Server{
+		Addr:              *listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- httpSrv.ListenAndServe() }()
+	fmt.Printf("%s serving on %s\n", time.Now().Format(time.RFC3339), *listen)
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := httpSrv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
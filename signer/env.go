@@ -0,0 +1,26 @@
+package signer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EnvPrivateKey is the default variable holding a raw hex key. Raw keys are
+// meant for devnets only, use a keystore or mnemonic anywhere else.
+const EnvPrivateKey = "RDE_PRIVATE_KEY"
+
+// FromEnv reads a hex private key from the environment variable name.
+func FromEnv(name string) (Signer, error) {
+	keyHex := strings.TrimSpace(os.Getenv(name))
+	if keyHex == "" {
+		return nil, fmt.Errorf("$%s is not set", name)
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(keyHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("$%s is not a valid private key: %w", name, err)
+	}
+	return NewPrivateKeySigner(key), nil
+}
@@ -0,0 +1,31 @@
+package signer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+// FromKeystore decrypts a go-ethereum encrypted keystore (V3) file.
+func FromKeystore(path, passphrase string) (Signer, error) {
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore %s: %w", path, err)
+	}
+	return NewPrivateKeySigner(key.PrivateKey), nil
+}
+
+// ReadPassphrase reads a passphrase file, dropping the trailing newline.
+func ReadPassphrase(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	return strings.TrimRight(string(raw), "\r\n"), nil
+}
@@ -0,0 +1,85 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultHDPath is the first account of the standard Ethereum BIP-44 path.
+const DefaultHDPath = "m/44'/60'/0'/0/0"
+
+// FromMnemonic derives the key at hdPath (BIP-44) from a BIP-39 mnemonic and
+// an optional BIP-39 passphrase.
+func FromMnemonic(mnemonic, passphrase, hdPath string) (Signer, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	if hdPath == "" {
+		hdPath = DefaultHDPath
+	}
+	path, err := accounts.ParseDerivationPath(hdPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(seed, path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPrivateKeySigner(key), nil
+}
+
+// deriveKey implements BIP-32 private child key derivation on secp256k1.
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	curveN := crypto.S256().Params().N
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := new(big.Int).SetBytes(sum[:32]), sum[32:]
+	if key.Sign() == 0 || key.Cmp(curveN) >= 0 {
+		return nil, errors.New("invalid master key")
+	}
+
+	for _, index := range path {
+		var data []byte
+		if index >= 0x80000000 {
+			// hardened child: 0x00 || ser256(k) || ser32(i)
+			data = append([]byte{0}, math.PaddedBigBytes(key, 32)...)
+		} else {
+			// normal child: serP(point(k)) || ser32(i)
+			parent, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&parent.PublicKey)
+		}
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac = hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum = mac.Sum(nil)
+		il := new(big.Int).SetBytes(sum[:32])
+		if il.Cmp(curveN) >= 0 {
+			return nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+		key = il.Add(il, key).Mod(il, curveN)
+		if key.Sign() == 0 {
+			return nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+		chainCode = sum[32:]
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
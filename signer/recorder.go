@@ -0,0 +1,58 @@
+package signer
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrNoKey is returned by a Recorder asked to sign a digest, it has no key.
+var ErrNoKey = errors.New("recorder has no key, sign offline")
+
+// RecordedTx is an unsigned tx caught by a Recorder with the chain it was
+// meant for.
+type RecordedTx struct {
+	Tx      *types.Transaction
+	ChainID *big.Int
+}
+
+// Recorder stands in for the Signer of an account whose key never touches a
+// networked machine. SignTx does not sign, it records the tx and returns it
+// unchanged, and TransactOpts of a Recorder do not send, so an operation run
+// with a Recorder leaves the txs it would have sent in Txs.
+type Recorder struct {
+	address common.Address
+
+	mu  sync.Mutex
+	txs []RecordedTx
+}
+
+// NewRecorder records the txs of address.
+func NewRecorder(address common.Address) *Recorder {
+	return &Recorder{address: address}
+}
+
+func (r *Recorder) Address() common.Address {
+	return r.address
+}
+
+func (r *Recorder) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.txs = append(r.txs, RecordedTx{Tx: tx, ChainID: new(big.Int).Set(chainID)})
+	return tx, nil
+}
+
+func (r *Recorder) SignHash(hash common.Hash) ([]byte, error) {
+	return nil, ErrNoKey
+}
+
+// Txs returns the recorded txs in the order they were built.
+func (r *Recorder) Txs() []RecordedTx {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedTx(nil), r.txs...)
+}
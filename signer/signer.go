@@ -0,0 +1,65 @@
+// Package signer abstracts where transaction keys come from. Every
+// transaction the tooling sends is signed through a Signer.
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Signer signs transactions and digests for a single account.
+type Signer interface {
+	Address() common.Address
+	// SignTx signs tx with the latest signer for chainID.
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	// SignHash signs a 32 byte digest and returns [R || S || V] with V 0 or 1.
+	SignHash(hash common.Hash) ([]byte, error)
+}
+
+// TransactOpts builds bind.TransactOpts that sign through s. The opts of a
+// Recorder build txs without sending them.
+func TransactOpts(ctx context.Context, s Signer, chainID *big.Int) *bind.TransactOpts {
+	from := s.Address()
+	_, record := s.(*Recorder)
+	return &bind.TransactOpts{
+		NoSend:  record,
+		From:    from,
+		Context: ctx,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, bind.ErrNotAuthorized
+			}
+			return s.SignTx(tx, chainID)
+		},
+	}
+}
+
+// keySigner holds the private key in memory. The keystore, mnemonic and env
+// signers all end up here once the key is unlocked.
+type keySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewPrivateKeySigner wraps an in-memory private key.
+func NewPrivateKeySigner(key *ecdsa.PrivateKey) Signer {
+	return &keySigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+func (s *keySigner) Address() common.Address {
+	return s.address
+}
+
+func (s *keySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+func (s *keySigner) SignHash(hash common.Hash) ([]byte, error) {
+	return crypto.Sign(hash[:], s.key)
+}
@@ -0,0 +1,78 @@
+package signer
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the well known devnet mnemonic
+const testMnemonic = "test test test test test test test test test test test junk"
+
+func Test_FromMnemonic(t *testing.T) {
+	ast := assert.New(t)
+	s, err := FromMnemonic(testMnemonic, "", "")
+	require.NoError(t, err)
+	ast.Equal(common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), s.Address())
+
+	s, err = FromMnemonic(testMnemonic, "", "m/44'/60'/0'/0/1")
+	require.NoError(t, err)
+	ast.Equal(common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), s.Address())
+
+	_, err = FromMnemonic("test test test", "", "")
+	ast.Error(err)
+	_, err = FromMnemonic(testMnemonic, "", "m/bad")
+	ast.Error(err)
+}
+
+func Test_FromKeystore(t *testing.T) {
+	ast := assert.New(t)
+	account, err := keystore.StoreKey(t.TempDir(), "secret", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	path := account.URL.Path
+
+	s, err := FromKeystore(path, "secret")
+	require.NoError(t, err)
+	ast.Equal(account.Address, s.Address())
+
+	_, err = FromKeystore(path, "wrong")
+	ast.Error(err)
+}
+
+func Test_SignTx(t *testing.T) {
+	ast := assert.New(t)
+	t.Setenv(EnvPrivateKey, "0x59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d")
+	s, err := FromEnv(EnvPrivateKey)
+	require.NoError(t, err)
+
+	chainID := big.NewInt(900)
+	to := common.HexToAddress("0x01")
+	tx := types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 1, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 21000, To: &to})
+
+	opts := TransactOpts(nil, s, chainID)
+	signed, err := opts.Signer(s.Address(), tx)
+	require.NoError(t, err)
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	require.NoError(t, err)
+	ast.Equal(s.Address(), sender)
+
+	_, err = opts.Signer(to, tx)
+	ast.Error(err)
+
+	hash := crypto.Keccak256Hash([]byte("digest"))
+	sig, err := s.SignHash(hash)
+	require.NoError(t, err)
+	pub, err := crypto.SigToPub(hash[:], sig)
+	require.NoError(t, err)
+	ast.Equal(s.Address(), crypto.PubkeyToAddress(*pub))
+
+	t.Setenv(EnvPrivateKey, "")
+	_, err = FromEnv(EnvPrivateKey)
+	ast.Error(err)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"try_rde/bridge"
+	"try_rde/txutils"
+)
+
+func runTrack(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("track", flag.ContinueOnError)
+	txHex := fs.String("tx", "", "L1 deposit tx hash")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	txHash, err := parseTxHash(*txHex)
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+	return env.followDeposits(ctx, c, txHash)
+}
+
+// followDeposits waits for every deposit of the L1 tx to run on L2 and prints
+// the L2 receipt and bridge events.
+func (e *cliEnv) followDeposits(ctx context.Context, c *bridge.Client, l1TxHash common.Hash) error {
+	deposits, err := c.Deposits(ctx, l1TxHash)
+	if err != nil {
+		return err
+	}
+	opts := txutils.WaitOpts{
+		Confirmations: e.confirmations,
+		Timeout:       e.receiptTimeout,
+		Log:           txutils.NewStdLogger(nil),
+	}
+	for _, d := range deposits {
+		fmt.Printf("l2 deposit tx hash is %s\n", d.L2TxHash.Hex())
+		err := c.WaitForDeposit(ctx, d, opts)
+		var reverted *txutils.RevertedError
+		if errors.As(err, &reverted) {
+			return fmt.Errorf("deposit ran on L2 but %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deposit executed in l2 block %d\n", d.L2Receipt.BlockNumber)
+		if ev := d.ETHBridgeFinalized; ev != nil {
+			fmt.Printf("ETHBridgeFinalized from %s to %s amount %s\n", ev.From.Hex(), ev.To.Hex(), ev.Amount)
+		}
+		if ev := d.MNTBridgeFinalized; ev != nil {
+			fmt.Printf("MNTBridgeFinalized from %s to %s amount %s\n", ev.From.Hex(), ev.To.Hex(), ev.Amount)
+		}
+		if ev := d.DepositFinalized; ev != nil {
+			fmt.Printf("DepositFinalized l1 token %s l2 token %s from %s to %s amount %s\n",
+				ev.L1Token.Hex(), ev.L2Token.Hex(), ev.From.Hex(), ev.To.Hex(), ev.Amount)
+		}
+	}
+	return nil
+}
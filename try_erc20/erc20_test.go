@@ -7,7 +7,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -16,25 +15,35 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient/gethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"try_rde/abistr/abijson"
+	"try_rde/config"
+	"try_rde/signer"
 	"try_rde/try_erc20/contract"
 	"try_rde/txutils"
+	"try_rde/txutils/txtest"
 )
 
 var (
-	OtimismMintableERC20FactoryAddr = common.HexToAddress("0x4200000000000000000000000000000000000012")
-	L1URL                           = "http://localhost:8545"
-	L2URL                           = "http://localhost:9545"
-	account20SK                     = "dd888cfabd6d3c3eeb683063657706fb660416ec4972bb5761204e0dbf59e33c"
-	priv, _                         = crypto.HexToECDSA(account20SK)
-	wwqTokenAddrL1                  = common.HexToAddress("0xfaeEBf311d135C7918430542e612b77033c4CA14")
-	wwqTokenAddrL2                  = common.HexToAddress("0x7c6b91D9Be155A6Db01f749217d76fF02A7227F2")
+	profile                         = config.MustFromEnv()
+	OtimismMintableERC20FactoryAddr = profile.Addresses.OptimismMintableERC20Factory
+	L1URL                           = profile.L1.RPC
+	L2URL                           = profile.L2.RPC
+	wwqTokenAddrL1                  = profile.Tokens["WWQT"].L1
+	wwqTokenAddrL2                  = profile.Tokens["WWQT"].L2
 	StandardL2TokenCreatedTopic     = crypto.Keccak256Hash([]byte("StandardL2TokenCreated(address,address)"))
 
-	l1BridgeAddr = common.HexToAddress("0xDc64a140Aa3E981100a9becA4E685f962f0cF6C9") //proxy addr
-	l2BridgeAddr = common.HexToAddress("0x4200000000000000000000000000000000000010")
+	l1BridgeAddr = profile.Addresses.L1StandardBridge //proxy addr
+	l2BridgeAddr = profile.Addresses.L2StandardBridge
 )
 
+// testSigner loads the sender from $RDE_PRIVATE_KEY.
+func testSigner(t *testing.T) signer.Signer {
+	s, err := signer.FromEnv(signer.EnvPrivateKey)
+	require.NoError(t, err)
+	return s
+}
+
 func Test_deployContractToL2(t *testing.T) {
 	ast := assert.New(t)
 	//l1cli, err := ethclient.Dial(L1URL)
@@ -49,17 +58,14 @@ func Test_deployContractToL2(t *testing.T) {
 	chainID, err := l2cli.ChainID(context.Background())
 	ast.NoError(err)
 
-	opt, err := bind.NewKeyedTransactorWithChainID(priv, chainID)
-	ast.NoError(err)
+	opt := signer.TransactOpts(context.Background(), testSigner(t), chainID)
 	tx, err := erc20Factory.CreateStandardL2Token(opt, wwqTokenAddrL1, "wwqToken", "WWQT")
 	ast.NoError(err)
 	if err == nil {
 		t.Logf("L2 create contract tx hash is %s\n", tx.Hash().Hex()) // 0x95957a45ac14205053b3ae633be11355ef20843d038b6696c9d3a8c2899e7cf8
 	}
 
-	time.Sleep(3 * time.Second)
-
-	receipt, err := l2cli.TransactionReceipt(context.Background(), tx.Hash())
+	receipt, err := txutils.WaitForReceipt(context.Background(), l2cli, tx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
 	ast.NoError(err)
 	ast.Equal(types.ReceiptStatusSuccessful, receipt.Status, "receipt status should be 0x01")
 
@@ -107,9 +113,8 @@ func Test_approveForDepositWWQT(t *testing.T) {
 	t.Log("========================== approve =========================")
 	chainID, err := l1cli.ChainID(context.Background())
 	ast.NoError(err)
-	opt, err := bind.NewKeyedTransactorWithChainID(priv, chainID)
-	ast.NoError(err)
-	//tx, err := l1WWQTContract.Approve(opt, crypto.PubkeyToAddress(priv.PublicKey), big.NewInt(1000000))
+	opt := signer.TransactOpts(context.Background(), testSigner(t), chainID)
+	//tx, err := l1WWQTContract.Approve(opt, opt.From, big.NewInt(1000000))
 	tx, err := l1WWQTContract.Approve(opt, l1BridgeAddr, big.NewInt(1000000))
 	ast.NoError(err)
 	if err == nil {
@@ -117,8 +122,7 @@ func Test_approveForDepositWWQT(t *testing.T) {
 	}
 
 	// approve receipt
-	time.Sleep(10 * time.Second)
-	receipt, err := l1cli.TransactionReceipt(context.Background(), tx.Hash())
+	receipt, err := txutils.WaitForReceipt(context.Background(), l1cli, tx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
 	ast.NoError(err)
 	ast.Equal(types.ReceiptStatusSuccessful, receipt.Status, "receipt status should be 0x01")
 }
@@ -139,8 +143,7 @@ func Test_depositWWQT(t *testing.T) {
 	t.Log("========================== deposit =========================")
 	chainID, err := l1cli.ChainID(context.Background())
 	ast.NoError(err)
-	opt, err := bind.NewKeyedTransactorWithChainID(priv, chainID)
-	ast.NoError(err)
+	opt := signer.TransactOpts(context.Background(), testSigner(t), chainID)
 	amount, minGasLimit := big.NewInt(10000), uint32(200000)
 	tx, err := l1Bridge.DepositERC20(opt, wwqTokenAddrL1, wwqTokenAddrL2, amount, minGasLimit, []byte{})
 	ast.NoError(err)
@@ -149,8 +152,7 @@ func Test_depositWWQT(t *testing.T) {
 	}
 
 	// deposit receipt
-	time.Sleep(5 * time.Second)
-	receipt, err := l1cli.TransactionReceipt(context.Background(), tx.Hash())
+	receipt, err := txutils.WaitForReceipt(context.Background(), l1cli, tx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
 	ast.NoError(err)
 	ast.Equal(types.ReceiptStatusSuccessful, receipt.Status, "receipt status should be 0x01")
 }
@@ -171,9 +173,8 @@ func Test_approveAndDepositWWQT(t *testing.T) {
 	t.Log("========================== approve =========================")
 	chainID, err := l1cli.ChainID(context.Background())
 	ast.NoError(err)
-	opt, err := bind.NewKeyedTransactorWithChainID(priv, chainID)
-	ast.NoError(err)
-	//tx, err := l1WWQTContract.Approve(opt, crypto.PubkeyToAddress(priv.PublicKey), big.NewInt(1000000))
+	opt := signer.TransactOpts(context.Background(), testSigner(t), chainID)
+	//tx, err := l1WWQTContract.Approve(opt, opt.From, big.NewInt(1000000))
 	tx, err := l1WWQTContract.Approve(opt, l1BridgeAddr, big.NewInt(1000000))
 	ast.NoError(err)
 	if err == nil {
@@ -181,8 +182,7 @@ func Test_approveAndDepositWWQT(t *testing.T) {
 	}
 
 	// approve receipt
-	time.Sleep(10 * time.Second)
-	receipt, err := l1cli.TransactionReceipt(context.Background(), tx.Hash())
+	receipt, err := txutils.WaitForReceipt(context.Background(), l1cli, tx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
 	ast.NoError(err)
 	ast.Equal(types.ReceiptStatusSuccessful, receipt.Status, "receipt status should be 0x01")
 
@@ -196,8 +196,7 @@ func Test_approveAndDepositWWQT(t *testing.T) {
 	}
 
 	// deposit receipt
-	time.Sleep(5 * time.Second)
-	receipt, err = l1cli.TransactionReceipt(context.Background(), tx.Hash())
+	receipt, err = txutils.WaitForReceipt(context.Background(), l1cli, tx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
 	ast.NoError(err)
 	ast.Equal(types.ReceiptStatusSuccessful, receipt.Status, "receipt status should be 0x01")
 }
@@ -215,8 +214,7 @@ func Test_approveForWithdrawWWQT(t *testing.T) {
 	t.Log("========================== approve =========================")
 	chainID, err := l2cli.ChainID(context.Background())
 	ast.NoError(err)
-	opt, err := bind.NewKeyedTransactorWithChainID(priv, chainID)
-	ast.NoError(err)
+	opt := signer.TransactOpts(context.Background(), testSigner(t), chainID)
 	tx, err := l2WWQTContract.Approve(opt, l2BridgeAddr, big.NewInt(1000000))
 	ast.NoError(err)
 	if err == nil {
@@ -224,8 +222,7 @@ func Test_approveForWithdrawWWQT(t *testing.T) {
 	}
 
 	// approve receipt
-	time.Sleep(10 * time.Second)
-	receipt, err := l2cli.TransactionReceipt(context.Background(), tx.Hash())
+	receipt, err := txutils.WaitForReceipt(context.Background(), l2cli, tx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
 	ast.NoError(err)
 	ast.Equal(types.ReceiptStatusSuccessful, receipt.Status, "receipt status should be 0x01")
 }
@@ -246,8 +243,7 @@ func Test_withdrawWWQT(t *testing.T) {
 	t.Log("========================== withdraw =========================")
 	chainID, err := l2cli.ChainID(context.Background())
 	ast.NoError(err)
-	opt, err := bind.NewKeyedTransactorWithChainID(priv, chainID)
-	ast.NoError(err)
+	opt := signer.TransactOpts(context.Background(), testSigner(t), chainID)
 	ast.NotNil(opt)
 
 	amount, minGasLimit := big.NewInt(10000), uint32(200000)
@@ -258,8 +254,7 @@ func Test_withdrawWWQT(t *testing.T) {
 	}
 
 	// receipt
-	time.Sleep(5 * time.Second)
-	receipt, err := l2cli.TransactionReceipt(context.Background(), tx.Hash())
+	receipt, err := txutils.WaitForReceipt(context.Background(), l2cli, tx.Hash(), txutils.WaitOpts{Timeout: time.Minute})
 	ast.NoError(err)
 	ast.Equal(types.ReceiptStatusSuccessful, receipt.Status, "receipt status should be 0x1")
 
@@ -273,20 +268,18 @@ func Test_provenAndFinalize(t *testing.T) {
 	ast.NoError(err)
 
 	var (
-		L2OutputOracleProxy     = "0x5FC8d32690cc91D4c39d9d3abcBD16989F875707"
-		L1OptimismPortal        = "0xa513E6E4b8f2a923D98304ec87F64353C4D5C853"
+		L2OutputOracleProxyAddr = profile.Addresses.L2OutputOracleProxy
+		L1OptimismPortalAddr    = profile.Addresses.L1OptimismPortal
+		l2ToL1MessagePasserAddr = profile.Addresses.L2ToL1MessagePasser
 		wdTx                    = "0xb224e220e11eecb16da98a3540ffb2969a849c80a2b311fdc8351c7adb0a25a3"
-		l2ToL1MessagePasserAddr = "0x4200000000000000000000000000000000000016"
-		L1OptimismPortalAddr    = "0xa513E6E4b8f2a923D98304ec87F64353C4D5C853"
 	)
 
 	withdrawReceipt, err := l2cli.TransactionReceipt(context.Background(), common.HexToHash(wdTx))
 	ast.NoError(err)
 
 	// prepare withdraw tx
-	L2OutputOracleProxyAddr := common.HexToAddress(L2OutputOracleProxy)
 	L2OutputOracleProxyContract, err := abijson.NewL2OutputOracleProxy(L2OutputOracleProxyAddr, l1cli)
-	l2ToL1MessagePasserContract, err := abijson.NewL2ToL1MessagePasser(common.HexToAddress(l2ToL1MessagePasserAddr), l2cli)
+	l2ToL1MessagePasserContract, err := abijson.NewL2ToL1MessagePasser(l2ToL1MessagePasserAddr, l2cli)
 	ast.NoError(err)
 
 	l2RpcClient, err := rpc.DialContext(context.Background(), L2URL)
@@ -306,14 +299,14 @@ func Test_provenAndFinalize(t *testing.T) {
 	if err == nil {
 		t.Logf("finalizationPeriod is %d\n", finalizationPeriod)
 	}
-	blkNum, err := txutils.WaitForFinalizationPeriod(context.Background(), t, l1cli, common.HexToAddress(L1OptimismPortal), withdrawReceipt.BlockNumber, finalizationPeriod)
+	blkNum, err := txtest.WaitForFinalizationPeriod(context.Background(), t, l1cli, L1OptimismPortalAddr, withdrawReceipt.BlockNumber, finalizationPeriod)
 	ast.NoError(err)
 	header, err := receiptCli.HeaderByNumber(context.Background(), big.NewInt(int64(blkNum)))
 	ast.NoError(err)
 	ast.NotNil(header)
 	t.Logf("blkNum is %d, header.Num is %d\n", blkNum, header.Number)
 
-	params, err := txutils.ProveWithdrawalParameters(context.Background(), t, l2GethCli, receiptCli, common.HexToHash(wdTx), header, &L2OutputOracleProxyContract.L2OutputOracleProxyCaller, l2ToL1MessagePasserContract)
+	params, err := txtest.ProveWithdrawalParameters(context.Background(), t, l2GethCli, receiptCli, l2ToL1MessagePasserAddr, common.HexToHash(wdTx), header, &L2OutputOracleProxyContract.L2OutputOracleProxyCaller, l2ToL1MessagePasserContract)
 	ast.NoError(err)
 
 	// // prepare withdraw transaction
@@ -330,7 +323,7 @@ func Test_provenAndFinalize(t *testing.T) {
 
 	// prepare proven
 	t.Log("================= prepare proven =================")
-	opPortal, err := abijson.NewL1OptimismPortal(common.HexToAddress(L1OptimismPortalAddr), l1cli)
+	opPortal, err := abijson.NewL1OptimismPortal(L1OptimismPortalAddr, l1cli)
 	ast.NoError(err)
 
 	t.Logf("wd %+v\n", wd)
@@ -338,14 +331,14 @@ func Test_provenAndFinalize(t *testing.T) {
 	slot, err := mytypeWd.StorageSlot()
 	ast.NoError(err)
 
-	proof, err := l2GethCli.GetProof(context.Background(), predeploys.L2ToL1MessagePasserAddr, []string{slot.String()}, big.NewInt(int64(blkNum)))
+	proof, err := l2GethCli.GetProof(context.Background(), l2ToL1MessagePasserAddr, []string{slot.String()}, big.NewInt(int64(blkNum)))
 	ast.NoError(err)
 	ast.NotNil(proof)
 	t.Logf("proof.storage.len is %d", len(proof.StorageProof))
 
 	var abiTrue = common.Hash{31: 0x01}
 
-	storageValue, err := l2cli.StorageAt(context.Background(), predeploys.L2ToL1MessagePasserAddr, slot, big.NewInt(int64(blkNum)))
+	storageValue, err := l2cli.StorageAt(context.Background(), l2ToL1MessagePasserAddr, slot, big.NewInt(int64(blkNum)))
 	ast.NoError(err)
 	if err == nil {
 		t.Logf("L2ToL1MessagePasser status value %s\n", common.Bytes2Hex(storageValue))
@@ -375,8 +368,7 @@ func Test_provenAndFinalize(t *testing.T) {
 		// proven
 		t.Log("================= proven =================")
 		ast.NoError(err)
-		proveOpt, err := bind.NewKeyedTransactorWithChainID(priv, l1ChainID)
-		ast.NoError(err)
+		proveOpt := signer.TransactOpts(context.Background(), testSigner(t), l1ChainID)
 		ast.NotNil(proveOpt)
 		proveTx, err := opPortal.ProveWithdrawalTransaction(
 			proveOpt,
@@ -408,8 +400,7 @@ func Test_provenAndFinalize(t *testing.T) {
 
 	// opPortal
 	t.Log("================= finalize optimismPortal =================")
-	finOpt, err := bind.NewKeyedTransactorWithChainID(priv, l1ChainID)
-	ast.NoError(err)
+	finOpt := signer.TransactOpts(context.Background(), testSigner(t), l1ChainID)
 	fTx, err := opPortal.FinalizeWithdrawalTransaction(finOpt, wd)
 	ast.NoError(err)
 	ast.NotNil(fTx)
@@ -1,13 +1,20 @@
 package txutils
 
 import (
-	"testing"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/abistr/abijson"
 )
 
-func L2ETHApprove(t *testing.T) {
-	//WETH9Addr := "0xdEAddEaDdeadDEadDEADDEAddEADDEAddead1111" //"0x4200000000000000000000000000000000000006"
-	//l2ETHInst, err := abijson.NewL2TestToken(common.HexToAddress(WETH9Addr), client)
-	//assert.NoError(t, err)
-	//
-	//l2ETHInst.Approve()
+// L2ETHApprove approves spender (usually the L2StandardBridge) to move amount
+// of BVM_ETH on L2.
+func L2ETHApprove(opts *bind.TransactOpts, client bind.ContractBackend, l2ETH common.Address, spender common.Address, amount *big.Int) (*types.Transaction, error) {
+	l2ETHInst, err := abijson.NewL2TestToken(l2ETH, client)
+	if err != nil {
+		return nil, err
+	}
+	return l2ETHInst.Approve(opts, spender, amount)
 }
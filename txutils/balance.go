@@ -1,14 +1,12 @@
 package txutils
 
 import (
+	"context"
+	"fmt"
 	"math/big"
-	"testing"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
 	"try_rde/abistr/abijson"
 )
 
@@ -36,21 +34,15 @@ import (
 //	return balance
 //}
 
-func GetETHBalanceFromL2(t *testing.T, address string) *big.Int {
-	client, err := ethclient.Dial("http://localhost:9545")
-	require.NoError(t, err)
-	require.NotNil(t, client)
-	WETH9Addr := "0xdEAddEaDdeadDEadDEADDEAddEADDEAddead1111" //"0x4200000000000000000000000000000000000006"
-
-	l2ETHInst, err := abijson.NewL2TestToken(common.HexToAddress(WETH9Addr), client)
-	assert.NoError(t, err)
-
-	bal, err := l2ETHInst.BalanceOf(&bind.CallOpts{}, common.HexToAddress(address))
-	assert.NoError(t, err)
-
-	if err == nil {
-		//t.Logf("getETHBalanceFromL2 balance is %d\n", bal)
-		return bal
+// GetETHBalanceFromL2 returns the BVM_ETH balance of address on L2.
+func GetETHBalanceFromL2(ctx context.Context, client bind.ContractCaller, l2ETH common.Address, address common.Address) (*big.Int, error) {
+	l2ETHInst, err := abijson.NewL2TestTokenCaller(l2ETH, client)
+	if err != nil {
+		return nil, err
+	}
+	bal, err := l2ETHInst.BalanceOf(&bind.CallOpts{Context: ctx}, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get l2 eth balance: %w", err)
 	}
-	return nil
+	return bal, nil
 }
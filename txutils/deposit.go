@@ -0,0 +1,107 @@
+package txutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"try_rde/abistr/abijson"
+)
+
+// DepositTxType is the EIP-2718 type of L2 deposit transactions.
+const DepositTxType = 0x7e
+
+// DepositVersion0 is the only opaqueData layout the portal emits:
+// mint | value | ethValue | gasLimit | isCreation | data.
+const DepositVersion0 = 0
+
+// userDepositSourceDomain tags the source hash of deposits made by users
+// through the portal, as opposed to L1 info deposits.
+const userDepositSourceDomain = 0
+
+// ErrUnknownDepositVersion is returned for TransactionDeposited events with a
+// version this package cannot decode.
+var ErrUnknownDepositVersion = errors.New("unknown deposit version")
+
+// DepositTx is the L2 transaction the sequencer derives from a
+// TransactionDeposited event. The fields and their order follow the Mantle
+// deposit transaction, which adds EthValue for BVM_ETH minted on L2.
+type DepositTx struct {
+	SourceHash common.Hash
+	From       common.Address
+	// To is nil for contract creations.
+	To *common.Address `rlp:"nil"`
+	// Mint is the MNT minted on L2, nil if none.
+	Mint  *big.Int `rlp:"nil"`
+	Value *big.Int
+	Gas   uint64
+	// IsSystemTransaction is always false for user deposits.
+	IsSystemTransaction bool
+	// EthValue is the BVM_ETH minted on L2, nil if none.
+	EthValue *big.Int `rlp:"nil"`
+	Data     []byte
+}
+
+// Hash returns the L2 tx hash of the deposit, keccak256(0x7e || rlp(tx)).
+func (d *DepositTx) Hash() common.Hash {
+	enc, err := rlp.EncodeToBytes(d)
+	if err != nil {
+		// every field is rlp encodable, this cannot happen
+		panic(err)
+	}
+	return crypto.Keccak256Hash(append([]byte{DepositTxType}, enc...))
+}
+
+// DepositSourceHash identifies a user deposit by the L1 log that created it.
+// logIndex is the index of the log in its block, not in its receipt.
+func DepositSourceHash(l1BlockHash common.Hash, logIndex uint) common.Hash {
+	var input [64]byte
+	copy(input[:32], l1BlockHash[:])
+	binary.BigEndian.PutUint64(input[56:], uint64(logIndex))
+	depositID := crypto.Keccak256(input[:])
+
+	var domain [32]byte
+	binary.BigEndian.PutUint64(domain[24:], userDepositSourceDomain)
+	return crypto.Keccak256Hash(domain[:], depositID)
+}
+
+// UnmarshalDepositEvent derives the L2 deposit tx from a TransactionDeposited
+// event. The event must carry its raw log, as parsed by the portal binding.
+func UnmarshalDepositEvent(ev *abijson.L1OptimismPortalTransactionDeposited) (*DepositTx, error) {
+	if ev.Version == nil || !ev.Version.IsUint64() || ev.Version.Uint64() != DepositVersion0 {
+		return nil, fmt.Errorf("%w %v", ErrUnknownDepositVersion, ev.Version)
+	}
+	data := ev.OpaqueData
+	// 3 uint256 amounts, uint64 gas limit and the creation flag
+	const fixedLen = 32 + 32 + 32 + 8 + 1
+	if len(data) < fixedLen {
+		return nil, fmt.Errorf("deposit opaque data too short: %d bytes", len(data))
+	}
+
+	dep := &DepositTx{
+		SourceHash: DepositSourceHash(ev.Raw.BlockHash, ev.Raw.Index),
+		From:       ev.From,
+		Value:      new(big.Int).SetBytes(data[32:64]),
+		Gas:        binary.BigEndian.Uint64(data[96:104]),
+		Data:       common.CopyBytes(data[fixedLen:]),
+	}
+	if mint := new(big.Int).SetBytes(data[:32]); mint.Sign() != 0 {
+		dep.Mint = mint
+	}
+	if ethValue := new(big.Int).SetBytes(data[64:96]); ethValue.Sign() != 0 {
+		dep.EthValue = ethValue
+	}
+	switch data[104] {
+	case 0:
+		to := ev.To
+		dep.To = &to
+	case 1:
+	default:
+		return nil, fmt.Errorf("invalid deposit creation flag %d", data[104])
+	}
+	return dep, nil
+}
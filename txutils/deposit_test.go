@@ -0,0 +1,81 @@
+package txutils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/abistr/abijson"
+)
+
+func opaqueData(mint, value, ethValue int64, gas uint64, isCreation bool, data []byte) []byte {
+	var out []byte
+	out = append(out, common.LeftPadBytes(big.NewInt(mint).Bytes(), 32)...)
+	out = append(out, common.LeftPadBytes(big.NewInt(value).Bytes(), 32)...)
+	out = append(out, common.LeftPadBytes(big.NewInt(ethValue).Bytes(), 32)...)
+	out = append(out, new(big.Int).SetUint64(gas).FillBytes(make([]byte, 8))...)
+	if isCreation {
+		out = append(out, 1)
+	} else {
+		out = append(out, 0)
+	}
+	return append(out, data...)
+}
+
+func Test_UnmarshalDepositEvent(t *testing.T) {
+	ast := assert.New(t)
+	blockHash := common.HexToHash("0xabcd")
+	from, to := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	ev := &abijson.L1OptimismPortalTransactionDeposited{
+		From:       from,
+		To:         to,
+		Version:    big.NewInt(0),
+		OpaqueData: opaqueData(0, 5, 7, 200000, false, []byte{0xca, 0xfe}),
+		Raw:        types.Log{BlockHash: blockHash, Index: 3},
+	}
+	dep, err := UnmarshalDepositEvent(ev)
+	require.NoError(t, err)
+	ast.Equal(from, dep.From)
+	ast.Equal(&to, dep.To)
+	ast.Nil(dep.Mint, "zero mint is left out")
+	ast.Equal(big.NewInt(5), dep.Value)
+	ast.Equal(big.NewInt(7), dep.EthValue)
+	ast.Equal(uint64(200000), dep.Gas)
+	ast.Equal([]byte{0xca, 0xfe}, dep.Data)
+	ast.False(dep.IsSystemTransaction)
+
+	depositID := crypto.Keccak256(blockHash[:], common.LeftPadBytes([]byte{3}, 32))
+	ast.Equal(crypto.Keccak256Hash(make([]byte, 32), depositID), dep.SourceHash)
+
+	enc, err := rlp.EncodeToBytes([]interface{}{
+		dep.SourceHash, from, to, []byte{}, big.NewInt(5), uint64(200000), false, big.NewInt(7), []byte{0xca, 0xfe},
+	})
+	require.NoError(t, err)
+	ast.Equal(crypto.Keccak256Hash(append([]byte{0x7e}, enc...)), dep.Hash())
+
+	// a different log is a different deposit
+	ev.Raw.Index = 4
+	other, err := UnmarshalDepositEvent(ev)
+	require.NoError(t, err)
+	ast.NotEqual(dep.Hash(), other.Hash())
+
+	ev.OpaqueData = opaqueData(1, 0, 0, 21000, true, nil)
+	dep, err = UnmarshalDepositEvent(ev)
+	require.NoError(t, err)
+	ast.Nil(dep.To)
+	ast.Equal(big.NewInt(1), dep.Mint)
+	ast.Nil(dep.EthValue)
+
+	ev.OpaqueData = ev.OpaqueData[:50]
+	_, err = UnmarshalDepositEvent(ev)
+	ast.Error(err)
+
+	ev.Version = big.NewInt(1)
+	_, err = UnmarshalDepositEvent(ev)
+	ast.ErrorIs(err, ErrUnknownDepositVersion)
+}
@@ -0,0 +1,171 @@
+package txutils
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"try_rde/abistr/abijson"
+)
+
+// FinalizationOpts tunes WaitForFinalization. The zero value reads the
+// finalization period from the oracle and polls every 12 seconds.
+type FinalizationOpts struct {
+	// FinalizationPeriod overrides FINALIZATION_PERIOD_SECONDS of the oracle.
+	FinalizationPeriod *big.Int
+	// PollInterval is how often the L1 head is checked, and the oracle too
+	// while OutputProposed can not be subscribed to.
+	PollInterval time.Duration
+	// OnProgress is called after every check.
+	OnProgress func(p FinalizationProgress)
+	// Log reports subscription failures and deleted outputs, defaults to
+	// NopLogger.
+	Log Logger
+}
+
+// FinalizationProgress is what WaitForFinalization knows so far. All times
+// are L1 block timestamps.
+type FinalizationProgress struct {
+	L2BlockNumber     uint64 // the L2 block waited for
+	LatestOutputBlock uint64 // L2OutputOracle.latestBlockNumber()
+	// OutputIndex and Output are the first output covering L2BlockNumber,
+	// nil until it is proposed.
+	OutputIndex *big.Int
+	Output      *abijson.TypesOutputProposal
+	// FinalizableAt is the first L1 timestamp at which the output is past
+	// its finalization period, 0 without output.
+	FinalizableAt uint64
+	L1Time        uint64 // timestamp of the latest L1 block
+	Done          bool
+}
+
+// WaitForFinalization waits until an output covering l2BlockNumber is
+// proposed to the oracle at oracleAddr and its finalization period has passed
+// by the time of the latest L1 block. New outputs are noticed through a
+// subscription to OutputProposed, polling the oracle if the backend can't
+// subscribe. An output deleted while waiting sends the wait back to the next
+// proposal.
+//
+// Failing checks are logged and retried, except the first one so a wrong
+// address or endpoint fails fast. The wait ends with ctx.
+func WaitForFinalization(ctx context.Context, backend bind.ContractBackend, oracleAddr common.Address, l2BlockNumber *big.Int, opts FinalizationOpts) (*FinalizationProgress, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 12 * time.Second
+	}
+	if opts.Log == nil {
+		opts.Log = NopLogger
+	}
+	oracle, err := abijson.NewL2OutputOracleProxy(oracleAddr, backend)
+	if err != nil {
+		return nil, err
+	}
+	period := opts.FinalizationPeriod
+	if period == nil {
+		if period, err = oracle.FINALIZATIONPERIODSECONDS(&bind.CallOpts{Context: ctx}); err != nil {
+			return nil, fmt.Errorf("failed to get finalization period: %w", err)
+		}
+	}
+
+	// subscribe before the first check so no proposal falls in between
+	proposed := make(chan *abijson.L2OutputOracleProxyOutputProposed, 16)
+	var subErr <-chan error
+	sub, err := oracle.WatchOutputProposed(&bind.WatchOpts{Context: ctx}, proposed, nil, nil, nil)
+	if err != nil {
+		opts.Log.Logf("can not subscribe to OutputProposed, polling the oracle instead: %v", err)
+	} else {
+		defer sub.Unsubscribe()
+		subErr = sub.Err()
+	}
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	p := &FinalizationProgress{L2BlockNumber: l2BlockNumber.Uint64()}
+	for first := true; ; first = false {
+		err := checkFinalization(ctx, backend, oracle, period, l2BlockNumber, p, opts.Log)
+		switch {
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case err != nil && first:
+			return nil, err
+		case err != nil:
+			opts.Log.Logf("finalization check failed, retrying: %v", err)
+		default:
+			if opts.OnProgress != nil {
+				opts.OnProgress(*p)
+			}
+			if p.Done {
+				return p, nil
+			}
+		}
+
+		// with a live subscription the oracle only needs to be read when
+		// something was proposed, the L1 head is polled once there is an
+		// output
+		var tick <-chan time.Time
+		if subErr == nil || p.Output != nil || err != nil {
+			tick = ticker.C
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-proposed:
+		case err := <-subErr:
+			opts.Log.Logf("OutputProposed subscription failed, polling the oracle instead: %v", err)
+			subErr = nil
+		case <-tick:
+		}
+	}
+}
+
+// checkFinalization updates p from the oracle and the L1 head.
+func checkFinalization(ctx context.Context, backend bind.ContractBackend, oracle *abijson.L2OutputOracleProxy, period, l2BlockNumber *big.Int, p *FinalizationProgress, log Logger) error {
+	opts := &bind.CallOpts{Context: ctx}
+	latest, err := oracle.LatestBlockNumber(opts)
+	if err != nil {
+		return fmt.Errorf("failed to get latest output block: %w", err)
+	}
+	p.LatestOutputBlock = latest.Uint64()
+
+	if p.Output != nil {
+		next, err := oracle.NextOutputIndex(opts)
+		if err != nil {
+			return fmt.Errorf("failed to get next output index: %w", err)
+		}
+		gone := p.OutputIndex.Cmp(next) >= 0
+		if !gone {
+			output, err := oracle.GetL2Output(opts, p.OutputIndex)
+			if err != nil {
+				return fmt.Errorf("failed to get l2 output %d: %w", p.OutputIndex, err)
+			}
+			gone = output.OutputRoot != p.Output.OutputRoot
+		}
+		if gone {
+			log.Logf("output %d was deleted, waiting for a new one", p.OutputIndex)
+			p.OutputIndex, p.Output, p.FinalizableAt = nil, nil, 0
+		}
+	}
+	if p.Output == nil && latest.Cmp(l2BlockNumber) >= 0 {
+		index, err := oracle.GetL2OutputIndexAfter(opts, l2BlockNumber)
+		if err != nil {
+			return fmt.Errorf("failed to get l2OutputIndex: %w", err)
+		}
+		output, err := oracle.GetL2Output(opts, index)
+		if err != nil {
+			return fmt.Errorf("failed to get l2 output %d: %w", index, err)
+		}
+		p.OutputIndex, p.Output = index, &output
+		// the portal requires block.timestamp > output timestamp + period
+		p.FinalizableAt = output.Timestamp.Uint64() + period.Uint64() + 1
+	}
+
+	head, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get l1 head: %w", err)
+	}
+	p.L1Time = head.Time
+	p.Done = p.Output != nil && p.L1Time >= p.FinalizableAt
+	return nil
+}
@@ -0,0 +1,123 @@
+package txutils_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/txutils"
+)
+
+// pollingBackend can't subscribe, like a plain http endpoint.
+type pollingBackend struct {
+	bridge.Backend
+}
+
+func (pollingBackend) SubscribeFilterLogs(context.Context, ethereum.FilterQuery, chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("notifications not supported")
+}
+
+type finalizationWait struct {
+	t        *testing.T
+	progress chan txutils.FinalizationProgress
+	done     chan error
+	cancel   context.CancelFunc
+}
+
+func startFinalizationWait(t *testing.T, backend bridge.Backend, n *bridgetest.Network, l2Block *big.Int, poll time.Duration) *finalizationWait {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	w := &finalizationWait{t: t, progress: make(chan txutils.FinalizationProgress, 1000), done: make(chan error, 1), cancel: cancel}
+	go func() {
+		_, err := txutils.WaitForFinalization(ctx, backend, n.Profile.Addresses.L2OutputOracleProxy, l2Block, txutils.FinalizationOpts{
+			PollInterval: poll,
+			OnProgress:   func(p txutils.FinalizationProgress) { w.progress <- p },
+			Log:          t,
+		})
+		w.done <- err
+	}()
+	return w
+}
+
+// next returns the first progress report that matches.
+func (w *finalizationWait) next(match func(p txutils.FinalizationProgress) bool) txutils.FinalizationProgress {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case p := <-w.progress:
+			if match(p) {
+				return p
+			}
+		case <-timeout:
+			w.t.Fatal("timed out waiting for progress")
+		}
+	}
+}
+
+func (w *finalizationWait) result() error {
+	select {
+	case err := <-w.done:
+		return err
+	case <-time.After(5 * time.Second):
+		w.t.Fatal("timed out waiting for the wait to end")
+		return nil
+	}
+}
+
+func hasOutput(p txutils.FinalizationProgress) bool { return p.Output != nil }
+
+func Test_WaitForFinalizationSubscribed(t *testing.T) {
+	ast := assert.New(t)
+	n := bridgetest.NewNetwork()
+	n.L2.Mine()
+	head, err := n.L2.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+
+	// the poll interval is far too long to notice the output, only the
+	// subscription can
+	w := startFinalizationWait(t, n.L1, n, head.Number, time.Hour)
+	first := w.next(func(txutils.FinalizationProgress) bool { return true })
+	ast.Nil(first.Output)
+	ast.Equal(head.Number.Uint64(), first.L2BlockNumber)
+
+	require.NoError(t, n.ProposeOutput())
+	p := w.next(hasOutput)
+	ast.Equal(int64(0), p.OutputIndex.Int64())
+	ast.Equal(p.Output.Timestamp.Uint64()+bridgetest.FinalizationPeriod+1, p.FinalizableAt)
+	ast.False(p.Done)
+
+	w.cancel()
+	ast.ErrorIs(w.result(), context.Canceled)
+}
+
+func Test_WaitForFinalizationPolling(t *testing.T) {
+	ast := assert.New(t)
+	n := bridgetest.NewNetwork()
+	n.L2.Mine()
+	head, err := n.L2.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+
+	w := startFinalizationWait(t, pollingBackend{n.L1}, n, head.Number, 10*time.Millisecond)
+	require.NoError(t, n.ProposeOutput())
+	first := w.next(hasOutput)
+
+	require.NoError(t, n.DeleteOutputs(0))
+	w.next(func(p txutils.FinalizationProgress) bool { return p.Output == nil })
+	n.L2.Mine()
+	require.NoError(t, n.ProposeOutput())
+	second := w.next(hasOutput)
+	ast.NotEqual(first.Output.OutputRoot, second.Output.OutputRoot)
+
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	done := w.next(func(p txutils.FinalizationProgress) bool { return p.Done })
+	ast.GreaterOrEqual(done.L1Time, done.FinalizableAt)
+	ast.NoError(w.result())
+}
@@ -2,47 +2,176 @@ package txutils
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
+	"math"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
-func GetGas(cli *ethclient.Client, contractABI abi.ABI, gasLimit uint32, from, to common.Address, value *big.Int) (*big.Int, *big.Int, uint32, error) {
-	gasTipCap, err := cli.SuggestGasTipCap(context.Background())
-	header, err := cli.HeaderByNumber(context.Background(), nil)
+const (
+	// DefaultGasMargin is added on top of every gas estimate, 0.2 means 20%.
+	DefaultGasMargin = 0.2
+	// DefaultFeeHistoryBlocks is how many recent blocks the tip is taken from.
+	DefaultFeeHistoryBlocks = 10
+	// DefaultTipPercentile is the percentile of the tips paid in those blocks.
+	DefaultTipPercentile = 50
+)
+
+// FeeBackend is the part of a chain client the fee estimator needs.
+type FeeBackend interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+}
+
+// Fees is a gas limit with either legacy or EIP-1559 pricing.
+type Fees struct {
+	GasLimit uint64
+	// GasPrice is set for legacy pricing only.
+	GasPrice *big.Int
+	// GasTipCap and GasFeeCap are set for EIP-1559 pricing only.
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+}
+
+// Apply copies the fees into opts so bind neither estimates nor prices again.
+func (f *Fees) Apply(opts *bind.TransactOpts) {
+	opts.GasLimit = f.GasLimit
+	opts.GasPrice = f.GasPrice
+	opts.GasTipCap = f.GasTipCap
+	opts.GasFeeCap = f.GasFeeCap
+}
+
+// FeeEstimator estimates the gas of contract calls and prices them. The zero
+// values of the knobs fall back to the Default constants, except GasMargin
+// for which 0 means no margin.
+type FeeEstimator struct {
+	Backend FeeBackend
+	// GasMargin is the fraction added on top of the estimated gas, set to
+	// DefaultGasMargin by NewFeeEstimator.
+	GasMargin float64
+	// Legacy prices with eth_gasPrice even on chains with a base fee.
+	Legacy           bool
+	FeeHistoryBlocks uint64
+	TipPercentile    float64
+}
+
+// NewFeeEstimator returns an estimator using the defaults.
+func NewFeeEstimator(backend FeeBackend) *FeeEstimator {
+	return &FeeEstimator{Backend: backend, GasMargin: DefaultGasMargin}
+}
+
+// Estimate packs method with args, estimates its gas with the margin on top
+// and prices it.
+func (e *FeeEstimator) Estimate(ctx context.Context, from common.Address, to *common.Address, value *big.Int, contractABI *abi.ABI, method string, args ...interface{}) (*Fees, error) {
+	data, err := contractABI.Pack(method, args...)
 	if err != nil {
-		return nil, nil, 0, err
+		return nil, fmt.Errorf("failed to pack %s: %w", method, err)
 	}
-	gasFeeCap := new(big.Int).Add(
-		gasTipCap,
-		new(big.Int).Mul(header.BaseFee, big.NewInt(2)),
-	)
-
-	estCallData, err := contractABI.Pack("depositETH", gasLimit, []byte{}) // approveData)
+	fees, err := e.Price(ctx)
 	if err != nil {
-		return nil, nil, 0, err
+		return nil, err
 	}
-	fmt.Printf("callData is %s\n", hex.EncodeToString(estCallData))
-
-	estMsg := ethereum.CallMsg{
+	msg := ethereum.CallMsg{
 		From:      from,
-		To:        &to,
-		GasFeeCap: gasFeeCap,
-		GasTipCap: gasTipCap,
+		To:        to,
+		GasPrice:  fees.GasPrice,
+		GasTipCap: fees.GasTipCap,
+		GasFeeCap: fees.GasFeeCap,
 		Value:     value,
-		Data:      estCallData,
+		Data:      data,
+	}
+	if fees.GasLimit, err = e.EstimateGas(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to estimate gas of %s: %w", method, err)
+	}
+	return fees, nil
+}
+
+// EstimateGas estimates msg and adds the margin.
+func (e *FeeEstimator) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	if e.GasMargin < 0 {
+		return 0, fmt.Errorf("gas margin must not be negative, got %v", e.GasMargin)
 	}
-	estGasLimit, estErr := cli.EstimateGas(context.Background(), estMsg)
-	if estErr != nil {
-		fmt.Printf("estErr: %s\n", estErr.Error())
-	} else {
-		fmt.Printf("estGas: %d\n", estGasLimit)
+	gas, err := e.Backend.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(math.Ceil(float64(gas) * (1 + e.GasMargin))), nil
+}
+
+// Price returns the fees without a gas limit. Chains without a base fee, and
+// every chain when Legacy is set, get a legacy gas price.
+func (e *FeeEstimator) Price(ctx context.Context) (*Fees, error) {
+	head, err := e.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest header: %w", err)
+	}
+	if e.Legacy || head.BaseFee == nil {
+		gasPrice, err := e.Backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to suggest gas price: %w", err)
+		}
+		return &Fees{GasPrice: gasPrice}, nil
+	}
+
+	tip, baseFee, err := e.feeHistory(ctx)
+	if err != nil {
+		return nil, err
 	}
+	if baseFee == nil {
+		baseFee = head.BaseFee
+	}
+	// leave room for the base fee to rise for a few full blocks
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(baseFee, big.NewInt(2)))
+	return &Fees{GasTipCap: tip, GasFeeCap: feeCap}, nil
+}
+
+// feeHistory returns the tip percentile of recent blocks and the base fee of
+// the next block. Nodes without fee history fall back to eth_maxPriorityFeePerGas.
+func (e *FeeEstimator) feeHistory(ctx context.Context) (*big.Int, *big.Int, error) {
+	blocks := e.FeeHistoryBlocks
+	if blocks == 0 {
+		blocks = DefaultFeeHistoryBlocks
+	}
+	percentile := e.TipPercentile
+	if percentile == 0 {
+		percentile = DefaultTipPercentile
+	}
+
+	var baseFee *big.Int
+	var tips []*big.Int
+	history, err := e.Backend.FeeHistory(ctx, blocks, nil, []float64{percentile})
+	if err == nil {
+		if n := len(history.BaseFee); n > 0 {
+			baseFee = history.BaseFee[n-1]
+		}
+		for _, reward := range history.Reward {
+			if len(reward) > 0 && reward[0] != nil {
+				tips = append(tips, reward[0])
+			}
+		}
+	}
+	if len(tips) == 0 {
+		tip, err := e.Backend.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to suggest gas tip cap: %w", err)
+		}
+		return tip, baseFee, nil
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+	return new(big.Int).Set(tips[len(tips)/2]), baseFee, nil
+}
 
-	return gasTipCap, gasFeeCap, gasLimit, nil
+// GetGas estimates method called with args on contract from the sender with
+// the default margin and pricing.
+func GetGas(ctx context.Context, cli FeeBackend, contractABI *abi.ABI, method string, from, to common.Address, value *big.Int, args ...interface{}) (*Fees, error) {
+	return NewFeeEstimator(cli).Estimate(ctx, from, &to, value, contractABI, method, args...)
 }
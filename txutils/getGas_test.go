@@ -0,0 +1,105 @@
+package txutils
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/abistr/abijson"
+)
+
+type fakeFeeBackend struct {
+	baseFee *big.Int
+	history *ethereum.FeeHistory
+	gas     uint64
+	msg     ethereum.CallMsg
+}
+
+func (f *fakeFeeBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(100), BaseFee: f.baseFee}, nil
+}
+
+func (f *fakeFeeBackend) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	f.msg = msg
+	return f.gas, nil
+}
+
+func (f *fakeFeeBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(7), nil
+}
+
+func (f *fakeFeeBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(3), nil
+}
+
+func (f *fakeFeeBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	if f.history == nil {
+		return nil, errors.New("the method eth_feeHistory does not exist")
+	}
+	return f.history, nil
+}
+
+func Test_FeeEstimator(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridgeABI, err := abijson.L1StandardBridgeMetaData.GetAbi()
+	require.NoError(t, err)
+	from, to := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+
+	backend := &fakeFeeBackend{
+		baseFee: big.NewInt(10),
+		gas:     100000,
+		history: &ethereum.FeeHistory{
+			Reward:  [][]*big.Int{{big.NewInt(5)}, {big.NewInt(1)}, {big.NewInt(2)}},
+			BaseFee: []*big.Int{big.NewInt(10), big.NewInt(11), big.NewInt(12), big.NewInt(13)},
+		},
+	}
+	fees, err := GetGas(ctx, backend, bridgeABI, "depositETH", from, to, big.NewInt(1), uint32(200000), []byte{})
+	require.NoError(t, err)
+	ast.Equal(uint64(120000), fees.GasLimit, "default 20% margin")
+	ast.Equal(big.NewInt(2), fees.GasTipCap, "median tip")
+	ast.Equal(big.NewInt(28), fees.GasFeeCap, "tip + 2 * next base fee")
+	ast.Nil(fees.GasPrice)
+	ast.Equal(&to, backend.msg.To)
+	ast.Equal(bridgeABI.Methods["depositETH"].ID, backend.msg.Data[:4])
+
+	_, err = GetGas(ctx, backend, bridgeABI, "noSuchMethod", from, to, nil)
+	ast.Error(err)
+
+	// nodes without fee history fall back to eth_maxPriorityFeePerGas
+	backend.history = nil
+	e := &FeeEstimator{Backend: backend, GasMargin: 0.5}
+	fees, err = e.Estimate(ctx, from, &to, nil, bridgeABI, "depositETH", uint32(200000), []byte{})
+	require.NoError(t, err)
+	ast.Equal(uint64(150000), fees.GasLimit)
+	ast.Equal(big.NewInt(3), fees.GasTipCap)
+	ast.Equal(big.NewInt(23), fees.GasFeeCap)
+
+	// a zero margin adds nothing, a negative one is rejected
+	e.GasMargin = 0
+	gas, err := e.EstimateGas(ctx, ethereum.CallMsg{})
+	require.NoError(t, err)
+	ast.Equal(uint64(100000), gas)
+	e.GasMargin = -0.1
+	_, err = e.EstimateGas(ctx, ethereum.CallMsg{})
+	ast.ErrorContains(err, "must not be negative")
+	e.GasMargin = 0.5
+
+	// legacy pricing, forced or on chains without a base fee
+	e.Legacy = true
+	fees, err = e.Price(ctx)
+	require.NoError(t, err)
+	ast.Equal(big.NewInt(7), fees.GasPrice)
+	ast.Nil(fees.GasTipCap)
+	e.Legacy = false
+	backend.baseFee = nil
+	fees, err = e.Price(ctx)
+	require.NoError(t, err)
+	ast.Equal(big.NewInt(7), fees.GasPrice)
+}
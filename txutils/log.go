@@ -0,0 +1,34 @@
+package txutils
+
+import (
+	"log"
+)
+
+// Logger is the logging interface used by txutils. *testing.T satisfies it,
+// so tests can pass t directly.
+type Logger interface {
+	Logf(format string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Logf(string, ...interface{}) {}
+
+// NopLogger discards everything.
+var NopLogger Logger = nopLogger{}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (s stdLogger) Logf(format string, args ...interface{}) {
+	s.l.Printf(format, args...)
+}
+
+// NewStdLogger logs through a standard library logger, log.Default() if l is nil.
+func NewStdLogger(l *log.Logger) Logger {
+	if l == nil {
+		l = log.Default()
+	}
+	return stdLogger{l: l}
+}
@@ -0,0 +1,192 @@
+package txutils
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// maxNonceRetries bounds how often Transact resyncs and resends after the node
+// rejected a nonce as too low.
+const maxNonceRetries = 3
+
+// NonceSource is the part of a chain client the nonce manager needs.
+type NonceSource interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// IsNonceTooLow reports whether err is the node rejecting a nonce that was
+// already used.
+func IsNonceTooLow(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "nonce too low")
+}
+
+// IsAlreadyKnown reports whether err is the node rejecting a tx that is
+// already in its pool.
+func IsAlreadyKnown(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "already known")
+}
+
+// NonceManager hands out nonces locally so several txs from one account can
+// be sent back to back or in parallel without asking the node each time.
+// A manager serves a single chain, use one per chain client.
+type NonceManager struct {
+	source NonceSource
+
+	mu       sync.Mutex
+	accounts map[common.Address]*accountNonces
+}
+
+type accountNonces struct {
+	synced bool
+	next   uint64
+	// released holds nonces handed out but never sent, in ascending order.
+	// They are reused before next so a failed send leaves no gap.
+	released []uint64
+}
+
+// NewNonceManager returns a manager that syncs with source on first use of
+// every account.
+func NewNonceManager(source NonceSource) *NonceManager {
+	return &NonceManager{
+		source:   source,
+		accounts: make(map[common.Address]*accountNonces),
+	}
+}
+
+func (m *NonceManager) account(account common.Address) *accountNonces {
+	acc, ok := m.accounts[account]
+	if !ok {
+		acc = &accountNonces{}
+		m.accounts[account] = acc
+	}
+	return acc
+}
+
+// Next returns the nonce to use for the next tx of account.
+func (m *NonceManager) Next(ctx context.Context, account common.Address) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	acc := m.account(account)
+	if !acc.synced {
+		if err := m.resync(ctx, account, acc); err != nil {
+			return 0, err
+		}
+	}
+	if len(acc.released) > 0 {
+		nonce := acc.released[0]
+		acc.released = acc.released[1:]
+		return nonce, nil
+	}
+	nonce := acc.next
+	acc.next++
+	return nonce, nil
+}
+
+// Release gives back a nonce whose tx was never accepted by the node, so the
+// next call to Next reuses it.
+func (m *NonceManager) Release(account common.Address, nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	acc := m.account(account)
+	if !acc.synced || nonce >= acc.next {
+		return
+	}
+	if nonce+1 == acc.next {
+		acc.next--
+		return
+	}
+	i := sort.Search(len(acc.released), func(i int) bool { return acc.released[i] >= nonce })
+	if i < len(acc.released) && acc.released[i] == nonce {
+		return
+	}
+	acc.released = append(acc.released, 0)
+	copy(acc.released[i+1:], acc.released[i:])
+	acc.released[i] = nonce
+}
+
+// Resync moves the local view of account up to the pending nonce of the
+// node. Nonces handed out to senders whose txs the node has not seen yet stay
+// in use, and released nonces the node has already seen are dropped.
+func (m *NonceManager) Resync(ctx context.Context, account common.Address) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resync(ctx, account, m.account(account))
+}
+
+func (m *NonceManager) resync(ctx context.Context, account common.Address, acc *accountNonces) error {
+	pending, err := m.source.PendingNonceAt(ctx, account)
+	if err != nil {
+		return fmt.Errorf("failed to get pending nonce of %s: %w", account, err)
+	}
+	acc.synced = true
+	if pending >= acc.next {
+		acc.next = pending
+		acc.released = nil
+		return nil
+	}
+	i := sort.Search(len(acc.released), func(i int) bool { return acc.released[i] >= pending })
+	acc.released = acc.released[i:]
+	return nil
+}
+
+// Transact calls send with a copy of opts carrying a managed nonce. If the
+// node rejects the nonce as too low the manager resyncs and sends again, if
+// the tx is already known it is returned as sent. Any other error releases
+// the nonce. opts with an explicit Nonce are passed through unchanged.
+func (m *NonceManager) Transact(opts *bind.TransactOpts, send func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	if opts.Nonce != nil {
+		return send(opts)
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for attempt := 0; ; attempt++ {
+		nonce, err := m.Next(ctx, opts.From)
+		if err != nil {
+			return nil, err
+		}
+
+		// keep the signed tx, bind drops it when sending fails
+		var signed *types.Transaction
+		managed := *opts
+		managed.Nonce = new(big.Int).SetUint64(nonce)
+		managed.Signer = func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			tx, err := opts.Signer(from, tx)
+			signed = tx
+			return tx, err
+		}
+
+		tx, err := send(&managed)
+		switch {
+		case err == nil:
+			return tx, nil
+		case IsAlreadyKnown(err) && signed != nil:
+			if err := m.Resync(ctx, opts.From); err != nil {
+				return nil, err
+			}
+			return signed, nil
+		case IsNonceTooLow(err):
+			if err := m.Resync(ctx, opts.From); err != nil {
+				return nil, err
+			}
+			if attempt == maxNonceRetries {
+				return nil, err
+			}
+		default:
+			m.Release(opts.From, nonce)
+			return nil, err
+		}
+	}
+}
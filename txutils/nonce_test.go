@@ -0,0 +1,210 @@
+package txutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNonceSource struct {
+	mu      sync.Mutex
+	pending uint64
+	calls   int
+}
+
+func (f *fakeNonceSource) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.pending, nil
+}
+
+func noopSigner(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	return tx, nil
+}
+
+func Test_NonceManager(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	account := common.HexToAddress("0x01")
+	source := &fakeNonceSource{pending: 5}
+	m := NewNonceManager(source)
+
+	for want := uint64(5); want < 8; want++ {
+		nonce, err := m.Next(ctx, account)
+		require.NoError(t, err)
+		ast.Equal(want, nonce)
+	}
+	ast.Equal(1, source.calls, "only the first Next asks the node")
+
+	// a released nonce is handed out again before new ones
+	m.Release(account, 6)
+	nonce, _ := m.Next(ctx, account)
+	ast.Equal(uint64(6), nonce)
+	nonce, _ = m.Next(ctx, account)
+	ast.Equal(uint64(8), nonce)
+
+	// releasing the latest nonce just steps back
+	m.Release(account, 8)
+	nonce, _ = m.Next(ctx, account)
+	ast.Equal(uint64(8), nonce)
+
+	source.pending = 20
+	require.NoError(t, m.Resync(ctx, account))
+	nonce, _ = m.Next(ctx, account)
+	ast.Equal(uint64(20), nonce)
+}
+
+func Test_NonceManager_parallel(t *testing.T) {
+	m := NewNonceManager(&fakeNonceSource{})
+	account := common.HexToAddress("0x01")
+
+	var wg sync.WaitGroup
+	nonces := make([]uint64, 50)
+	for i := range nonces {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nonces[i], _ = m.Next(context.Background(), account)
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[uint64]bool)
+	for _, n := range nonces {
+		assert.False(t, seen[n], "nonce %d handed out twice", n)
+		seen[n] = true
+	}
+}
+
+func Test_NonceManager_Transact(t *testing.T) {
+	ast := assert.New(t)
+	account := common.HexToAddress("0x01")
+	source := &fakeNonceSource{pending: 1}
+	m := NewNonceManager(source)
+	opts := &bind.TransactOpts{From: account, Signer: noopSigner}
+
+	// the node has seen nonce 1 from elsewhere, the manager resyncs and resends
+	var sent []uint64
+	tx, err := m.Transact(opts, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		sent = append(sent, opts.Nonce.Uint64())
+		if opts.Nonce.Uint64() < 3 {
+			source.pending = 3
+			return nil, errors.New("nonce too low")
+		}
+		return types.NewTx(&types.LegacyTx{Nonce: opts.Nonce.Uint64()}), nil
+	})
+	require.NoError(t, err)
+	ast.Equal(uint64(3), tx.Nonce())
+	ast.Equal([]uint64{1, 3}, sent)
+	ast.Nil(opts.Nonce, "the caller's opts are left alone")
+
+	// an already known tx counts as sent
+	tx, err = m.Transact(opts, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		signed, _ := opts.Signer(opts.From, types.NewTx(&types.LegacyTx{Nonce: opts.Nonce.Uint64()}))
+		source.pending = signed.Nonce() + 1
+		return nil, errors.New("already known")
+	})
+	require.NoError(t, err)
+	ast.Equal(uint64(4), tx.Nonce())
+
+	// any other failure gives the nonce back
+	_, err = m.Transact(opts, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return nil, errors.New("insufficient funds")
+	})
+	ast.Error(err)
+	nonce, err := m.Next(context.Background(), account)
+	require.NoError(t, err)
+	ast.Equal(uint64(5), nonce)
+}
+
+func Test_NonceManager_resyncKeepsOutstanding(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	account := common.HexToAddress("0x01")
+	source := &fakeNonceSource{pending: 5}
+	m := NewNonceManager(source)
+
+	// 5 and 6 are out with senders, 7 was released, the node saw none yet
+	for i := 0; i < 4; i++ {
+		_, err := m.Next(ctx, account)
+		require.NoError(t, err)
+	}
+	m.Release(account, 7)
+	require.NoError(t, m.Resync(ctx, account))
+	nonce, _ := m.Next(ctx, account)
+	ast.Equal(uint64(7), nonce, "the released nonce is still free")
+	nonce, _ = m.Next(ctx, account)
+	ast.Equal(uint64(9), nonce)
+
+	// the node got ahead, released nonces below its pending one are dropped
+	m.Release(account, 7)
+	source.pending = 8
+	require.NoError(t, m.Resync(ctx, account))
+	nonce, _ = m.Next(ctx, account)
+	ast.Equal(uint64(10), nonce)
+
+	// senders resync while others still hold their nonces
+	var wg sync.WaitGroup
+	nonces := make([]uint64, 50)
+	for i := range nonces {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nonces[i], _ = m.Next(ctx, account)
+			if i%5 == 0 {
+				assert.NoError(t, m.Resync(ctx, account))
+			}
+		}(i)
+	}
+	wg.Wait()
+	seen := make(map[uint64]bool)
+	for _, n := range nonces {
+		ast.False(seen[n], "nonce %d handed out twice", n)
+		ast.GreaterOrEqual(n, uint64(11))
+		seen[n] = true
+	}
+}
+
+// rawTxService answers eth_sendRawTransaction with err, as a node would.
+type rawTxService struct{ err error }
+
+func (s rawTxService) SendRawTransaction(ctx context.Context, enc hexutil.Bytes) (common.Hash, error) {
+	return common.Hash{}, s.err
+}
+
+func Test_NodeErrors(t *testing.T) {
+	ast := assert.New(t)
+	send := func(nodeErr error) error {
+		srv := rpc.NewServer()
+		require.NoError(t, srv.RegisterName("eth", rawTxService{err: nodeErr}))
+		defer srv.Stop()
+		cl := ethclient.NewClient(rpc.DialInProc(srv))
+		defer cl.Close()
+		return cl.SendTransaction(context.Background(), types.NewTx(&types.LegacyTx{}))
+	}
+
+	err := send(core.ErrNonceTooLow)
+	ast.True(IsNonceTooLow(err), err)
+	ast.False(IsAlreadyKnown(err))
+	err = send(fmt.Errorf("%w: address %v, tx: %d state: %d", core.ErrNonceTooLow, common.Address{}, 1, 2))
+	ast.True(IsNonceTooLow(err), err)
+	err = send(core.ErrAlreadyKnown)
+	ast.True(IsAlreadyKnown(err), err)
+	ast.False(IsNonceTooLow(err))
+	err = send(core.ErrNonceTooHigh)
+	ast.False(IsNonceTooLow(err) || IsAlreadyKnown(err), err)
+	ast.False(IsNonceTooLow(nil) || IsAlreadyKnown(nil))
+}
@@ -0,0 +1,143 @@
+package txutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"try_rde/abistr/abijson"
+)
+
+var (
+	// ErrInvalidProof is returned when an eth_getProof result does not verify
+	// against the state root it was requested for.
+	ErrInvalidProof = errors.New("invalid merkle proof")
+	// ErrOutputRootMismatch is returned when the locally computed output root
+	// differs from the one proposed to the L2OutputOracle.
+	ErrOutputRootMismatch = errors.New("output root mismatch")
+)
+
+// ProofBackend serves eth_getProof. *gethclient.Client satisfies it.
+type ProofBackend interface {
+	GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*gethclient.AccountResult, error)
+}
+
+// abiTrue represents the storage representation of the boolean value true.
+var abiTrue = common.Hash{31: 0x01}
+
+// proofDB puts hex encoded trie nodes into a db keyed by their hash, which is
+// how trie.VerifyProof looks them up.
+func proofDB(nodes []string) (*memorydb.Database, error) {
+	db := memorydb.New()
+	for i, node := range nodes {
+		enc := common.FromHex(node)
+		if err := db.Put(crypto.Keccak256(enc), enc); err != nil {
+			return nil, fmt.Errorf("failed to load proof node %d: %w", i, err)
+		}
+	}
+	return db, nil
+}
+
+// VerifyAccountProof checks that the account is part of the state trie with
+// the given root.
+func VerifyAccountProof(root common.Hash, address common.Address, account types.StateAccount, proof []string) error {
+	expected, err := rlp.EncodeToBytes(&account)
+	if err != nil {
+		return fmt.Errorf("failed to encode account: %w", err)
+	}
+	db, err := proofDB(proof)
+	if err != nil {
+		return err
+	}
+	value, err := trie.VerifyProof(root, crypto.Keccak256(address[:]), db)
+	if err != nil {
+		return fmt.Errorf("%w: account %s: %v", ErrInvalidProof, address, err)
+	}
+	if !bytes.Equal(value, expected) {
+		return fmt.Errorf("%w: account %s does not match the proven value", ErrInvalidProof, address)
+	}
+	return nil
+}
+
+// VerifyStorageProof checks that the storage slot holds the reported value in
+// the storage trie with the given root.
+func VerifyStorageProof(root common.Hash, proof gethclient.StorageResult) error {
+	expected, err := rlp.EncodeToBytes(proof.Value.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to encode storage value: %w", err)
+	}
+	db, err := proofDB(proof.Proof)
+	if err != nil {
+		return err
+	}
+	value, err := trie.VerifyProof(root, crypto.Keccak256(common.HexToHash(proof.Key).Bytes()), db)
+	if err != nil {
+		return fmt.Errorf("%w: slot %s: %v", ErrInvalidProof, proof.Key, err)
+	}
+	// an absent slot verifies as an empty value
+	if !bytes.Equal(value, expected) && !(len(value) == 0 && proof.Value.Sign() == 0) {
+		return fmt.Errorf("%w: slot %s does not match the proven value", ErrInvalidProof, proof.Key)
+	}
+	return nil
+}
+
+// VerifyProof checks an eth_getProof result, the account and every storage
+// slot, against the state root of the block it was requested for.
+func VerifyProof(stateRoot common.Hash, proof *gethclient.AccountResult) error {
+	err := VerifyAccountProof(stateRoot, proof.Address, types.StateAccount{
+		Nonce:    proof.Nonce,
+		Balance:  proof.Balance,
+		Root:     proof.StorageHash,
+		CodeHash: proof.CodeHash[:],
+	}, proof.AccountProof)
+	if err != nil {
+		return err
+	}
+	for _, storageProof := range proof.StorageProof {
+		if err := VerifyStorageProof(proof.StorageHash, storageProof); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyWithdrawalProof checks that the proof shows the withdrawal hash as
+// sent in the L2ToL1MessagePasser at messagePasser at the given state root.
+func VerifyWithdrawalProof(stateRoot common.Hash, messagePasser common.Address, withdrawalHash common.Hash, proof *gethclient.AccountResult) error {
+	if proof.Address != messagePasser {
+		return fmt.Errorf("%w: proof is for account %s, want the message passer %s", ErrInvalidProof, proof.Address, messagePasser)
+	}
+	if len(proof.StorageProof) != 1 {
+		return errors.New("invalid amount of storage proofs")
+	}
+	slot := StorageSlotOfWithdrawalHash(withdrawalHash)
+	if common.HexToHash(proof.StorageProof[0].Key) != slot {
+		return fmt.Errorf("%w: proof is for slot %s, want %s", ErrInvalidProof, proof.StorageProof[0].Key, slot)
+	}
+	if common.BigToHash(proof.StorageProof[0].Value) != abiTrue {
+		return fmt.Errorf("%w: withdrawal %s is not in the message passer storage", ErrInvalidProof, withdrawalHash)
+	}
+	return VerifyProof(stateRoot, proof)
+}
+
+// VerifyOutputRoot recomputes the output root from its proof elements and
+// compares it to the root proposed to the L2OutputOracle.
+func VerifyOutputRoot(proofElements *abijson.TypesOutputRootProof, proposed common.Hash) error {
+	local, err := ComputeL2OutputRoot(proofElements)
+	if err != nil {
+		return err
+	}
+	if common.Hash(local) != proposed {
+		return fmt.Errorf("%w: got 0x%x expected %s", ErrOutputRootMismatch, local, proposed)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package txutils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/abistr/abijson"
+)
+
+func proveKey(t *testing.T, tr *trie.Trie, key []byte) []string {
+	db := memorydb.New()
+	require.NoError(t, tr.Prove(key, 0, db))
+	var nodes []string
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		nodes = append(nodes, hexutil.Encode(it.Value()))
+	}
+	return nodes
+}
+
+// buildProof creates a message passer account holding the withdrawal and
+// returns the state root with an eth_getProof style result for it.
+func buildProof(t *testing.T, withdrawalHash common.Hash) (common.Hash, *gethclient.AccountResult) {
+	db := trie.NewDatabase(memorydb.New())
+	slot := StorageSlotOfWithdrawalHash(withdrawalHash)
+
+	storage := trie.NewEmpty(db)
+	value, _ := rlp.EncodeToBytes(abiTrue.Big().Bytes())
+	storage.Update(crypto.Keccak256(slot[:]), value)
+	// some unrelated slots so the proof has more than one node
+	for i := byte(1); i < 20; i++ {
+		other := common.Hash{0: i}
+		storage.Update(crypto.Keccak256(other[:]), value)
+	}
+
+	passer := common.HexToAddress("0x4200000000000000000000000000000000000016")
+	account := types.StateAccount{
+		Nonce:    1,
+		Balance:  big.NewInt(0),
+		Root:     storage.Hash(),
+		CodeHash: crypto.Keccak256([]byte("code")),
+	}
+	enc, err := rlp.EncodeToBytes(&account)
+	require.NoError(t, err)
+	state := trie.NewEmpty(db)
+	state.Update(crypto.Keccak256(passer[:]), enc)
+	for i := byte(1); i < 20; i++ {
+		other := common.Address{0: i}
+		state.Update(crypto.Keccak256(other[:]), enc)
+	}
+
+	return state.Hash(), &gethclient.AccountResult{
+		Address:      passer,
+		AccountProof: proveKey(t, state, crypto.Keccak256(passer[:])),
+		Balance:      account.Balance,
+		CodeHash:     common.BytesToHash(account.CodeHash),
+		Nonce:        account.Nonce,
+		StorageHash:  account.Root,
+		StorageProof: []gethclient.StorageResult{{
+			Key:   slot.Hex(),
+			Value: abiTrue.Big(),
+			Proof: proveKey(t, storage, crypto.Keccak256(slot[:])),
+		}},
+	}
+}
+
+func Test_VerifyWithdrawalProof(t *testing.T) {
+	ast := assert.New(t)
+	passer := common.HexToAddress("0x4200000000000000000000000000000000000016")
+	withdrawalHash := crypto.Keccak256Hash([]byte("withdrawal"))
+	root, proof := buildProof(t, withdrawalHash)
+	ast.NoError(VerifyWithdrawalProof(root, passer, withdrawalHash, proof))
+
+	// wrong state root
+	ast.ErrorIs(VerifyWithdrawalProof(common.Hash{1}, passer, withdrawalHash, proof), ErrInvalidProof)
+	// proof for another withdrawal
+	ast.ErrorIs(VerifyWithdrawalProof(root, passer, common.Hash{2}, proof), ErrInvalidProof)
+
+	// proof of another account
+	ast.ErrorIs(VerifyWithdrawalProof(root, common.Address{1}, withdrawalHash, proof), ErrInvalidProof)
+
+	// tampered account balance
+	_, tampered := buildProof(t, withdrawalHash)
+	tampered.Balance = big.NewInt(1)
+	ast.ErrorIs(VerifyProof(root, tampered), ErrInvalidProof)
+
+	// slot not set
+	_, tampered = buildProof(t, withdrawalHash)
+	tampered.StorageProof[0].Value = big.NewInt(0)
+	ast.ErrorIs(VerifyWithdrawalProof(root, passer, withdrawalHash, tampered), ErrInvalidProof)
+	ast.ErrorIs(VerifyProof(root, tampered), ErrInvalidProof)
+
+	// missing node
+	_, tampered = buildProof(t, withdrawalHash)
+	tampered.StorageProof[0].Proof = tampered.StorageProof[0].Proof[1:]
+	ast.ErrorIs(VerifyProof(root, tampered), ErrInvalidProof)
+}
+
+func Test_VerifyStorageProof_compactKey(t *testing.T) {
+	ast := assert.New(t)
+	storage := trie.NewEmpty(trie.NewDatabase(memorydb.New()))
+	slot := common.BigToHash(big.NewInt(1))
+	value, _ := rlp.EncodeToBytes(big.NewInt(42).Bytes())
+	storage.Update(crypto.Keccak256(slot[:]), value)
+	storage.Update(crypto.Keccak256(common.Hash{0: 1}.Bytes()), value)
+
+	proof := gethclient.StorageResult{
+		Key:   "0x1",
+		Value: big.NewInt(42),
+		Proof: proveKey(t, storage, crypto.Keccak256(slot[:])),
+	}
+	ast.NoError(VerifyStorageProof(storage.Hash(), proof))
+	proof.Key = slot.Hex()
+	ast.NoError(VerifyStorageProof(storage.Hash(), proof))
+	proof.Key = "0x2"
+	ast.ErrorIs(VerifyStorageProof(storage.Hash(), proof), ErrInvalidProof)
+}
+
+func Test_VerifyOutputRoot(t *testing.T) {
+	ast := assert.New(t)
+	proof := &abijson.TypesOutputRootProof{
+		StateRoot:                common.Hash{1},
+		MessagePasserStorageRoot: common.Hash{2},
+		LatestBlockhash:          common.Hash{3},
+	}
+	root, err := ComputeL2OutputRoot(proof)
+	require.NoError(t, err)
+	ast.NoError(VerifyOutputRoot(proof, common.Hash(root)))
+
+	proof.LatestBlockhash = common.Hash{4}
+	ast.ErrorIs(VerifyOutputRoot(proof, common.Hash(root)), ErrOutputRootMismatch)
+}
@@ -0,0 +1,131 @@
+package txutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrReceiptTimeout is returned by WaitForReceipt when WaitOpts.Timeout
+// passes before the tx has enough confirmations.
+var ErrReceiptTimeout = errors.New("timed out waiting for receipt")
+
+// RevertedError is returned together with the receipt of a tx that was
+// mined but failed.
+type RevertedError struct {
+	Receipt *types.Receipt
+}
+
+func (e *RevertedError) Error() string {
+	return fmt.Sprintf("tx %s reverted in block %d", e.Receipt.TxHash, e.Receipt.BlockNumber)
+}
+
+// ReceiptBackend is the part of a chain client WaitForReceipt needs.
+type ReceiptBackend interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// WaitOpts tunes WaitForReceipt. The zero value waits without a timeout
+// until the tx is mined.
+type WaitOpts struct {
+	// Confirmations is the number of blocks, counting the one holding the
+	// tx, the chain must have before the receipt is returned. 0 and 1 both
+	// mean mined.
+	Confirmations uint64
+	// Timeout bounds the whole wait, 0 waits until ctx is done.
+	Timeout time.Duration
+	// PollInterval defaults to one second.
+	PollInterval time.Duration
+	// Log reports reorgs, defaults to NopLogger.
+	Log Logger
+}
+
+// WaitForReceipt polls until txHash is mined and buried under
+// opts.Confirmations blocks. If the block holding the receipt is reorged out
+// meanwhile the count starts over from the block the tx lands in next. A
+// receipt with a failed status is returned with a *RevertedError.
+func WaitForReceipt(ctx context.Context, backend ReceiptBackend, txHash common.Hash, opts WaitOpts) (*types.Receipt, error) {
+	parent := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.Log == nil {
+		opts.Log = NopLogger
+	}
+	depth := opts.Confirmations
+	if depth == 0 {
+		depth = 1
+	}
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	var seen *types.Receipt
+	for {
+		receipt, confirmed, err := checkReceipt(ctx, backend, txHash, depth)
+		switch {
+		case errors.Is(err, ethereum.NotFound):
+			if seen != nil {
+				opts.Log.Logf("tx %s was in block %s which got reorged out, waiting for it again", txHash, seen.BlockHash)
+				seen = nil
+			}
+		case err != nil:
+			if ctx.Err() == nil {
+				return nil, fmt.Errorf("failed to get receipt of %s: %w", txHash, err)
+			}
+		default:
+			if seen != nil && seen.BlockHash != receipt.BlockHash {
+				opts.Log.Logf("tx %s moved from block %s to %s after a reorg", txHash, seen.BlockHash, receipt.BlockHash)
+			}
+			seen = receipt
+			if confirmed {
+				if receipt.Status != types.ReceiptStatusSuccessful {
+					return receipt, &RevertedError{Receipt: receipt}
+				}
+				return receipt, nil
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if parent.Err() == nil {
+				return nil, fmt.Errorf("%w %s after %s", ErrReceiptTimeout, txHash, opts.Timeout)
+			}
+			return nil, parent.Err()
+		}
+	}
+}
+
+// checkReceipt fetches the receipt of txHash and reports whether its block is
+// still canonical and buried under depth blocks.
+func checkReceipt(ctx context.Context, backend ReceiptBackend, txHash common.Hash, depth uint64) (*types.Receipt, bool, error) {
+	receipt, err := backend.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, false, err
+	}
+	head, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if head.Number.Uint64()+1 < receipt.BlockNumber.Uint64()+depth {
+		return receipt, false, nil
+	}
+	canonical, err := backend.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return nil, false, err
+	}
+	return receipt, canonical.Hash() == receipt.BlockHash, nil
+}
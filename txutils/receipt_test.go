@@ -0,0 +1,122 @@
+package txutils
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChain is a chain of empty headers. Every receipt query advances the
+// head by one block, so tests see confirmations pile up.
+type fakeChain struct {
+	mu       sync.Mutex
+	headers  []*types.Header
+	receipts map[common.Hash]*types.Receipt
+	onPoll   func(c *fakeChain)
+}
+
+func newFakeChain() *fakeChain {
+	c := &fakeChain{receipts: make(map[common.Hash]*types.Receipt)}
+	c.mine(common.Hash{})
+	return c
+}
+
+// mine appends a block, salt makes it differ from a block at the same height
+// on another branch.
+func (c *fakeChain) mine(salt common.Hash) *types.Header {
+	h := &types.Header{Number: big.NewInt(int64(len(c.headers))), Extra: salt[:]}
+	if len(c.headers) > 0 {
+		h.ParentHash = c.headers[len(c.headers)-1].Hash()
+	}
+	c.headers = append(c.headers, h)
+	return h
+}
+
+func (c *fakeChain) include(txHash common.Hash, status uint64, salt common.Hash) {
+	h := c.mine(salt)
+	c.receipts[txHash] = &types.Receipt{TxHash: txHash, Status: status, BlockHash: h.Hash(), BlockNumber: h.Number}
+}
+
+func (c *fakeChain) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.onPoll != nil {
+		c.onPoll(c)
+	}
+	r, ok := c.receipts[txHash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return r, nil
+}
+
+func (c *fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number == nil {
+		return c.headers[len(c.headers)-1], nil
+	}
+	if number.Uint64() >= uint64(len(c.headers)) {
+		return nil, ethereum.NotFound
+	}
+	return c.headers[number.Uint64()], nil
+}
+
+func Test_WaitForReceipt(t *testing.T) {
+	ast := assert.New(t)
+	txHash := common.HexToHash("0x01")
+	opts := WaitOpts{Confirmations: 3, PollInterval: time.Millisecond, Timeout: time.Second}
+
+	chain := newFakeChain()
+	chain.include(txHash, types.ReceiptStatusSuccessful, common.Hash{})
+	chain.onPoll = func(c *fakeChain) { c.mine(common.Hash{}) }
+	receipt, err := WaitForReceipt(context.Background(), chain, txHash, opts)
+	require.NoError(t, err)
+	ast.Equal(uint64(1), receipt.BlockNumber.Uint64())
+	ast.GreaterOrEqual(len(chain.headers), 4, "waited for 3 confirmations")
+
+	// the tx is reorged into another block before it is confirmed
+	chain = newFakeChain()
+	chain.include(txHash, types.ReceiptStatusSuccessful, common.Hash{})
+	orphaned := chain.headers[1].Hash()
+	polls := 0
+	chain.onPoll = func(c *fakeChain) {
+		polls++
+		if polls == 2 {
+			c.headers = c.headers[:1]
+			c.include(txHash, types.ReceiptStatusSuccessful, common.HexToHash("0xbeef"))
+			return
+		}
+		c.mine(common.Hash{})
+	}
+	receipt, err = WaitForReceipt(context.Background(), chain, txHash, opts)
+	require.NoError(t, err)
+	ast.NotEqual(orphaned, receipt.BlockHash)
+	ast.Equal(chain.headers[1].Hash(), receipt.BlockHash)
+
+	// reverted
+	chain = newFakeChain()
+	chain.include(txHash, types.ReceiptStatusFailed, common.Hash{})
+	receipt, err = WaitForReceipt(context.Background(), chain, txHash, WaitOpts{PollInterval: time.Millisecond})
+	var reverted *RevertedError
+	require.True(t, errors.As(err, &reverted))
+	ast.Equal(receipt, reverted.Receipt)
+
+	// never mined
+	_, err = WaitForReceipt(context.Background(), newFakeChain(), txHash, WaitOpts{PollInterval: time.Millisecond, Timeout: 20 * time.Millisecond})
+	ast.ErrorIs(err, ErrReceiptTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = WaitForReceipt(ctx, newFakeChain(), txHash, WaitOpts{PollInterval: time.Millisecond, Timeout: time.Second})
+	ast.ErrorIs(err, context.Canceled)
+}
@@ -0,0 +1,47 @@
+// Package txtest wraps the txutils API for tests: failures are reported on
+// the *testing.T and progress is logged through it.
+package txtest
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/abistr/abijson"
+	"try_rde/config"
+	"try_rde/txutils"
+)
+
+func ProveWithdrawalParameters(ctx context.Context, t *testing.T, proofCl *gethclient.Client, l2ReceiptCl *ethclient.Client, messagePasser common.Address, txHash common.Hash, header *types.Header, l2OutputOracleContract *abijson.L2OutputOracleProxyCaller, l2ToL1MessagePasser *abijson.L2ToL1MessagePasser) (txutils.ProvenWithdrawalParameters, error) {
+	params, err := txutils.ProveWithdrawalParameters(ctx, proofCl, l2ReceiptCl, messagePasser, txHash, header, l2OutputOracleContract, l2ToL1MessagePasser)
+	assert.NoError(t, err)
+	return params, err
+}
+
+func WaitForFinalizationPeriod(ctx context.Context, t *testing.T, client *ethclient.Client, portalAddr common.Address, l2BlockNumber *big.Int, finalizationPeriod *big.Int) (uint64, error) {
+	return txutils.WaitForFinalizationPeriod(ctx, t, client, portalAddr, l2BlockNumber, finalizationPeriod)
+}
+
+func GetBlockNum(t *testing.T, submissionInterval *big.Int, startingBlockNumber *big.Int, l2BlockNumber *big.Int) *big.Int {
+	t.Logf("GetBlockNum input blkNum is %d\n", l2BlockNumber)
+	blkNum := txutils.GetBlockNum(submissionInterval, startingBlockNumber, l2BlockNumber)
+	t.Logf("GetBlockNum output blkNum is %d\n", blkNum)
+	return blkNum
+}
+
+func GetETHBalanceFromL2(t *testing.T, profile *config.Profile, address string) *big.Int {
+	client, err := ethclient.Dial(profile.L2.RPC)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+	defer client.Close()
+
+	bal, err := txutils.GetETHBalanceFromL2(context.Background(), client, profile.Addresses.L2ETH, common.HexToAddress(address))
+	assert.NoError(t, err)
+	return bal
+}
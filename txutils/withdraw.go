@@ -6,20 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"testing"
 	"time"
 
-	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
-	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/ethclient/gethclient"
-	"github.com/stretchr/testify/assert"
 	"try_rde/abistr/abijson"
 )
 
@@ -137,15 +131,22 @@ type ProvenWithdrawalParameters struct {
 
 var MessagePassedTopic = crypto.Keccak256Hash([]byte("MessagePassed(uint256,address,address,uint256,uint256,uint256,bytes,bytes32)"))
 
-// ParseMessagePassed parses MessagePassed events from
-// a transaction receipt. It does not support multiple withdrawals
-// per receipt.
+// ParseMessagePassed returns the first MessagePassed event of a transaction
+// receipt. See ParseMessagesPassed for receipts with several withdrawals.
 func ParseMessagePassed(contract *abijson.L2ToL1MessagePasser, receipt *types.Receipt) (*abijson.L2ToL1MessagePasserMessagePassed, error) {
-	//contract, err := abijson.NewL2ToL1MessagePasser(common.HexToAddress("0x4200000000000000000000000000000000000016"), nil)
-	//if err != nil {
-	//	return nil, err
-	//}
+	evs, err := ParseMessagesPassed(contract, receipt)
+	if err != nil {
+		return nil, err
+	}
+	return evs[0], nil
+}
 
+// ParseMessagesPassed returns every MessagePassed event of a transaction
+// receipt in log order, a contract or a multicall may start several
+// withdrawals in one tx. Raw.Index of an event is its log index, which tells
+// the withdrawals of the tx apart.
+func ParseMessagesPassed(contract *abijson.L2ToL1MessagePasser, receipt *types.Receipt) ([]*abijson.L2ToL1MessagePasserMessagePassed, error) {
+	var evs []*abijson.L2ToL1MessagePasserMessagePassed
 	for _, log := range receipt.Logs {
 		if len(log.Topics) == 0 || log.Topics[0] != MessagePassedTopic {
 			continue
@@ -153,26 +154,26 @@ func ParseMessagePassed(contract *abijson.L2ToL1MessagePasser, receipt *types.Re
 
 		ev, err := contract.ParseMessagePassed(*log)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse log: %w", err)
+			return nil, fmt.Errorf("failed to parse log %d: %w", log.Index, err)
 		}
-		return ev, nil
+		evs = append(evs, ev)
+	}
+	if len(evs) == 0 {
+		return nil, errors.New("Unable to find MessagePassed event")
 	}
-	return nil, errors.New("Unable to find MessagePassed event")
+	return evs, nil
 }
 
-func GetBlockNum(t *testing.T, submissionInterval *big.Int, startingBlockNumber *big.Int, l2BlockNumber *big.Int) *big.Int {
-	t.Logf("GetBlockNum input blkNum is %d\n", l2BlockNumber)
+// GetBlockNum rounds l2BlockNumber up to the next output submission boundary.
+func GetBlockNum(submissionInterval *big.Int, startingBlockNumber *big.Int, l2BlockNumber *big.Int) *big.Int {
+	blockNumber := new(big.Int).Sub(l2BlockNumber, startingBlockNumber) // Don't clobber caller owned l2BlockNumber
 	rem := new(big.Int)
-	l2BlockNumber = l2BlockNumber.Sub(l2BlockNumber, startingBlockNumber)
-	l2BlockNumber, rem = l2BlockNumber.DivMod(l2BlockNumber, submissionInterval, rem)
+	blockNumber, rem = blockNumber.DivMod(blockNumber, submissionInterval, rem) // z = z(a, b, c);  z = a/b  c = a%b
 	if rem.Cmp(common.Big0) != 0 {
-		l2BlockNumber = l2BlockNumber.Add(l2BlockNumber, common.Big1)
+		blockNumber = blockNumber.Add(blockNumber, common.Big1)
 	}
-	l2BlockNumber = l2BlockNumber.Mul(l2BlockNumber, submissionInterval)
-	l2BlockNumber = l2BlockNumber.Add(l2BlockNumber, startingBlockNumber)
-	t.Logf("GetBlockNum output blkNum is %d\n", l2BlockNumber)
-
-	return l2BlockNumber
+	blockNumber = blockNumber.Mul(blockNumber, submissionInterval)
+	return blockNumber.Add(blockNumber, startingBlockNumber)
 }
 
 func WithdrawalHash(ev *abijson.L2ToL1MessagePasserMessagePassed) (common.Hash, error) {
@@ -196,50 +197,64 @@ func WithdrawalHash(ev *abijson.L2ToL1MessagePasserMessagePassed) (common.Hash,
 // ProveWithdrawalParameters queries L1 & L2 to generate all withdrawal parameters and proof necessary to prove a withdrawal on L1.
 // The header provided is very important. It should be a block (timestamp) for which there is a submitted output in the L2 Output Oracle
 // contract. If not, the withdrawal will fail as it the storage proof cannot be verified if there is no submitted state root.
-func ProveWithdrawalParameters(ctx context.Context, t *testing.T, proofCl *gethclient.Client, l2ReceiptCl *ethclient.Client, txHash common.Hash, header *types.Header, l2OutputOracleContract *abijson.L2OutputOracleProxyCaller, l2ToL1MessagePasser *abijson.L2ToL1MessagePasser) (ProvenWithdrawalParameters, error) {
-	ast := assert.New(t)
+// messagePasser is the address of the L2ToL1MessagePasser, the one of the profile.
+func ProveWithdrawalParameters(ctx context.Context, proofCl ProofBackend, l2ReceiptCl ReceiptBackend, messagePasser common.Address, txHash common.Hash, header *types.Header, l2OutputOracleContract *abijson.L2OutputOracleProxyCaller, l2ToL1MessagePasser *abijson.L2ToL1MessagePasser) (ProvenWithdrawalParameters, error) {
 	// Transaction receipt
 	receipt, err := l2ReceiptCl.TransactionReceipt(ctx, txHash)
-	ast.NoError(err)
 	if err != nil {
 		return ProvenWithdrawalParameters{}, err
 	}
-	// Parse the receipt
+	// Parse the receipt, only its first withdrawal is proven
 	ev, err := ParseMessagePassed(l2ToL1MessagePasser, receipt)
-	ast.NoError(err)
 	if err != nil {
 		return ProvenWithdrawalParameters{}, err
 	}
+	return ProveMessagePassedParameters(ctx, proofCl, messagePasser, ev, header, l2OutputOracleContract)
+}
+
+// ProveMessagePassedParameters is ProveWithdrawalParameters for one
+// MessagePassed event, any of the withdrawals of a tx, emitted by the
+// L2ToL1MessagePasser at messagePasser.
+func ProveMessagePassedParameters(ctx context.Context, proofCl ProofBackend, messagePasser common.Address, ev *abijson.L2ToL1MessagePasserMessagePassed, header *types.Header, l2OutputOracleContract *abijson.L2OutputOracleProxyCaller) (ProvenWithdrawalParameters, error) {
 	// Generate then verify the withdrawal proof
 	withdrawalHash, err := WithdrawalHash(ev)
-	ast.NoError(err)
+	if err != nil {
+		return ProvenWithdrawalParameters{}, err
+	}
 	if !bytes.Equal(withdrawalHash[:], ev.WithdrawalHash[:]) {
 		return ProvenWithdrawalParameters{}, errors.New("Computed withdrawal hash incorrectly")
 	}
+	slot := StorageSlotOfWithdrawalHash(withdrawalHash)
+	p, err := proofCl.GetProof(ctx, messagePasser, []string{slot.String()}, header.Number)
 	if err != nil {
 		return ProvenWithdrawalParameters{}, err
 	}
-	slot := StorageSlotOfWithdrawalHash(withdrawalHash)
-	p, err := proofCl.GetProof(ctx, predeploys.L2ToL1MessagePasserAddr, []string{slot.String()}, header.Number)
-	ast.NoError(err)
-	if err != nil {
+
+	// Check the proof locally, a bad proof would only revert on L1 and waste gas
+	if err := VerifyWithdrawalProof(header.Root, messagePasser, withdrawalHash, p); err != nil {
 		return ProvenWithdrawalParameters{}, err
 	}
 
 	// Fetch the L2OutputIndex from the L2 Output Oracle caller (on L1)
-	l2OutputIndex, err := l2OutputOracleContract.GetL2OutputIndexAfter(&bind.CallOpts{}, header.Number)
-	ast.NoError(err)
+	opts := &bind.CallOpts{Context: ctx}
+	l2OutputIndex, err := l2OutputOracleContract.GetL2OutputIndexAfter(opts, header.Number)
 	if err != nil {
 		return ProvenWithdrawalParameters{}, fmt.Errorf("failed to get l2OutputIndex: %w", err)
 	}
-	//// TODO: Could skip this step, but it's nice to double check it
-	//err = VerifyProof(header.Root, p)
-	//if err != nil {
-	//	return ProvenWithdrawalParameters{}, err
-	//}
-	//if len(p.StorageProof) != 1 {
-	//	return ProvenWithdrawalParameters{}, errors.New("invalid amount of storage proofs")
-	//}
+	outputRootProof := abijson.TypesOutputRootProof{
+		Version:                  [32]byte{}, // Empty for version 1
+		StateRoot:                header.Root,
+		MessagePasserStorageRoot: p.StorageHash,
+		LatestBlockhash:          header.Hash(),
+	}
+	// The header must be the block of the output, otherwise the root can not match
+	output, err := l2OutputOracleContract.GetL2Output(opts, l2OutputIndex)
+	if err != nil {
+		return ProvenWithdrawalParameters{}, fmt.Errorf("failed to get l2 output %d: %w", l2OutputIndex, err)
+	}
+	if err := VerifyOutputRoot(&outputRootProof, output.OutputRoot); err != nil {
+		return ProvenWithdrawalParameters{}, fmt.Errorf("output %d (l2 block %d): %w", l2OutputIndex, output.L2BlockNumber, err)
+	}
 
 	// Encode it as expected by the contract
 	trieNodes := make([][]byte, len(p.StorageProof[0].Proof))
@@ -248,20 +263,15 @@ func ProveWithdrawalParameters(ctx context.Context, t *testing.T, proofCl *gethc
 	}
 
 	return ProvenWithdrawalParameters{
-		Nonce:         ev.Nonce,
-		Sender:        ev.Sender,
-		Target:        ev.Target,
-		MNTValue:      ev.MntValue,
-		ETHValue:      ev.EthValue,
-		GasLimit:      ev.GasLimit,
-		L2OutputIndex: l2OutputIndex,
-		Data:          ev.Data,
-		OutputRootProof: abijson.TypesOutputRootProof{
-			Version:                  [32]byte{}, // Empty for version 1
-			StateRoot:                header.Root,
-			MessagePasserStorageRoot: p.StorageHash,
-			LatestBlockhash:          header.Hash(),
-		},
+		Nonce:           ev.Nonce,
+		Sender:          ev.Sender,
+		Target:          ev.Target,
+		MNTValue:        ev.MntValue,
+		ETHValue:        ev.EthValue,
+		GasLimit:        ev.GasLimit,
+		L2OutputIndex:   l2OutputIndex,
+		Data:            ev.Data,
+		OutputRootProof: outputRootProof,
 		WithdrawalProof: trieNodes,
 	}, nil
 }
@@ -272,110 +282,27 @@ func StorageSlotOfWithdrawalHash(hash common.Hash) common.Hash {
 	return crypto.Keccak256Hash(buf)
 }
 
-func WaitForFinalizationPeriod(ctx context.Context, t *testing.T, client *ethclient.Client, portalAddr common.Address, l2BlockNumber *big.Int, finalizationPeriod *big.Int) (uint64, error) {
-	l2BlockNumber = new(big.Int).Set(l2BlockNumber) // Don't clobber caller owned l2BlockNumber
-	opts := &bind.CallOpts{Context: ctx}
-
-	portal, err := abijson.NewL1OptimismPortal(portalAddr, client) //NewOptimismPortalCaller(portalAddr, client)
-	if err != nil {
-		return 0, err
-	}
-	l2OOAddress, err := portal.L2ORACLE(opts) // L2OutputOracleAddr
+// WaitForFinalizationPeriod waits until an output covering l2BlockNumber is proposed and its finalization period has
+// passed on L1. It returns the L2 block number of that output. See WaitForFinalization for how it waits.
+func WaitForFinalizationPeriod(ctx context.Context, log Logger, client bind.ContractBackend, portalAddr common.Address, l2BlockNumber *big.Int, finalizationPeriod *big.Int) (uint64, error) {
+	portal, err := abijson.NewL1OptimismPortalCaller(portalAddr, client)
 	if err != nil {
 		return 0, err
 	}
-	t.Logf("[WaitForFinalizationPeriod] l2OOAddress is %s\n", l2OOAddress)
-	l2OO, err := bindings.NewL2OutputOracleCaller(l2OOAddress, client)
+	l2OOAddress, err := portal.L2ORACLE(&bind.CallOpts{Context: ctx}) // L2OutputOracleAddr
 	if err != nil {
 		return 0, err
 	}
-	submissionInterval, err := l2OO.SUBMISSIONINTERVAL(opts)
-	if err != nil {
-		return 0, err
-	}
-	t.Logf("[WaitForFinalizationPeriod] submissionInterval is %d\n", submissionInterval)
-	startingBlockNumber, err := l2OO.StartingBlockNumber(opts)
-	if err != nil {
-		return 0, err
-	}
-	t.Logf("[WaitForFinalizationPeriod] startingBlockNumber is %d\n", startingBlockNumber)
-
-	// Convert blockNumber to submission interval boundary
-	t.Logf("[WaitForFinalizationPeriod] before calculate, l2BlockNumber is %d\n", l2BlockNumber)
-	rem := new(big.Int)
-	l2BlockNumber = l2BlockNumber.Sub(l2BlockNumber, startingBlockNumber)             // -
-	l2BlockNumber, rem = l2BlockNumber.DivMod(l2BlockNumber, submissionInterval, rem) // z = z(a, b, c);  z = a/b  c = a%b
-	if rem.Cmp(common.Big0) != 0 {
-		l2BlockNumber = l2BlockNumber.Add(l2BlockNumber, common.Big1)
-	}
-	l2BlockNumber = l2BlockNumber.Mul(l2BlockNumber, submissionInterval) // *
-	l2BlockNumber = l2BlockNumber.Add(l2BlockNumber, startingBlockNumber)
-	t.Logf("[WaitForFinalizationPeriod] after calculate, l2BlockNumber is %d\n", l2BlockNumber)
-
-	//finalizationPeriod, err := l2OO.FINALIZATIONPERIODSECONDS(opts)  // proxy才有这个方法
-	//if err != nil {
-	//	return 0, err
-	//}
-
-	latest, err := l2OO.LatestBlockNumber(opts)
-	if err != nil {
-		return 0, err
-	}
-	t.Logf("[WaitForFinalizationPeriod] latest is %d\n", latest)
-
-	// Now poll for the output to be submitted on chain
-	var ticker *time.Ticker
-	diff := new(big.Int).Sub(l2BlockNumber, latest)
-	if diff.Cmp(big.NewInt(10)) > 0 {
-		ticker = time.NewTicker(time.Minute)
-	} else {
-		ticker = time.NewTicker(time.Second)
-	}
-
-loop:
-	for {
-		select {
-		case <-ticker.C:
-			latest, err = l2OO.LatestBlockNumber(opts)
-			if err != nil {
-				return 0, err
-			}
-			// Already passed the submitted block (likely just equals rather than >= here).
-			if latest.Cmp(l2BlockNumber) >= 0 {
-				break loop
-			}
-		case <-ctx.Done():
-			return 0, ctx.Err()
-		}
-	}
-
-	// Now wait for it to be finalized
-	output, err := l2OO.GetL2OutputAfter(opts, l2BlockNumber)
+	p, err := WaitForFinalization(ctx, client, l2OOAddress, l2BlockNumber, FinalizationOpts{
+		FinalizationPeriod: finalizationPeriod,
+		PollInterval:       time.Second,
+		Log:                log,
+		OnProgress: func(p FinalizationProgress) {
+			log.Logf("[WaitForFinalizationPeriod] latest output block is %d, l1 time %d, finalizable at %d\n", p.LatestOutputBlock, p.L1Time, p.FinalizableAt)
+		},
+	})
 	if err != nil {
 		return 0, err
 	}
-	if output.OutputRoot == [32]byte{} {
-		return 0, errors.New("empty output root. likely no proposal at timestamp")
-	}
-	targetTimestamp := new(big.Int).Add(output.Timestamp, finalizationPeriod)
-	targetTime := time.Unix(targetTimestamp.Int64(), 0)
-	// Assume clock is relatively correct
-	time.Sleep(time.Until(targetTime))
-	// Poll for L1 Block to have a time greater than the target time
-	ticker = time.NewTicker(time.Second)
-	for {
-		select {
-		case <-ticker.C:
-			header, err := client.HeaderByNumber(ctx, nil)
-			if err != nil {
-				return 0, err
-			}
-			if header.Time > targetTimestamp.Uint64() {
-				return l2BlockNumber.Uint64(), nil
-			}
-		case <-ctx.Done():
-			return 0, ctx.Err()
-		}
-	}
-
+	return p.Output.L2BlockNumber.Uint64(), nil
 }
@@ -0,0 +1,19 @@
+package txutils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetBlockNum(t *testing.T) {
+	ast := assert.New(t)
+	interval, start := big.NewInt(10), big.NewInt(5)
+	cases := map[int64]int64{5: 5, 6: 15, 14: 15, 15: 15, 16: 25}
+	for in, want := range cases {
+		l2Block := big.NewInt(in)
+		ast.Equal(big.NewInt(want), GetBlockNum(interval, start, l2Block), in)
+		ast.Equal(big.NewInt(in), l2Block, "input must not be modified")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+)
+
+func runWithdraw(ctx context.Context, env *cliEnv, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("missing asset, use eth, mnt or erc20")
+	}
+	asset := args[0]
+	if err := checkAsset(asset); err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("withdraw "+asset, flag.ContinueOnError)
+	var tf transferFlags
+	var tk tokenFlags
+	tf.register(fs)
+	if asset == "erc20" {
+		tk.register(fs)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	transfer, err := tf.args()
+	if err != nil {
+		return err
+	}
+	s, err := env.signer()
+	if err != nil {
+		return err
+	}
+	c, err := env.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch asset {
+	case "eth":
+		tx, err := c.WithdrawETH(ctx, s, transfer)
+		if err != nil {
+			return err
+		}
+		return env.report(ctx, c.L2, tx, tf.wait)
+	case "mnt":
+		tx, err := c.WithdrawMNT(ctx, s, transfer)
+		if err != nil {
+			return err
+		}
+		return env.report(ctx, c.L2, tx, tf.wait)
+	case "erc20":
+		token, err := tk.resolve(c.Profile)
+		if err != nil {
+			return err
+		}
+		tx, err := c.WithdrawERC20(ctx, s, token.L2, transfer)
+		if err != nil {
+			return err
+		}
+		return env.report(ctx, c.L2, tx, tf.wait)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package withdrawal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/abistr/abijson"
+	"try_rde/bridge"
+)
+
+// Derive reads the current facts of the withdrawal started by the L2 tx, the
+// one at logIndex if the tx started several. The MessagePassed event is nil if
+// the L2 tx reverted.
+func Derive(ctx context.Context, c *bridge.Client, txHash common.Hash, logIndex *uint) (*Facts, *abijson.L2ToL1MessagePasserMessagePassed, error) {
+	receipt, err := c.L2.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get l2 receipt: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return &Facts{L2Reverted: true, L2BlockNumber: receipt.BlockNumber.Uint64()}, nil, nil
+	}
+	ev, err := c.ParseWithdrawal(receipt, logIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	f := &Facts{L2BlockNumber: receipt.BlockNumber.Uint64()}
+	if f.Sent, err = c.MessagePasser.SentMessages(opts, ev.WithdrawalHash); err != nil {
+		return nil, nil, fmt.Errorf("failed to get sent message: %w", err)
+	}
+	latest, err := c.Oracle.LatestBlockNumber(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get latest output block: %w", err)
+	}
+	f.LatestOutputBlock = latest.Uint64()
+	period, err := c.Oracle.FINALIZATIONPERIODSECONDS(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get finalization period: %w", err)
+	}
+	f.FinalizationPeriod = period.Uint64()
+	proven, err := c.Portal.ProvenWithdrawals(opts, ev.WithdrawalHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get proven withdrawal: %w", err)
+	}
+	f.ProvenTimestamp = proven.Timestamp.Uint64()
+	if f.Finalized, err = c.Portal.FinalizedWithdrawals(opts, ev.WithdrawalHash); err != nil {
+		return nil, nil, fmt.Errorf("failed to get finalized withdrawal: %w", err)
+	}
+	if f.ProvenTimestamp != 0 && !f.Finalized {
+		if f.ProvenOutputGone, err = c.OutputGone(opts, proven.L2OutputIndex, proven.OutputRoot); err != nil {
+			return nil, nil, err
+		}
+	}
+	head, err := c.L1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get l1 head: %w", err)
+	}
+	f.L1Time = head.Time
+	return f, ev, nil
+}
@@ -0,0 +1,362 @@
+package withdrawal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/bridge"
+	"try_rde/signer"
+	"try_rde/txutils"
+)
+
+// DefaultTxTimeout is how long a step waits for a sent tx to be mined.
+const DefaultTxTimeout = 5 * time.Minute
+
+// ErrTxPending is returned by a step that gave up waiting for its tx. The tx
+// stays on record, the next step waits for it again.
+var ErrTxPending = errors.New("tx is still pending")
+
+// Driver moves withdrawals through their lifecycle, sending the prove and
+// finalize transactions when they become possible. Every sent tx is stored
+// before waiting on it, so a restarted Driver picks up the pending tx instead
+// of submitting a second one.
+type Driver struct {
+	Client       *bridge.Client
+	Signer       signer.Signer
+	Store        Store
+	PollInterval time.Duration
+	// TxTimeout bounds how long one step waits for a sent tx, so a tx stuck
+	// in the mempool does not hold up the other withdrawals of a Relayer.
+	TxTimeout time.Duration
+	// OnTransition is called every time a record changes state.
+	OnTransition func(rec *Record)
+}
+
+// Run drives the withdrawal started by the L2 tx until it is finalized or
+// failed, resuming from the stored record if there is one. logIndex picks the
+// withdrawal of a tx that started several, nil is fine for the only one.
+func (d *Driver) Run(ctx context.Context, txHash common.Hash, logIndex *uint) (*Record, error) {
+	indexes, err := d.logIndexes(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case logIndex == nil && len(indexes) > 1:
+		return nil, fmt.Errorf("%w: %d in tx %s", bridge.ErrSeveralWithdrawals, len(indexes), txHash)
+	case logIndex != nil && len(indexes) == 1:
+		// the only withdrawal of a tx is stored without log index
+		if indexes[0] == nil || *indexes[0] != *logIndex {
+			return nil, fmt.Errorf("tx %s has no MessagePassed event at log index %d", txHash, *logIndex)
+		}
+		logIndex = nil
+	}
+	rec, err := d.load(txHash, logIndex)
+	if err != nil {
+		return nil, err
+	}
+	return rec, d.drive(ctx, []*Record{rec})
+}
+
+// RunAll is Run for every withdrawal started by the L2 tx. They are driven
+// side by side, so none waits for the challenge window of another.
+func (d *Driver) RunAll(ctx context.Context, txHash common.Hash) ([]*Record, error) {
+	indexes, err := d.logIndexes(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 1 {
+		indexes[0] = nil
+	}
+	records := make([]*Record, 0, len(indexes))
+	for _, logIndex := range indexes {
+		rec, err := d.load(txHash, logIndex)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, d.drive(ctx, records)
+}
+
+// logIndexes returns the log index of every MessagePassed event of the L2
+// tx, a single nil one if the tx reverted.
+func (d *Driver) logIndexes(ctx context.Context, txHash common.Hash) ([]*uint, error) {
+	receipt, err := d.Client.L2.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get l2 receipt: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return []*uint{nil}, nil
+	}
+	evs, err := txutils.ParseMessagesPassed(d.Client.MessagePasser, receipt)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([]*uint, len(evs))
+	for i, ev := range evs {
+		index := ev.Raw.Index
+		indexes[i] = &index
+	}
+	return indexes, nil
+}
+
+// load returns the stored record of the withdrawal, a new one if there is
+// none.
+func (d *Driver) load(txHash common.Hash, logIndex *uint) (*Record, error) {
+	rec, err := d.Store.Get(txHash, logIndex)
+	if errors.Is(err, ErrNotFound) {
+		rec = &Record{TxHash: txHash, LogIndex: logIndex, State: StateInitiated}
+		if err := d.save(rec, StateInitiated); err != nil {
+			return nil, err
+		}
+		return rec, nil
+	}
+	return rec, err
+}
+
+// drive steps the records until all are terminal, sleeping whenever all that
+// are left wait on the chain.
+func (d *Driver) drive(ctx context.Context, records []*Record) error {
+	for {
+		pending, waiting := 0, 0
+		for _, rec := range records {
+			if rec.State.Terminal() {
+				continue
+			}
+			err := d.Step(ctx, rec)
+			if err != nil && !errors.Is(err, ErrTxPending) {
+				return err
+			}
+			if rec.State.Terminal() {
+				continue
+			}
+			pending++
+			if err != nil {
+				// the step already waited for the tx
+				continue
+			}
+			if rec.State == StateWaitingForOutput || rec.State == StateInChallengeWindow || rec.State == StateProofInvalidated {
+				waiting++
+			}
+		}
+		if pending == 0 {
+			return nil
+		}
+		if waiting == pending {
+			if err := sleep(ctx, d.pollInterval()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Step derives the current state and performs at most one action for it.
+func (d *Driver) Step(ctx context.Context, rec *Record) error {
+	facts, ev, err := Derive(ctx, d.Client, rec.TxHash, rec.LogIndex)
+	if err != nil {
+		return err
+	}
+	if ev != nil {
+		rec.WithdrawalHash = ev.WithdrawalHash
+	}
+	rec.FinalizableAt = facts.FinalizableAt()
+
+	switch state := facts.State(); state {
+	case StateReadyToProve:
+		return d.prove(ctx, rec, state)
+	case StateProofInvalidated:
+		if rec.State != StateProofInvalidated {
+			// the prove tx on record is the invalidated one
+			rec.ProveTx = nil
+			if err := d.save(rec, state); err != nil {
+				return err
+			}
+		}
+		if facts.LatestOutputBlock < facts.L2BlockNumber {
+			// wait for an output that covers the withdrawal again
+			return nil
+		}
+		return d.prove(ctx, rec, state)
+	case StateFinalizable:
+		return d.finalize(ctx, rec)
+	case StateFailed:
+		rec.Error = "l2 withdrawal tx reverted or message not sent"
+		return d.save(rec, state)
+	default:
+		return d.save(rec, state)
+	}
+}
+
+// prove sends the prove tx unless one is pending and waits for it. The record
+// stays in state until the tx is mined.
+func (d *Driver) prove(ctx context.Context, rec *Record, state State) error {
+	if rec.ProveTx == nil {
+		tx, err := d.send(ctx, rec, d.Client.ProveWithdrawal, d.Client.ProveWithdrawalAt)
+		if err != nil {
+			return err
+		}
+		hash := tx.Hash()
+		rec.ProveTx = &hash
+		if err := d.save(rec, state); err != nil {
+			return err
+		}
+	}
+
+	receipt, err := d.awaitTx(ctx, *rec.ProveTx)
+	if err != nil {
+		return err
+	}
+	if receipt == nil {
+		// dropped from the mempool, prove again on the next step
+		rec.ProveTx = nil
+		return d.save(rec, state)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		reverted := fmt.Sprintf("prove tx %s reverted", rec.ProveTx)
+		rec.ProveTx = nil
+		return d.rederive(ctx, rec, reverted)
+	}
+	return d.save(rec, StateProven)
+}
+
+func (d *Driver) finalize(ctx context.Context, rec *Record) error {
+	if rec.FinalizeTx == nil {
+		tx, err := d.send(ctx, rec, d.Client.FinalizeWithdrawal, d.Client.FinalizeWithdrawalAt)
+		if err != nil {
+			return err
+		}
+		hash := tx.Hash()
+		rec.FinalizeTx = &hash
+		if err := d.save(rec, StateFinalizable); err != nil {
+			return err
+		}
+	}
+
+	receipt, err := d.awaitTx(ctx, *rec.FinalizeTx)
+	if err != nil {
+		return err
+	}
+	if receipt == nil {
+		rec.FinalizeTx = nil
+		return d.save(rec, StateFinalizable)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		reverted := fmt.Sprintf("finalize tx %s reverted", rec.FinalizeTx)
+		rec.FinalizeTx = nil
+		return d.rederive(ctx, rec, reverted)
+	}
+	// the portal marks the withdrawal finalized even if the call to the
+	// target failed, WithdrawalFinalized.success tells them apart
+	for _, log := range receipt.Logs {
+		ev, err := d.Client.Portal.ParseWithdrawalFinalized(*log)
+		if err != nil || ev.WithdrawalHash != rec.WithdrawalHash {
+			continue
+		}
+		if !ev.Success {
+			rec.Error = "withdrawal finalized but the call on L1 failed"
+			return d.save(rec, StateFailed)
+		}
+	}
+	return d.save(rec, StateFinalized)
+}
+
+// rederive stores the state derived from the chain after a tx of the record
+// reverted. Relaying is permissionless, so the usual cause is another relayer
+// that proved or finalized the withdrawal first: the record moves on with the
+// chain. If the chain still waits for the action it is retried on the next
+// step, whose gas estimate fails instead of sending a tx bound to revert.
+func (d *Driver) rederive(ctx context.Context, rec *Record, reverted string) error {
+	facts, _, err := Derive(ctx, d.Client, rec.TxHash, rec.LogIndex)
+	if err != nil {
+		return fmt.Errorf("%s, failed to derive state: %w", reverted, err)
+	}
+	rec.FinalizableAt = facts.FinalizableAt()
+	state := facts.State()
+	switch state {
+	case StateReadyToProve, StateProofInvalidated, StateFinalizable:
+		rec.Error = reverted
+	default:
+		rec.Error = ""
+	}
+	return d.save(rec, state)
+}
+
+// send calls the bridge method for the withdrawal of the record, the one taking
+// a log index if it has one.
+func (d *Driver) send(ctx context.Context, rec *Record,
+	only func(context.Context, signer.Signer, common.Hash) (*types.Transaction, error),
+	at func(context.Context, signer.Signer, common.Hash, uint) (*types.Transaction, error),
+) (*types.Transaction, error) {
+	if rec.LogIndex == nil {
+		return only(ctx, d.Signer, rec.TxHash)
+	}
+	return at(ctx, d.Signer, rec.TxHash, *rec.LogIndex)
+}
+
+// awaitTx waits for the receipt of a tx sent earlier, at most TxTimeout. A
+// nil receipt without error means the node does not know the tx anymore.
+func (d *Driver) awaitTx(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	timeout := d.TxTimeout
+	if timeout <= 0 {
+		timeout = DefaultTxTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		receipt, err := d.Client.L1.TransactionReceipt(ctx, hash)
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		if _, _, err := d.Client.L1.TransactionByHash(ctx, hash); errors.Is(err, ethereum.NotFound) {
+			return nil, nil
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, fmt.Errorf("%w: %s after %s", ErrTxPending, hash, timeout)
+		}
+		if wait > time.Second {
+			wait = time.Second
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *Driver) save(rec *Record, state State) error {
+	changed := rec.State != state
+	rec.State = state
+	rec.UpdatedAt = time.Now()
+	if err := d.Store.Put(rec); err != nil {
+		return fmt.Errorf("failed to store withdrawal record: %w", err)
+	}
+	if changed && d.OnTransition != nil {
+		d.OnTransition(rec)
+	}
+	return nil
+}
+
+func (d *Driver) pollInterval() time.Duration {
+	if d.PollInterval <= 0 {
+		return 12 * time.Second
+	}
+	return d.PollInterval
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,205 @@
+package withdrawal
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/txutils"
+)
+
+func Test_DriverStep(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	n.FundPortal(big.NewInt(params.Ether))
+
+	tx, err := c.WithdrawMNT(ctx, s, bridge.TransferArgs{Amount: big.NewInt(params.GWei)})
+	require.NoError(t, err)
+	_, err = txutils.WaitForReceipt(ctx, c.L2, tx.Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+
+	var transitions []State
+	d := &Driver{Client: c, Signer: s, Store: NewMemStore(), OnTransition: func(rec *Record) {
+		transitions = append(transitions, rec.State)
+	}}
+	rec := &Record{TxHash: tx.Hash(), State: StateInitiated}
+	step := func() State {
+		require.NoError(t, d.Step(ctx, rec))
+		return rec.State
+	}
+
+	ast.Equal(StateWaitingForOutput, step())
+	require.NoError(t, n.ProposeOutput())
+	ast.Equal(StateProven, step())
+	ast.NotNil(rec.ProveTx)
+	ast.Equal(StateInChallengeWindow, step())
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	ast.Equal(StateFinalized, step())
+	ast.NotNil(rec.FinalizeTx)
+	ast.Empty(rec.Error)
+	ast.Equal([]State{
+		StateWaitingForOutput, StateReadyToProve, StateProven,
+		StateInChallengeWindow, StateFinalizable, StateFinalized,
+	}, transitions)
+}
+
+func Test_DriverReprovesAfterOutputsDeleted(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	n.FundPortal(big.NewInt(params.Ether))
+
+	tx, err := c.WithdrawMNT(ctx, s, bridge.TransferArgs{Amount: big.NewInt(params.GWei)})
+	require.NoError(t, err)
+	_, err = txutils.WaitForReceipt(ctx, c.L2, tx.Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+
+	d := &Driver{Client: c, Signer: s, Store: NewMemStore()}
+	rec := &Record{TxHash: tx.Hash(), State: StateInitiated}
+	step := func() State {
+		require.NoError(t, d.Step(ctx, rec))
+		return rec.State
+	}
+	require.NoError(t, n.ProposeOutput())
+	ast.Equal(StateProven, step())
+	require.NoError(t, n.DeleteOutputs(0))
+	ast.Equal(StateProofInvalidated, step())
+	ast.Nil(rec.ProveTx)
+	ast.Equal(StateProofInvalidated, step(), "no output covers the withdrawal yet")
+
+	n.L2.Mine()
+	require.NoError(t, n.ProposeOutput())
+	ast.Equal(StateProven, step())
+	ast.NotNil(rec.ProveTx)
+	ast.Equal(StateInChallengeWindow, step())
+}
+
+func Test_DriverRunAll(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+
+	tx, err := n.Multicall(ctx, s,
+		n.WithdrawalCall(common.HexToAddress("0x01"), nil),
+		n.WithdrawalCall(common.HexToAddress("0x02"), nil))
+	require.NoError(t, err)
+	_, err = txutils.WaitForReceipt(ctx, c.L2, tx.Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+	require.NoError(t, n.ProposeOutput())
+
+	store := NewMemStore()
+	d := &Driver{Client: c, Signer: s, Store: store, PollInterval: time.Millisecond}
+	_, err = d.Run(ctx, tx.Hash(), nil)
+	ast.ErrorIs(err, bridge.ErrSeveralWithdrawals)
+
+	proven := make(chan struct{}, 2)
+	d.OnTransition = func(rec *Record) {
+		if rec.State == StateInChallengeWindow {
+			proven <- struct{}{}
+		}
+	}
+	done := make(chan []*Record)
+	go func() {
+		records, err := d.RunAll(ctx, tx.Hash())
+		assert.NoError(t, err)
+		done <- records
+	}()
+	// both are proven before either challenge window has passed
+	<-proven
+	<-proven
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	records := <-done
+	require.Len(t, records, 2)
+	for _, rec := range records {
+		require.NotNil(t, rec.LogIndex)
+		ast.Equal(StateFinalized, rec.State)
+		ast.NotNil(rec.FinalizeTx)
+	}
+	ast.NotEqual(*records[0].LogIndex, *records[1].LogIndex)
+
+	// a relayer finds the same records
+	r := &Relayer{Driver: d, FromBlock: 1}
+	r.OnTracked = func(rec *Record) { t.Errorf("withdrawal tracked twice: %+v", rec) }
+	require.NoError(t, r.Poll(ctx))
+}
+
+// fixedGas skips the gas estimate, so a tx bound to revert is sent anyway.
+type fixedGas struct{ txutils.FeeBackend }
+
+func (fixedGas) EstimateGas(context.Context, ethereum.CallMsg) (uint64, error) {
+	return 1000000, nil
+}
+
+func Test_DriverAdoptsOtherRelayersProgress(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	n.FundPortal(big.NewInt(params.Ether))
+	tx, err := c.WithdrawMNT(ctx, s, bridge.TransferArgs{Amount: big.NewInt(params.GWei)})
+	require.NoError(t, err)
+	_, err = txutils.WaitForReceipt(ctx, c.L2, tx.Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+	require.NoError(t, n.ProposeOutput())
+
+	other := &Driver{Client: c, Signer: bridgetest.Account(t, n), Store: NewMemStore()}
+	otherRec := &Record{TxHash: tx.Hash(), State: StateInitiated}
+	ours, err := n.Client(ctx)
+	require.NoError(t, err)
+	ours.L1Fees.Backend = fixedGas{ours.L1Fees.Backend}
+	d := &Driver{Client: ours, Signer: s, Store: NewMemStore()}
+	rec := &Record{TxHash: tx.Hash(), State: StateReadyToProve}
+
+	// the other relayer's prove is mined before ours, which reverts
+	require.NoError(t, other.Step(ctx, otherRec))
+	require.Equal(t, StateProven, otherRec.State)
+	require.NoError(t, d.prove(ctx, rec, StateReadyToProve))
+	ast.Equal(StateInChallengeWindow, rec.State)
+	ast.Nil(rec.ProveTx)
+	ast.Empty(rec.Error)
+
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	require.NoError(t, other.Step(ctx, otherRec))
+	require.Equal(t, StateFinalized, otherRec.State)
+	require.NoError(t, d.finalize(ctx, rec))
+	ast.Equal(StateFinalized, rec.State)
+	ast.Nil(rec.FinalizeTx)
+}
+
+// pendingBackend knows every tx but mines none.
+type pendingBackend struct{ bridge.Backend }
+
+func (pendingBackend) TransactionReceipt(context.Context, common.Hash) (*types.Receipt, error) {
+	return nil, ethereum.NotFound
+}
+
+func (pendingBackend) TransactionByHash(context.Context, common.Hash) (*types.Transaction, bool, error) {
+	return types.NewTx(&types.LegacyTx{}), true, nil
+}
+
+func Test_DriverGivesUpOnPendingTx(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n := bridgetest.NewNetwork()
+	c, err := bridge.NewClient(ctx, n.Profile, pendingBackend{n.L1}, n.L2, n.L2)
+	require.NoError(t, err)
+	d := &Driver{Client: c, Store: NewMemStore(), TxTimeout: 10 * time.Millisecond}
+
+	start := time.Now()
+	_, err = d.awaitTx(ctx, common.Hash{1})
+	ast.ErrorIs(err, ErrTxPending)
+	ast.Less(time.Since(start), time.Second)
+}
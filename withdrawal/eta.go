@@ -0,0 +1,254 @@
+package withdrawal
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/bridge"
+)
+
+// etaSamples is how many of the latest outputs the proposer cadence is
+// measured on.
+const etaSamples = 8
+
+// ETA predicts when a withdrawal can be proven and finalized. Times are L1
+// block times. They are estimates unless the step already happened.
+type ETA struct {
+	L2BlockNumber uint64
+	// OutputBlock is the L2 block of the first output covering the
+	// withdrawal, until it is proposed the one the oracle expects next at or
+	// after L2BlockNumber.
+	OutputBlock uint64
+	OutputIndex *big.Int // nil until the output is proposed
+	ProposedAt  time.Time
+	// ProvableAt is when the withdrawal can be proven, once the output is
+	// proposed.
+	ProvableAt time.Time
+	Proven     bool
+	ProvenAt   time.Time // zero unless proven
+	// FinalizableAt assumes a withdrawal that is not proven yet is proven as
+	// soon as it can be.
+	FinalizableAt time.Time
+	Finalized     bool
+	L1Time        time.Time // the latest L1 block
+	// Cadence is the average L1 time between the latest proposals, Lag how
+	// long after the time of its L2 block an output is proposed on average.
+	// Both are zero without proposals to measure.
+	Cadence time.Duration
+	Lag     time.Duration
+}
+
+// Proposed reports whether the covering output is on L1 already.
+func (e *ETA) Proposed() bool {
+	return e.OutputIndex != nil
+}
+
+// oracleParams are the L2OutputOracle settings the estimate is based on.
+type oracleParams struct {
+	interval      uint64 // SUBMISSION_INTERVAL in L2 blocks
+	blockTime     uint64 // L2_BLOCK_TIME in seconds
+	startBlock    uint64
+	startTime     uint64
+	period        uint64 // FINALIZATION_PERIOD_SECONDS
+	nextBlock     uint64 // nextBlockNumber(), the block the next output must be at
+	latestBlock   uint64
+	nextIndex     uint64
+	recentOutputs []outputSample
+}
+
+type outputSample struct {
+	l2Block    uint64
+	proposedAt uint64
+}
+
+// l2Time is the timestamp of an L2 block, computeL2Timestamp of the oracle.
+func (p *oracleParams) l2Time(block uint64) uint64 {
+	if block < p.startBlock {
+		return p.startTime
+	}
+	return p.startTime + (block-p.startBlock)*p.blockTime
+}
+
+func readOracleParams(ctx context.Context, c *bridge.Client) (*oracleParams, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	var p oracleParams
+	for _, read := range []struct {
+		name string
+		get  func(*bind.CallOpts) (*big.Int, error)
+		dst  *uint64
+	}{
+		{"SUBMISSION_INTERVAL", c.Oracle.SUBMISSIONINTERVAL, &p.interval},
+		{"L2_BLOCK_TIME", c.Oracle.L2BLOCKTIME, &p.blockTime},
+		{"startingBlockNumber", c.Oracle.StartingBlockNumber, &p.startBlock},
+		{"startingTimestamp", c.Oracle.StartingTimestamp, &p.startTime},
+		{"FINALIZATION_PERIOD_SECONDS", c.Oracle.FINALIZATIONPERIODSECONDS, &p.period},
+		{"nextBlockNumber", c.Oracle.NextBlockNumber, &p.nextBlock},
+		{"latestBlockNumber", c.Oracle.LatestBlockNumber, &p.latestBlock},
+		{"nextOutputIndex", c.Oracle.NextOutputIndex, &p.nextIndex},
+	} {
+		v, err := read.get(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", read.name, err)
+		}
+		*read.dst = v.Uint64()
+	}
+	if p.interval == 0 {
+		return nil, fmt.Errorf("oracle reports a submission interval of 0")
+	}
+	first := uint64(0)
+	if p.nextIndex > etaSamples {
+		first = p.nextIndex - etaSamples
+	}
+	for i := first; i < p.nextIndex; i++ {
+		out, err := c.Oracle.GetL2Output(opts, new(big.Int).SetUint64(i))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get l2 output %d: %w", i, err)
+		}
+		p.recentOutputs = append(p.recentOutputs, outputSample{l2Block: out.L2BlockNumber.Uint64(), proposedAt: out.Timestamp.Uint64()})
+	}
+	return &p, nil
+}
+
+// cadence returns the average seconds between the sampled proposals and
+// between an L2 block and the proposal of its output.
+func (p *oracleParams) cadence() (between, lag uint64) {
+	n := uint64(len(p.recentOutputs))
+	if n == 0 {
+		return 0, 0
+	}
+	var lagSum uint64
+	for _, out := range p.recentOutputs {
+		if t := p.l2Time(out.l2Block); out.proposedAt > t {
+			lagSum += out.proposedAt - t
+		}
+	}
+	if n > 1 {
+		first, last := p.recentOutputs[0], p.recentOutputs[n-1]
+		if last.proposedAt > first.proposedAt {
+			between = (last.proposedAt - first.proposedAt) / (n - 1)
+		}
+	}
+	return between, lagSum / n
+}
+
+// EstimateETA predicts when a withdrawal started in the L2 block can be
+// proven and finalized. Whether it is proven already is not known from the
+// block alone, see EstimateWithdrawalETA.
+func EstimateETA(ctx context.Context, c *bridge.Client, l2BlockNumber uint64) (*ETA, error) {
+	p, err := readOracleParams(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	head, err := c.L1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get l1 head: %w", err)
+	}
+	between, lag := p.cadence()
+	eta := &ETA{
+		L2BlockNumber: l2BlockNumber,
+		L1Time:        unixTime(head.Time),
+		Cadence:       time.Duration(between) * time.Second,
+		Lag:           time.Duration(lag) * time.Second,
+	}
+
+	var proposedAt uint64
+	if p.nextIndex > 0 && p.latestBlock >= l2BlockNumber {
+		opts := &bind.CallOpts{Context: ctx}
+		index, err := c.Oracle.GetL2OutputIndexAfter(opts, new(big.Int).SetUint64(l2BlockNumber))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get l2OutputIndex: %w", err)
+		}
+		out, err := c.Oracle.GetL2Output(opts, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get l2 output %d: %w", index, err)
+		}
+		eta.OutputIndex, eta.OutputBlock = index, out.L2BlockNumber.Uint64()
+		proposedAt = out.Timestamp.Uint64()
+	} else {
+		// outputs must be at nextBlockNumber and every interval after it
+		eta.OutputBlock = p.nextBlock
+		if l2BlockNumber > p.nextBlock {
+			steps := (l2BlockNumber - p.nextBlock + p.interval - 1) / p.interval
+			eta.OutputBlock += steps * p.interval
+		}
+		// the output can't be proposed before its L2 block exists, nor
+		// faster than the proposer has been proposing lately
+		proposedAt = max64(head.Time, p.l2Time(eta.OutputBlock)+lag)
+		if n := len(p.recentOutputs); between > 0 && n > 0 {
+			outputs := (eta.OutputBlock - p.latestBlock + p.interval - 1) / p.interval
+			proposedAt = max64(proposedAt, p.recentOutputs[n-1].proposedAt+outputs*between)
+		}
+	}
+	eta.ProposedAt = unixTime(proposedAt)
+	eta.ProvableAt = eta.ProposedAt
+	// the portal requires block.timestamp > proven and output time + period
+	eta.FinalizableAt = unixTime(max64(proposedAt, head.Time) + p.period + 1)
+	return eta, nil
+}
+
+// EstimateWithdrawalETA is EstimateETA for the withdrawal started by the L2
+// tx, the one at logIndex if it started several, taking into account whether
+// it is proven or finalized already.
+func EstimateWithdrawalETA(ctx context.Context, c *bridge.Client, txHash common.Hash, logIndex *uint) (*ETA, error) {
+	receipt, err := c.L2.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get l2 receipt: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("l2 tx %s reverted, there is no withdrawal", txHash)
+	}
+	ev, err := c.ParseWithdrawal(receipt, logIndex)
+	if err != nil {
+		return nil, err
+	}
+	eta, err := EstimateETA(ctx, c, receipt.BlockNumber.Uint64())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	if eta.Finalized, err = c.Portal.FinalizedWithdrawals(opts, ev.WithdrawalHash); err != nil {
+		return nil, fmt.Errorf("failed to get finalized withdrawal: %w", err)
+	}
+	proven, err := c.Portal.ProvenWithdrawals(opts, ev.WithdrawalHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proven withdrawal: %w", err)
+	}
+	if proven.Timestamp.Sign() == 0 {
+		return eta, nil
+	}
+	if !eta.Finalized {
+		gone, err := c.OutputGone(opts, proven.L2OutputIndex, proven.OutputRoot)
+		if err != nil {
+			return nil, err
+		}
+		if gone {
+			// it has to be proven again, the estimate without proof holds
+			return eta, nil
+		}
+	}
+	period, err := c.Oracle.FINALIZATIONPERIODSECONDS(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get finalization period: %w", err)
+	}
+	eta.Proven, eta.ProvenAt = true, unixTime(proven.Timestamp.Uint64())
+	provenAt := proven.Timestamp.Uint64()
+	eta.FinalizableAt = unixTime(max64(provenAt, uint64(eta.ProposedAt.Unix())) + period.Uint64() + 1)
+	return eta, nil
+}
+
+func unixTime(ts uint64) time.Time {
+	return time.Unix(int64(ts), 0).UTC()
+}
+
+func max64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,75 @@
+package withdrawal
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/txutils"
+)
+
+func Test_EstimateETA(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	n.FundPortal(big.NewInt(params.Ether))
+	period := time.Duration(bridgetest.FinalizationPeriod) * time.Second
+
+	tx, err := c.WithdrawMNT(ctx, s, bridge.TransferArgs{Amount: big.NewInt(params.GWei)})
+	require.NoError(t, err)
+	receipt, err := txutils.WaitForReceipt(ctx, c.L2, tx.Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+
+	eta, err := EstimateWithdrawalETA(ctx, c, tx.Hash(), nil)
+	require.NoError(t, err)
+	ast.False(eta.Proposed())
+	ast.Equal(receipt.BlockNumber.Uint64(), eta.OutputBlock, "the submission interval is 1")
+	ast.False(eta.ProposedAt.Before(eta.L1Time))
+	ast.Equal(eta.ProposedAt, eta.ProvableAt)
+	ast.Equal(eta.ProposedAt.Add(period+time.Second), eta.FinalizableAt)
+	ast.Zero(eta.Cadence)
+
+	require.NoError(t, n.ProposeOutput())
+	eta, err = EstimateWithdrawalETA(ctx, c, tx.Hash(), nil)
+	require.NoError(t, err)
+	require.True(t, eta.Proposed())
+	ast.Equal(int64(0), eta.OutputIndex.Int64())
+	ast.False(eta.Proven)
+	ast.Equal(eta.L1Time.Add(period+time.Second), eta.FinalizableAt, "proven now at the earliest")
+
+	d := &Driver{Client: c, Signer: s, Store: NewMemStore()}
+	rec := &Record{TxHash: tx.Hash()}
+	require.NoError(t, d.Step(ctx, rec))
+	require.Equal(t, StateProven, rec.State)
+	eta, err = EstimateWithdrawalETA(ctx, c, tx.Hash(), nil)
+	require.NoError(t, err)
+	ast.True(eta.Proven)
+	facts, _, err := Derive(ctx, c, tx.Hash(), nil)
+	require.NoError(t, err)
+	ast.Equal(int64(facts.FinalizableAt()), eta.FinalizableAt.Unix())
+
+	// two more proposals a minute apart give the proposer a cadence
+	for i := 0; i < 2; i++ {
+		n.AdvanceTime(60)
+		n.L2.Mine()
+		require.NoError(t, n.ProposeOutput())
+	}
+	latest, err := c.Oracle.LatestBlockNumber(nil)
+	require.NoError(t, err)
+	future, err := EstimateETA(ctx, c, latest.Uint64()+10)
+	require.NoError(t, err)
+	ast.False(future.Proposed())
+	ast.Equal(latest.Uint64()+10, future.OutputBlock)
+	ast.GreaterOrEqual(future.Cadence, 30*time.Second)
+	last, err := c.Oracle.GetL2Output(nil, big.NewInt(2))
+	require.NoError(t, err)
+	ast.False(future.ProposedAt.Before(time.Unix(last.Timestamp.Int64(), 0).Add(10*future.Cadence)),
+		"ten outputs at the recent cadence")
+}
@@ -0,0 +1,249 @@
+package withdrawal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"try_rde/abistr/abijson"
+	"try_rde/txutils"
+)
+
+const (
+	// DefaultRelayPageSize is the number of L2 blocks scanned per
+	// eth_getLogs call.
+	DefaultRelayPageSize = 1000
+	// relayCheckpoint names the scan of the Relayer in a Checkpoints store.
+	relayCheckpoint = "relayer"
+)
+
+// Relayer proves and finalizes other people's withdrawals, which anyone may
+// do. It scans the L2ToL1MessagePasser for MessagePassed, tracks every
+// withdrawal it finds in the store of its Driver and steps the tracked ones
+// on every poll: each is proven as soon as an output covers it and finalized
+// once its challenge window has passed.
+//
+// Progress is kept in the store, so a restarted Relayer neither submits a tx
+// twice nor loses a withdrawal. If the store implements Checkpoints the scan
+// resumes where it stopped, otherwise at FromBlock. Records put into the store
+// by others, e.g. the complete command, are driven too.
+type Relayer struct {
+	Driver *Driver
+	// Senders and Targets restrict the withdrawals relayed to the ones sent
+	// from one of the Senders or to one of the Targets, a withdrawal matching
+	// either list is relayed. Empty lists allow any.
+	Senders []common.Address
+	Targets []common.Address
+	// FromBlock is the first L2 block scanned without checkpoint, 0 starts at
+	// the head.
+	FromBlock    uint64
+	PageSize     uint64
+	PollInterval time.Duration
+	// OnTracked is called for every withdrawal the scan adds to the store.
+	OnTracked func(rec *Record)
+	Log       txutils.Logger
+
+	next uint64 // next L2 block to scan, 0 before the first poll
+}
+
+// Run polls until ctx is done. Failed polls are logged and retried.
+func (r *Relayer) Run(ctx context.Context) error {
+	for {
+		if err := r.Poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.logger().Logf("relayer poll failed, retrying: %v", err)
+		}
+		if err := sleep(ctx, r.pollInterval()); err != nil {
+			return err
+		}
+	}
+}
+
+// Poll scans the L2 blocks since the last poll for new withdrawals, then
+// steps every tracked withdrawal that is not done. A withdrawal that fails to
+// step is logged and retried on the next poll, it does not hold up the others.
+func (r *Relayer) Poll(ctx context.Context) error {
+	if err := r.scan(ctx); err != nil {
+		return err
+	}
+	return r.drive(ctx)
+}
+
+func (r *Relayer) scan(ctx context.Context) error {
+	c := r.Driver.Client
+	head, err := c.L2.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get l2 head: %w", err)
+	}
+	to := head.Number.Uint64()
+	if r.next == 0 {
+		if r.next, err = r.start(to); err != nil {
+			return err
+		}
+	}
+	pageSize := r.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultRelayPageSize
+	}
+
+	for r.next <= to {
+		end := r.next + pageSize - 1
+		if end > to {
+			end = to
+		}
+		opts := &bind.FilterOpts{Start: r.next, End: &end, Context: ctx}
+		passed, err := r.messagesPassed(opts)
+		if err != nil {
+			return err
+		}
+		// withdrawals per tx, the filter may hide some of them
+		counts := make(map[common.Hash]int)
+		for _, ev := range passed {
+			if err := r.track(ctx, ev.Raw, ev.WithdrawalHash, counts); err != nil {
+				return err
+			}
+		}
+		if cp, ok := r.Driver.Store.(Checkpoints); ok {
+			if err := cp.PutCheckpoint(relayCheckpoint, end+1); err != nil {
+				return fmt.Errorf("failed to store checkpoint: %w", err)
+			}
+		}
+		r.next = end + 1
+	}
+	return nil
+}
+
+// messagesPassed returns the MessagePassed events of the blocks that match
+// Senders or Targets, in log order. Topic filters of one query are combined
+// with AND, so a withdrawal matching either list needs a query per list.
+func (r *Relayer) messagesPassed(opts *bind.FilterOpts) ([]*abijson.L2ToL1MessagePasserMessagePassed, error) {
+	queries := [][2][]common.Address{{r.Senders, r.Targets}}
+	if len(r.Senders) > 0 && len(r.Targets) > 0 {
+		queries = [][2][]common.Address{{r.Senders, nil}, {nil, r.Targets}}
+	}
+	type logID struct {
+		tx    common.Hash
+		index uint
+	}
+	seen := make(map[logID]bool)
+	var events []*abijson.L2ToL1MessagePasserMessagePassed
+	for _, q := range queries {
+		it, err := r.Driver.Client.MessagePasser.FilterMessagePassed(opts, nil, q[0], q[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter MessagePassed: %w", err)
+		}
+		for it.Next() {
+			id := logID{it.Event.Raw.TxHash, it.Event.Raw.Index}
+			if !seen[id] {
+				seen[id] = true
+				events = append(events, it.Event)
+			}
+		}
+		if err := it.Error(); err != nil {
+			return nil, fmt.Errorf("failed to filter MessagePassed: %w", err)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i].Raw, events[j].Raw
+		if a.BlockNumber != b.BlockNumber {
+			return a.BlockNumber < b.BlockNumber
+		}
+		return a.Index < b.Index
+	})
+	return events, nil
+}
+
+// start returns the first block of the first scan.
+func (r *Relayer) start(head uint64) (uint64, error) {
+	if cp, ok := r.Driver.Store.(Checkpoints); ok {
+		next, found, err := cp.Checkpoint(relayCheckpoint)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get checkpoint: %w", err)
+		}
+		if found {
+			return next, nil
+		}
+	}
+	if r.FromBlock != 0 {
+		return r.FromBlock, nil
+	}
+	return head, nil
+}
+
+// track adds the withdrawal of the MessagePassed log to the store unless it
+// is there already. It is stored with its log index if its tx started
+// several withdrawals, counts caches how many.
+func (r *Relayer) track(ctx context.Context, log types.Log, withdrawalHash common.Hash, counts map[common.Hash]int) error {
+	txHash := log.TxHash
+	if _, ok := counts[txHash]; !ok {
+		indexes, err := r.Driver.logIndexes(ctx, txHash)
+		if err != nil {
+			return err
+		}
+		counts[txHash] = len(indexes)
+	}
+	var logIndex *uint
+	if counts[txHash] > 1 {
+		index := log.Index
+		logIndex = &index
+	}
+	_, err := r.Driver.Store.Get(txHash, logIndex)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	rec := &Record{TxHash: txHash, LogIndex: logIndex, WithdrawalHash: withdrawalHash, State: StateInitiated}
+	if err := r.Driver.save(rec, StateInitiated); err != nil {
+		return err
+	}
+	r.logger().Logf("tracking withdrawal %s of l2 tx %s", withdrawalHash, txHash)
+	if r.OnTracked != nil {
+		r.OnTracked(rec)
+	}
+	return nil
+}
+
+// drive steps the records that are not done. Records in the challenge window
+// are stepped too, so a proof against a deleted output is noticed before the
+// window would have passed.
+func (r *Relayer) drive(ctx context.Context) error {
+	records, err := r.Driver.Store.List()
+	if err != nil {
+		return fmt.Errorf("failed to list withdrawals: %w", err)
+	}
+	for _, rec := range records {
+		if rec.State.Terminal() {
+			continue
+		}
+		if err := r.Driver.Step(ctx, rec); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.logger().Logf("failed to relay withdrawal %s: %v", rec.TxHash, err)
+		}
+	}
+	return nil
+}
+
+func (r *Relayer) logger() txutils.Logger {
+	if r.Log == nil {
+		return txutils.NopLogger
+	}
+	return r.Log
+}
+
+func (r *Relayer) pollInterval() time.Duration {
+	if r.PollInterval <= 0 {
+		return 12 * time.Second
+	}
+	return r.PollInterval
+}
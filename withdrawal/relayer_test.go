@@ -0,0 +1,118 @@
+package withdrawal
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/txutils"
+)
+
+func Test_Relayer(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	n.FundPortal(big.NewInt(params.Ether))
+
+	// two users withdraw, a third party relays
+	var txHashes []common.Hash
+	for i := 0; i < 2; i++ {
+		user := bridgetest.Account(t, n)
+		tx, err := c.WithdrawMNT(ctx, user, bridge.TransferArgs{Amount: big.NewInt(params.GWei)})
+		require.NoError(t, err)
+		_, err = txutils.WaitForReceipt(ctx, c.L2, tx.Hash(), txutils.WaitOpts{})
+		require.NoError(t, err)
+		txHashes = append(txHashes, tx.Hash())
+	}
+	relayer := bridgetest.Key(t)
+
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	newRelayer := func(targets ...common.Address) *Relayer {
+		return &Relayer{
+			Driver:    &Driver{Client: c, Signer: relayer, Store: store},
+			Targets:   targets,
+			FromBlock: 1,
+			PageSize:  1,
+		}
+	}
+	states := func() map[common.Hash]State {
+		list, err := store.List()
+		require.NoError(t, err)
+		out := make(map[common.Hash]State)
+		for _, rec := range list {
+			out[rec.TxHash] = rec.State
+		}
+		return out
+	}
+	all := func(state State) map[common.Hash]State {
+		return map[common.Hash]State{txHashes[0]: state, txHashes[1]: state}
+	}
+
+	require.NoError(t, newRelayer(common.HexToAddress("0x01")).Poll(ctx))
+	ast.Empty(states(), "no withdrawal goes to the allowed target")
+	_, err = os.Stat(filepath.Join(store.dir, "checkpoint-relayer.json"))
+	require.NoError(t, err)
+
+	// a withdrawal matching either list is relayed
+	nobody := []common.Address{common.HexToAddress("0x01")}
+	l2Bridge := []common.Address{n.Profile.Addresses.L2StandardBridge}
+	l1Bridge := []common.Address{n.Profile.Addresses.L1StandardBridge}
+	for _, filter := range [][2][]common.Address{{nobody, l1Bridge}, {l2Bridge, nobody}, {l2Bridge, l1Bridge}} {
+		var found int
+		either := &Relayer{
+			Driver:    &Driver{Client: c, Signer: relayer, Store: NewMemStore()},
+			Senders:   filter[0],
+			Targets:   filter[1],
+			FromBlock: 1,
+			OnTracked: func(*Record) { found++ },
+		}
+		require.NoError(t, either.scan(ctx))
+		ast.Equal(2, found, "senders %v or targets %v", filter[0], filter[1])
+	}
+
+	// rescan from the start with the bridge allowed
+	require.NoError(t, store.PutCheckpoint(relayCheckpoint, 1))
+	r := newRelayer(n.Profile.Addresses.L1StandardBridge)
+	var tracked int
+	r.OnTracked = func(*Record) { tracked++ }
+	require.NoError(t, r.Poll(ctx))
+	ast.Equal(all(StateWaitingForOutput), states())
+	ast.Equal(2, tracked)
+
+	require.NoError(t, n.ProposeOutput())
+	require.NoError(t, r.Poll(ctx))
+	ast.Equal(all(StateProven), states())
+	proofs := make(map[common.Hash]common.Hash)
+	for _, txHash := range txHashes {
+		rec, err := store.Get(txHash, nil)
+		require.NoError(t, err)
+		require.NotNil(t, rec.ProveTx)
+		proofs[txHash] = *rec.ProveTx
+	}
+
+	// a restarted relayer resumes from the store and doesn't prove again
+	r = newRelayer(n.Profile.Addresses.L1StandardBridge)
+	r.OnTracked = func(*Record) { tracked++ }
+	require.NoError(t, r.Poll(ctx))
+	ast.Equal(all(StateInChallengeWindow), states())
+	ast.Equal(2, tracked, "nothing is tracked twice")
+
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	require.NoError(t, r.Poll(ctx))
+	ast.Equal(all(StateFinalized), states())
+	for _, txHash := range txHashes {
+		rec, err := store.Get(txHash, nil)
+		require.NoError(t, err)
+		ast.Equal(proofs[txHash], *rec.ProveTx)
+		ast.NotNil(rec.FinalizeTx)
+	}
+}
@@ -0,0 +1,180 @@
+package withdrawal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"try_rde/abistr/abijson"
+	"try_rde/bridge"
+	"try_rde/indexer"
+)
+
+// DefaultScanBlocks bounds a ScanFinder without FromBlock: a week of 2 second
+// L2 blocks, the length of the challenge window on mainnet.
+const DefaultScanBlocks = 7 * 24 * 3600 / 2
+
+var (
+	// ErrUnknownWithdrawal is returned for a withdrawal hash the
+	// L2ToL1MessagePasser never sent.
+	ErrUnknownWithdrawal = errors.New("withdrawal was never sent on l2")
+	// ErrMessageNotFound is returned by a Finder that did not come across the
+	// MessagePassed event of the withdrawal.
+	ErrMessageNotFound = errors.New("MessagePassed event of the withdrawal not found")
+)
+
+// Finder looks up the MessagePassed event of a withdrawal hash. The hash is
+// not an indexed topic of the event, so finders go through the events of a
+// range of blocks.
+type Finder interface {
+	FindMessagePassed(ctx context.Context, withdrawalHash common.Hash) (*abijson.L2ToL1MessagePasserMessagePassed, error)
+}
+
+// IndexFinder searches the MessagePassed events ingested by the indexer, it
+// only knows withdrawals up to the last indexed block.
+type IndexFinder struct {
+	DB     ethdb.Iteratee
+	Passer *abijson.L2ToL1MessagePasser
+}
+
+func (f *IndexFinder) FindMessagePassed(ctx context.Context, withdrawalHash common.Hash) (*abijson.L2ToL1MessagePasserMessagePassed, error) {
+	var found *abijson.L2ToL1MessagePasserMessagePassed
+	err := indexer.ForEachEvent(f.DB, indexer.SourceMessagePasser, 0, ^uint64(0), func(ev *indexer.Event) (bool, error) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if ev.Name != "MessagePassed" {
+			return true, nil
+		}
+		passed, err := f.Passer.ParseMessagePassed(ev.Log)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse indexed MessagePassed: %w", err)
+		}
+		if passed.WithdrawalHash != withdrawalHash {
+			return true, nil
+		}
+		found = passed
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w in the index", ErrMessageNotFound)
+	}
+	return found, nil
+}
+
+// ScanFinder filters the MessagePassed logs of the L2 node, newest blocks
+// first, so recent withdrawals are found with few calls.
+type ScanFinder struct {
+	Client *bridge.Client
+	// FromBlock and ToBlock bound the scan, ToBlock 0 is the head. Without
+	// FromBlock at most MaxBlocks are scanned.
+	FromBlock uint64
+	ToBlock   uint64
+	MaxBlocks uint64 // defaults to DefaultScanBlocks
+	PageSize  uint64 // defaults to DefaultRelayPageSize
+}
+
+func (f *ScanFinder) FindMessagePassed(ctx context.Context, withdrawalHash common.Hash) (*abijson.L2ToL1MessagePasserMessagePassed, error) {
+	to := f.ToBlock
+	if to == 0 {
+		head, err := f.Client.L2.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get l2 head: %w", err)
+		}
+		to = head.Number.Uint64()
+	}
+	from := f.FromBlock
+	if from == 0 {
+		maxBlocks := f.MaxBlocks
+		if maxBlocks == 0 {
+			maxBlocks = DefaultScanBlocks
+		}
+		if to >= maxBlocks {
+			from = to - maxBlocks + 1
+		}
+	}
+	pageSize := f.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultRelayPageSize
+	}
+
+	for end := to; end >= from; {
+		start := from
+		if end-from >= pageSize {
+			start = end - pageSize + 1
+		}
+		opts := &bind.FilterOpts{Start: start, End: &end, Context: ctx}
+		it, err := f.Client.MessagePasser.FilterMessagePassed(opts, nil, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter MessagePassed: %w", err)
+		}
+		for it.Next() {
+			if it.Event.WithdrawalHash == withdrawalHash {
+				it.Close()
+				return it.Event, nil
+			}
+		}
+		if err := it.Error(); err != nil {
+			return nil, fmt.Errorf("failed to filter MessagePassed: %w", err)
+		}
+		if start == 0 {
+			break
+		}
+		end = start - 1
+	}
+	return nil, fmt.Errorf("%w in l2 blocks %d to %d", ErrMessageNotFound, from, to)
+}
+
+// Resolution is a withdrawal found by its hash.
+type Resolution struct {
+	WithdrawalHash common.Hash
+	TxHash         common.Hash // the L2 tx that started it
+	LogIndex       uint        // of its MessagePassed in the receipt
+	Message        *abijson.L2ToL1MessagePasserMessagePassed
+	Facts          *Facts
+}
+
+// State is where the withdrawal is in its lifecycle.
+func (r *Resolution) State() State {
+	return r.Facts.State()
+}
+
+// Resolve finds the L2 tx that started the withdrawal with the hash and
+// derives its state on L1. It fails with ErrUnknownWithdrawal if the
+// L2ToL1MessagePasser never sent it and with ErrMessageNotFound if it was sent
+// but the finder did not find where.
+func Resolve(ctx context.Context, c *bridge.Client, finder Finder, withdrawalHash common.Hash) (*Resolution, error) {
+	sent, err := c.MessagePasser.SentMessages(&bind.CallOpts{Context: ctx}, withdrawalHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sent message: %w", err)
+	}
+	if !sent {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWithdrawal, withdrawalHash)
+	}
+	ev, err := finder.FindMessagePassed(ctx, withdrawalHash)
+	if err != nil {
+		return nil, err
+	}
+	logIndex := ev.Raw.Index
+	facts, derived, err := Derive(ctx, c, ev.Raw.TxHash, &logIndex)
+	if err != nil {
+		return nil, err
+	}
+	if derived == nil || derived.WithdrawalHash != withdrawalHash {
+		// a reorg moved the log since it was found
+		return nil, fmt.Errorf("l2 tx %s no longer has withdrawal %s at log index %d", ev.Raw.TxHash, withdrawalHash, logIndex)
+	}
+	return &Resolution{
+		WithdrawalHash: withdrawalHash,
+		TxHash:         ev.Raw.TxHash,
+		LogIndex:       logIndex,
+		Message:        derived,
+		Facts:          facts,
+	}, nil
+}
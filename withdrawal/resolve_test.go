@@ -0,0 +1,65 @@
+package withdrawal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge/bridgetest"
+	"try_rde/indexer"
+	"try_rde/txutils"
+)
+
+func Test_Resolve(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+
+	tx, err := n.Multicall(ctx, s,
+		n.WithdrawalCall(common.HexToAddress("0x01"), nil),
+		n.WithdrawalCall(common.HexToAddress("0x02"), nil))
+	require.NoError(t, err)
+	receipt, err := txutils.WaitForReceipt(ctx, c.L2, tx.Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+	_, evs, err := c.Withdrawals(ctx, tx.Hash())
+	require.NoError(t, err)
+	wanted := evs[1]
+	// blocks after the withdrawal for the scan to go through
+	for i := 0; i < 5; i++ {
+		n.L2.Mine()
+	}
+
+	db := memorydb.New()
+	sources, err := indexer.Sources(c)
+	require.NoError(t, err)
+	require.NoError(t, indexer.New(db, sources).Sync(ctx))
+
+	for name, finder := range map[string]Finder{
+		"scan":  &ScanFinder{Client: c, PageSize: 2},
+		"index": &IndexFinder{DB: db, Passer: c.MessagePasser},
+	} {
+		res, err := Resolve(ctx, c, finder, wanted.WithdrawalHash)
+		require.NoError(t, err, name)
+		ast.Equal(tx.Hash(), res.TxHash, name)
+		ast.Equal(wanted.Raw.Index, res.LogIndex, name)
+		ast.Equal(common.HexToAddress("0x02"), res.Message.Target, name)
+		ast.Equal(receipt.BlockNumber.Uint64(), res.Facts.L2BlockNumber, name)
+		ast.Equal(StateWaitingForOutput, res.State(), name)
+	}
+
+	_, err = Resolve(ctx, c, &ScanFinder{Client: c}, common.HexToHash("0xbad"))
+	ast.ErrorIs(err, ErrUnknownWithdrawal)
+	_, err = Resolve(ctx, c, &ScanFinder{Client: c, MaxBlocks: 5}, wanted.WithdrawalHash)
+	ast.ErrorIs(err, ErrMessageNotFound, "the withdrawal is older than the scanned blocks")
+
+	require.NoError(t, n.ProposeOutput())
+	_, err = c.ProveWithdrawalAt(ctx, s, tx.Hash(), wanted.Raw.Index)
+	require.NoError(t, err)
+	res, err := Resolve(ctx, c, &ScanFinder{Client: c}, wanted.WithdrawalHash)
+	require.NoError(t, err)
+	ast.Equal(StateInChallengeWindow, res.State())
+}
@@ -0,0 +1,121 @@
+package withdrawal
+
+import (
+	"fmt"
+)
+
+// State is a step in the L2 -> L1 withdrawal lifecycle.
+type State int
+
+const (
+	// StateInitiated is the state of a withdrawal that has not been looked at yet.
+	StateInitiated State = iota
+	// StateWaitingForOutput means no output covering the L2 block has been proposed.
+	StateWaitingForOutput
+	// StateReadyToProve means an output covers the L2 block and the withdrawal can be proven.
+	StateReadyToProve
+	// StateProven means our prove tx was mined, the challenge window starts.
+	StateProven
+	// StateInChallengeWindow means the withdrawal is proven but the finalization period has not passed.
+	StateInChallengeWindow
+	// StateFinalizable means the finalization period has passed.
+	StateFinalizable
+	// StateProofInvalidated means the output the withdrawal was proven
+	// against was deleted, it must be proven again against a newer one.
+	StateProofInvalidated
+	// StateFinalized means the portal has finalized the withdrawal.
+	StateFinalized
+	// StateFailed means the withdrawal can not be completed.
+	StateFailed
+)
+
+var stateNames = [...]string{
+	StateInitiated:         "initiated",
+	StateWaitingForOutput:  "waiting-for-output",
+	StateReadyToProve:      "ready-to-prove",
+	StateProven:            "proven",
+	StateInChallengeWindow: "in-challenge-window",
+	StateFinalizable:       "finalizable",
+	StateProofInvalidated:  "proof-invalidated",
+	StateFinalized:         "finalized",
+	StateFailed:            "failed",
+}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return fmt.Sprintf("state(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+// Terminal reports whether no more transitions can happen.
+func (s State) Terminal() bool {
+	return s == StateFinalized || s == StateFailed
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	st, err := ParseState(string(text))
+	if err != nil {
+		return err
+	}
+	*s = st
+	return nil
+}
+
+// ParseState is the inverse of State.String.
+func ParseState(name string) (State, error) {
+	for i, n := range stateNames {
+		if n == name {
+			return State(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown withdrawal state %q", name)
+}
+
+// Facts are the on-chain observations a state is derived from.
+type Facts struct {
+	L2Reverted         bool   // the L2 tx reverted, there is no withdrawal
+	Sent               bool   // L2ToL1MessagePasser.sentMessages(withdrawalHash)
+	L2BlockNumber      uint64 // block of the L2 withdrawal tx
+	LatestOutputBlock  uint64 // L2OutputOracle.latestBlockNumber()
+	ProvenTimestamp    uint64 // OptimismPortal.provenWithdrawals(hash).timestamp, 0 if not proven
+	ProvenOutputGone   bool   // the output proven against was deleted or replaced since
+	FinalizationPeriod uint64 // L2OutputOracle.FINALIZATION_PERIOD_SECONDS()
+	L1Time             uint64 // timestamp of the latest L1 block
+	Finalized          bool   // OptimismPortal.finalizedWithdrawals(hash)
+}
+
+// FinalizableAt is the first L1 timestamp at which the portal accepts the
+// finalization, 0 if the withdrawal is not proven.
+func (f *Facts) FinalizableAt() uint64 {
+	if f.ProvenTimestamp == 0 {
+		return 0
+	}
+	// the portal requires block.timestamp > provenTimestamp + period
+	return f.ProvenTimestamp + f.FinalizationPeriod + 1
+}
+
+// State classifies the facts. It never returns StateInitiated or StateProven,
+// those are only set by the Driver.
+func (f *Facts) State() State {
+	switch {
+	case f.L2Reverted || !f.Sent:
+		return StateFailed
+	case f.Finalized:
+		return StateFinalized
+	case f.ProvenTimestamp != 0 && f.ProvenOutputGone:
+		return StateProofInvalidated
+	case f.ProvenTimestamp != 0 && f.L1Time >= f.FinalizableAt():
+		return StateFinalizable
+	case f.ProvenTimestamp != 0:
+		return StateInChallengeWindow
+	case f.LatestOutputBlock >= f.L2BlockNumber:
+		return StateReadyToProve
+	default:
+		return StateWaitingForOutput
+	}
+}
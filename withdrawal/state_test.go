@@ -0,0 +1,89 @@
+package withdrawal
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FactsState(t *testing.T) {
+	ast := assert.New(t)
+	base := Facts{Sent: true, L2BlockNumber: 100, FinalizationPeriod: 12, L1Time: 1000}
+
+	cases := []struct {
+		name  string
+		edit  func(f *Facts)
+		state State
+	}{
+		{"reverted", func(f *Facts) { f.L2Reverted = true }, StateFailed},
+		{"not sent", func(f *Facts) { f.Sent = false }, StateFailed},
+		{"no output", func(f *Facts) { f.LatestOutputBlock = 99 }, StateWaitingForOutput},
+		{"covered", func(f *Facts) { f.LatestOutputBlock = 100 }, StateReadyToProve},
+		{"challenge window", func(f *Facts) { f.LatestOutputBlock = 120; f.ProvenTimestamp = 988 }, StateInChallengeWindow},
+		{"finalizable", func(f *Facts) { f.LatestOutputBlock = 120; f.ProvenTimestamp = 987 }, StateFinalizable},
+		{"finalized", func(f *Facts) { f.ProvenTimestamp = 987; f.Finalized = true }, StateFinalized},
+		{"output deleted", func(f *Facts) { f.LatestOutputBlock = 99; f.ProvenTimestamp = 987; f.ProvenOutputGone = true }, StateProofInvalidated},
+	}
+	for _, c := range cases {
+		f := base
+		c.edit(&f)
+		ast.Equal(c.state, f.State(), c.name)
+	}
+}
+
+func Test_StateText(t *testing.T) {
+	ast := assert.New(t)
+	for s := StateInitiated; s <= StateFailed; s++ {
+		text, err := s.MarshalText()
+		require.NoError(t, err)
+		var back State
+		ast.NoError(back.UnmarshalText(text))
+		ast.Equal(s, back)
+	}
+	_, err := ParseState("nope")
+	ast.Error(err)
+}
+
+func Test_FileStore(t *testing.T) {
+	ast := assert.New(t)
+	s, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	txHash := common.HexToHash("0x01")
+	_, err = s.Get(txHash, nil)
+	ast.ErrorIs(err, ErrNotFound)
+
+	prove := common.HexToHash("0x02")
+	ast.NoError(s.Put(&Record{TxHash: txHash, State: StateProven, ProveTx: &prove}))
+	rec, err := s.Get(txHash, nil)
+	require.NoError(t, err)
+	ast.Equal(StateProven, rec.State)
+	ast.Equal(prove, *rec.ProveTx)
+}
+
+func Test_StoreList(t *testing.T) {
+	ast := assert.New(t)
+	fs, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	for _, s := range []Store{NewMemStore(), fs} {
+		list, err := s.List()
+		require.NoError(t, err)
+		ast.Empty(list)
+
+		ast.NoError(s.Put(&Record{TxHash: common.HexToHash("0x01"), State: StateProven}))
+		ast.NoError(s.Put(&Record{TxHash: common.HexToHash("0x02"), State: StateFinalized}))
+		ast.NoError(s.Put(&Record{TxHash: common.HexToHash("0x01"), State: StateFinalizable}))
+		list, err = s.List()
+		require.NoError(t, err)
+		states := make(map[common.Hash]State)
+		for _, rec := range list {
+			states[rec.TxHash] = rec.State
+		}
+		ast.Equal(map[common.Hash]State{
+			common.HexToHash("0x01"): StateFinalizable,
+			common.HexToHash("0x02"): StateFinalized,
+		}, states)
+	}
+}
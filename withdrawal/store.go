@@ -0,0 +1,215 @@
+package withdrawal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrNotFound is returned by a Store that has no record for a tx.
+var ErrNotFound = errors.New("withdrawal record not found")
+
+// Record is the persisted progress of one withdrawal. LogIndex is the index
+// of its MessagePassed log in the receipt of the L2 tx, nil if the tx started
+// only one withdrawal.
+type Record struct {
+	TxHash         common.Hash  `json:"txHash"`
+	LogIndex       *uint        `json:"logIndex,omitempty"`
+	WithdrawalHash common.Hash  `json:"withdrawalHash"`
+	State          State        `json:"state"`
+	ProveTx        *common.Hash `json:"proveTx,omitempty"`
+	FinalizeTx     *common.Hash `json:"finalizeTx,omitempty"`
+	FinalizableAt  uint64       `json:"finalizableAt,omitempty"` // L1 timestamp
+	Error          string       `json:"error,omitempty"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+}
+
+// Store persists withdrawal records so a Driver can resume after a restart.
+// A record is found by its tx hash and log index.
+type Store interface {
+	Get(txHash common.Hash, logIndex *uint) (*Record, error)
+	Put(rec *Record) error
+	// List returns every record, in no particular order.
+	List() ([]*Record, error)
+}
+
+// recordKey names the record of a withdrawal, the tx hash with the log index
+// appended if there is one.
+func recordKey(txHash common.Hash, logIndex *uint) string {
+	if logIndex == nil {
+		return txHash.Hex()
+	}
+	return fmt.Sprintf("%s-%d", txHash.Hex(), *logIndex)
+}
+
+// Checkpoints is implemented by stores that also remember how far a scan for
+// withdrawals got, so a restarted Relayer continues where it stopped.
+type Checkpoints interface {
+	// Checkpoint returns the next block to scan for the named scan, false if
+	// there is none yet.
+	Checkpoint(name string) (uint64, bool, error)
+	PutCheckpoint(name string, next uint64) error
+}
+
+// MemStore keeps records in memory.
+type MemStore struct {
+	mu          sync.Mutex
+	records     map[string]Record
+	checkpoints map[string]uint64
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{records: make(map[string]Record), checkpoints: make(map[string]uint64)}
+}
+
+func (s *MemStore) Get(txHash common.Hash, logIndex *uint) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[recordKey(txHash, logIndex)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+func (s *MemStore) Put(rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[recordKey(rec.TxHash, rec.LogIndex)] = *rec
+	return nil
+}
+
+func (s *MemStore) List() ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*Record, 0, len(s.records))
+	for _, rec := range s.records {
+		rec := rec
+		out = append(out, &rec)
+	}
+	return out, nil
+}
+
+func (s *MemStore) Checkpoint(name string) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next, ok := s.checkpoints[name]
+	return next, ok, nil
+}
+
+func (s *MemStore) PutCheckpoint(name string, next uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[name] = next
+	return nil
+}
+
+// FileStore keeps one JSON file per withdrawal in a directory.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store dir: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(txHash common.Hash, logIndex *uint) string {
+	return filepath.Join(s.dir, recordKey(txHash, logIndex)+".json")
+}
+
+func (s *FileStore) Get(txHash common.Hash, logIndex *uint) (*Record, error) {
+	rec, err := s.read(s.path(txHash, logIndex))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return rec, err
+}
+
+func (s *FileStore) read(path string) (*Record, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rec := &Record{}
+	if err := json.Unmarshal(raw, rec); err != nil {
+		return nil, fmt.Errorf("failed to decode record %s: %w", strings.TrimSuffix(filepath.Base(path), ".json"), err)
+	}
+	return rec, nil
+}
+
+// Put writes the record to a temp file first so a crash never leaves a
+// truncated record behind.
+func (s *FileStore) Put(rec *Record) error {
+	raw, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.path(rec.TxHash, rec.LogIndex)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) List() ([]*Record, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "0x*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Record, 0, len(paths))
+	for _, path := range paths {
+		rec, err := s.read(path)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// checkpointPath does not start with 0x, List never mistakes it for a record.
+func (s *FileStore) checkpointPath(name string) string {
+	return filepath.Join(s.dir, "checkpoint-"+name+".json")
+}
+
+type checkpoint struct {
+	Next uint64 `json:"next"`
+}
+
+func (s *FileStore) Checkpoint(name string) (uint64, bool, error) {
+	raw, err := os.ReadFile(s.checkpointPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(raw, &cp); err != nil {
+		return 0, false, fmt.Errorf("failed to decode checkpoint %s: %w", name, err)
+	}
+	return cp.Next, true, nil
+}
+
+func (s *FileStore) PutCheckpoint(name string, next uint64) error {
+	raw, err := json.Marshal(checkpoint{Next: next})
+	if err != nil {
+		return err
+	}
+	tmp := s.checkpointPath(name) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.checkpointPath(name))
+}
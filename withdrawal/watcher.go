@@ -0,0 +1,301 @@
+package withdrawal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"try_rde/txutils"
+)
+
+const (
+	// DefaultWatchPageSize is the number of L1 blocks scanned per
+	// eth_getLogs call.
+	DefaultWatchPageSize = 1000
+	// watchCheckpoint names the scan of the Watcher in a Checkpoints store.
+	watchCheckpoint = "watcher"
+)
+
+// Watcher follows the L2OutputOracle for OutputProposed and OutputsDeleted.
+// When the challenger deletes outputs, every withdrawal proven against one of
+// them has to be proven again before it can be finalized. The Watcher marks
+// the records of its Driver's store that are affected StateProofInvalidated
+// and re-proves them as soon as a newer output covers their L2 block.
+//
+// It steps the records itself, so don't run a Driver on the same store at the
+// same time, the Driver notices deleted outputs on its own anyway.
+//
+// If the store implements Checkpoints the scan resumes where it stopped,
+// otherwise at FromBlock. Either way the first poll checks every tracked
+// record, so outputs deleted while the Watcher was down are not missed.
+type Watcher struct {
+	Driver *Driver
+	// FromBlock is the first L1 block scanned without checkpoint, 0 starts
+	// at the head.
+	FromBlock    uint64
+	PageSize     uint64
+	PollInterval time.Duration
+	// OnInvalidated is called for every record found proven against a
+	// deleted output.
+	OnInvalidated func(rec *Record)
+	Log           txutils.Logger
+
+	next uint64 // next L1 block to scan, 0 before the first poll
+	// proofs maps the tracked withdrawals seen in WithdrawalProven logs to
+	// the L1 tx that proved them, which may be another relayer's.
+	proofs map[common.Hash]common.Hash
+}
+
+// Run polls until ctx is done. Failed polls are logged and retried.
+func (w *Watcher) Run(ctx context.Context) error {
+	for {
+		if err := w.Poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.logger().Logf("output watcher poll failed, retrying: %v", err)
+		}
+		if err := sleep(ctx, w.pollInterval()); err != nil {
+			return err
+		}
+	}
+}
+
+// Poll scans the L1 blocks since the last poll, page by page. Deleted
+// outputs invalidate the proofs made against them, any new or deleted output
+// re-proves the invalidated records it can.
+func (w *Watcher) Poll(ctx context.Context) error {
+	head, err := w.Driver.Client.L1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get l1 head: %w", err)
+	}
+	to := head.Number.Uint64()
+	if w.proofs == nil {
+		w.proofs = make(map[common.Hash]common.Hash)
+	}
+	if w.next == 0 {
+		if w.next, err = w.start(to); err != nil {
+			return err
+		}
+		if err := w.invalidate(ctx); err != nil {
+			return err
+		}
+		if err := w.reprove(ctx); err != nil {
+			return err
+		}
+	}
+	pageSize := w.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultWatchPageSize
+	}
+
+	for w.next <= to {
+		end := w.next + pageSize - 1
+		if end > to {
+			end = to
+		}
+		if err := w.scan(ctx, w.next, end); err != nil {
+			return err
+		}
+		if cp, ok := w.Driver.Store.(Checkpoints); ok {
+			if err := cp.PutCheckpoint(watchCheckpoint, end+1); err != nil {
+				return fmt.Errorf("failed to store checkpoint: %w", err)
+			}
+		}
+		w.next = end + 1
+	}
+	return nil
+}
+
+// start returns the first block of the first scan.
+func (w *Watcher) start(head uint64) (uint64, error) {
+	if cp, ok := w.Driver.Store.(Checkpoints); ok {
+		next, found, err := cp.Checkpoint(watchCheckpoint)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get checkpoint: %w", err)
+		}
+		if found {
+			return next, nil
+		}
+	}
+	if w.FromBlock != 0 {
+		return w.FromBlock, nil
+	}
+	return head, nil
+}
+
+// scan handles the oracle and portal logs of L1 blocks [from, to].
+func (w *Watcher) scan(ctx context.Context, from, to uint64) error {
+	c := w.Driver.Client
+	opts := &bind.FilterOpts{Start: from, End: &to, Context: ctx}
+
+	proven, err := c.Portal.FilterWithdrawalProven(opts, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to filter WithdrawalProven: %w", err)
+	}
+	for proven.Next() {
+		w.proofs[proven.Event.WithdrawalHash] = proven.Event.Raw.TxHash
+	}
+	if err := proven.Error(); err != nil {
+		return fmt.Errorf("failed to filter WithdrawalProven: %w", err)
+	}
+
+	deleted, err := c.Oracle.FilterOutputsDeleted(opts, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to filter OutputsDeleted: %w", err)
+	}
+	var deletions int
+	for deleted.Next() {
+		w.logger().Logf("outputs from index %d on deleted in l1 block %d", deleted.Event.NewNextOutputIndex, deleted.Event.Raw.BlockNumber)
+		deletions++
+	}
+	if err := deleted.Error(); err != nil {
+		return fmt.Errorf("failed to filter OutputsDeleted: %w", err)
+	}
+	proposed, err := c.Oracle.FilterOutputProposed(opts, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to filter OutputProposed: %w", err)
+	}
+	var proposals int
+	for proposed.Next() {
+		proposals++
+	}
+	if err := proposed.Error(); err != nil {
+		return fmt.Errorf("failed to filter OutputProposed: %w", err)
+	}
+
+	if deletions > 0 {
+		if err := w.invalidate(ctx); err != nil {
+			return err
+		}
+	} else if err := w.prune(); err != nil {
+		return err
+	}
+	if deletions > 0 || proposals > 0 {
+		if err := w.reprove(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tracked returns the records of the store that are not done, by withdrawal
+// hash.
+func (w *Watcher) tracked() (map[common.Hash]*Record, error) {
+	records, err := w.Driver.Store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list withdrawals: %w", err)
+	}
+	tracked := make(map[common.Hash]*Record, len(records))
+	for _, rec := range records {
+		if rec.WithdrawalHash != (common.Hash{}) && !rec.State.Terminal() {
+			tracked[rec.WithdrawalHash] = rec
+		}
+	}
+	return tracked, nil
+}
+
+// prune forgets the proofs of withdrawals that are not tracked or are done.
+func (w *Watcher) prune() error {
+	if len(w.proofs) == 0 {
+		return nil
+	}
+	tracked, err := w.tracked()
+	if err != nil {
+		return err
+	}
+	for hash := range w.proofs {
+		if _, ok := tracked[hash]; !ok {
+			delete(w.proofs, hash)
+		}
+	}
+	return nil
+}
+
+// invalidate checks every tracked withdrawal against the oracle, the ones
+// that are proven against a deleted output are marked StateProofInvalidated.
+// Proofs of withdrawals that are untracked or done are forgotten.
+func (w *Watcher) invalidate(ctx context.Context) error {
+	c := w.Driver.Client
+	tracked, err := w.tracked()
+	if err != nil {
+		return err
+	}
+	if err := w.prune(); err != nil {
+		return err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	for hash, rec := range tracked {
+		proven, err := c.Portal.ProvenWithdrawals(opts, hash)
+		if err != nil {
+			return fmt.Errorf("failed to get proven withdrawal %s: %w", hash, err)
+		}
+		if proven.Timestamp.Sign() == 0 {
+			continue
+		}
+		finalized, err := c.Portal.FinalizedWithdrawals(opts, hash)
+		if err != nil {
+			return fmt.Errorf("failed to get finalized withdrawal %s: %w", hash, err)
+		}
+		if finalized {
+			delete(w.proofs, hash)
+			continue
+		}
+		gone, err := c.OutputGone(opts, proven.L2OutputIndex, proven.OutputRoot)
+		if err != nil {
+			return err
+		}
+		if !gone || rec.State == StateProofInvalidated {
+			continue
+		}
+		if proveTx, ok := w.proofs[hash]; ok {
+			w.logger().Logf("withdrawal %s proven in %s was proven against deleted output %d", rec.TxHash, proveTx, proven.L2OutputIndex)
+			delete(w.proofs, hash)
+		} else {
+			w.logger().Logf("withdrawal %s was proven against deleted output %d", rec.TxHash, proven.L2OutputIndex)
+		}
+		rec.ProveTx = nil
+		if err := w.Driver.save(rec, StateProofInvalidated); err != nil {
+			return err
+		}
+		if w.OnInvalidated != nil {
+			w.OnInvalidated(rec)
+		}
+	}
+	return nil
+}
+
+// reprove steps every invalidated record, which proves it again if an output
+// covers its L2 block by now.
+func (w *Watcher) reprove(ctx context.Context) error {
+	records, err := w.Driver.Store.List()
+	if err != nil {
+		return fmt.Errorf("failed to list withdrawals: %w", err)
+	}
+	for _, rec := range records {
+		if rec.State != StateProofInvalidated {
+			continue
+		}
+		if err := w.Driver.Step(ctx, rec); err != nil {
+			w.logger().Logf("failed to prove withdrawal %s again: %v", rec.TxHash, err)
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) logger() txutils.Logger {
+	if w.Log == nil {
+		return txutils.NopLogger
+	}
+	return w.Log
+}
+
+func (w *Watcher) pollInterval() time.Duration {
+	if w.PollInterval <= 0 {
+		return 12 * time.Second
+	}
+	return w.PollInterval
+}
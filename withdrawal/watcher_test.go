@@ -0,0 +1,118 @@
+package withdrawal
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"try_rde/bridge"
+	"try_rde/bridge/bridgetest"
+	"try_rde/txutils"
+)
+
+func Test_WatcherReprovesAfterOutputsDeleted(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	n.FundPortal(big.NewInt(params.Ether))
+
+	tx, err := c.WithdrawMNT(ctx, s, bridge.TransferArgs{Amount: big.NewInt(params.GWei)})
+	require.NoError(t, err)
+	_, err = txutils.WaitForReceipt(ctx, c.L2, tx.Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+
+	store := NewMemStore()
+	d := &Driver{Client: c, Signer: s, Store: store}
+	var invalidated []*Record
+	w := &Watcher{Driver: d, FromBlock: 1, PageSize: 2, OnInvalidated: func(rec *Record) { invalidated = append(invalidated, rec) }}
+	get := func() *Record {
+		rec, err := store.Get(tx.Hash(), nil)
+		require.NoError(t, err)
+		return rec
+	}
+
+	require.NoError(t, n.ProposeOutput())
+	rec := &Record{TxHash: tx.Hash(), State: StateInitiated}
+	require.NoError(t, d.Step(ctx, rec))
+	require.Equal(t, StateProven, rec.State)
+	firstProof := *rec.ProveTx
+	require.NoError(t, w.Poll(ctx))
+	ast.Equal(StateProven, get().State, "nothing was deleted")
+
+	require.NoError(t, n.DeleteOutputs(0))
+	require.NoError(t, w.Poll(ctx))
+	ast.Equal(StateProofInvalidated, get().State)
+	ast.Nil(get().ProveTx)
+	require.Len(t, invalidated, 1)
+	ast.Equal(tx.Hash(), invalidated[0].TxHash)
+	require.NoError(t, w.Poll(ctx))
+	ast.Len(invalidated, 1, "an invalidated record is reported once")
+
+	n.L2.Mine()
+	require.NoError(t, n.ProposeOutput())
+	require.NoError(t, w.Poll(ctx))
+	rec = get()
+	ast.Equal(StateProven, rec.State)
+	require.NotNil(t, rec.ProveTx)
+	ast.NotEqual(firstProof, *rec.ProveTx)
+
+	n.AdvanceTime(bridgetest.FinalizationPeriod)
+	require.NoError(t, d.Step(ctx, rec))
+	require.NoError(t, d.Step(ctx, rec))
+	ast.Equal(StateFinalized, rec.State)
+}
+
+func Test_WatcherResumesAfterRestart(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	n, c := bridgetest.Setup(t)
+	s := bridgetest.Account(t, n)
+	n.FundPortal(big.NewInt(params.Ether))
+
+	tx, err := c.WithdrawMNT(ctx, s, bridge.TransferArgs{Amount: big.NewInt(params.GWei)})
+	require.NoError(t, err)
+	_, err = txutils.WaitForReceipt(ctx, c.L2, tx.Hash(), txutils.WaitOpts{})
+	require.NoError(t, err)
+	require.NoError(t, n.ProposeOutput())
+
+	store := NewMemStore()
+	d := &Driver{Client: c, Signer: s, Store: store}
+	rec := &Record{TxHash: tx.Hash(), State: StateInitiated}
+	require.NoError(t, d.Step(ctx, rec))
+	require.Equal(t, StateProven, rec.State)
+	require.NoError(t, (&Watcher{Driver: d}).Poll(ctx))
+	head, err := n.L1.BlockNumber(ctx)
+	require.NoError(t, err)
+	next, ok, err := store.Checkpoint(watchCheckpoint)
+	require.NoError(t, err)
+	ast.True(ok)
+	ast.Equal(head+1, next)
+
+	// the output is deleted while no watcher runs
+	require.NoError(t, n.DeleteOutputs(0))
+	n.L1.Mine()
+	var invalidated []*Record
+	w := &Watcher{Driver: d, OnInvalidated: func(rec *Record) { invalidated = append(invalidated, rec) }}
+	require.NoError(t, w.Poll(ctx))
+	require.Len(t, invalidated, 1)
+	ast.Equal(tx.Hash(), invalidated[0].TxHash)
+	got, err := store.Get(tx.Hash(), nil)
+	require.NoError(t, err)
+	ast.Equal(StateProofInvalidated, got.State)
+
+	// a store without checkpoints is checked in full on the first poll
+	plain := &plainStore{NewMemStore()}
+	rec = &Record{TxHash: tx.Hash(), State: StateProven, WithdrawalHash: got.WithdrawalHash}
+	require.NoError(t, plain.Put(rec))
+	invalidated = nil
+	w = &Watcher{Driver: &Driver{Client: c, Signer: s, Store: plain}, OnInvalidated: func(rec *Record) { invalidated = append(invalidated, rec) }}
+	require.NoError(t, w.Poll(ctx))
+	ast.Len(invalidated, 1)
+}
+
+// plainStore hides the Checkpoints of the store it wraps.
+type plainStore struct{ Store }